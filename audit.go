@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// auditRecord is a line of the audit log describing an install
+type auditRecord struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	// SudoUser is who ran easy-add with sudo, when that's how it was run
+	SudoUser string `json:"sudoUser,omitempty"`
+	Name     string `json:"name,omitempty"`
+	From     string `json:"from"`
+	// Digest is that of the downloaded archive
+	Digest string `json:"digest"`
+	// Files are where the installed files were placed
+	Files []auditFile `json:"files,omitempty"`
+}
+
+type auditFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// auditInstall appends a record of the install to the audit log, when one is given, where
+// installs skipped as already up-to-date aren't recorded
+func auditInstall(name string, result *easyadd.Result) error {
+	if networkArgs.AuditLog == "" || result.Skipped {
+		return nil
+	}
+
+	record := auditRecord{
+		Time:     time.Now().UTC(),
+		User:     currentUser(),
+		SudoUser: os.Getenv("SUDO_USER"),
+		Name:     name,
+		From:     redactedURL(result.From),
+		Digest:   "sha256:" + result.ArchiveSHA256,
+	}
+	recorded := make(map[string]bool)
+	addFile := func(path string, sha256 string) {
+		if path == "" {
+			return
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if !recorded[path] {
+			recorded[path] = true
+			record.Files = append(record.Files, auditFile{Path: path, SHA256: sha256})
+		}
+	}
+	addFile(result.Path, result.SHA256)
+	for _, file := range append(result.Extracted, result.Mapped...) {
+		addFile(file.Path, file.SHA256)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(networkArgs.AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return fmt.Errorf("unable to open audit log: %w", err)
+	}
+	//noinspection GoUnhandledErrorResult
+	defer f.Close()
+	// a single write of the whole line keeps those of concurrent runs from interleaving
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("unable to write audit log: %w", err)
+	}
+	return nil
+}
+
+// redactedURL hides the password of a URL, which is otherwise given as is
+func redactedURL(s string) string {
+	if u, err := url.Parse(s); err == nil {
+		return u.Redacted()
+	}
+	return s
+}
+
+// currentUser is the name of the user running easy-add, or their ID when it has no name
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return strconv.Itoa(os.Getuid())
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/bundle"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/extract"
+	"io/ioutil"
+	"os"
+	"runtime"
+)
+
+type applyArgs struct {
+	To            string `usage:"The [path] where the tools will be placed, which defaults to the to of the bundled manifest"`
+	Mkdirs        bool   `usage:"Attempt to create the directory path specified by to"`
+	NoPathWarning bool   `usage:"Don't warn when the directory of the installed files is not on the PATH"`
+	Output        string `usage:"The [format] of the results written to stdout: text or json" default:"text"`
+}
+
+func applyCommand() *command {
+	args := &applyArgs{}
+	return &command{
+		name:    "apply",
+		usage:   "[flags] bundle [name...]",
+		summary: "Installs the tools of a bundle for the current platform without downloading anything",
+		args:    args,
+		// for the policy and audit log, since nothing is downloaded
+		network: true,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			if flagSet.NArg() == 0 {
+				return &usageError{"the path of a bundle is required"}
+			}
+			switch args.Output {
+			case "text":
+			case "json":
+				// keep stdout clean for the JSON results
+				logWriter.out = os.Stderr
+				jsonErrors = true
+			default:
+				return &usageError{"output must be text or json"}
+			}
+			return applyBundle(ctx, args, flagSet.Arg(0), flagSet.Args()[1:])
+		},
+	}
+}
+
+func applyBundle(ctx context.Context, args *applyArgs, bundlePath string, names []string) error {
+	dir, err := ioutil.TempDir("", "easy-add-apply-")
+	if err != nil {
+		return err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer os.RemoveAll(dir)
+
+	index, err := bundle.Extract(bundlePath, dir)
+	if err != nil {
+		return err
+	}
+	entries, err := index.Select(runtime.GOOS+"/"+runtime.GOARCH, names)
+	if err != nil {
+		return err
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+
+	to := args.To
+	if to == "" {
+		to = index.To
+	}
+	var results []*easyadd.Result
+	for _, entry := range entries {
+		result, err := easyadd.Install(ctx, easyadd.Options{
+			From:          entry.From,
+			ArchivePath:   entry.Archive,
+			File:          entry.File,
+			Format:        extract.Format(entry.Format),
+			Vars:          entry.Vars,
+			Checksum:      entry.Checksum,
+			To:            to,
+			Mkdirs:        args.Mkdirs,
+			NoPathWarning: args.NoPathWarning,
+			Policy:        policy,
+			StrictDigests: networkArgs.StrictDigests,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to install %s: %w", entry.Name, err)
+		}
+		err = auditInstall(entry.Name, result)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	if args.Output == "json" {
+		return json.NewEncoder(os.Stdout).Encode(results)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/bundle"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/manifest"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+type bundleArgs struct {
+	Manifest string   `usage:"The [path] of the manifest declaring the tools to bundle" default:"tools.yaml"`
+	Out      string   `usage:"The [path] of the bundle to write, which is a tar file"`
+	Catalog  []string `usage:"[URL] or path of a catalog whose tools are added to, or replace, the built-in ones. Can be repeated."`
+}
+
+func bundleCommand() *command {
+	args := &bundleArgs{}
+	return &command{
+		name:    "bundle",
+		usage:   "[flags] [name...]",
+		summary: "Downloads and verifies the archives of the manifest's tools, for each of its platforms, into a single file for apply to install offline",
+		args:    args,
+		network: true,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			if args.Out == "" {
+				return &usageError{"out is required"}
+			}
+			return writeBundle(ctx, args, flagSet.Args())
+		},
+	}
+}
+
+func writeBundle(ctx context.Context, args *bundleArgs, names []string) error {
+	m, err := manifest.Load(args.Manifest)
+	if err != nil {
+		return err
+	}
+	tools, err := m.Select(names)
+	if err != nil {
+		return err
+	}
+	platforms, err := m.PlatformList()
+	if err != nil {
+		return err
+	}
+	c, err := loadCatalog(ctx, args.Catalog)
+	if err != nil {
+		return err
+	}
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return err
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+
+	// where each archive is kept once verified, before it's copied into the bundle
+	stagingDir, err := ioutil.TempDir("", "easy-add-bundle-")
+	if err != nil {
+		return err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer os.RemoveAll(stagingDir)
+
+	w, err := bundle.Create(args.Out, m.To)
+	if err != nil {
+		return err
+	}
+	for _, tool := range tools {
+		definition, err := tool.Definition(c)
+		if err != nil {
+			w.Abort()
+			return err
+		}
+
+		for _, platform := range platforms {
+			err = bundleTool(ctx, w, filepath.Join(stagingDir, tool.Name, platform[0]+"-"+platform[1]), &bundle.Entry{
+				Name:     tool.Name,
+				Version:  tool.Version,
+				Platform: platform[0] + "/" + platform[1],
+				From:     definition.From,
+				File:     definition.File,
+				Format:   definition.Format,
+				Vars:     definition.PlatformVars(tool.Version, platform[0], platform[1]),
+				Checksum: tool.Digests[platform[0]+"/"+platform[1]],
+			}, easyadd.Options{
+				AllowHTTP:     allowHTTP(),
+				PreferHTTPS:   networkArgs.PreferHttps,
+				Policy:        policy,
+				StrictDigests: networkArgs.StrictDigests,
+				HTTPClient:    client,
+			})
+			if err != nil {
+				w.Abort()
+				return fmt.Errorf("failed to bundle %s for %s/%s: %w", tool.Name, platform[0], platform[1], err)
+			}
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	log.Printf("I! Wrote bundle to %s", args.Out)
+	return nil
+}
+
+// bundleTool downloads and verifies the archive of the entry, keeping it in keepDir, and adds it
+// to the bundle along with its checksum
+func bundleTool(ctx context.Context, w *bundle.Writer, keepDir string, entry *bundle.Entry, opts easyadd.Options) error {
+	if entry.Checksum == "" {
+		log.Printf("W! %s has no digest for %s, which easy-add lock --update-checksums can add", entry.Name, entry.Platform)
+	}
+	opts.From = entry.From
+	opts.File = entry.File
+	opts.Format = extract.Format(entry.Format)
+	opts.Vars = entry.Vars
+	opts.Checksum = entry.Checksum
+	opts.KeepArchiveDir = keepDir
+	result, err := easyadd.Resolve(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	kept, err := ioutil.ReadDir(keepDir)
+	if err != nil {
+		return err
+	}
+	if len(kept) != 1 {
+		return fmt.Errorf("expected one kept archive in %s, but found %d", keepDir, len(kept))
+	}
+	// the archive is installed from the bundle with the digest of what was verified
+	entry.Checksum = "sha256:" + result.ArchiveSHA256
+	return w.Add(entry, filepath.Join(keepDir, kept[0].Name()))
+}
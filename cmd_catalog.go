@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/catalog"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"os"
+	"text/tabwriter"
+)
+
+type catalogArgs struct {
+	Catalog []string `usage:"[URL] or path of a catalog whose tools are added to, or replace, the built-in ones. Can be repeated."`
+}
+
+func catalogCommand() *command {
+	args := &catalogArgs{}
+	return &command{
+		name:    "catalog",
+		usage:   "[flags]",
+		summary: "Lists the tools that can be installed by name, such as easy-add jq@1.7.1",
+		args:    args,
+		network: true,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			c, err := loadCatalog(ctx, args.Catalog)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "NAME\tREPO")
+			for _, name := range c.Names() {
+				_, _ = fmt.Fprintf(w, "%s\t%s\n", name, c.Tools[name].Repo)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// loadCatalog merges the catalogs at the given URLs or paths, in order, over the built-in catalog
+func loadCatalog(ctx context.Context, sources []string) (*catalog.Catalog, error) {
+	c := catalog.Builtin()
+	if len(sources) == 0 {
+		return c, nil
+	}
+
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return nil, err
+	}
+	opts := easyadd.Options{
+		AllowHTTP:   allowHTTP(),
+		PreferHTTPS: networkArgs.PreferHttps,
+		Policy:      policy,
+		HTTPClient:  client,
+	}
+
+	for _, source := range sources {
+		content, err := easyadd.ReadReference(ctx, opts, source, "catalog")
+		if err != nil {
+			return nil, err
+		}
+		loaded, err := catalog.Parse(content, source)
+		if err != nil {
+			return nil, err
+		}
+		c = c.Merge(loaded)
+	}
+	return c, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type completionArgs struct {
+}
+
+func completionCommand() *command {
+	args := &completionArgs{}
+	return &command{
+		name:    "completion",
+		usage:   "bash|zsh|fish",
+		summary: "Outputs a shell completion script",
+		args:    args,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			if flagSet.NArg() != 1 {
+				return &usageError{"a shell is required"}
+			}
+
+			flagNames := make(map[string][]string)
+			var names []string
+			for _, cmd := range commands() {
+				names = append(names, cmd.name)
+				cmdFlags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+				err := declareFlags(cmdFlags, cmd, &networkFlags{})
+				if err != nil {
+					return err
+				}
+				cmdFlags.VisitAll(func(f *flag.Flag) {
+					flagNames[cmd.name] = append(flagNames[cmd.name], "--"+f.Name)
+				})
+			}
+
+			switch flagSet.Arg(0) {
+			case "bash":
+				writeBashCompletion(os.Stdout, names, flagNames)
+			case "zsh":
+				_, _ = fmt.Fprintln(os.Stdout, "#compdef easy-add")
+				_, _ = fmt.Fprintln(os.Stdout, "autoload -U bashcompinit && bashcompinit")
+				writeBashCompletion(os.Stdout, names, flagNames)
+			case "fish":
+				writeFishCompletion(os.Stdout, names, flagNames)
+			default:
+				return &usageError{fmt.Sprintf("unsupported shell '%s'", flagSet.Arg(0))}
+			}
+			return nil
+		},
+	}
+}
+
+func writeBashCompletion(out io.Writer, names []string, flagNames map[string][]string) {
+	_, _ = fmt.Fprintln(out, "_easy_add() {")
+	_, _ = fmt.Fprintln(out, `  local cur="${COMP_WORDS[COMP_CWORD]}" cmd=get`)
+	_, _ = fmt.Fprintln(out, `  if [[ ${COMP_CWORD} -gt 1 && "${COMP_WORDS[1]}" != -* ]]; then cmd="${COMP_WORDS[1]}"; fi`)
+	_, _ = fmt.Fprintln(out, `  if [[ ${COMP_CWORD} -eq 1 && "${cur}" != -* ]]; then`)
+	_, _ = fmt.Fprintf(out, "    COMPREPLY=($(compgen -W %q -- \"${cur}\"))\n", strings.Join(append(names, "help"), " "))
+	_, _ = fmt.Fprintln(out, "    return")
+	_, _ = fmt.Fprintln(out, "  fi")
+	_, _ = fmt.Fprintln(out, `  case "${cmd}" in`)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(out, "    %s) COMPREPLY=($(compgen -W %q -- \"${cur}\")) ;;\n", name, strings.Join(flagNames[name], " "))
+	}
+	_, _ = fmt.Fprintln(out, "  esac")
+	_, _ = fmt.Fprintln(out, "}")
+	_, _ = fmt.Fprintln(out, "complete -o default -F _easy_add easy-add")
+}
+
+func writeFishCompletion(out io.Writer, names []string, flagNames map[string][]string) {
+	_, _ = fmt.Fprintf(out, "complete -c easy-add -n '__fish_use_subcommand' -f -a '%s'\n", strings.Join(append(names, "help"), " "))
+	for _, name := range names {
+		condition := fmt.Sprintf("__fish_seen_subcommand_from %s", name)
+		if name == "get" {
+			condition = fmt.Sprintf("not __fish_seen_subcommand_from %s", strings.Join(names[1:], " "))
+		}
+		for _, f := range flagNames[name] {
+			_, _ = fmt.Fprintf(out, "complete -c easy-add -n '%s' -l %s\n", condition, strings.TrimPrefix(f, "--"))
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/internal/githubapi"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"github.com/itzg/easy-add/pkg/install"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type doctorArgs struct {
+	To       string   `usage:"The [path] where tools are installed, which defaults to that of get"`
+	CacheDir string   `usage:"The [dir] given as cache-dir of get, if any, to check"`
+	Host     []string `usage:"Additional [host] to check, such as that of an artifact mirror. Can be repeated."`
+}
+
+// doctorHosts are where the archives of catalog tools and GitHub releases are typically downloaded from
+var doctorHosts = []string{
+	"github.com",
+	"objects.githubusercontent.com",
+	"release-assets.githubusercontent.com",
+}
+
+// doctorTimeout limits how long each connection check takes
+const doctorTimeout = 15 * time.Second
+
+func doctorCommand() *command {
+	args := &doctorArgs{}
+	return &command{
+		name:    "doctor",
+		usage:   "[flags]",
+		summary: "Diagnoses the proxy, DNS, TLS trust, cache, and install directory, such as when installs fail in CI but not locally",
+		args:    args,
+		network: true,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			d := &doctor{}
+			hosts := append([]string{}, doctorHosts...)
+			if apiURL, err := url.Parse(githubapi.BaseURL); err == nil && apiURL.Host != "" {
+				hosts = append(hosts, apiURL.Host)
+			}
+			hosts = append(hosts, args.Host...)
+
+			if networkArgs.Proxy == "" && os.Getenv("HTTPS_PROXY") == "" && os.Getenv("https_proxy") == "" &&
+				(os.Getenv("HTTP_PROXY") != "" || os.Getenv("http_proxy") != "") {
+				log.Printf("W! HTTP_PROXY is set but not HTTPS_PROXY, so HTTPS downloads don't use a proxy")
+			}
+			for _, host := range hosts {
+				problems := d.problems
+				viaProxy := d.checkProxy(host)
+				if !viaProxy {
+					d.checkDNS(ctx, host)
+				}
+				// since connecting would only fail the same way
+				if d.problems == problems {
+					d.checkTLS(ctx, host)
+				}
+			}
+			d.checkCacheDir(args.CacheDir)
+			to := args.To
+			if to == "" {
+				to = install.DefaultDir()
+			}
+			d.checkInstallDir(to)
+
+			if d.problems > 0 {
+				return fmt.Errorf("%d of %d checks found problems", d.problems, d.checks)
+			}
+			log.Printf("I! All %d checks passed", d.checks)
+			return nil
+		},
+	}
+}
+
+// doctor tallies the checks of the doctor command
+type doctor struct {
+	checks   int
+	problems int
+}
+
+func (d *doctor) ok(format string, args ...interface{}) {
+	d.checks++
+	log.Printf("I! OK "+format, args...)
+}
+
+// problem reports a failed check along with a suggestion of how to resolve it
+func (d *doctor) problem(suggestion string, format string, args ...interface{}) {
+	d.checks++
+	d.problems++
+	log.Printf("E! PROBLEM "+format, args...)
+	log.Printf("I!   %s", suggestion)
+}
+
+// checkProxy reports the proxy used for the host, as determined by the proxy flag or environment
+// variables, and whether it can be connected to. It returns true when a proxy is used, which
+// then resolves the host.
+func (d *doctor) checkProxy(host string) bool {
+	if networkArgs.UnixSocket != "" {
+		d.ok("%s is reached through the Unix socket %s", host, networkArgs.UnixSocket)
+		return true
+	}
+
+	proxyFunc, err := fetch.ProxyFunc(networkArgs.Proxy)
+	if err != nil {
+		d.problem("Correct the URL given to proxy", "%v", err)
+		return false
+	}
+	req, err := http.NewRequest(http.MethodHead, "https://"+host+"/", nil)
+	if err != nil {
+		d.problem("Correct the host given to host", "%v", err)
+		return false
+	}
+	proxyURL, err := proxyFunc(req)
+	if err != nil {
+		d.problem("Correct the URL of HTTPS_PROXY or pass proxy instead",
+			"the proxy declared for %s is invalid: %v", host, err)
+		return false
+	}
+	if proxyURL == nil {
+		d.ok("%s is connected to directly", host)
+		return false
+	}
+
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", proxyAddr, doctorTimeout)
+	if err != nil {
+		d.problem("Confirm the proxy is reachable from here or correct HTTPS_PROXY, NO_PROXY, or proxy",
+			"unable to connect to the proxy %s of %s: %v", proxyURL.Redacted(), host, err)
+		return true
+	}
+	_ = conn.Close()
+	d.ok("%s is reached through the proxy %s", host, proxyURL.Redacted())
+	return true
+}
+
+func (d *doctor) checkDNS(ctx context.Context, host string) {
+	resolver := net.DefaultResolver
+	if networkArgs.Dns != "" {
+		resolver = fetch.NewResolver(networkArgs.Dns)
+	}
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	addrs, err := resolver.LookupHost(ctx, hostname)
+	if err != nil {
+		suggestion := "Check the DNS servers of this host, such as in /etc/resolv.conf, or pass dns to use another"
+		if networkArgs.Dns != "" {
+			suggestion = "Confirm the DNS server given to dns is reachable and can resolve public hosts"
+		}
+		d.problem(suggestion, "unable to resolve %s: %v", hostname, err)
+		return
+	}
+	d.ok("%s resolves to %s", hostname, strings.Join(addrs, ", "))
+}
+
+// checkTLS connects to the host with the client used for downloads, so that the certificates of
+// ca-cert are trusted, where any response confirms the certificate of the host is trusted
+func (d *doctor) checkTLS(ctx context.Context, host string) {
+	client, err := sharedHTTPClient()
+	if err != nil {
+		d.problem("Correct the network flags, such as ca-cert", "unable to create the HTTP client: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "https://"+host+"/", nil)
+	if err != nil {
+		d.problem("Correct the host given to host", "%v", err)
+		return
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var unknownAuthority x509.UnknownAuthorityError
+		var hostnameErr x509.HostnameError
+		switch {
+		case errors.As(err, &unknownAuthority):
+			d.problem("Pass ca-cert with the CA certificate of your organization, such as that of a TLS intercepting proxy, "+
+				"or add it to the trust store of this host",
+				"the certificate of %s isn't trusted since it's issued by %s", host, issuerName(unknownAuthority.Cert))
+		case errors.As(err, &hostnameErr):
+			d.problem(fmt.Sprintf("A proxy or firewall may be intercepting connections, so ask for %s to be allowed through", host),
+				"the certificate presented for %s is for another host: %v", host, err)
+		default:
+			d.problem("Check the firewall rules and proxy settings of this host",
+				"unable to connect to %s: %v", host, err)
+		}
+		return
+	}
+	//noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	issuer := "an unknown issuer"
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		issuer = issuerName(resp.TLS.PeerCertificates[0])
+	}
+	d.ok("%s presents a trusted certificate issued by %s", host, issuer)
+}
+
+func issuerName(cert *x509.Certificate) string {
+	if cert == nil {
+		return "an unknown issuer"
+	}
+	if cert.Issuer.CommonName != "" {
+		return cert.Issuer.CommonName
+	}
+	return cert.Issuer.String()
+}
+
+// checkCacheDir confirms the cache directory is writable and that its archives each have the
+// digest recorded when they were cached
+func (d *doctor) checkCacheDir(dir string) {
+	if dir == "" {
+		log.Printf("I! Skipping the cache directory since cache-dir isn't given")
+		return
+	}
+
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		d.ok("cache directory %s will be created by the first download", dir)
+		return
+	} else if err != nil {
+		d.problem("Check the permissions of the cache directory", "unable to access cache directory: %v", err)
+		return
+	} else if !info.IsDir() {
+		d.problem("Pass cache-dir a directory instead", "cache directory %s is not a directory", dir)
+		return
+	}
+	if !d.checkWritable(dir, "cache directory") {
+		return
+	}
+
+	var archives, unrecorded int
+	var size int64
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || strings.HasSuffix(path, ".sha256") {
+			return err
+		}
+		archives++
+		size += info.Size()
+		if _, err := os.Stat(path + ".sha256"); os.IsNotExist(err) {
+			unrecorded++
+		}
+		return nil
+	})
+	if err != nil {
+		d.problem("Check the permissions of the cache directory", "unable to read cache directory: %v", err)
+		return
+	}
+	if unrecorded > 0 {
+		log.Printf("W! %d archives in cache directory %s have no recorded digest, such as from being interrupted, "+
+			"and will be retrieved again", unrecorded, dir)
+	}
+	d.ok("cache directory %s has %d archives using %d bytes", dir, archives, size)
+}
+
+func (d *doctor) checkInstallDir(to string) {
+	info, err := os.Stat(to)
+	if os.IsNotExist(err) {
+		d.problem("Create it or pass mkdirs to get", "install directory %s does not exist", to)
+		return
+	} else if err != nil {
+		d.problem("Check the permissions of the install directory", "unable to access install directory: %v", err)
+		return
+	} else if !info.IsDir() {
+		d.problem("Pass to a directory instead", "install directory %s is not a directory", to)
+		return
+	}
+	if d.checkWritable(to, "install directory") {
+		install.WarnIfNotOnPath(filepath.Join(to, "easy-add"), nil)
+	}
+}
+
+// checkWritable confirms a file can be created in dir, the same way an install does
+func (d *doctor) checkWritable(dir string, description string) bool {
+	file, err := ioutil.TempFile(dir, ".easy-add-doctor-*")
+	if err != nil {
+		suggestion := "Check the permissions of the directory"
+		if os.IsPermission(err) {
+			suggestion = "Run as a user that can write to it, such as with sudo, or pass another directory"
+		}
+		d.problem(suggestion, "unable to write to %s %s: %v", description, dir, err)
+		return false
+	}
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+	d.ok("%s %s is writable", description, dir)
+	return true
+}
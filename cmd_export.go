@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/catalog"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/manifest"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+type exportArgs struct {
+	Manifest string   `usage:"The [path] of the manifest" default:"tools.yaml"`
+	Out      string   `usage:"The [path] to write the resolved manifest to, which defaults to stdout"`
+	Catalog  []string `usage:"[URL] or path of a catalog whose tools are added to, or replace, the built-in ones. Can be repeated."`
+}
+
+func exportCommand() *command {
+	args := &exportArgs{}
+	return &command{
+		name:    "export",
+		usage:   "[flags] [name...]",
+		summary: "Writes the manifest with its tools resolved to the URL, file, and digest of each platform for import to install elsewhere",
+		args:    args,
+		network: true,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			m, err := manifest.Load(args.Manifest)
+			if err != nil {
+				return err
+			}
+			tools, err := m.Select(flagSet.Args())
+			if err != nil {
+				return err
+			}
+			c, err := loadCatalog(ctx, args.Catalog)
+			if err != nil {
+				return err
+			}
+
+			resolved, err := resolveManifest(ctx, m, tools, c)
+			if err != nil {
+				return err
+			}
+			content, err := resolved.Encode()
+			if err != nil {
+				return err
+			}
+			if args.Out == "" {
+				_, err = os.Stdout.Write(content)
+				return err
+			}
+			err = ioutil.WriteFile(args.Out, content, 0644)
+			if err != nil {
+				return fmt.Errorf("unable to write resolved manifest: %w", err)
+			}
+			log.Printf("I! Wrote resolved manifest to %s", args.Out)
+			return nil
+		},
+	}
+}
+
+// resolveManifest resolves the download of each tool for each platform of the manifest, where
+// a digest that the manifest doesn't declare is resolved by downloading the archive
+func resolveManifest(ctx context.Context, m *manifest.Manifest, tools []*manifest.Tool, c *catalog.Catalog) (*manifest.Resolved, error) {
+	platforms, err := m.PlatformList()
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &manifest.Resolved{To: m.To}
+	for _, tool := range tools {
+		definition, err := tool.Definition(c)
+		if err != nil {
+			return nil, err
+		}
+
+		resolvedTool := &manifest.ResolvedTool{
+			Name:      tool.Name,
+			Version:   tool.Version,
+			Downloads: make(map[string]*manifest.Download, len(platforms)),
+		}
+		for _, platform := range platforms {
+			download, err := resolveDownload(tool, definition, platform[0], platform[1])
+			if err != nil {
+				return nil, err
+			}
+			if download.Checksum == "" {
+				download.Checksum, err = resolveDigest(ctx, download)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve %s for %s/%s: %w", tool.Name, platform[0], platform[1], err)
+				}
+				log.Printf("I! Resolved digest of %s for %s/%s", tool.Name, platform[0], platform[1])
+			}
+			resolvedTool.Downloads[platform[0]+"/"+platform[1]] = download
+		}
+		resolved.Tools = append(resolved.Tools, resolvedTool)
+	}
+	return resolved, nil
+}
+
+// resolveDownload evaluates the URL and file of the tool for the platform, along with its
+// digest declared by the manifest, if any
+func resolveDownload(tool *manifest.Tool, definition *catalog.Tool, goos string, goarch string) (*manifest.Download, error) {
+	vars := definition.PlatformVars(tool.Version, goos, goarch)
+	from, err := easyadd.EvaluateTemplate(definition.From, vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate 'from' of %s: %w", tool.Name, err)
+	}
+	file, err := easyadd.EvaluateTemplate(definition.File, vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate 'file' of %s: %w", tool.Name, err)
+	}
+	return &manifest.Download{
+		URL:      from,
+		File:     file,
+		Format:   definition.Format,
+		Checksum: tool.Digests[goos+"/"+goarch],
+	}, nil
+}
+
+// resolveDigest downloads the archive to determine its digest
+func resolveDigest(ctx context.Context, download *manifest.Download) (string, error) {
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return "", err
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return "", err
+	}
+	result, err := easyadd.Resolve(ctx, easyadd.Options{
+		From:          download.URL,
+		File:          download.File,
+		Format:        extract.Format(download.Format),
+		AllowHTTP:     allowHTTP(),
+		PreferHTTPS:   networkArgs.PreferHttps,
+		Policy:        policy,
+		StrictDigests: networkArgs.StrictDigests,
+		HTTPClient:    client,
+	})
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + result.ArchiveSHA256, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/catalog"
+	"github.com/itzg/easy-add/pkg/manifest"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type genArgs struct {
+	Manifest string   `usage:"The [path] of the manifest" default:"tools.yaml"`
+	Catalog  []string `usage:"[URL] or path of a catalog whose tools are added to, or replace, the built-in ones. Can be repeated."`
+}
+
+func genCommand() *command {
+	args := &genArgs{}
+	return &command{
+		name:    "gen",
+		usage:   "[flags] dockerfile [name...]",
+		summary: "Generates Dockerfile instructions that install the tools of the manifest",
+		args:    args,
+		network: true,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			if flagSet.Arg(0) != "dockerfile" {
+				return &usageError{"the dockerfile generator is required"}
+			}
+			// pick up flags given after the generator
+			_ = flagSet.Parse(flagSet.Args()[1:])
+
+			m, err := manifest.Load(args.Manifest)
+			if err != nil {
+				return err
+			}
+			tools, err := m.Select(flagSet.Args())
+			if err != nil {
+				return err
+			}
+			c, err := loadCatalog(ctx, args.Catalog)
+			if err != nil {
+				return err
+			}
+
+			return genDockerfile(os.Stdout, m, tools, c)
+		},
+	}
+}
+
+// genDockerfile writes a RUN instruction per tool, in manifest order, so that bumping a tool
+// only invalidates its layer and those after it. Each download is pinned to its resolved URL
+// and digest, and a manifest with several platforms selects them by the BuildKit TARGETOS and
+// TARGETARCH args.
+func genDockerfile(out io.Writer, m *manifest.Manifest, tools []*manifest.Tool, c *catalog.Catalog) error {
+	platforms, err := m.PlatformList()
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(out, "# Generated by easy-add gen dockerfile, which requires easy-add in the image")
+	multiPlatform := len(platforms) > 1
+	if multiPlatform {
+		_, _ = fmt.Fprintln(out, "ARG TARGETOS")
+		_, _ = fmt.Fprintln(out, "ARG TARGETARCH")
+	}
+
+	for _, tool := range tools {
+		definition, err := tool.Definition(c)
+		if err != nil {
+			return err
+		}
+
+		commands := make([]string, len(platforms))
+		for i, platform := range platforms {
+			command, err := getCommandLine(m, tool, definition, platform[0], platform[1])
+			if err != nil {
+				return err
+			}
+			commands[i] = command
+		}
+
+		_, _ = fmt.Fprintf(out, "# %s %s\n", tool.Name, tool.Version)
+		if !multiPlatform {
+			_, _ = fmt.Fprintf(out, "RUN %s\n", commands[0])
+			continue
+		}
+		_, _ = fmt.Fprintln(out, `RUN case "${TARGETOS}/${TARGETARCH}" in \`)
+		for i, platform := range platforms {
+			_, _ = fmt.Fprintf(out, "      %s/%s) %s ;; \\\n", platform[0], platform[1], commands[i])
+		}
+		_, _ = fmt.Fprintf(out, "      *) echo \"%s is not available for ${TARGETOS}/${TARGETARCH}\" && exit 1 ;; \\\n", tool.Name)
+		_, _ = fmt.Fprintln(out, "    esac")
+	}
+	return nil
+}
+
+// getCommandLine is the easy-add invocation that installs the tool for the platform
+func getCommandLine(m *manifest.Manifest, tool *manifest.Tool, definition *catalog.Tool, goos string, goarch string) (string, error) {
+	download, err := resolveDownload(tool, definition, goos, goarch)
+	if err != nil {
+		return "", err
+	}
+	if download.Checksum == "" {
+		return "", fmt.Errorf("%s has no digest for %s/%s, which easy-add lock --update-checksums can add", tool.Name, goos, goarch)
+	}
+
+	args := []string{"easy-add", "--from", download.URL, "--file", download.File, "--checksum", download.Checksum}
+	if download.Format != "" {
+		args = append(args, "--format", download.Format)
+	}
+	if m.To != "" {
+		args = append(args, "--to", m.To)
+	}
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,1017 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/catalog"
+	"github.com/itzg/easy-add/pkg/checksum"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/install"
+	"github.com/itzg/easy-add/pkg/lockfile"
+	"github.com/itzg/easy-add/pkg/manifest"
+	"github.com/itzg/easy-add/pkg/sbom"
+	"github.com/itzg/easy-add/pkg/telemetry"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type getArgs struct {
+	From                string            `usage:"[URL] of a tar.gz or zip archive to download. May contain Go template references to 'var' entries."`
+	FromArchive         string            `usage:"[path] of an archive that was already downloaded, which is installed from rather than retrieving from. When from is also given, it only names the archive, such as to find it in a list of sums."`
+	FromPart            []string          `usage:"[URL] of a further part of an archive that was split, which is retrieved after from and joined with it. Parts numbered after a from ending with .001 are retrieved without being given. May be repeated and contain Go template references to var entries."`
+	Var                 map[string]string `usage:"Sets variables that can be referenced in 'from' and 'file'. Format is [name=value]"`
+	VersionFromUrl      string            `usage:"[URL] or path of a JSON document, such as a latest.json published by the project, that the version var is set from with version-jsonpath. May contain Go template references to 'var' entries."`
+	VersionJsonpath     string            `usage:"The JSONPath of the version within the document of version-from-url, such as $.tag_name or $.releases[0].version" default:"$.version"`
+	GitTags             string            `usage:"[URL] of a git repository, such as https://github.com/owner/repo, whose newest tag matching tag-pattern sets the tag var and the version var, which is the tag without the literal prefix of the pattern"`
+	RegistryTags        string            `usage:"The [repository] of an OCI registry, such as ghcr.io/owner/tool, whose newest tag matching tag-pattern sets the tag var and the version var, as with git-tags"`
+	TagPattern          string            `usage:"The [pattern] of the tags of git-tags or registry-tags to consider, such as v* or release-*, where tags that aren't versions, such as latest, and pre-releases are skipped" default:"*"`
+	File                string            `usage:"The [path] to executable to extract within archive. May contain Go template references to 'var' entries."`
+	Match               string            `usage:"How file is compared with archive entries: exact, suffix to match the end of an entry path, such as bin/tool for tool-1.2.3/bin/tool, or glob such as tool-*/bin/tool" default:"exact"`
+	Pick                string            `usage:"How one is chosen when file, or asset-regex, matches several: first in sorted order, shortest-path, largest, or error" default:"error"`
+	PreferStatic        bool              `usage:"When file matches several, prefers the statically linked ELF binaries among them, such as musl builds for alpine or scratch images"`
+	All                 bool              `usage:"Installs every archive entry that matches file, such as with match glob, at their paths within the archive under to"`
+	Flatten             bool              `usage:"Installs every archive entry that matches file directly in to, dropping their directories, and fails when two have the same name"`
+	StripTopDir         bool              `usage:"Treats file and map entries as relative to the one directory that every archive entry is within, such as tool-1.2.3. This is done without being set for source archives of GitHub repositories."`
+	Map                 []string          `usage:"Also extracts the archive entry, or one matched by a glob, to an absolute path given as [entry=path], such as tool-*/bin/tool=/usr/local/bin/tool. Can be repeated."`
+	Format              string            `usage:"The [format] of the archive, such as tar.gz, zip, or binary, rather than detecting it from the suffix of from"`
+	To                  string            `usage:"The [path] where executable will be placed, or - to write it to stdout"`
+	Layout              string            `usage:"How files are placed within to: flat, or versioned to install into to/name/version, where to/name/current links to the installed version and to/bin links to the installed file through it. The version is that of the tool or the version var." default:"flat"`
+	Root                string            `usage:"A [dir], such as a mounted root filesystem, that to, map destinations, and links are placed within, where links point at their targets as seen from within it"`
+	PreserveOwner       bool              `usage:"Gives installed files the numeric user and group ID recorded in the tar archive, such as when assembling a root filesystem, which requires running as root"`
+	OwnerMap            string            `usage:"How preserve-owner maps the owners recorded in the tar archive when not running as root: current to leave files owned by the current user, or [uid:gid=uid:gid] pairs separated by commas, where unmapped owners are left as the current user"`
+	Reproducible        bool              `usage:"Normalizes what is installed so that image layers built with it are reproducible: modification times of files, links, and their directories are set to source-date-epoch, permissions to 0755 or 0644, and, when running as root, owners to root"`
+	SourceDateEpoch     string            `usage:"The [seconds] since the Unix epoch that reproducible sets modification times to, which defaults to SOURCE_DATE_EPOCH or else the epoch itself"`
+	Mkdirs              bool              `usage:"Attempt to create the directory path specified by to"`
+	ZipPassword         string            `usage:"The [password] of an encrypted zip, which is better given by the EASY_ADD_ZIP_PASSWORD environment variable to keep it out of process listings"`
+	Integrity           string            `usage:"Expected Subresource Integrity of the downloaded archive, such as [sha256-base64] copied from npm metadata, as an alternative to checksum"`
+	AgeIdentity         []string          `usage:"[path] of an age identity file, as written by age-keygen, that decrypts an age encrypted archive. Can be repeated."`
+	Checksum            string            `usage:"Expected checksum of the downloaded archive as [algorithm:hex] where algorithm is sha256, sha384, sha512, sha1, md5, or blake2b, or the path or URL of a digest or list of sums such as SHA256SUMS"`
+	Preflight           bool              `usage:"Check the archive with a HEAD request before downloading it, reporting its size and type"`
+	MaxDownloadSize     string            `usage:"The maximum [size] of archive to download, such as 200M, where the K, M, and G suffixes are powers of 1024"`
+	KeepArchive         string            `usage:"A [dir] where a copy of the downloaded archive is kept, once verified, such as to populate a mirror"`
+	CacheDir            string            `usage:"A [dir] where downloaded archives are kept and reused by later installs of the same URL, once verified to be unchanged"`
+	WaitFor             time.Duration     `usage:"How long to keep checking for the archive, or the GitHub release and asset of a catalog tool, to be published when not found, such as when the job publishing it may still be running"`
+	Poll                time.Duration     `usage:"How often to check again while waiting with wait-for" default:"15s"`
+	HeadOnly            bool              `usage:"Only check that the archive exists, reporting its size and type, without downloading or installing anything"`
+	Check               bool              `usage:"Only check that the archive, or the GitHub release and asset of a catalog tool, exists, exiting with 1 when it doesn't, such as to gate a pipeline on its availability"`
+	DirMode             string            `usage:"Permissions, in octal such as 0750, of directories created by mkdirs rather than 0755 filtered by the umask"`
+	Owner               string            `usage:"The [user:group], by name or ID, to own directories created by mkdirs"`
+	Setcap              string            `usage:"Linux file [capabilities] to set on the installed file, such as cap_net_bind_service=+ep"`
+	SelinuxType         string            `usage:"The SELinux [type], such as bin_t, to label the installed file with"`
+	Provenance          bool              `usage:"Requires verified SLSA provenance of the archive, which is discovered alongside it unless provenance-url is given"`
+	ProvenanceUrl       string            `usage:"[URL] of the SLSA provenance of the archive, such as a .intoto.jsonl release asset. May contain Go template references to 'var' entries."`
+	ProvenanceBuilder   string            `usage:"The [prefix] that the builder of the provenance must have" default:"https://github.com/slsa-framework/slsa-github-generator/"`
+	ProvenanceRepo      string            `usage:"The source [repo], such as owner/repo, that the provenance must declare, which defaults to that of a GitHub release URL"`
+	Attestation         bool              `usage:"Requires a verified GitHub artifact attestation of the archive, as gh attestation verify would check"`
+	AttestationRepo     string            `usage:"The [repo], such as owner/repo, that must have built the archive, which defaults to that of a GitHub release URL"`
+	AttestationWorkflow string            `usage:"The [workflow], such as owner/repo/.github/workflows/release.yml, that must have signed the attestation"`
+	SshAllowedSigners   string            `usage:"[path] of an allowed signers file, as used by ssh-keygen -Y verify, requiring an SSH signature of the checksum file, or of the archive when checksum is not a file or URL"`
+	SshSigUrl           string            `usage:"[URL] or path of the SSH signature, which defaults to that of the signed file with .sig appended. May contain Go template references to 'var' entries."`
+	SshNamespace        string            `usage:"The [namespace] that the SSH signature must have been made for" default:"file"`
+	SshIdentity         string            `usage:"The [principal], such as an email address, that the SSH signer must be allowed for"`
+	MinisignKey         string            `usage:"The minisign public [key], as given to minisign -P, or path of its file, requiring a minisign signature with .minisig appended to the checksum file or archive"`
+	PgpKey              string            `usage:"[path] of PGP public keys, requiring a PGP signature with .asc appended to the checksum file or archive"`
+	Cosign              bool              `usage:"Requires a keyless cosign signature, with .pem and .sig appended to the checksum file or archive, made by a GitHub Actions workflow of cosign-repo"`
+	CosignRepo          string            `usage:"The [repo], such as owner/repo, that must have made the cosign signature, which defaults to that of a GitHub release URL"`
+	Verify              string            `usage:"When [mode] is auto, requires only one of the configured SSH, minisign, PGP, or cosign signatures, skipping those not found alongside the signed file"`
+	TargetOs            string            `usage:"The [os], named like GOOS such as linux, to download and check binaries for rather than that of this host, such as to populate a root filesystem for another platform"`
+	TargetArch          string            `usage:"The [arch], named like GOARCH such as arm64, to download and check binaries for rather than that of this host"`
+	RequireArchMatch    bool              `usage:"Fail rather than warn when the extracted binary is built for a different OS or architecture"`
+	JavaLauncher        string            `usage:"Creates a script with the given [name] alongside the installed file, a jar, that runs it with java, where links then point at the script. A from named with .jar is installed as is unless format is given."`
+	AssetRegex          string            `usage:"A [regex] that picks the one asset of the GitHub release of the catalog tool being installed to download rather than the from of its catalog entry, such as when its builds for the platform differ by libc. May contain Go template references to var entries, such as os and arch."`
+	Link                []string          `usage:"Creates or updates a symbolic link at the given [path] pointing at the installed file. Can be repeated."`
+	ExecAfter           string            `usage:"A shell [command] to run after successful extraction. May contain Go template references to 'var' entries and 'path' of the installed file."`
+	VerifyCmd           string            `usage:"Space separated [args] to run the extracted file with, such as --version, where a non-zero exit fails the install"`
+	MinVersion          string            `usage:"Skips the download when the file already installed reports at least this [version] when run with version-cmd"`
+	VersionCmd          string            `usage:"Space separated [args] to run the installed file with to report its version for min-version" default:"--version"`
+	VersionRegex        string            `usage:"The [regex] that finds the version in the output of version-cmd, where the first group, if any, is the version" default:"(\\d+(?:\\.\\d+)+)"`
+	NoPathWarning       bool              `usage:"Don't warn when the directory of the installed file, or one of its links, is not on the PATH"`
+	Catalog             []string          `usage:"[URL] or path of a catalog whose tools are added to, or replace, the built-in ones. Can be repeated."`
+	Manifest            string            `usage:"Installs the tools of the manifest at the given [path], or those named as arguments, with their pinned digests"`
+	Sbom                string            `usage:"Writes a CycloneDX JSON document describing the installed files to the given [path]"`
+	Lockfile            string            `usage:"Records the installed tool in the lockfile at the given [path]. When from is not given, the tool named by name is reinstalled as locked."`
+	Name                string            `usage:"The [name] of the tool in the lockfile, which defaults to the base name of file"`
+	Summary             bool              `usage:"Prints a table at the end of the run of, for each tool, how long downloading and extracting it took, how many bytes were downloaded, and the size of the installed files"`
+	Output              string            `usage:"The [format] of the result written to stdout: text or json" default:"text"`
+	Version             bool              `usage:"Show version and exit"`
+}
+
+func getCommand() *command {
+	args := &getArgs{To: install.DefaultDir()}
+	return &command{
+		name:    "get",
+		usage:   "[flags] --from URL --file PATH | [flags] TOOL[@VERSION]",
+		summary: "Downloads an archive and installs an executable from it",
+		args:    args,
+		network: true,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			err := runGet(ctx, flagSet, args)
+			if args.Check && notPublishedYet(err) {
+				return &missingError{err}
+			}
+			return err
+		},
+	}
+}
+
+func runGet(ctx context.Context, flagSet *flag.FlagSet, args *getArgs) error {
+	if args.Version {
+		fmt.Printf("version=%s, commit=%s\n", version, commit)
+		return nil
+	}
+
+	switch args.Output {
+	case "text":
+	case "json":
+		// keep stdout clean for the JSON result
+		logWriter.out = os.Stderr
+		jsonErrors = true
+	default:
+		return &usageError{"output must be text or json"}
+	}
+
+	if args.Manifest != "" {
+		if args.HeadOnly || args.Check {
+			return &usageError{"head-only and check can't be used with a manifest"}
+		}
+		return getManifest(ctx, flagSet, args)
+	}
+
+	var catalogTool *catalog.Tool
+	if flagSet.NArg() > 0 {
+		tool := flagSet.Arg(0)
+		// pick up flags given after the tool
+		_ = flagSet.Parse(flagSet.Args()[1:])
+		err := applyNetworkArgs()
+		if err != nil {
+			return &usageError{err.Error()}
+		}
+		if flagSet.NArg() > 0 {
+			return &usageError{"only one tool can be given"}
+		}
+		if args.From != "" {
+			return &usageError{"from can't be given along with a tool"}
+		}
+		catalogTool, err = resolveTool(ctx, args, tool)
+		if err != nil {
+			return err
+		}
+	} else if args.AssetRegex != "" {
+		return &usageError{"asset-regex requires a tool from the catalog"}
+	}
+
+	var lock *lockfile.Lockfile
+	if args.Lockfile != "" {
+		var err error
+		lock, err = lockfile.Load(args.Lockfile)
+		if err != nil {
+			return err
+		}
+
+		if args.From == "" && args.FromArchive == "" {
+			if args.Name == "" {
+				return &usageError{"from and file, or name with lockfile, are required"}
+			}
+			entry, exists := lock.Tools[args.Name]
+			if !exists {
+				return fmt.Errorf("%s is not in the lockfile", args.Name)
+			}
+			args.From = entry.From
+			args.FromPart = entry.Parts
+			args.File = entry.File
+			args.Match = entry.Match
+			if entry.Pick != "" {
+				args.Pick = entry.Pick
+			}
+			args.PreferStatic = entry.PreferStatic
+			args.All = entry.All
+			args.Flatten = entry.Flatten
+			args.StripTopDir = entry.StripTopDir
+			args.Map = entry.Map
+			args.Format = entry.Format
+			args.JavaLauncher = entry.JavaLauncher
+			if entry.Layout != "" {
+				args.Layout = entry.Layout
+			}
+			args.Var = entry.Vars
+			args.Checksum = entry.Checksum
+			if !flagWasSet(flagSet, "to") && entry.To != "" {
+				args.To = entry.To
+			}
+			if len(args.Link) == 0 {
+				args.Link = entry.Links
+			}
+		}
+	}
+
+	if args.VersionFromUrl != "" || args.GitTags != "" || args.RegistryTags != "" {
+		if catalogTool != nil {
+			return &usageError{"version-from-url, git-tags, and registry-tags can't be given along with a tool"}
+		}
+		sources := 0
+		for _, source := range []string{args.VersionFromUrl, args.GitTags, args.RegistryTags} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources > 1 {
+			return &usageError{"only one of version-from-url, git-tags, and registry-tags can be given"}
+		}
+		err := resolveVersion(ctx, args)
+		if err != nil {
+			return err
+		}
+	}
+
+	if (args.From == "" && args.FromArchive == "") || (args.File == "" && len(args.Map) == 0) {
+		return &usageError{"from, or from-archive, and either file or map are required"}
+	}
+	if args.Layout == layoutVersioned && args.Name == "" {
+		// the directory of the tool within to, which also names it in the lockfile
+		if args.JavaLauncher != "" {
+			args.Name = args.JavaLauncher
+		} else if args.File != "" {
+			file, err := easyadd.EvaluateTemplate(args.File, args.Var)
+			if err != nil {
+				return err
+			}
+			args.Name = path.Base(file)
+		} else {
+			return &usageError{"name is required for the versioned layout when file isn't given"}
+		}
+	}
+
+	opts, err := installOptions(args)
+	if err != nil {
+		return err
+	}
+	if args.To == "-" {
+		if opts.Versioned != nil {
+			return &usageError{"the versioned layout can't be used when writing the file to stdout"}
+		}
+		if args.Output == "json" {
+			return &usageError{"output json can't be used when writing the file to stdout"}
+		}
+		// keep stdout for the file content
+		logWriter.out = os.Stderr
+		opts.To = ""
+		opts.Writer = os.Stdout
+	}
+
+	if args.HeadOnly || args.Check {
+		var probed *easyadd.ProbeResult
+		err = waitForAvailability(ctx, args.WaitFor, args.Poll, func() error {
+			probed, err = easyadd.Probe(ctx, opts)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if args.Check {
+			log.Printf("I! %s exists", probed.From)
+		}
+		if args.Output == "json" {
+			return json.NewEncoder(os.Stdout).Encode(probed)
+		}
+		return nil
+	}
+
+	var result *easyadd.Result
+	err = waitForAvailability(ctx, args.WaitFor, args.Poll, func() error {
+		result, err = easyadd.Install(ctx, opts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = auditInstall(args.Name, result)
+	if err != nil {
+		return err
+	}
+
+	if lock != nil && !result.Skipped {
+		name := args.Name
+		if name == "" && args.JavaLauncher != "" {
+			name = args.JavaLauncher
+		} else if name == "" && args.File != "" {
+			name = path.Base(args.File)
+		} else if name == "" {
+			name = filepath.Base(result.Path)
+		}
+		err = recordInstall(lock, args.Lockfile, name, &opts, result)
+		if err != nil {
+			return err
+		}
+	}
+
+	if args.Sbom != "" {
+		name := args.Name
+		if name == "" {
+			name = path.Base(result.Path)
+		}
+		component := sbomComponent(name, args.Var["version"], catalogTool, result)
+		err = writeSbom(args.Sbom, []sbom.Component{component})
+		if err != nil {
+			return err
+		}
+	}
+
+	if args.Summary {
+		name := args.Name
+		if name == "" {
+			name = path.Base(result.Path)
+		}
+		err = writeSummary([]string{name}, []*easyadd.Result{result})
+		if err != nil {
+			return err
+		}
+	}
+
+	if args.Output == "json" {
+		return json.NewEncoder(os.Stdout).Encode(result)
+	}
+	return nil
+}
+
+// getManifest installs the selected tools of the manifest for the current platform
+func getManifest(ctx context.Context, flagSet *flag.FlagSet, args *getArgs) error {
+	if args.From != "" {
+		return &usageError{"from can't be given along with a manifest"}
+	}
+	if args.To == "-" {
+		return &usageError{"the files of a manifest can't be written to stdout"}
+	}
+
+	m, err := manifest.Load(args.Manifest)
+	if err != nil {
+		return err
+	}
+	tools, err := m.Select(flagSet.Args())
+	if err != nil {
+		return err
+	}
+	c, err := loadCatalog(ctx, args.Catalog)
+	if err != nil {
+		return err
+	}
+
+	var lock *lockfile.Lockfile
+	if args.Lockfile != "" {
+		lock, err = lockfile.Load(args.Lockfile)
+		if err != nil {
+			return err
+		}
+	}
+	if !flagWasSet(flagSet, "to") && m.To != "" {
+		args.To = m.To
+	}
+	goos, goarch := args.targetPlatform()
+	platform := goos + "/" + goarch
+
+	var results []*easyadd.Result
+	var components []sbom.Component
+	var names []string
+	for _, tool := range tools {
+		definition, err := tool.Definition(c)
+		if err != nil {
+			return err
+		}
+
+		opts, err := installOptions(args)
+		if err != nil {
+			return err
+		}
+		opts.From = definition.From
+		opts.File = definition.File
+		opts.Format = extract.Format(definition.Format)
+		opts.Vars = definition.PlatformVars(tool.Version, goos, goarch)
+		for k, v := range args.Var {
+			opts.Vars[k] = v
+		}
+		opts.Checksum = tool.Digests[platform]
+		if opts.Versioned != nil {
+			opts.Versioned = &easyadd.VersionedLayout{Name: tool.Name, Version: tool.Version}
+		}
+		if opts.Checksum == "" {
+			log.Printf("W! %s has no digest for %s, which easy-add lock --update-checksums can add", tool.Name, platform)
+		}
+
+		var result *easyadd.Result
+		err = waitForAvailability(ctx, args.WaitFor, args.Poll, func() error {
+			result, err = easyadd.Install(ctx, opts)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to install %s: %w", tool.Name, err)
+		}
+		err = auditInstall(tool.Name, result)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+		names = append(names, tool.Name)
+		components = append(components, sbomComponent(tool.Name, tool.Version, definition, result))
+
+		if lock != nil {
+			err = recordInstall(lock, args.Lockfile, tool.Name, &opts, result)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if args.Sbom != "" {
+		err = writeSbom(args.Sbom, components)
+		if err != nil {
+			return err
+		}
+	}
+
+	if args.Summary {
+		err = writeSummary(names, results)
+		if err != nil {
+			return err
+		}
+	}
+
+	if args.Output == "json" {
+		return json.NewEncoder(os.Stdout).Encode(results)
+	}
+	return nil
+}
+
+// reproducibleOptions sets modification times to the given seconds since the Unix epoch, or those
+// of SOURCE_DATE_EPOCH when not given
+func reproducibleOptions(sourceDateEpoch string) (*easyadd.ReproducibleOptions, error) {
+	if sourceDateEpoch == "" {
+		sourceDateEpoch = os.Getenv("SOURCE_DATE_EPOCH")
+	}
+	var seconds int64
+	if sourceDateEpoch != "" {
+		var err error
+		seconds, err = strconv.ParseInt(sourceDateEpoch, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("source date epoch '%s' must be a number of seconds", sourceDateEpoch)
+		}
+	}
+	return &easyadd.ReproducibleOptions{ModTime: time.Unix(seconds, 0)}, nil
+}
+
+// targetPlatform is the OS and architecture, named like GOOS and GOARCH, that the args install
+// binaries for
+func (args *getArgs) targetPlatform() (goos string, goarch string) {
+	goos, goarch = runtime.GOOS, runtime.GOARCH
+	if args.TargetOs != "" {
+		goos = args.TargetOs
+	}
+	if args.TargetArch != "" {
+		goarch = args.TargetArch
+	}
+	return goos, goarch
+}
+
+// The layouts of the installed files given by the layout flag
+const (
+	layoutFlat      = "flat"
+	layoutVersioned = "versioned"
+)
+
+// installOptions converts the args into install options
+func installOptions(args *getArgs) (easyadd.Options, error) {
+	opts := easyadd.Options{
+		From:             args.From,
+		ArchivePath:      args.FromArchive,
+		Parts:            args.FromPart,
+		File:             args.File,
+		All:              args.All,
+		Flatten:          args.Flatten,
+		StripTopDir:      args.StripTopDir,
+		Format:           extract.Format(args.Format),
+		Vars:             args.Var,
+		To:               args.To,
+		Root:             args.Root,
+		PreserveOwner:    args.PreserveOwner,
+		Mkdirs:           args.Mkdirs,
+		Owner:            args.Owner,
+		Checksum:         args.Checksum,
+		Preflight:        args.Preflight,
+		KeepArchiveDir:   args.KeepArchive,
+		CacheDir:         args.CacheDir,
+		ZipPassword:      args.ZipPassword,
+		AgeIdentityFiles: args.AgeIdentity,
+		Setcap:           args.Setcap,
+		SELinuxType:      args.SelinuxType,
+		TargetOS:         args.TargetOs,
+		TargetArch:       args.TargetArch,
+		RequireArchMatch: args.RequireArchMatch,
+		VerifyArgs:       strings.Fields(args.VerifyCmd),
+		JavaLauncher:     args.JavaLauncher,
+		Links:            args.Link,
+		ExecAfter:        args.ExecAfter,
+		NoPathWarning:    args.NoPathWarning,
+	}
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return opts, err
+	}
+	opts.HTTPClient = client
+	opts.AllowHTTP = allowHTTP()
+	opts.PreferHTTPS = networkArgs.PreferHttps
+	opts.StrictDigests = networkArgs.StrictDigests
+	opts.Policy, err = loadPolicy()
+	if err != nil {
+		return opts, err
+	}
+
+	match, err := extract.ParseMatch(args.Match)
+	if err != nil {
+		return opts, err
+	}
+	opts.Match = match
+	opts.Pick, err = extract.ParsePick(args.Pick)
+	if err != nil {
+		return opts, err
+	}
+	opts.PreferStatic = args.PreferStatic
+	if goos, goarch := args.targetPlatform(); goos != runtime.GOOS || goarch != runtime.GOARCH {
+		if args.VerifyCmd != "" || args.MinVersion != "" {
+			return opts, &usageError{fmt.Sprintf("verify-cmd and min-version can't run binaries built for %s/%s on this host", goos, goarch)}
+		}
+	}
+	switch args.Layout {
+	case "", layoutFlat:
+	case layoutVersioned:
+		opts.Versioned = &easyadd.VersionedLayout{Name: args.Name, Version: args.Var["version"]}
+	default:
+		return opts, &usageError{fmt.Sprintf("layout must be flat or versioned, not '%s'", args.Layout)}
+	}
+	opts.Mappings, err = parseMappings(args.Map)
+	if err != nil {
+		return opts, &usageError{err.Error()}
+	}
+	if args.MaxDownloadSize != "" {
+		opts.MaxDownloadSize, err = parseSize(args.MaxDownloadSize)
+		if err != nil {
+			return opts, &usageError{err.Error()}
+		}
+	}
+	if args.Format != "" {
+		opts.Format, err = extract.ParseFormat(args.Format)
+		if err != nil {
+			return opts, &usageError{err.Error()}
+		}
+	}
+	// an inline checksum, unless a template, is validated here while a file of sums is read later
+	if checksum.IsInline(args.Checksum) && !strings.Contains(args.Checksum, "{{") {
+		_, err = checksum.Parse(args.Checksum)
+		if err != nil {
+			return opts, &usageError{err.Error()}
+		}
+	}
+	if args.Integrity != "" {
+		if args.Checksum != "" {
+			return opts, &usageError{"only one of checksum and integrity can be given"}
+		}
+		if !checksum.IsIntegrity(args.Integrity) {
+			return opts, &usageError{fmt.Sprintf("integrity '%s' must be formatted as algorithm-base64, such as sha256-47DEQpj8...", args.Integrity)}
+		}
+		_, err = checksum.ParseIntegrity(args.Integrity)
+		if err != nil {
+			return opts, &usageError{err.Error()}
+		}
+		opts.Checksum = args.Integrity
+	}
+	if args.MinVersion != "" {
+		opts.MinVersion = &easyadd.MinVersionOptions{
+			Version: args.MinVersion,
+			Args:    strings.Fields(args.VersionCmd),
+			Regex:   args.VersionRegex,
+		}
+	}
+	if args.Reproducible {
+		opts.Reproducible, err = reproducibleOptions(args.SourceDateEpoch)
+		if err != nil {
+			return opts, err
+		}
+	} else if args.SourceDateEpoch != "" {
+		return opts, &usageError{"source-date-epoch only applies along with reproducible"}
+	}
+	if args.OwnerMap != "" {
+		if !args.PreserveOwner {
+			return opts, &usageError{"owner-map only applies along with preserve-owner"}
+		}
+		opts.OwnerMap, err = install.ParseOwnerMap(args.OwnerMap)
+		if err != nil {
+			return opts, &usageError{err.Error()}
+		}
+	}
+	if args.DirMode != "" {
+		mode, err := install.ParseDirMode(args.DirMode)
+		if err != nil {
+			return opts, &usageError{err.Error()}
+		}
+		opts.DirMode = &mode
+	}
+	if args.Provenance || args.ProvenanceUrl != "" {
+		opts.Provenance = &easyadd.ProvenanceOptions{
+			URL:        args.ProvenanceUrl,
+			BuilderID:  args.ProvenanceBuilder,
+			SourceRepo: args.ProvenanceRepo,
+		}
+	}
+	if args.Attestation || args.AttestationRepo != "" || args.AttestationWorkflow != "" {
+		opts.Attestation = &easyadd.AttestationOptions{
+			Repo:           args.AttestationRepo,
+			SignerWorkflow: args.AttestationWorkflow,
+		}
+	}
+	if args.SshAllowedSigners != "" {
+		opts.SSHSignature = &easyadd.SSHSignatureOptions{
+			AllowedSignersFile: args.SshAllowedSigners,
+			URL:                args.SshSigUrl,
+			Namespace:          args.SshNamespace,
+			Identity:           args.SshIdentity,
+		}
+	} else if args.SshSigUrl != "" {
+		return opts, &usageError{"ssh-allowed-signers is required to verify the SSH signature"}
+	}
+	if args.MinisignKey != "" {
+		opts.Minisign = &easyadd.MinisignOptions{PublicKey: args.MinisignKey}
+	}
+	if args.PgpKey != "" {
+		opts.PGP = &easyadd.PGPOptions{KeyringFile: args.PgpKey}
+	}
+	if args.Cosign || args.CosignRepo != "" {
+		opts.Cosign = &easyadd.CosignOptions{Repo: args.CosignRepo}
+	}
+	switch args.Verify {
+	case "":
+	case easyadd.VerifyAuto:
+		if opts.SSHSignature == nil && opts.Minisign == nil && opts.PGP == nil && opts.Cosign == nil {
+			return opts, &usageError{"verify auto requires at least one of ssh-allowed-signers, minisign-key, pgp-key, or cosign"}
+		}
+		opts.Verify = args.Verify
+	default:
+		return opts, &usageError{"verify must be auto when given"}
+	}
+	return opts, nil
+}
+
+func recordInstall(lock *lockfile.Lockfile, lockPath string, name string, opts *easyadd.Options, result *easyadd.Result) error {
+	lock.Tools[name] = lockEntry(opts, result)
+	err := lock.Save(lockPath)
+	if err != nil {
+		return err
+	}
+	log.Printf("I! Recorded %s in %s", name, lockPath)
+	return nil
+}
+
+// resolveTool sets the from, file, and vars of the args from the catalog entry of the tool
+// given as name or name@version, where the latest release is used when no version is given
+func resolveTool(ctx context.Context, args *getArgs, spec string) (_ *catalog.Tool, err error) {
+	ctx, span := telemetry.Start(ctx, "resolve")
+	defer func() {
+		span.End(err)
+	}()
+	span.SetAttribute("easy_add.tool", spec)
+
+	c, err := loadCatalog(ctx, args.Catalog)
+	if err != nil {
+		return nil, err
+	}
+	name, version := catalog.ParseSpec(spec)
+	tool, err := c.Lookup(name)
+	if err != nil {
+		return nil, &usageError{fmt.Sprintf("unknown command or tool '%s'", spec)}
+	}
+
+	if version == "" {
+		client, err := sharedHTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		version, err = tool.LatestVersion(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("I! Resolved latest version of %s to %s", name, version)
+	}
+
+	goos, goarch := args.targetPlatform()
+	vars := tool.PlatformVars(version, goos, goarch)
+	for k, v := range args.Var {
+		vars[k] = v
+	}
+	args.Var = vars
+	args.From = tool.From
+	args.File = tool.File
+	if args.AssetRegex != "" {
+		err = waitForAvailability(ctx, args.WaitFor, args.Poll, func() error {
+			args.From, err = pickReleaseAsset(ctx, tool, version, args.AssetRegex, args.Pick, vars)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else if args.Format == "" {
+		// the format of a picked asset is detected from its name instead
+		args.Format = tool.Format
+	}
+	if args.Checksum == "" {
+		args.Checksum = tool.PlatformChecksum(version, goos, goarch)
+	}
+	if args.Name == "" {
+		args.Name = name
+	}
+	return tool, nil
+}
+
+// resolveVersion sets the version var to the one found in the JSON document of version-from-url
+// or from the newest tag of git-tags or registry-tags, which also sets the tag var
+func resolveVersion(ctx context.Context, args *getArgs) error {
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return err
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+	opts := easyadd.Options{
+		HTTPClient:  client,
+		AllowHTTP:   allowHTTP(),
+		PreferHTTPS: networkArgs.PreferHttps,
+		Policy:      policy,
+	}
+	if args.Var == nil {
+		args.Var = make(map[string]string)
+	}
+
+	if args.GitTags != "" || args.RegistryTags != "" {
+		var tag, version string
+		if args.GitTags != "" {
+			tag, version, err = easyadd.LatestGitTag(ctx, opts, args.GitTags, args.TagPattern)
+		} else {
+			tag, version, err = easyadd.LatestRegistryTag(ctx, opts, args.RegistryTags, args.TagPattern)
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("I! Resolved version to %s from tag %s of %s", version, tag, args.GitTags+args.RegistryTags)
+		args.Var["tag"] = tag
+		args.Var["version"] = version
+		return nil
+	}
+
+	ref, err := easyadd.EvaluateTemplate(args.VersionFromUrl, args.Var)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate 'version-from-url': %w", err)
+	}
+	version, err := easyadd.VersionFromURL(ctx, opts, ref, args.VersionJsonpath)
+	if err != nil {
+		return err
+	}
+	log.Printf("I! Resolved version to %s from %s", version, ref)
+	args.Var["version"] = version
+	return nil
+}
+
+// pickReleaseAsset finds the URL of the one asset, of the tool's release of the version, whose
+// name matches the regex, which may reference the vars. When several match, the pick chooses
+// among them by name, or size for largest.
+func pickReleaseAsset(ctx context.Context, tool *catalog.Tool, version string, assetRegex string, pickName string,
+	vars map[string]string) (string, error) {
+
+	pick, err := extract.ParsePick(pickName)
+	if err != nil {
+		return "", err
+	}
+	assetRegex, err = easyadd.EvaluateTemplate(assetRegex, vars)
+	if err != nil {
+		return "", err
+	}
+	re, err := regexp.Compile(assetRegex)
+	if err != nil {
+		return "", &usageError{fmt.Sprintf("asset-regex is invalid: %v", err)}
+	}
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return "", err
+	}
+	assets, err := tool.ReleaseAssets(ctx, client, version)
+	if err != nil {
+		return "", err
+	}
+
+	var matched []catalog.Asset
+	for _, asset := range assets {
+		if re.MatchString(asset.Name) {
+			matched = append(matched, asset)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", &noMatchingAssetError{fmt.Sprintf("none of the %d assets of release %s of %s match '%s'",
+			len(assets), version, tool.Repo, assetRegex)}
+	case 1:
+		log.Printf("I! Picked release asset %s", matched[0].Name)
+		return matched[0].URL, nil
+	}
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Name < matched[j].Name })
+	names := make([]string, len(matched))
+	sizes := make(map[string]int64, len(matched))
+	urls := make(map[string]string, len(matched))
+	for i, asset := range matched {
+		names[i] = asset.Name
+		sizes[asset.Name] = asset.Size
+		urls[asset.Name] = asset.URL
+	}
+	picked := pick.Choose(names, sizes)
+	if picked == "" {
+		return "", fmt.Errorf("asset-regex '%s' matches more than one asset of release %s of %s: %s",
+			assetRegex, version, tool.Repo, strings.Join(names, ", "))
+	}
+	log.Printf("I! Picked release asset %s as %s of: %s", picked, pick, strings.Join(names, ", "))
+	return urls[picked], nil
+}
+
+// sbomComponent describes an installed file where tool, when known, provides its repo and license
+func sbomComponent(name string, version string, tool *catalog.Tool, result *easyadd.Result) sbom.Component {
+	component := sbom.Component{
+		Name:      name,
+		Version:   version,
+		SourceURL: result.From,
+		SHA256:    result.SHA256,
+		Path:      result.Path,
+	}
+	if absPath, err := filepath.Abs(result.Path); err == nil {
+		component.Path = absPath
+	}
+	if tool != nil {
+		component.Repo = tool.Repo
+		if tool.TagPrefix != "" && version != "" {
+			component.Tag = tool.TagPrefix + version
+		}
+		component.License = tool.License
+	}
+	return component
+}
+
+func writeSbom(path string, components []sbom.Component) error {
+	err := sbom.WriteCycloneDXFile(path, components, version)
+	if err != nil {
+		return err
+	}
+	log.Printf("I! Wrote SBOM to %s", path)
+	return nil
+}
+
+// writeSummary writes a table of the stats of each installed tool to where log messages go, so
+// that it stays out of a JSON result
+func writeSummary(names []string, results []*easyadd.Result) error {
+	w := tabwriter.NewWriter(logWriter.out, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tDOWNLOAD\tDOWNLOADED\tEXTRACT\tINSTALLED")
+	var total easyadd.Stats
+	for i, result := range results {
+		if result.Skipped {
+			_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\tskipped\n", names[i])
+			continue
+		}
+		stats := result.Stats
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", names[i],
+			formatDuration(stats.DownloadDuration), formatBytes(stats.DownloadedBytes),
+			formatDuration(stats.ExtractDuration), formatBytes(stats.InstalledBytes))
+		total.DownloadDuration += stats.DownloadDuration
+		total.DownloadedBytes += stats.DownloadedBytes
+		total.ExtractDuration += stats.ExtractDuration
+		total.InstalledBytes += stats.InstalledBytes
+	}
+	if len(results) > 1 {
+		_, _ = fmt.Fprintf(w, "TOTAL\t%s\t%s\t%s\t%s\n",
+			formatDuration(total.DownloadDuration), formatBytes(total.DownloadedBytes),
+			formatDuration(total.ExtractDuration), formatBytes(total.InstalledBytes))
+	}
+	return w.Flush()
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+// formatBytes gives the size in the largest of the units, as powers of 1024, that it reaches
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / unit
+	suffixes := []string{"KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for ; value >= unit && i < len(suffixes)-1; i++ {
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f %s", value, suffixes[i])
+}
+
+// lockEntry records an installed tool where the archive is pinned by its sha256 digest
+func lockEntry(opts *easyadd.Options, result *easyadd.Result) *lockfile.Entry {
+	entry := &lockfile.Entry{
+		From:         opts.From,
+		Parts:        opts.Parts,
+		File:         opts.File,
+		All:          opts.All,
+		Flatten:      opts.Flatten,
+		StripTopDir:  opts.StripTopDir,
+		Map:          mappingSpecs(opts.Mappings),
+		Format:       string(opts.Format),
+		JavaLauncher: opts.JavaLauncher,
+		Vars:         opts.Vars,
+		URL:          result.From,
+		Checksum:     "sha256:" + result.ArchiveSHA256,
+		To:           opts.To,
+		Path:         result.Path,
+		SHA256:       result.SHA256,
+		Links:        result.Links,
+	}
+	if opts.Versioned != nil {
+		entry.Layout = layoutVersioned
+	}
+	if entry.From == "" {
+		// installed from only a local archive
+		entry.From = result.From
+	}
+	if opts.Match != extract.MatchExact {
+		entry.Match = string(opts.Match)
+	}
+	if opts.Pick != extract.PickError {
+		entry.Pick = string(opts.Pick)
+	}
+	entry.PreferStatic = opts.PreferStatic
+	if absPath, err := filepath.Abs(result.Path); err == nil {
+		entry.Path = absPath
+	}
+	return entry
+}
+
+// parseMappings parses mappings given as entry=path
+func parseMappings(specs []string) ([]easyadd.Mapping, error) {
+	var mappings []easyadd.Mapping
+	for _, spec := range specs {
+		mapping, err := easyadd.ParseMapping(spec)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// mappingSpecs gives the mappings as they would be passed to --map
+func mappingSpecs(mappings []easyadd.Mapping) []string {
+	var specs []string
+	for _, mapping := range mappings {
+		specs = append(specs, mapping.String())
+	}
+	return specs
+}
+
+// parseSize parses a number of bytes with an optional K, M, or G suffix for powers of 1024
+func parseSize(value string) (int64, error) {
+	multiplier := int64(1)
+	number := strings.TrimSuffix(strings.ToUpper(value), "B")
+	switch {
+	case strings.HasSuffix(number, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(number, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(number, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		number = number[:len(number)-1]
+	}
+
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid size '%s', such as 200M", value)
+	}
+	if size > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("size '%s' is too large", value)
+	}
+	return size * multiplier, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/manifest"
+	"os"
+	"runtime"
+)
+
+type importArgs struct {
+	To            string `usage:"The [path] where the tools will be placed, which defaults to the to of the resolved manifest"`
+	Mkdirs        bool   `usage:"Attempt to create the directory path specified by to"`
+	NoPathWarning bool   `usage:"Don't warn when the directory of the installed files is not on the PATH"`
+	Output        string `usage:"The [format] of the results written to stdout: text or json" default:"text"`
+}
+
+func importCommand() *command {
+	args := &importArgs{}
+	return &command{
+		name:    "import",
+		usage:   "[flags] resolved-manifest [name...]",
+		summary: "Installs the tools of a manifest written by export for the current platform, as resolved",
+		args:    args,
+		network: true,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			if flagSet.NArg() == 0 {
+				return &usageError{"the path of a resolved manifest is required"}
+			}
+			switch args.Output {
+			case "text":
+			case "json":
+				// keep stdout clean for the JSON results
+				logWriter.out = os.Stderr
+				jsonErrors = true
+			default:
+				return &usageError{"output must be text or json"}
+			}
+			return importManifest(ctx, args, flagSet.Arg(0), flagSet.Args()[1:])
+		},
+	}
+}
+
+func importManifest(ctx context.Context, args *importArgs, path string, names []string) error {
+	resolved, err := manifest.LoadResolved(path)
+	if err != nil {
+		return err
+	}
+	tools, err := resolved.Select(names)
+	if err != nil {
+		return err
+	}
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return err
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+
+	to := args.To
+	if to == "" {
+		to = resolved.To
+	}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	var results []*easyadd.Result
+	for _, tool := range tools {
+		download := tool.Downloads[platform]
+		if download == nil {
+			return fmt.Errorf("%s was not resolved for %s", tool.Name, platform)
+		}
+
+		result, err := easyadd.Install(ctx, easyadd.Options{
+			From:          download.URL,
+			File:          download.File,
+			Format:        extract.Format(download.Format),
+			Checksum:      download.Checksum,
+			To:            to,
+			Mkdirs:        args.Mkdirs,
+			NoPathWarning: args.NoPathWarning,
+			AllowHTTP:     allowHTTP(),
+			PreferHTTPS:   networkArgs.PreferHttps,
+			Policy:        policy,
+			StrictDigests: networkArgs.StrictDigests,
+			HTTPClient:    client,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to install %s: %w", tool.Name, err)
+		}
+		err = auditInstall(tool.Name, result)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	if args.Output == "json" {
+		return json.NewEncoder(os.Stdout).Encode(results)
+	}
+	return nil
+}
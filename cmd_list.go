@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/lockfile"
+	"os"
+	"text/tabwriter"
+)
+
+type listArgs struct {
+	Lockfile string `usage:"The [path] of the lockfile" default:"easy-add.lock.yaml"`
+}
+
+func listCommand() *command {
+	args := &listArgs{}
+	return &command{
+		name:    "list",
+		usage:   "[flags]",
+		summary: "Lists the tools recorded in the lockfile",
+		args:    args,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			lock, err := lockfile.Load(args.Lockfile)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "NAME\tPATH\tURL")
+			for _, name := range lock.Names() {
+				entry := lock.Tools[name]
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", name, entry.Path, entry.URL)
+			}
+			return w.Flush()
+		},
+	}
+}
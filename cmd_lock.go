@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/catalog"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/install"
+	"github.com/itzg/easy-add/pkg/lockfile"
+	"github.com/itzg/easy-add/pkg/manifest"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type lockArgs struct {
+	Lockfile string            `usage:"The [path] of the lockfile, whose entries of the manifest's tools are also refreshed by update-checksums and update" default:"easy-add.lock.yaml"`
+	From     string            `usage:"[URL] of a tar.gz or zip archive to add to the lockfile. May contain Go template references to 'var' entries."`
+	Var      map[string]string `usage:"Sets variables that can be referenced in 'from' and 'file'. Format is [name=value]"`
+	File     string            `usage:"The [path] to executable within archive. May contain Go template references to 'var' entries."`
+	Name     string            `usage:"The [name] of the tool to add, which defaults to the base name of file"`
+
+	UpdateChecksums bool     `usage:"Refreshes the digests in the manifest for each of its platforms, such as after a version bump, rather than the lockfile"`
+	Update          bool     `usage:"Bumps each tool of the manifest to its newest release allowed by its constraint, refreshes its digests, and prints what changed"`
+	Manifest        string   `usage:"The [path] of the manifest used by update-checksums and update" default:"tools.yaml"`
+	Catalog         []string `usage:"[URL] or path of a catalog whose tools are added to, or replace, the built-in ones. Can be repeated."`
+}
+
+func lockCommand() *command {
+	args := &lockArgs{}
+	return &command{
+		name:    "lock",
+		usage:   "[flags] [name...]",
+		summary: "Resolves and pins archive checksums in the lockfile without installing",
+		args:    args,
+		network: true,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			if args.UpdateChecksums || args.Update {
+				return updateChecksums(ctx, args, flagSet.Args())
+			}
+
+			lock, err := lockfile.Load(args.Lockfile)
+			if err != nil {
+				return err
+			}
+
+			if args.From != "" {
+				if args.File == "" {
+					return &usageError{"file is required along with from"}
+				}
+				name := args.Name
+				if name == "" {
+					name = path.Base(args.File)
+				}
+				entry := &lockfile.Entry{
+					From: args.From,
+					File: args.File,
+					Vars: args.Var,
+				}
+				err = resolveEntry(ctx, entry)
+				if err != nil {
+					return err
+				}
+				lock.Tools[name] = entry
+			} else {
+				names, err := lock.Select(flagSet.Args())
+				if err != nil {
+					return err
+				}
+				for _, name := range names {
+					err = resolveEntry(ctx, lock.Tools[name])
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			return lock.Save(args.Lockfile)
+		},
+	}
+}
+
+// updateChecksums downloads the selected tools of the manifest for each of its platforms
+// and records their digests, along with the checksums of their entries in the lockfile. With
+// update, each tool is first moved to the newest release its constraint allows and the changes
+// are printed as a diff.
+func updateChecksums(ctx context.Context, args *lockArgs, names []string) error {
+	m, err := manifest.Load(args.Manifest)
+	if err != nil {
+		return err
+	}
+	tools, err := m.Select(names)
+	if err != nil {
+		return err
+	}
+	platforms, err := m.PlatformList()
+	if err != nil {
+		return err
+	}
+	lock, err := lockfile.Load(args.Lockfile)
+	if err != nil {
+		return err
+	}
+	c, err := loadCatalog(ctx, args.Catalog)
+	if err != nil {
+		return err
+	}
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return err
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+
+	lockChanged := false
+	for _, tool := range tools {
+		definition, err := tool.Definition(c)
+		if err != nil {
+			return err
+		}
+
+		previousVersion, previousDigests := tool.Version, tool.Digests
+		if args.Update {
+			tool.Version, err = newestAllowedVersion(ctx, client, tool, definition)
+			if err != nil {
+				return err
+			}
+		}
+
+		digests := make(map[string]string, len(platforms))
+		for _, platform := range platforms {
+			result, err := easyadd.Resolve(ctx, easyadd.Options{
+				From:          definition.From,
+				File:          definition.File,
+				Format:        extract.Format(definition.Format),
+				Vars:          definition.PlatformVars(tool.Version, platform[0], platform[1]),
+				AllowHTTP:     allowHTTP(),
+				PreferHTTPS:   networkArgs.PreferHttps,
+				Policy:        policy,
+				StrictDigests: networkArgs.StrictDigests,
+				HTTPClient:    client,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to resolve %s for %s/%s: %w", tool.Name, platform[0], platform[1], err)
+			}
+			digests[platform[0]+"/"+platform[1]] = "sha256:" + result.ArchiveSHA256
+		}
+
+		for platform, digest := range digests {
+			// a new version is expected to have new digests
+			if previous := tool.Digests[platform]; previous != "" && previous != digest && tool.Version == previousVersion {
+				log.Printf("I! Digest of %s %s for %s changed from %s", tool.Name, tool.Version, platform, previous)
+			}
+		}
+		tool.Digests = digests
+
+		var lockLines []string
+		if entry := lock.Tools[tool.Name]; entry != nil {
+			lockLines, err = updateLockEntry(ctx, tool.Name, entry, tool.Version)
+			if err != nil {
+				return err
+			}
+			lockChanged = true
+		}
+
+		if args.Update {
+			manifestLines := diffLines("  ", "version", previousVersion, tool.Version)
+			manifestLines = append(manifestLines, digestLines(previousDigests, tool.Digests)...)
+			if len(manifestLines) == 0 && len(lockLines) == 0 {
+				log.Printf("I! %s is up to date at %s", tool.Name, tool.Version)
+			}
+			printDiff(os.Stdout, args.Manifest, tool.Name, manifestLines)
+			printDiff(os.Stdout, args.Lockfile, tool.Name, lockLines)
+		}
+	}
+
+	err = m.Save(args.Manifest)
+	if err != nil {
+		return err
+	}
+	if lockChanged {
+		return lock.Save(args.Lockfile)
+	}
+	return nil
+}
+
+// updateLockEntry moves the lockfile entry of the tool to the version, when it declares a version
+// var, and re-resolves its URL and checksum, returning the lines of the diff of what changed
+func updateLockEntry(ctx context.Context, name string, entry *lockfile.Entry, version string) ([]string, error) {
+	previousVersion, previousURL, previousChecksum := entry.Vars["version"], entry.URL, entry.Checksum
+	if previousVersion != version {
+		if previousVersion == "" {
+			log.Printf("W! %s in the lockfile has no version var to move to %s, so only its checksum is refreshed", name, version)
+		} else {
+			vars := make(map[string]string, len(entry.Vars))
+			for k, v := range entry.Vars {
+				vars[k] = v
+			}
+			vars["version"] = version
+			entry.Vars = vars
+		}
+	}
+
+	err := resolveEntry(ctx, entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s of the lockfile: %w", name, err)
+	}
+
+	lines := diffLines("    ", "version", previousVersion, entry.Vars["version"])
+	lines = append(lines, diffLines("  ", "url", previousURL, entry.URL)...)
+	return append(lines, diffLines("  ", "checksum", previousChecksum, entry.Checksum)...), nil
+}
+
+// newestAllowedVersion finds the newest release of the tool allowed by its constraint, keeping
+// its current version when it can't be moved forward
+func newestAllowedVersion(ctx context.Context, client *http.Client, tool *manifest.Tool, definition *catalog.Tool) (string, error) {
+	constraint, err := manifest.ParseConstraint(tool.Constraint)
+	if err != nil {
+		return "", fmt.Errorf("tool %s has an %w", tool.Name, err)
+	}
+	if definition.Repo == "" {
+		log.Printf("I! Keeping %s at %s since it doesn't declare a repo to find newer releases in", tool.Name, tool.Version)
+		return tool.Version, nil
+	}
+
+	versions, err := definition.Versions(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	newest := constraint.Newest(versions)
+	switch {
+	case newest == "":
+		log.Printf("W! None of the recent releases of %s are allowed by its constraint '%s', keeping %s",
+			tool.Name, constraint, tool.Version)
+		return tool.Version, nil
+	case install.CompareVersions(newest, tool.Version) <= 0:
+		return tool.Version, nil
+	}
+	return newest, nil
+}
+
+// diffLines gives the removed and added lines of the key when its value changed
+func diffLines(indent string, key string, previous string, current string) []string {
+	if previous == current {
+		return nil
+	}
+	var lines []string
+	if previous != "" {
+		lines = append(lines, fmt.Sprintf("-%s%s: %s", indent, key, previous))
+	}
+	if current != "" {
+		lines = append(lines, fmt.Sprintf("+%s%s: %s", indent, key, current))
+	}
+	return lines
+}
+
+// digestLines gives the diff of the digests of a manifest tool by platform
+func digestLines(previous map[string]string, current map[string]string) []string {
+	platforms := make([]string, 0, len(current))
+	for platform := range current {
+		platforms = append(platforms, platform)
+	}
+	for platform := range previous {
+		if _, exists := current[platform]; !exists {
+			platforms = append(platforms, platform)
+		}
+	}
+	sort.Strings(platforms)
+
+	var lines []string
+	for _, platform := range platforms {
+		lines = append(lines, diffLines("    ", platform, previous[platform], current[platform])...)
+	}
+	return lines
+}
+
+// printDiff writes the changed lines of the tool within the file as a hunk, where nothing is
+// written when there are none
+func printDiff(w io.Writer, file string, name string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "@@ %s: %s @@\n%s\n", file, name, strings.Join(lines, "\n"))
+}
+
+// resolveEntry downloads the archive of the entry and pins its URL and checksum
+func resolveEntry(ctx context.Context, entry *lockfile.Entry) error {
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return err
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+	opts, err := entryOptions(entry)
+	if err != nil {
+		return err
+	}
+	opts.AllowHTTP = allowHTTP()
+	opts.PreferHTTPS = networkArgs.PreferHttps
+	opts.Policy = policy
+	opts.StrictDigests = networkArgs.StrictDigests
+	opts.HTTPClient = client
+	result, err := easyadd.Resolve(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	checksum := "sha256:" + result.ArchiveSHA256
+	if entry.Checksum != "" && entry.Checksum != checksum {
+		log.Printf("I! Checksum of %s changed from %s", result.From, entry.Checksum)
+	}
+	entry.URL = result.From
+	entry.Checksum = checksum
+	return nil
+}
+
+// entryOptions gives the options that resolve the archive and files recorded by the entry, as
+// they were given when it was locked
+func entryOptions(entry *lockfile.Entry) (easyadd.Options, error) {
+	opts := easyadd.Options{
+		From:         entry.From,
+		Parts:        entry.Parts,
+		File:         entry.File,
+		PreferStatic: entry.PreferStatic,
+		All:          entry.All,
+		Flatten:      entry.Flatten,
+		StripTopDir:  entry.StripTopDir,
+		Format:       extract.Format(entry.Format),
+		Vars:         entry.Vars,
+	}
+	var err error
+	opts.Match, err = extract.ParseMatch(entry.Match)
+	if err != nil {
+		return opts, err
+	}
+	opts.Pick, err = extract.ParsePick(entry.Pick)
+	if err != nil {
+		return opts, err
+	}
+	opts.Mappings, err = parseMappings(entry.Map)
+	if err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/itzg/easy-add/internal/githubapi"
+	"github.com/itzg/easy-add/pkg/lockfile"
+	"github.com/itzg/easy-add/pkg/manifest"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func toolArchive(t *testing.T, content string) []byte {
+	return tarGz(t, map[string]string{"tool": content})
+}
+
+// tarGz gives a tar.gz archive of the files, given as their content by name
+func tarGz(t *testing.T, files map[string]string) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		content := files[name]
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		if err == nil {
+			_, err = tw.Write([]byte(content))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := tw.Close()
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serveArchives serves the archives by path over plain HTTP, which is allowed until the test ends
+func serveArchives(t *testing.T, archives map[string][]byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if archive, exists := archives[r.URL.Path]; exists {
+			_, _ = w.Write(archive)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	networkArgs.AllowHttp = true
+	t.Cleanup(func() {
+		server.Close()
+		networkArgs.AllowHttp = false
+	})
+	return server
+}
+
+func TestLockResolvesSuffixMatch(t *testing.T) {
+	archive := tarGz(t, map[string]string{"tool-1.0/bin/tool": "1.0", "tool-1.0/README": "readme"})
+	server := serveArchives(t, map[string][]byte{"/tool.tar.gz": archive})
+
+	entry := &lockfile.Entry{
+		From:  server.URL + "/tool.tar.gz",
+		File:  "bin/tool",
+		Match: "suffix",
+	}
+	err := resolveEntry(context.Background(), entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sha256:" + digest(archive); entry.Checksum != want {
+		t.Errorf("checksum is %s rather than %s", entry.Checksum, want)
+	}
+	if entry.URL != entry.From {
+		t.Errorf("URL is %s rather than %s", entry.URL, entry.From)
+	}
+}
+
+func TestLockResolvesMappings(t *testing.T) {
+	archive := tarGz(t, map[string]string{"bin/tool": "1.0", "share/tool.1": "manual"})
+	server := serveArchives(t, map[string][]byte{"/tool.tar.gz": archive})
+
+	entry := &lockfile.Entry{
+		From: server.URL + "/tool.tar.gz",
+		Map:  []string{"bin/tool=/usr/local/bin/tool", "share/tool.1=/usr/local/share/man/man1/tool.1"},
+	}
+	err := resolveEntry(context.Background(), entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sha256:" + digest(archive); entry.Checksum != want {
+		t.Errorf("checksum is %s rather than %s", entry.Checksum, want)
+	}
+}
+
+func TestLockResolvesPartsWithinTopDir(t *testing.T) {
+	archive := tarGz(t, map[string]string{"tool-1.0/tool": "1.0", "tool-1.0/README": "readme"})
+	half := len(archive) / 2
+	server := serveArchives(t, map[string][]byte{
+		"/tool.tar.gz.part1": archive[:half],
+		"/tool.tar.gz.part2": archive[half:],
+	})
+
+	entry := &lockfile.Entry{
+		From:        server.URL + "/tool.tar.gz.part1",
+		Parts:       []string{server.URL + "/tool.tar.gz.part2"},
+		File:        "tool",
+		StripTopDir: true,
+		Format:      "tar.gz",
+	}
+	err := resolveEntry(context.Background(), entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sha256:" + digest(archive); entry.Checksum != want {
+		t.Errorf("checksum is %s rather than %s of the joined parts", entry.Checksum, want)
+	}
+}
+
+func TestLockUpdateRefreshesLockfile(t *testing.T) {
+	archives := map[string][]byte{
+		"1.7.4": toolArchive(t, "1.7.4"),
+		"1.7.5": toolArchive(t, "1.7.5"),
+		"1.8.0": toolArchive(t, "1.8.0"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/o/tool/releases":
+			_, _ = w.Write([]byte(`[{"tag_name":"v1.8.0"},{"tag_name":"v1.7.5"},{"tag_name":"v1.7.4"}]`))
+		case strings.HasPrefix(r.URL.Path, "/dl/tool-"):
+			version := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/dl/tool-"), ".tar.gz")
+			if archive, exists := archives[version]; exists {
+				_, _ = w.Write(archive)
+				return
+			}
+			http.NotFound(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	previousBaseURL := githubapi.BaseURL
+	githubapi.BaseURL = server.URL
+	networkArgs.AllowHttp = true
+	defer func() {
+		githubapi.BaseURL = previousBaseURL
+		networkArgs.AllowHttp = false
+	}()
+
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "tools.yaml")
+	lockfilePath := filepath.Join(dir, "easy-add.lock.yaml")
+	from := server.URL + "/dl/tool-{{.version}}.tar.gz"
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	err := ioutil.WriteFile(manifestPath, []byte(`platforms: [`+platform+`]
+tools:
+  - name: tool
+    repo: o/tool
+    tagPrefix: v
+    version: 1.7.4
+    constraint: ~1.7
+    from: `+from+`
+    file: tool
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := &lockfile.Lockfile{Tools: map[string]*lockfile.Entry{
+		"tool": {
+			From:     from,
+			File:     "tool",
+			Vars:     map[string]string{"version": "1.7.4"},
+			URL:      server.URL + "/dl/tool-1.7.4.tar.gz",
+			Checksum: "sha256:" + digest(archives["1.7.4"]),
+		},
+	}}
+	err = lock.Save(lockfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = updateChecksums(context.Background(), &lockArgs{
+		Lockfile: lockfilePath,
+		Manifest: manifestPath,
+		Update:   true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := lockfile.Load(lockfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := updated.Tools["tool"]
+	if entry == nil {
+		t.Fatal("tool is no longer in the lockfile")
+	}
+	if entry.Vars["version"] != "1.7.5" {
+		t.Errorf("lockfile version is %s rather than 1.7.5, the newest allowed by ~1.7", entry.Vars["version"])
+	}
+	if want := server.URL + "/dl/tool-1.7.5.tar.gz"; entry.URL != want {
+		t.Errorf("lockfile URL is %s rather than %s", entry.URL, want)
+	}
+	if want := "sha256:" + digest(archives["1.7.5"]); entry.Checksum != want {
+		t.Errorf("lockfile checksum is %s rather than %s", entry.Checksum, want)
+	}
+
+	m, err := manifest.Load(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tool := m.Lookup("tool"); tool.Version != "1.7.5" || tool.Digests[platform] != entry.Checksum {
+		t.Errorf("manifest has %s with digests %v rather than 1.7.5 with %s", tool.Version, tool.Digests, entry.Checksum)
+	}
+}
+
+func TestLockUpdateKeepsRecordedSettings(t *testing.T) {
+	archives := make(map[string][]byte)
+	for _, version := range []string{"1.7.4", "1.7.5"} {
+		archive := tarGz(t, map[string]string{
+			"tool-" + version + "/bin/tool":     version,
+			"tool-" + version + "/share/tool.1": "manual",
+		})
+		half := len(archive) / 2
+		archives["/dl/tool-"+version+".tar.gz"] = archive
+		archives["/parts/tool-"+version+".a"] = archive[:half]
+		archives["/parts/tool-"+version+".b"] = archive[half:]
+	}
+	archives["/repos/o/tool/releases"] = []byte(`[{"tag_name":"v1.7.5"},{"tag_name":"v1.7.4"}]`)
+	server := serveArchives(t, archives)
+
+	previousBaseURL := githubapi.BaseURL
+	githubapi.BaseURL = server.URL
+	defer func() {
+		githubapi.BaseURL = previousBaseURL
+	}()
+
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "tools.yaml")
+	lockfilePath := filepath.Join(dir, "easy-add.lock.yaml")
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	err := ioutil.WriteFile(manifestPath, []byte(`platforms: [`+platform+`]
+tools:
+  - name: tool
+    repo: o/tool
+    tagPrefix: v
+    version: 1.7.4
+    constraint: ~1.7
+    from: `+server.URL+`/dl/tool-{{.version}}.tar.gz
+    file: tool-{{.version}}/bin/tool
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := &lockfile.Lockfile{Tools: map[string]*lockfile.Entry{
+		"tool": {
+			From:        server.URL + "/parts/tool-{{.version}}.a",
+			Parts:       []string{server.URL + "/parts/tool-{{.version}}.b"},
+			File:        "tool",
+			Match:       "suffix",
+			StripTopDir: true,
+			Map:         []string{"share/tool.1=/usr/local/share/man/man1/tool.1"},
+			Format:      "tar.gz",
+			Vars:        map[string]string{"version": "1.7.4"},
+		},
+	}}
+	err = lock.Save(lockfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = updateChecksums(context.Background(), &lockArgs{
+		Lockfile: lockfilePath,
+		Manifest: manifestPath,
+		Update:   true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := lockfile.Load(lockfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := updated.Tools["tool"]
+	if entry.Vars["version"] != "1.7.5" {
+		t.Errorf("lockfile version is %s rather than 1.7.5", entry.Vars["version"])
+	}
+	if want := "sha256:" + digest(archives["/dl/tool-1.7.5.tar.gz"]); entry.Checksum != want {
+		t.Errorf("lockfile checksum is %s rather than %s of the joined parts", entry.Checksum, want)
+	}
+	if entry.Match != "suffix" || !entry.StripTopDir || len(entry.Parts) != 1 || len(entry.Map) != 1 {
+		t.Errorf("lockfile entry lost its recorded settings: %+v", entry)
+	}
+}
+
+func digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/internal/keychain"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+type loginArgs struct {
+	Username string `usage:"Send the token as the password of HTTP basic auth with this [username], such as for Artifactory or Nexus, rather than as a bearer token"`
+	Remove   bool   `usage:"Remove what is stored for the host instead"`
+}
+
+func loginCommand() *command {
+	args := &loginArgs{}
+	return &command{
+		name:    "login",
+		usage:   "[flags] host",
+		summary: "Stores a token, read from stdin, in the keychain of the operating system to send with requests of the host",
+		args:    args,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			if flagSet.NArg() != 1 {
+				return &usageError{"the host to log into is required"}
+			}
+			host := keychain.NormalizeHost(flagSet.Arg(0))
+
+			if args.Remove {
+				err := keychain.Delete(host)
+				if err != nil {
+					return fmt.Errorf("unable to remove the login of %s: %w", host, err)
+				}
+				log.Printf("I! Removed the login of %s", host)
+				return nil
+			}
+
+			token, err := readToken(host)
+			if err != nil {
+				return err
+			}
+			authorization := "Bearer " + token
+			if args.Username != "" {
+				authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(args.Username+":"+token))
+			}
+			err = keychain.Set(host, authorization)
+			if err != nil {
+				return fmt.Errorf("unable to store the login of %s: %w", host, err)
+			}
+			log.Printf("I! Stored the login of %s", host)
+			return nil
+		},
+	}
+}
+
+// readToken reads the token from stdin, prompting for it without echo when that's a terminal, so
+// that it's never part of the command line
+func readToken(host string) (string, error) {
+	var token string
+	var restore func()
+	if isTerminal(os.Stdin) {
+		// a character device that is not a terminal, such as /dev/null, is read like a pipe
+		restore, _ = disableEcho(os.Stdin)
+	}
+	if restore != nil {
+		fmt.Fprintf(os.Stderr, "Token for %s: ", host)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		restore()
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("unable to read token: %w", err)
+		}
+		token = line
+	} else {
+		content, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("unable to read token: %w", err)
+		}
+		token = string(content)
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", &usageError{"a token is required on stdin"}
+	}
+	return token, nil
+}
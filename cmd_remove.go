@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/lockfile"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+type removeArgs struct {
+	Lockfile string `usage:"The [path] of the lockfile" default:"easy-add.lock.yaml"`
+}
+
+func removeCommand() *command {
+	args := &removeArgs{}
+	return &command{
+		name:    "remove",
+		usage:   "[flags] name...",
+		summary: "Deletes installed tools and their links, and drops them from the lockfile",
+		args:    args,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			if flagSet.NArg() == 0 {
+				return &usageError{"at least one name is required"}
+			}
+
+			lock, err := lockfile.Load(args.Lockfile)
+			if err != nil {
+				return err
+			}
+			names, err := lock.Select(flagSet.Args())
+			if err != nil {
+				return err
+			}
+
+			for _, name := range names {
+				entry := lock.Tools[name]
+				paths := append(entry.Links, entry.Path)
+				if entry.JavaLauncher != "" && entry.Path != "" {
+					paths = append(paths, filepath.Join(filepath.Dir(entry.Path), easyadd.JavaLauncherName(entry.JavaLauncher)))
+				}
+				if entry.Layout == layoutVersioned {
+					if entry.JavaLauncher != "" {
+						paths = append(paths, filepath.Join(easyadd.VersionedBinDir(entry.To), easyadd.JavaLauncherName(entry.JavaLauncher)))
+					} else if entry.Path != "" {
+						paths = append(paths, filepath.Join(easyadd.VersionedBinDir(entry.To), filepath.Base(entry.Path)))
+					}
+					// every version of the tool along with its current link
+					toolDir := (&easyadd.VersionedLayout{Name: name}).ToolDir(entry.To)
+					err = os.RemoveAll(toolDir)
+					if err != nil {
+						return fmt.Errorf("failed to remove %s: %w", toolDir, err)
+					}
+				}
+				mappings, err := parseMappings(entry.Map)
+				if err != nil {
+					return err
+				}
+				for _, mapping := range mappings {
+					dest, err := easyadd.EvaluateTemplate(mapping.Dest, entry.Vars)
+					if err != nil {
+						return err
+					}
+					paths = append(paths, dest)
+				}
+				for _, p := range paths {
+					if p == "" {
+						continue
+					}
+					err = os.Remove(p)
+					if err != nil && !os.IsNotExist(err) {
+						return fmt.Errorf("failed to remove %s: %w", p, err)
+					}
+				}
+				delete(lock.Tools, name)
+				log.Printf("I! Removed %s", name)
+			}
+
+			return lock.Save(args.Lockfile)
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/lockfile"
+	"log"
+)
+
+type upgradeArgs struct {
+	Lockfile string            `usage:"The [path] of the lockfile" default:"easy-add.lock.yaml"`
+	Var      map[string]string `usage:"Sets variables, such as a new version, over those recorded for the tools. Format is [name=value]"`
+}
+
+func upgradeCommand() *command {
+	args := &upgradeArgs{}
+	return &command{
+		name:    "upgrade",
+		usage:   "[flags] [name...]",
+		summary: "Reinstalls tools from the lockfile with updated variables and records the new checksums",
+		args:    args,
+		network: true,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			lock, err := lockfile.Load(args.Lockfile)
+			if err != nil {
+				return err
+			}
+			names, err := lock.Select(flagSet.Args())
+			if err != nil {
+				return err
+			}
+			client, err := sharedHTTPClient()
+			if err != nil {
+				return err
+			}
+			policy, err := loadPolicy()
+			if err != nil {
+				return err
+			}
+
+			for _, name := range names {
+				entry := lock.Tools[name]
+				vars := make(map[string]string)
+				for k, v := range entry.Vars {
+					vars[k] = v
+				}
+				for k, v := range args.Var {
+					vars[k] = v
+				}
+
+				opts, err := entryOptions(entry)
+				if err != nil {
+					return err
+				}
+				opts.Vars = vars
+				opts.To = entry.To
+				opts.JavaLauncher = entry.JavaLauncher
+				opts.Links = entry.Links
+				opts.AllowHTTP = allowHTTP()
+				opts.PreferHTTPS = networkArgs.PreferHttps
+				opts.Policy = policy
+				opts.StrictDigests = networkArgs.StrictDigests
+				opts.HTTPClient = client
+				if entry.Layout == layoutVersioned {
+					opts.Versioned = &easyadd.VersionedLayout{Name: name, Version: vars["version"]}
+				}
+				result, err := easyadd.Install(ctx, opts)
+				if err != nil {
+					return err
+				}
+				err = auditInstall(name, result)
+				if err != nil {
+					return err
+				}
+				lock.Tools[name] = lockEntry(&opts, result)
+				log.Printf("I! Upgraded %s from %s", name, result.From)
+			}
+
+			return lock.Save(args.Lockfile)
+		},
+	}
+}
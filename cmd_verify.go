@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/lockfile"
+	"io"
+	"log"
+	"os"
+)
+
+type verifyArgs struct {
+	Lockfile string `usage:"The [path] of the lockfile" default:"easy-add.lock.yaml"`
+}
+
+func verifyCommand() *command {
+	args := &verifyArgs{}
+	return &command{
+		name:    "verify",
+		usage:   "[flags] [name...]",
+		summary: "Confirms the installed tools still match the digests recorded in the lockfile",
+		args:    args,
+		run: func(ctx context.Context, flagSet *flag.FlagSet) error {
+			lock, err := lockfile.Load(args.Lockfile)
+			if err != nil {
+				return err
+			}
+			names, err := lock.Select(flagSet.Args())
+			if err != nil {
+				return err
+			}
+
+			failed := 0
+			for _, name := range names {
+				entry := lock.Tools[name]
+				if entry.Path == "" {
+					log.Printf("W! %s has not been installed", name)
+					continue
+				}
+
+				digest, err := fileSHA256(entry.Path)
+				if os.IsNotExist(err) {
+					log.Printf("E! MISSING %s at %s", name, entry.Path)
+					failed++
+				} else if err != nil {
+					return err
+				} else if digest != entry.SHA256 {
+					log.Printf("E! MISMATCH %s at %s has sha256:%s", name, entry.Path, digest)
+					failed++
+				} else {
+					log.Printf("I! OK %s at %s", name, entry.Path)
+				}
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d tools failed verification", failed, len(names))
+			}
+			return nil
+		},
+	}
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
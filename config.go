@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/itzg/go-flagsfiller"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// configPaths are the config files that provide defaults for flags, in increasing precedence
+func configPaths() []string {
+	var paths []string
+	if runtime.GOOS != "windows" {
+		paths = append(paths, "/etc/easy-add/config.yaml")
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "easy-add", "config.yaml"))
+	}
+	return paths
+}
+
+// applyConfigFiles sets flags from the entries of any config files that exist, where each
+// entry is named like the flag and an entry of a later file replaces that of an earlier one.
+// Flags set by environment variable are left as is and command line arguments, which are parsed
+// afterwards, take precedence over both, replacing rather than adding to config file lists.
+// Values from config files aren't seen by flagWasSet. Entries for flags of other commands are
+// skipped, but those that are not in knownNames are rejected. The targets are the structs the
+// flags were declared from.
+func applyConfigFiles(flagSet *flag.FlagSet, knownNames map[string]bool, targets ...interface{}) error {
+	values := make(map[string][]string)
+	sources := make(map[string]string)
+	for _, configPath := range configPaths() {
+		content, err := ioutil.ReadFile(configPath)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("unable to read config file: %w", err)
+		}
+
+		var config map[string]interface{}
+		err = yaml.Unmarshal(content, &config)
+		if err != nil {
+			return fmt.Errorf("unable to parse config file %s: %w", configPath, err)
+		}
+
+		for name, value := range config {
+			if flagSet.Lookup(name) == nil {
+				if !knownNames[name] {
+					return fmt.Errorf("unknown setting '%s' in config file %s", name, configPath)
+				}
+				continue
+			}
+			values[name] = configValues(value)
+			sources[name] = configPath
+		}
+	}
+
+	fields := flagFields(targets)
+	for name, vs := range values {
+		if _, exists := os.LookupEnv(envName(name)); exists {
+			continue
+		}
+
+		f := flagSet.Lookup(name)
+		// setting the value rather than the flag leaves it looking unset to flagWasSet
+		for _, v := range vs {
+			err := f.Value.Set(v)
+			if err != nil {
+				return fmt.Errorf("invalid setting '%s' in config file %s: %w", name, sources[name], err)
+			}
+		}
+		if field, exists := fields[name]; exists && len(vs) > 0 &&
+			(field.Kind() == reflect.Slice || field.Kind() == reflect.Map) {
+			f.Value = &configuredValue{Value: f.Value, field: field}
+		}
+	}
+	return nil
+}
+
+// flagFields finds the fields of the structs that flags are declared from by the flag name
+// flagsfiller gives them
+func flagFields(targets []interface{}) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	for _, target := range targets {
+		v := reflect.ValueOf(target).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			fields[flagsfiller.DefaultFieldRenamer(v.Type().Field(i).Name)] = v.Field(i)
+		}
+	}
+	return fields
+}
+
+// configuredValue is a list or map flag holding the values of a config file, which are cleared
+// when the flag is first given on the command line rather than being added to
+type configuredValue struct {
+	flag.Value
+	field   reflect.Value
+	cleared bool
+}
+
+func (c *configuredValue) Set(value string) error {
+	if !c.cleared {
+		c.cleared = true
+		if c.field.Kind() == reflect.Map {
+			// the flag refers to the map itself, so its entries are removed
+			for _, key := range c.field.MapKeys() {
+				c.field.SetMapIndex(key, reflect.Value{})
+			}
+		} else {
+			c.field.Set(reflect.Zero(c.field.Type()))
+		}
+	}
+	return c.Value.Set(value)
+}
+
+func (c *configuredValue) String() string {
+	// the flag package calls this on a zero value to find if the default is empty
+	if c.Value == nil {
+		return ""
+	}
+	return c.Value.String()
+}
+
+// envName is the environment variable that flagsfiller sets the named flag from
+func envName(flagName string) string {
+	return "EASY_ADD_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// configValues converts a config file value into one or more flag values, where lists
+// become repeated values and maps become name=value entries
+func configValues(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	case map[string]interface{}:
+		var values []string
+		for k, item := range v {
+			values = append(values, fmt.Sprintf("%s=%v", k, item))
+		}
+		sort.Strings(values)
+		return values
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withUserConfig writes the content as the config file of the user, skipping the test when a
+// system config file would also apply
+func withUserConfig(t *testing.T, content string) {
+	if _, err := os.Stat("/etc/easy-add/config.yaml"); err == nil {
+		t.Skip("a system config file exists")
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(filepath.Join(userConfigDir, "easy-add"), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(userConfigDir, "easy-add", "config.yaml"), []byte(content), 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigValuesAreNotSetFlags(t *testing.T) {
+	withUserConfig(t, "to: /opt/bin\ncatalog: [a.yaml, b.yaml]\n")
+	cmd := getCommand()
+	flagSet, err := newFlagSet(cmd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = flagSet.Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := cmd.args.(*getArgs)
+	if args.To != "/opt/bin" {
+		t.Errorf("to is %s rather than that of the config file", args.To)
+	}
+	if flagWasSet(flagSet, "to") {
+		t.Error("to from the config file was reported as given on the command line")
+	}
+	if want := []string{"a.yaml", "b.yaml"}; !reflect.DeepEqual(args.Catalog, want) {
+		t.Errorf("catalog is %v rather than %v", args.Catalog, want)
+	}
+}
+
+func TestCommandLineReplacesConfigLists(t *testing.T) {
+	withUserConfig(t, "catalog: [a.yaml, b.yaml]\nvar:\n  a: 1\n  b: 2\n")
+	cmd := getCommand()
+	flagSet, err := newFlagSet(cmd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = flagSet.Parse([]string{"--catalog", "c.yaml", "--catalog", "d.yaml", "--var", "c=3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := cmd.args.(*getArgs)
+	if want := []string{"c.yaml", "d.yaml"}; !reflect.DeepEqual(args.Catalog, want) {
+		t.Errorf("catalog is %v rather than %v", args.Catalog, want)
+	}
+	if want := map[string]string{"c": "3"}; !reflect.DeepEqual(args.Var, want) {
+		t.Errorf("var is %v rather than %v", args.Var, want)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin)
+
+package main
+
+import "os"
+
+// disableEcho leaves the echo on since turning it off isn't supported on this platform
+func disableEcho(*os.File) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,27 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// disableEcho turns off the echo of what's typed into the terminal, returning how to restore it
+func disableEcho(f *os.File) (func(), error) {
+	fd := int(f.Fd())
+	termios, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, err
+	}
+	original := *termios
+	termios.Lflag &^= unix.ECHO
+	termios.Lflag |= unix.ICANON | unix.ISIG
+	err = unix.IoctlSetTermios(fd, ioctlWriteTermios, termios)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		_ = unix.IoctlSetTermios(fd, ioctlWriteTermios, &original)
+	}, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"io"
+)
+
+// The exit codes of easy-add, which are documented in the README for wrapper scripts to react to
+const (
+	exitFailure = 1
+	exitUsage   = 2
+	// exitDownload is a download that failed without a response, such as being unable to connect
+	exitDownload            = 3
+	exitDownloadClientError = 4
+	exitDownloadServerError = 5
+	exitChecksum            = 6
+	exitVerification        = 7
+	exitNotFound            = 8
+	exitFilesystem          = 9
+	exitPolicy              = 10
+	exitInterrupted         = 130
+)
+
+// exitCode determines the exit code for the error of a command
+func exitCode(err error) int {
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		return exitUsage
+	}
+	// check exits with 1 when the archive doesn't exist, rather than by the response of its download
+	var missingErr *missingError
+	if errors.As(err, &missingErr) {
+		return exitFailure
+	}
+
+	switch easyadd.Categorize(err) {
+	case easyadd.CategoryDownload:
+		var statusErr *fetch.StatusError
+		if !errors.As(err, &statusErr) {
+			return exitDownload
+		}
+		if statusErr.StatusCode/100 == 4 {
+			return exitDownloadClientError
+		}
+		return exitDownloadServerError
+	case easyadd.CategoryChecksum:
+		return exitChecksum
+	case easyadd.CategoryVerification:
+		return exitVerification
+	case easyadd.CategoryNotFound:
+		return exitNotFound
+	case easyadd.CategoryFilesystem:
+		return exitFilesystem
+	case easyadd.CategoryPolicy:
+		return exitPolicy
+	default:
+		return exitFailure
+	}
+}
+
+// jsonErrors reports the error of a command with writeJSONError, such as when its result is JSON
+var jsonErrors bool
+
+// jsonError is how an error is reported for orchestration tooling to triage
+type jsonError struct {
+	Code     int    `json:"code"`
+	Category string `json:"category"`
+	// Status is that of the unsuccessful HTTP response of a download
+	Status  int    `json:"status,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Entry   string `json:"entry,omitempty"`
+	Message string `json:"message"`
+}
+
+// writeJSONError writes the error, and the code that easy-add exits with, as a JSON object
+func writeJSONError(out io.Writer, err error, code int) {
+	reported := jsonError{
+		Code:     code,
+		Category: string(easyadd.Categorize(err)),
+		Message:  err.Error(),
+	}
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		reported.Category = "usage"
+	} else if code == exitInterrupted {
+		reported.Category = "interrupted"
+	}
+	var installErr *easyadd.Error
+	if errors.As(err, &installErr) {
+		reported.URL = installErr.URL
+		reported.Entry = installErr.Entry
+	}
+	var statusErr *fetch.StatusError
+	if errors.As(err, &statusErr) {
+		reported.Status = statusErr.StatusCode
+	}
+	_ = json.NewEncoder(out).Encode(reported)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/checksum"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"os"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"usage", &usageError{"only one tool can be given"}, exitUsage},
+		{"wrapped usage", fmt.Errorf("get: %w", &usageError{"bad"}), exitUsage},
+		{"missing", &missingError{errors.New("gone")}, exitFailure},
+		{"connect", &easyadd.Error{Category: easyadd.CategoryDownload, Err: errors.New("refused")}, exitDownload},
+		{"client status", &easyadd.Error{Category: easyadd.CategoryDownload, Err: &fetch.StatusError{StatusCode: 404}}, exitDownloadClientError},
+		{"server status", &easyadd.Error{Category: easyadd.CategoryDownload, Err: &fetch.StatusError{StatusCode: 503}}, exitDownloadServerError},
+		{"checksum", &checksum.MismatchError{}, exitChecksum},
+		{"not found", fmt.Errorf("tool: %w", extract.ErrNotFound), exitNotFound},
+		{"filesystem", &os.PathError{Op: "open", Path: "/x", Err: os.ErrPermission}, exitFilesystem},
+		{"policy", &easyadd.Error{Category: easyadd.CategoryPolicy, Err: errors.New("refused")}, exitPolicy},
+		{"other", errors.New("failed"), exitFailure},
+	}
+	for _, test := range tests {
+		if got := exitCode(test.err); got != test.want {
+			t.Errorf("%s: exit code is %d rather than %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMalformedArgsExitWithUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args getArgs
+	}{
+		{"short checksum", getArgs{Checksum: "sha256:00"}},
+		{"non-hex checksum", getArgs{Checksum: "sha256:zz"}},
+		{"malformed integrity", getArgs{Integrity: "sha256-xx"}},
+		{"unknown format", getArgs{Format: "rar"}},
+		{"malformed size", getArgs{MaxDownloadSize: "200X"}},
+		{"overflowing size", getArgs{MaxDownloadSize: "99999999999G"}},
+		{"malformed dir mode", getArgs{DirMode: "rwx"}},
+	}
+	for _, test := range tests {
+		args := test.args
+		args.From = "https://example.com/tool.tar.gz"
+		args.File = "tool"
+		_, err := installOptions(&args)
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+			continue
+		}
+		code := exitCode(err)
+		if code != exitUsage {
+			t.Errorf("%s: exit code is %d rather than %d for %v", test.name, code, exitUsage, err)
+		}
+
+		var out bytes.Buffer
+		writeJSONError(&out, err, code)
+		var reported jsonError
+		if err := json.Unmarshal(out.Bytes(), &reported); err != nil {
+			t.Fatal(err)
+		}
+		if reported.Category != "usage" || reported.Code != exitUsage {
+			t.Errorf("%s: reported as %s with %d", test.name, reported.Category, reported.Code)
+		}
+	}
+}
+
+func TestValidArgsAreNotUsageErrors(t *testing.T) {
+	args := &getArgs{
+		From:     "https://example.com/tool.tar.gz",
+		File:     "tool",
+		Format:   "tar.gz",
+		Checksum: "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}
+	if _, err := installOptions(args); err != nil {
+		t.Errorf("valid args failed with %v", err)
+	}
+	args.Checksum = "sha256:{{.sha}}"
+	if _, err := installOptions(args); err != nil {
+		t.Errorf("templated checksum failed with %v", err)
+	}
+}
@@ -1,5 +1,12 @@
-module easy-add
+module github.com/itzg/easy-add
 
-go 1.12
+go 1.20
 
-require github.com/itzg/go-flagsfiller v1.14.0
+require (
+	github.com/itzg/go-flagsfiller v1.14.0
+	golang.org/x/crypto v0.33.0
+	golang.org/x/sys v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/iancoleman/strcase v0.3.0 // indirect
@@ -0,0 +1,24 @@
+// Package ctxio provides I/O helpers that observe context cancellation
+package ctxio
+
+import (
+	"context"
+	"io"
+)
+
+// Reader stops reading from the wrapped reader once the context is cancelled
+type Reader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func NewReader(ctx context.Context, r io.Reader) *Reader {
+	return &Reader{ctx: ctx, r: r}
+}
+
+func (c *Reader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,70 @@
+// Package dirlock serializes the installs of concurrent processes into the same directory, such
+// as those of parallel CI provisioning
+package dirlock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPath is the file locked for the directory, which is kept in the temporary directory, rather
+// than the directory itself, so that installed trees, such as those of images, don't include it.
+// It's left in place since removing it would let a waiting process lock a file that others no
+// longer open.
+func lockPath(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(dir)))
+	return filepath.Join(os.TempDir(), "easy-add-"+hex.EncodeToString(sum[:8])+".lock")
+}
+
+// pollInterval is how often a lock held by another process is tried again
+const pollInterval = 100 * time.Millisecond
+
+// Lock waits until holding an exclusive advisory lock of the directory, or the context is done,
+// and returns what releases it. Where locking isn't supported, it isn't held.
+func Lock(ctx context.Context, dir string) (func(), error) {
+	path := lockPath(dir)
+	// readable by any user, since locking doesn't require writing
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if os.IsPermission(err) {
+		// such as one created by another user in a sticky temporary directory
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock %s: %w", dir, err)
+	}
+
+	waiting := false
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("unable to lock %s: %w", dir, err)
+		}
+		if locked {
+			return func() {
+				_ = unlock(f)
+				_ = f.Close()
+			}, nil
+		}
+
+		if !waiting {
+			log.Printf("I! Waiting for another install into %s to finish", dir)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package dirlock
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+)
+
+// tryLock takes an exclusive lock of the first byte of the file, returning false when another
+// process holds it
+func tryLock(f *os.File) (bool, error) {
+	overlapped := &windows.Overlapped{}
+	err := windows.LockFileEx(windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, overlapped)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &windows.Overlapped{})
+}
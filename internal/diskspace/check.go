@@ -0,0 +1,23 @@
+// Package diskspace checks for available space ahead of writing files
+package diskspace
+
+import (
+	"fmt"
+	"log"
+)
+
+// Check fails early, rather than leaving a partially written file, when the
+// filesystem containing dir cannot hold the given number of bytes
+func Check(dir string, required int64) error {
+	available, err := availableDiskSpace(dir)
+	if err != nil {
+		log.Printf("W! Skipping free disk space check: %v", err)
+		return nil
+	}
+
+	if required > 0 && uint64(required) > available {
+		return fmt.Errorf("insufficient disk space in %s: need %d bytes but only %d are available",
+			dir, required, available)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || windows)
+
+package diskspace
+
+import "errors"
+
+func availableDiskSpace(string) (uint64, error) {
+	return 0, errors.New("free disk space check is not supported on this platform")
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package diskspace
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// availableDiskSpace returns the number of bytes available to an unprivileged user in the
+// filesystem containing dir
+func availableDiskSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(dir, &stat)
+	if err != nil {
+		return 0, fmt.Errorf("unable to stat filesystem of %s: %w", dir, err)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,22 @@
+package diskspace
+
+import (
+	"fmt"
+	"golang.org/x/sys/windows"
+)
+
+// availableDiskSpace returns the number of bytes available to the current user in the
+// volume containing dir
+func availableDiskSpace(dir string) (uint64, error) {
+	dirPtr, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var available uint64
+	err = windows.GetDiskFreeSpaceEx(dirPtr, &available, nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get free space of %s: %w", dir, err)
+	}
+	return available, nil
+}
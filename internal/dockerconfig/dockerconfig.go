@@ -0,0 +1,161 @@
+// Package dockerconfig looks up the registry credentials of the Docker CLI, such as from docker login
+package dockerconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// HelperPrefix is the prefix of the credential helper executables named by the config
+const HelperPrefix = "docker-credential-"
+
+// dockerHubServer is how the credentials of Docker Hub are keyed, rather than by its registry host
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// Credentials are those of a registry, where IdentityToken, when set, is an OAuth2 refresh token
+// used in place of the password
+type Credentials struct {
+	Username      string
+	Password      string
+	IdentityToken string
+}
+
+type config struct {
+	Auths       map[string]authEntry `json:"auths"`
+	CredsStore  string               `json:"credsStore"`
+	CredHelpers map[string]string    `json:"credHelpers"`
+}
+
+type authEntry struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// Path is that of the config file, which is within DOCKER_CONFIG when set
+func Path() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// Lookup finds the credentials of the registry host, such as ghcr.io, from the credential helper
+// the config declares for it, its auths entry, or otherwise the credsStore. It returns nil when
+// there's no config or it has no credentials for the registry.
+func Lookup(ctx context.Context, registry string) (*Credentials, error) {
+	configPath := Path()
+	if configPath == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read Docker config: %w", err)
+	}
+	var c config
+	err = json.Unmarshal(content, &c)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse Docker config %s: %w", configPath, err)
+	}
+
+	server := registry
+	if registry == "docker.io" || registry == "registry-1.docker.io" || registry == "index.docker.io" {
+		server = dockerHubServer
+	}
+
+	if helper := c.CredHelpers[server]; helper != "" {
+		return runHelper(ctx, helper, server)
+	}
+	for key, entry := range c.Auths {
+		if normalize(key) != normalize(server) {
+			continue
+		}
+		creds, err := entry.credentials()
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth of %s in Docker config %s: %w", key, configPath, err)
+		}
+		if creds != nil {
+			return creds, nil
+		}
+	}
+	if c.CredsStore != "" {
+		return runHelper(ctx, c.CredsStore, server)
+	}
+	return nil, nil
+}
+
+// normalize reduces a key of auths, which may be a URL such as https://ghcr.io/v1/, to its host
+func normalize(server string) string {
+	if server == dockerHubServer {
+		return server
+	}
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	if i := strings.Index(server, "/"); i >= 0 {
+		server = server[:i]
+	}
+	return strings.ToLower(server)
+}
+
+func (e authEntry) credentials() (*Credentials, error) {
+	creds := &Credentials{Username: e.Username, Password: e.Password, IdentityToken: e.IdentityToken}
+	if e.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("auth is not of the form username:password")
+		}
+		creds.Username, creds.Password = parts[0], parts[1]
+	}
+	if creds.Password == "" && creds.IdentityToken == "" {
+		// such as an entry that only marks the registry as logged into with a credsStore
+		return nil, nil
+	}
+	return creds, nil
+}
+
+// runHelper gets the credentials of the server from the credential helper with the given suffix
+func runHelper(ctx context.Context, helper string, server string) (*Credentials, error) {
+	cmd := exec.CommandContext(ctx, HelperPrefix+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(string(output)+stderr.String(), "credentials not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("credential helper %s%s failed for %s: %w", HelperPrefix, helper, server, err)
+	}
+
+	var result struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	err = json.Unmarshal(output, &result)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the output of credential helper %s%s: %w", HelperPrefix, helper, err)
+	}
+	// helpers indicate that the secret is an identity token with this username
+	if result.Username == "<token>" {
+		return &Credentials{IdentityToken: result.Secret}, nil
+	}
+	return &Credentials{Username: result.Username, Password: result.Secret}, nil
+}
@@ -0,0 +1,74 @@
+package githubapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// releaseDownloadPath matches the path of a release asset download, capturing the repo, tag,
+// and asset name
+var releaseDownloadPath = regexp.MustCompile(`^/([^/]+/[^/]+)/releases/download/(.+)/([^/]+)$`)
+
+// PrivateAssets wraps the transport so that downloads of release assets that aren't found, as is
+// the case for those of private repositories without a login, are retried through the API with
+// the Token, when there is one
+func PrivateAssets(next http.RoundTripper) http.RoundTripper {
+	return &privateAssetTransport{next: next}
+}
+
+type privateAssetTransport struct {
+	next http.RoundTripper
+}
+
+func (t *privateAssetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusNotFound ||
+		(req.Method != http.MethodGet && req.Method != http.MethodHead) ||
+		!strings.EqualFold(req.URL.Host, ghHost()) {
+		return resp, err
+	}
+	m := releaseDownloadPath.FindStringSubmatch(req.URL.Path)
+	if m == nil || Token() == "" {
+		return resp, err
+	}
+
+	assetURL, err := t.assetURL(req, m[1], m[2], m[3])
+	if err != nil {
+		log.Printf("D! Unable to find %s through the GitHub API: %v", req.URL, err)
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+
+	apiReq, err := http.NewRequestWithContext(req.Context(), req.Method, assetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	apiReq.Header.Set("Accept", "application/octet-stream")
+	apiReq.Header.Set("Authorization", "Bearer "+Token())
+	log.Printf("D! Retrieving %s through the GitHub API since it wasn't found without a login", req.URL)
+	return t.next.RoundTrip(apiReq)
+}
+
+// assetURL finds the API URL of the asset of the release
+func (t *privateAssetTransport) assetURL(req *http.Request, repo string, tag string, name string) (string, error) {
+	var release struct {
+		Assets []struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"assets"`
+	}
+	err := Get(req.Context(), &http.Client{Transport: t.next}, "/repos/"+repo+"/releases/tags/"+url.PathEscape(tag), &release)
+	if err != nil {
+		return "", err
+	}
+	for _, asset := range release.Assets {
+		if asset.Name == name {
+			return asset.URL, nil
+		}
+	}
+	return "", fmt.Errorf("release %s of %s has no asset named %s", tag, repo, name)
+}
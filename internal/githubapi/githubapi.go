@@ -0,0 +1,126 @@
+// Package githubapi makes requests of the GitHub REST API
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// BaseURL is of the GitHub REST API
+var BaseURL = "https://api.github.com"
+
+// MaxRateLimitWait is the longest to wait for an exceeded rate limit to reset before
+// retrying, where zero fails right away
+var MaxRateLimitWait time.Duration
+
+// Get decodes the JSON response of the API path into v. The Token, such as that of the
+// GITHUB_TOKEN environment variable, is used when there is one to avoid anonymous rate limits.
+func Get(ctx context.Context, client *http.Client, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	for {
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		limit := parseRateLimit(resp)
+		if limit != nil {
+			log.Printf("D! GitHub API rate limit has %d of %d requests remaining until %s",
+				limit.remaining, limit.limit, limit.reset.Format(time.RFC3339))
+		}
+
+		if limit != nil && limit.exceeded(resp) {
+			_ = resp.Body.Close()
+			wait := time.Until(limit.reset).Round(time.Second) + time.Second
+			if wait > MaxRateLimitWait {
+				return fmt.Errorf("GitHub API rate limit of %d requests is exceeded until %s, which can be raised by setting GITHUB_TOKEN",
+					limit.limit, limit.reset.Format(time.RFC3339))
+			}
+
+			log.Printf("W! GitHub API rate limit is exceeded, waiting %s for it to reset", wait)
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			continue
+		}
+
+		return decode(resp, v)
+	}
+}
+
+// StatusError is an unsuccessful response of the GitHub API, such as 404 for a release that
+// doesn't exist
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "GitHub API responded with " + e.Status
+}
+
+func decode(resp *http.Response, v interface{}) error {
+	//noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	err := json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("failed to decode GitHub API response: %w", err)
+	}
+	return nil
+}
+
+// rateLimit is declared by the X-RateLimit headers of a response
+type rateLimit struct {
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+func parseRateLimit(resp *http.Response) *rateLimit {
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return nil
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return nil
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &rateLimit{
+		limit:     limit,
+		remaining: remaining,
+		reset:     time.Unix(reset, 0),
+	}
+}
+
+// exceeded determines if the response was refused due to the rate limit, which GitHub
+// reports as a 403 or 429 when no requests remain
+func (r *rateLimit) exceeded(resp *http.Response) bool {
+	return r.remaining == 0 &&
+		(resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests)
+}
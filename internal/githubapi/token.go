@@ -0,0 +1,127 @@
+package githubapi
+
+import (
+	"context"
+	"github.com/itzg/easy-add/internal/keychain"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UseGHAuth enables Token to fall back to that of the gh CLI when neither GITHUB_TOKEN nor a
+// stored login is there
+var UseGHAuth bool
+
+var (
+	tokenOnce sync.Once
+	token     string
+)
+
+// Token is that of GITHUB_TOKEN, the bearer token of the login stored by easy-add login for the
+// GitHub host or, when UseGHAuth is enabled, GH_TOKEN or the token the gh CLI is logged in with,
+// where empty is anonymous
+func Token() string {
+	tokenOnce.Do(func() {
+		token = os.Getenv("GITHUB_TOKEN")
+		if token != "" {
+			return
+		}
+		host := ghHost()
+		if authorization, err := keychain.Get(host); err != nil {
+			log.Printf("W! Unable to look up the login of %s: %v", host, err)
+		} else if strings.HasPrefix(authorization, "Bearer ") {
+			token = strings.TrimPrefix(authorization, "Bearer ")
+			return
+		}
+		if !UseGHAuth {
+			return
+		}
+		if token = os.Getenv("GH_TOKEN"); token != "" {
+			return
+		}
+		token = ghAuthToken(host)
+		if token == "" {
+			token = ghHostsToken(host)
+		}
+		if token == "" {
+			log.Printf("W! gh isn't logged into %s, so GitHub API requests are anonymous", host)
+		}
+	})
+	return token
+}
+
+// ghHost is the host gh knows the server of BaseURL by, such as github.com for api.github.com
+func ghHost() string {
+	u, err := url.Parse(BaseURL)
+	if err != nil || u.Host == "api.github.com" {
+		return "github.com"
+	}
+	return u.Host
+}
+
+// ghAuthToken runs gh to get its token, which also covers one it keeps in the system keyring
+func ghAuthToken(host string) string {
+	ghPath, err := exec.LookPath("gh")
+	if err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, ghPath, "auth", "token", "--hostname", host).Output()
+	if err != nil {
+		log.Printf("D! Unable to get the token of gh: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// ghHostsToken reads the token from the hosts.yml of gh, for when gh itself isn't installed, such
+// as when its config is mounted into a container
+func ghHostsToken(host string) string {
+	content, err := ioutil.ReadFile(filepath.Join(ghConfigDir(), "hosts.yml"))
+	if err != nil {
+		return ""
+	}
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+		User       string `yaml:"user"`
+		Users      map[string]struct {
+			OAuthToken string `yaml:"oauth_token"`
+		} `yaml:"users"`
+	}
+	err = yaml.Unmarshal(content, &hosts)
+	if err != nil {
+		log.Printf("W! Unable to parse the hosts.yml of gh: %v", err)
+		return ""
+	}
+	entry := hosts[host]
+	if entry.OAuthToken != "" {
+		return entry.OAuthToken
+	}
+	return entry.Users[entry.User].OAuthToken
+}
+
+// ghConfigDir is where gh keeps its config, following its own precedence
+func ghConfigDir() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh")
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("AppData"); dir != "" {
+			return filepath.Join(dir, "GitHub CLI")
+		}
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gh")
+}
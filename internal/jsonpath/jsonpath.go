@@ -0,0 +1,93 @@
+// Package jsonpath selects one value of a JSON document with the simple subset of JSONPath that
+// names it directly, such as $.tag_name or $.releases[0].version
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Select returns the value at the path within the document, as decoded by encoding/json into an
+// interface{}. The path is $ followed by .name, ['name'], and [index] steps, where a negative
+// index counts back from the end of an array.
+func Select(doc interface{}, path string) (interface{}, error) {
+	steps, err := parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := doc
+	at := "$"
+	for _, step := range steps {
+		switch step := step.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is not an object", at)
+			}
+			value, ok = object[step]
+			if !ok {
+				return nil, fmt.Errorf("%s has no %s", at, step)
+			}
+			at += "." + step
+		case int:
+			array, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is not an array", at)
+			}
+			index := step
+			if index < 0 {
+				index += len(array)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("%s has %d elements, so there's no [%d]", at, len(array), step)
+			}
+			value = array[index]
+			at += fmt.Sprintf("[%d]", step)
+		}
+	}
+	return value, nil
+}
+
+// parse splits the path into its steps, each of which is a string key or an int index
+func parse(path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid path '%s', which must start with $", path)
+	}
+
+	var steps []interface{}
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("invalid path '%s', which has an empty name", path)
+			}
+			steps = append(steps, name)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path '%s', which is missing a ]", path)
+			}
+			inner := rest[1:end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, inner[1:len(inner)-1])
+			} else if index, err := strconv.Atoi(inner); err == nil {
+				steps = append(steps, index)
+			} else {
+				return nil, fmt.Errorf("invalid path '%s', where [%s] is neither an index nor a quoted name", path, inner)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid path '%s' at %s", path, rest)
+		}
+	}
+	return steps, nil
+}
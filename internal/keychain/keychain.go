@@ -0,0 +1,40 @@
+// Package keychain keeps the credentials of hosts in the keychain of the operating system, so
+// that they don't need to be kept in environment variables or files
+package keychain
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ErrUnsupported is returned when the operating system has no keychain that is supported
+var ErrUnsupported = errors.New("storing credentials isn't supported on this operating system")
+
+// service is what the credentials of easy-add are kept under
+const service = "easy-add"
+
+// Set stores the value of the Authorization header to send to the host, replacing any already stored
+func Set(host string, authorization string) error {
+	return set(NormalizeHost(host), authorization)
+}
+
+// Get retrieves the value of the Authorization header stored for the host, where empty is none
+func Get(host string) (string, error) {
+	return get(NormalizeHost(host))
+}
+
+// Delete removes what is stored for the host, if anything
+func Delete(host string) error {
+	return remove(NormalizeHost(host))
+}
+
+// NormalizeHost reduces a host, which may be given as a URL, to its lowercase host and port
+func NormalizeHost(host string) string {
+	if strings.Contains(host, "://") {
+		if u, err := url.Parse(host); err == nil {
+			host = u.Host
+		}
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "/"))
+}
@@ -0,0 +1,50 @@
+package keychain
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// itemNotFound is the exit code of security when there's no such item
+const itemNotFound = 44
+
+// set adds a generic password to the login keychain, with the security tool since the keychain
+// API requires cgo
+func set(host string, authorization string) error {
+	return security("add-generic-password", "-U", "-s", service, "-a", host, "-w", authorization)
+}
+
+func get(host string) (string, error) {
+	cmd := exec.Command("security", "find-generic-password", "-s", service, "-a", host, "-w")
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == itemNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to read keychain: %w", err)
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+func remove(host string) error {
+	err := security("delete-generic-password", "-s", service, "-a", host)
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == itemNotFound {
+		return nil
+	}
+	return err
+}
+
+func security(args ...string) error {
+	cmd := exec.Command("security", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return err
+		}
+		return fmt.Errorf("unable to run security: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package keychain
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// keyPerm allows the user to use the key from any of their sessions rather than only those
+// that possess it, since processes run by sudo or in another terminal don't
+const keyPerm = 0x3f3f0000
+
+func description(host string) string {
+	return service + ":" + host
+}
+
+// set adds a user key to the user keyring, which is kept by the kernel until the user's last
+// session ends or the system restarts
+func set(host string, authorization string) error {
+	id, err := unix.AddKey("user", description(host), []byte(authorization), unix.KEY_SPEC_USER_KEYRING)
+	if err != nil {
+		return err
+	}
+	return unix.KeyctlSetperm(id, keyPerm)
+}
+
+func get(host string) (string, error) {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, "user", description(host), 0)
+	if err == unix.ENOKEY {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, nil, 0)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, size)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+func remove(host string) error {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, "user", description(host), 0)
+	if err == unix.ENOKEY {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = unix.KeyctlInt(unix.KEYCTL_UNLINK, id, unix.KEY_SPEC_USER_KEYRING, 0, 0)
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || windows)
+
+package keychain
+
+func set(string, string) error {
+	return ErrUnsupported
+}
+
+// get finds nothing since there's no keychain to store credentials in
+func get(string) (string, error) {
+	return "", nil
+}
+
+func remove(string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,104 @@
+package keychain
+
+import (
+	"golang.org/x/sys/windows"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+var (
+	advapi32       = windows.NewLazySystemDLL("advapi32.dll")
+	procCredWrite  = advapi32.NewProc("CredWriteW")
+	procCredRead   = advapi32.NewProc("CredReadW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+// credential is the CREDENTIALW structure of the Windows Credential Manager
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+func targetName(host string) (*uint16, error) {
+	return windows.UTF16PtrFromString(service + ":" + host)
+}
+
+// set writes a generic credential to the Credential Manager, which persists across logons
+func set(host string, authorization string) error {
+	target, err := targetName(host)
+	if err != nil {
+		return err
+	}
+	user, err := windows.UTF16PtrFromString(service)
+	if err != nil {
+		return err
+	}
+	blob := []byte(authorization)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	ret, _, err := procCredWrite.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if ret == 0 {
+		return err
+	}
+	return nil
+}
+
+func get(host string) (string, error) {
+	target, err := targetName(host)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	ret, _, err := procCredRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		if err == syscall.Errno(windows.ERROR_NOT_FOUND) {
+			return "", nil
+		}
+		return "", err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	if cred.CredentialBlobSize == 0 {
+		return "", nil
+	}
+	// the blob is at most CRED_MAX_CREDENTIAL_BLOB_SIZE of 5 * 512 bytes
+	blob := (*[5 * 512]byte)(unsafe.Pointer(cred.CredentialBlob))[:cred.CredentialBlobSize:cred.CredentialBlobSize]
+	return string(blob), nil
+}
+
+func remove(host string) error {
+	target, err := targetName(host)
+	if err != nil {
+		return err
+	}
+	ret, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if ret == 0 && err != syscall.Errno(windows.ERROR_NOT_FOUND) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package keychain
+
+import (
+	"log"
+	"net/http"
+	"sync"
+)
+
+// Authorize wraps the transport so that https requests without an Authorization header carry
+// the one stored for their host, if any
+func Authorize(next http.RoundTripper) http.RoundTripper {
+	return &authorizeTransport{next: next, stored: make(map[string]string)}
+}
+
+type authorizeTransport struct {
+	next http.RoundTripper
+
+	mu sync.Mutex
+	// stored caches what is stored by host, since the keychain can be slow to query
+	stored map[string]string
+}
+
+func (t *authorizeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || req.Header.Get("Authorization") != "" {
+		return t.next.RoundTrip(req)
+	}
+	authorization := t.lookup(req.URL.Host)
+	if authorization == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	// a round tripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", authorization)
+	return t.next.RoundTrip(req)
+}
+
+func (t *authorizeTransport) lookup(host string) string {
+	host = NormalizeHost(host)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if authorization, ok := t.stored[host]; ok {
+		return authorization
+	}
+
+	authorization, err := Get(host)
+	if err != nil {
+		log.Printf("W! Unable to look up the login of %s: %v", host, err)
+	} else if authorization != "" {
+		log.Printf("D! Using the stored login of %s", host)
+	}
+	t.stored[host] = authorization
+	return authorization
+}
@@ -0,0 +1,430 @@
+package xz
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	numStates       = 12
+	posBitsMax      = 4
+	endPosModel     = 14
+	numFullDists    = 128
+	numAlignBits    = 4
+	matchMinLen     = 2
+	probInitValue   = 1 << 10
+	numBitModelBits = 11
+	topValue        = 1 << 24
+)
+
+var (
+	errCorrupt  = errors.New("xz data is corrupt")
+	errTooLarge = errors.New("xz content exceeds its maximum size")
+)
+
+// rangeDecoder decodes the bits of an LZMA chunk
+type rangeDecoder struct {
+	in    []byte
+	rng   uint32
+	code  uint32
+	err   error
+	index int
+}
+
+func (r *rangeDecoder) init(in []byte) {
+	r.in = in
+	r.index = 0
+	r.rng = 0xffffffff
+	r.code = 0
+	if len(in) < 5 || in[0] != 0 {
+		r.err = errCorrupt
+		return
+	}
+	for i := 1; i < 5; i++ {
+		r.code = r.code<<8 | uint32(in[i])
+	}
+	r.index = 5
+	if r.code == r.rng {
+		r.err = errCorrupt
+	}
+}
+
+func (r *rangeDecoder) readByte() uint32 {
+	if r.index >= len(r.in) {
+		r.err = errCorrupt
+		return 0
+	}
+	b := r.in[r.index]
+	r.index++
+	return uint32(b)
+}
+
+func (r *rangeDecoder) normalize() {
+	if r.rng < topValue {
+		r.rng <<= 8
+		r.code = r.code<<8 | r.readByte()
+	}
+}
+
+func (r *rangeDecoder) directBits(count uint) uint32 {
+	var res uint32
+	for ; count > 0; count-- {
+		r.rng >>= 1
+		r.code -= r.rng
+		t := 0 - (r.code >> 31)
+		r.code += r.rng & t
+		if r.code == r.rng {
+			r.err = errCorrupt
+		}
+		r.normalize()
+		res = res<<1 + t + 1
+	}
+	return res
+}
+
+func (r *rangeDecoder) bit(prob *uint16) uint32 {
+	v := uint32(*prob)
+	bound := (r.rng >> numBitModelBits) * v
+	var symbol uint32
+	if r.code < bound {
+		v += ((1 << numBitModelBits) - v) >> 5
+		r.rng = bound
+	} else {
+		v -= v >> 5
+		r.code -= bound
+		r.rng -= bound
+		symbol = 1
+	}
+	*prob = uint16(v)
+	r.normalize()
+	return symbol
+}
+
+func (r *rangeDecoder) bitTree(probs []uint16, numBits uint) uint32 {
+	m := uint32(1)
+	for i := uint(0); i < numBits; i++ {
+		m = m<<1 + r.bit(&probs[m])
+	}
+	return m - 1<<numBits
+}
+
+func (r *rangeDecoder) reverseBitTree(probs []uint16, numBits uint) uint32 {
+	m := uint32(1)
+	var symbol uint32
+	for i := uint(0); i < numBits; i++ {
+		bit := r.bit(&probs[m])
+		m = m<<1 + bit
+		symbol |= bit << i
+	}
+	return symbol
+}
+
+func initProbs(probs []uint16) {
+	for i := range probs {
+		probs[i] = probInitValue
+	}
+}
+
+type lenDecoder struct {
+	choice  uint16
+	choice2 uint16
+	low     [1 << posBitsMax][1 << 3]uint16
+	mid     [1 << posBitsMax][1 << 3]uint16
+	high    [1 << 8]uint16
+}
+
+func (l *lenDecoder) init() {
+	l.choice = probInitValue
+	l.choice2 = probInitValue
+	initProbs(l.high[:])
+	for i := range l.low {
+		initProbs(l.low[i][:])
+		initProbs(l.mid[i][:])
+	}
+}
+
+func (l *lenDecoder) decode(r *rangeDecoder, posState uint32) uint32 {
+	if r.bit(&l.choice) == 0 {
+		return r.bitTree(l.low[posState][:], 3)
+	}
+	if r.bit(&l.choice2) == 0 {
+		return 8 + r.bitTree(l.mid[posState][:], 3)
+	}
+	return 16 + r.bitTree(l.high[:], 8)
+}
+
+// lzmaState is the state of LZMA decoding that carries across the chunks of an LZMA2 stream
+// until they reset it
+type lzmaState struct {
+	lc, lp, pb uint
+	literal    []uint16
+	posSlot    [4][1 << 6]uint16
+	posDecoder [1 + numFullDists - endPosModel]uint16
+	align      [1 << numAlignBits]uint16
+	isMatch    [numStates << posBitsMax]uint16
+	isRep      [numStates]uint16
+	isRepG0    [numStates]uint16
+	isRepG1    [numStates]uint16
+	isRepG2    [numStates]uint16
+	isRep0Long [numStates << posBitsMax]uint16
+	length     lenDecoder
+	repLength  lenDecoder
+	state      uint32
+	reps       [4]uint32
+}
+
+func (s *lzmaState) setProperties(props byte) error {
+	if props >= 9*5*5 {
+		return errCorrupt
+	}
+	s.lc = uint(props % 9)
+	props /= 9
+	s.lp = uint(props % 5)
+	s.pb = uint(props / 5)
+	if s.lc+s.lp > 4 {
+		return fmt.Errorf("invalid LZMA2 properties lc=%d lp=%d", s.lc, s.lp)
+	}
+	s.literal = make([]uint16, 0x300<<(s.lc+s.lp))
+	return nil
+}
+
+func (s *lzmaState) reset() {
+	initProbs(s.literal)
+	for i := range s.posSlot {
+		initProbs(s.posSlot[i][:])
+	}
+	initProbs(s.posDecoder[:])
+	initProbs(s.align[:])
+	initProbs(s.isMatch[:])
+	initProbs(s.isRep[:])
+	initProbs(s.isRepG0[:])
+	initProbs(s.isRepG1[:])
+	initProbs(s.isRepG2[:])
+	initProbs(s.isRep0Long[:])
+	s.length.init()
+	s.repLength.init()
+	s.state = 0
+	s.reps = [4]uint32{}
+}
+
+// lzma2Decoder decodes an LZMA2 stream entirely into memory, where the output serves as the dictionary
+type lzma2Decoder struct {
+	out []byte
+	// dictStart is where the dictionary was last reset, which matches can't reach before
+	dictStart int
+	limit     int
+	lzma      lzmaState
+}
+
+// decodeLZMA2 decodes the LZMA2 stream, failing if it decodes to more than limit bytes. It returns
+// the decoded content and the number of bytes of the stream consumed.
+func decodeLZMA2(in []byte, limit int) ([]byte, int, error) {
+	d := &lzma2Decoder{limit: limit}
+	pos := 0
+	needProps := true
+	for {
+		if pos >= len(in) {
+			return nil, 0, errCorrupt
+		}
+		control := in[pos]
+		pos++
+		if control == 0 {
+			return d.out, pos, nil
+		}
+
+		if control < 0x80 {
+			if control > 2 || pos+2 > len(in) {
+				return nil, 0, errCorrupt
+			}
+			size := int(in[pos])<<8 | int(in[pos+1]) + 1
+			pos += 2
+			if pos+size > len(in) {
+				return nil, 0, errCorrupt
+			}
+			if len(d.out)+size > d.limit {
+				return nil, 0, errTooLarge
+			}
+			if control == 1 {
+				d.dictStart = len(d.out)
+			}
+			d.out = append(d.out, in[pos:pos+size]...)
+			pos += size
+			continue
+		}
+
+		if pos+4 > len(in) {
+			return nil, 0, errCorrupt
+		}
+		unpacked := int(control&0x1f)<<16 | int(in[pos])<<8 | int(in[pos+1]) + 1
+		packed := int(in[pos+2])<<8 | int(in[pos+3]) + 1
+		pos += 4
+		reset := (control >> 5) & 3
+		if reset == 3 {
+			d.dictStart = len(d.out)
+		}
+		if reset >= 2 {
+			if pos >= len(in) {
+				return nil, 0, errCorrupt
+			}
+			err := d.lzma.setProperties(in[pos])
+			if err != nil {
+				return nil, 0, err
+			}
+			pos++
+			needProps = false
+		} else if needProps {
+			return nil, 0, errCorrupt
+		}
+		if reset >= 1 {
+			d.lzma.reset()
+		}
+		if pos+packed > len(in) {
+			return nil, 0, errCorrupt
+		}
+		if len(d.out)+unpacked > d.limit {
+			return nil, 0, errTooLarge
+		}
+		err := d.decodeChunk(in[pos:pos+packed], unpacked)
+		if err != nil {
+			return nil, 0, err
+		}
+		pos += packed
+	}
+}
+
+func (d *lzma2Decoder) decodeChunk(in []byte, unpacked int) error {
+	var r rangeDecoder
+	r.init(in)
+	s := &d.lzma
+	end := len(d.out) + unpacked
+	pbMask := uint32(1)<<s.pb - 1
+	lpMask := uint32(1)<<s.lp - 1
+
+	for len(d.out) < end && r.err == nil {
+		posState := uint32(len(d.out)) & pbMask
+		if r.bit(&s.isMatch[s.state<<posBitsMax+posState]) == 0 {
+			d.decodeLiteral(&r, lpMask)
+			switch {
+			case s.state < 4:
+				s.state = 0
+			case s.state < 10:
+				s.state -= 3
+			default:
+				s.state -= 6
+			}
+			continue
+		}
+
+		var length uint32
+		if r.bit(&s.isRep[s.state]) != 0 {
+			if len(d.out) == d.dictStart {
+				return errCorrupt
+			}
+			if r.bit(&s.isRepG0[s.state]) == 0 {
+				if r.bit(&s.isRep0Long[s.state<<posBitsMax+posState]) == 0 {
+					if s.state < 7 {
+						s.state = 9
+					} else {
+						s.state = 11
+					}
+					d.out = append(d.out, d.out[len(d.out)-int(s.reps[0])-1])
+					continue
+				}
+			} else {
+				var dist uint32
+				if r.bit(&s.isRepG1[s.state]) == 0 {
+					dist = s.reps[1]
+				} else {
+					if r.bit(&s.isRepG2[s.state]) == 0 {
+						dist = s.reps[2]
+					} else {
+						dist = s.reps[3]
+						s.reps[3] = s.reps[2]
+					}
+					s.reps[2] = s.reps[1]
+				}
+				s.reps[1] = s.reps[0]
+				s.reps[0] = dist
+			}
+			length = s.repLength.decode(&r, posState)
+			if s.state < 7 {
+				s.state = 8
+			} else {
+				s.state = 11
+			}
+		} else {
+			s.reps[3], s.reps[2], s.reps[1] = s.reps[2], s.reps[1], s.reps[0]
+			length = s.length.decode(&r, posState)
+			if s.state < 7 {
+				s.state = 7
+			} else {
+				s.state = 10
+			}
+			s.reps[0] = d.decodeDistance(&r, length)
+			if s.reps[0] == 0xffffffff {
+				// LZMA2 chunks have no end marker
+				return errCorrupt
+			}
+		}
+
+		length += matchMinLen
+		dist := int(s.reps[0]) + 1
+		if dist > len(d.out)-d.dictStart || len(d.out)+int(length) > end {
+			return errCorrupt
+		}
+		for i := uint32(0); i < length; i++ {
+			d.out = append(d.out, d.out[len(d.out)-dist])
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return nil
+}
+
+func (d *lzma2Decoder) decodeLiteral(r *rangeDecoder, lpMask uint32) {
+	s := &d.lzma
+	var prevByte uint32
+	if len(d.out) > d.dictStart {
+		prevByte = uint32(d.out[len(d.out)-1])
+	}
+	litState := (uint32(len(d.out))&lpMask)<<s.lc + prevByte>>(8-s.lc)
+	probs := s.literal[0x300*litState:]
+
+	symbol := uint32(1)
+	if s.state >= 7 && int(s.reps[0]) < len(d.out)-d.dictStart {
+		matchByte := uint32(d.out[len(d.out)-int(s.reps[0])-1])
+		for symbol < 0x100 {
+			matchBit := (matchByte >> 7) & 1
+			matchByte <<= 1
+			bit := r.bit(&probs[(1+matchBit)<<8+symbol])
+			symbol = symbol<<1 | bit
+			if matchBit != bit {
+				break
+			}
+		}
+	}
+	for symbol < 0x100 {
+		symbol = symbol<<1 | r.bit(&probs[symbol])
+	}
+	d.out = append(d.out, byte(symbol-0x100))
+}
+
+func (d *lzma2Decoder) decodeDistance(r *rangeDecoder, length uint32) uint32 {
+	s := &d.lzma
+	lenState := length
+	if lenState > 3 {
+		lenState = 3
+	}
+	posSlot := r.bitTree(s.posSlot[lenState][:], 6)
+	if posSlot < 4 {
+		return posSlot
+	}
+	numDirectBits := uint(posSlot>>1) - 1
+	dist := (2 | posSlot&1) << numDirectBits
+	if posSlot < endPosModel {
+		return dist + r.reverseBitTree(s.posDecoder[dist-posSlot:], numDirectBits)
+	}
+	dist += r.directBits(numDirectBits-numAlignBits) << numAlignBits
+	return dist + r.reverseBitTree(s.align[:], numAlignBits)
+}
@@ -0,0 +1,206 @@
+// Package xz decompresses xz streams that use only the LZMA2 filter, such as the blocks of
+// squashfs filesystems
+package xz
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+)
+
+var (
+	headerMagic = []byte{0xfd, '7', 'z', 'X', 'Z', 0}
+	crc64Table  = crc64.MakeTable(crc64.ECMA)
+)
+
+const lzma2FilterID = 0x21
+
+// Decompress decompresses the xz stream, which is entirely in memory, and fails if it decompresses
+// to more than limit bytes
+func Decompress(in []byte, limit int) ([]byte, error) {
+	if len(in) < 12 || !bytes.Equal(in[:6], headerMagic) {
+		return nil, errors.New("not xz content")
+	}
+	if crc32.ChecksumIEEE(in[6:8]) != binary.LittleEndian.Uint32(in[8:12]) || in[6] != 0 {
+		return nil, errors.New("invalid xz stream header")
+	}
+	checkType := in[7] & 0xf
+	newCheck, checkSize, err := checker(checkType)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	var blockSizes []int
+	pos := 12
+	for {
+		if pos >= len(in) {
+			return nil, errCorrupt
+		}
+		if in[pos] == 0 {
+			// the index follows the last block, which confirms the stream is complete
+			err = checkIndex(in[pos:], in[6:8], blockSizes)
+			if err != nil {
+				return nil, err
+			}
+			return out, nil
+		}
+
+		headerSize := (int(in[pos]) + 1) * 4
+		if pos+headerSize > len(in) {
+			return nil, errCorrupt
+		}
+		err = checkBlockHeader(in[pos : pos+headerSize])
+		if err != nil {
+			return nil, err
+		}
+		pos += headerSize
+
+		content, consumed, err := decodeLZMA2(in[pos:], limit-len(out))
+		if err != nil {
+			return nil, err
+		}
+		pos += consumed
+		for pos%4 != 0 {
+			if pos >= len(in) || in[pos] != 0 {
+				return nil, errCorrupt
+			}
+			pos++
+		}
+		if pos+checkSize > len(in) {
+			return nil, errCorrupt
+		}
+		if newCheck != nil {
+			h := newCheck()
+			h.Write(content)
+			sum := h.Sum(nil)
+			if checkType == 1 || checkType == 4 {
+				// CRC32 and CRC64 are stored little endian
+				for i, j := 0, len(sum)-1; i < j; i, j = i+1, j-1 {
+					sum[i], sum[j] = sum[j], sum[i]
+				}
+			}
+			if !bytes.Equal(sum, in[pos:pos+checkSize]) {
+				return nil, errors.New("xz content does not match its check")
+			}
+		}
+		pos += checkSize
+		out = append(out, content...)
+		blockSizes = append(blockSizes, len(content))
+	}
+}
+
+// checkIndex confirms the index, which is followed by the stream footer, records the decompressed
+// size of each block and that the stream ends with the footer
+func checkIndex(rest []byte, streamFlags []byte, blockSizes []int) error {
+	if len(rest) < 12 {
+		return errCorrupt
+	}
+	footer := rest[len(rest)-12:]
+	if string(footer[10:]) != "YZ" ||
+		crc32.ChecksumIEEE(footer[4:10]) != binary.LittleEndian.Uint32(footer) ||
+		!bytes.Equal(footer[8:10], streamFlags) {
+		return errors.New("invalid xz stream footer")
+	}
+	indexSize := (int(binary.LittleEndian.Uint32(footer[4:])) + 1) * 4
+	if indexSize != len(rest)-12 {
+		return errors.New("invalid xz index")
+	}
+	index := rest[:indexSize]
+	if crc32.ChecksumIEEE(index[:indexSize-4]) != binary.LittleEndian.Uint32(index[indexSize-4:]) {
+		return errors.New("invalid xz index")
+	}
+
+	records := index[1 : indexSize-4]
+	count, records, err := readVarint(records)
+	if err != nil || count != uint64(len(blockSizes)) {
+		return errors.New("invalid xz index")
+	}
+	for _, size := range blockSizes {
+		// the unpadded size of the block precedes its decompressed size
+		_, records, err = readVarint(records)
+		if err != nil {
+			return errors.New("invalid xz index")
+		}
+		var recorded uint64
+		recorded, records, err = readVarint(records)
+		if err != nil || recorded != uint64(size) {
+			return errors.New("invalid xz index")
+		}
+	}
+	for _, b := range records {
+		if b != 0 {
+			return errors.New("invalid xz index")
+		}
+	}
+	return nil
+}
+
+// checkBlockHeader confirms the block header is intact and declares only the LZMA2 filter
+func checkBlockHeader(header []byte) error {
+	size := len(header)
+	if crc32.ChecksumIEEE(header[:size-4]) != binary.LittleEndian.Uint32(header[size-4:]) {
+		return errors.New("invalid xz block header")
+	}
+	flags := header[1]
+	if flags&0x3c != 0 {
+		return errors.New("invalid xz block header")
+	}
+	if flags&0x3 != 0 {
+		return errors.New("xz filters other than LZMA2 are not supported")
+	}
+
+	rest := header[2 : size-4]
+	var err error
+	if flags&0x40 != 0 {
+		rest, err = skipVarint(rest)
+	}
+	if err == nil && flags&0x80 != 0 {
+		rest, err = skipVarint(rest)
+	}
+	if err != nil || len(rest) < 3 {
+		return errors.New("invalid xz block header")
+	}
+	if rest[0] != lzma2FilterID {
+		return fmt.Errorf("xz filter %#x is not supported", rest[0])
+	}
+	return nil
+}
+
+func skipVarint(b []byte) ([]byte, error) {
+	_, rest, err := readVarint(b)
+	return rest, err
+}
+
+// readVarint reads a variable length integer, which holds seven bits in each byte
+func readVarint(b []byte) (uint64, []byte, error) {
+	var n uint64
+	for i := 0; i < len(b) && i < 9; i++ {
+		n |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i]&0x80 == 0 {
+			return n, b[i+1:], nil
+		}
+	}
+	return 0, nil, errCorrupt
+}
+
+// checker provides the hash of the given check type along with the size of its sum
+func checker(checkType byte) (func() hash.Hash, int, error) {
+	switch checkType {
+	case 0:
+		return nil, 0, nil
+	case 1:
+		return func() hash.Hash { return crc32.NewIEEE() }, 4, nil
+	case 4:
+		return func() hash.Hash { return crc64.New(crc64Table) }, 8, nil
+	case 10:
+		return sha256.New, 32, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported xz check type %d", checkType)
+	}
+}
@@ -0,0 +1,112 @@
+package xz
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The streams in testdata were compressed by the xz command from the content generated below
+
+func testText(size int) []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "%04d the quick brown fox jumps over the lazy dog\n", i%1000)
+	}
+	return b.Bytes()[:size]
+}
+
+// testRandom generates content that doesn't compress, so it is stored in uncompressed chunks
+func testRandom(size int) []byte {
+	out := make([]byte, size)
+	x := uint32(1)
+	for i := range out {
+		x = (x*1103515245 + 12345) & 0x7fffffff
+		out[i] = byte(x >> 16)
+	}
+	return out
+}
+
+func readTestStream(t *testing.T, name string) []byte {
+	in, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in
+}
+
+func TestDecompress(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []byte
+	}{
+		// several LZMA2 chunks, with a CRC64 check
+		{name: "text.xz", expected: testText(3 << 20)},
+		{name: "text-crc32.xz", expected: testText(20000)},
+		{name: "text-sha256.xz", expected: testText(20000)},
+		{name: "text-none.xz", expected: testText(20000)},
+		{name: "text-blocks.xz", expected: testText(20000)},
+		{name: "random.xz", expected: testRandom(20000)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := Decompress(readTestStream(t, test.name), len(test.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, test.expected) {
+				t.Errorf("decompressed %d bytes that don't match the %d expected", len(out), len(test.expected))
+			}
+		})
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	for _, name := range []string{"text-crc32.xz", "text-blocks.xz", "random.xz"} {
+		_, err := Decompress(readTestStream(t, name), 19999)
+		if err == nil {
+			t.Errorf("%s decompressed beyond its limit", name)
+		}
+	}
+}
+
+func TestDecompressUnsupportedFilter(t *testing.T) {
+	_, err := Decompress(readTestStream(t, "text-delta.xz"), 20000)
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("decompressing with the delta filter gave %v", err)
+	}
+}
+
+func TestDecompressTruncated(t *testing.T) {
+	for _, name := range []string{"text-crc32.xz", "text-blocks.xz"} {
+		in := readTestStream(t, name)
+		for size := 0; size < len(in); size++ {
+			_, err := Decompress(in[:size], 20000)
+			if err == nil {
+				t.Errorf("%s truncated to %d bytes decompressed", name, size)
+			}
+		}
+	}
+}
+
+// TestDecompressCorrupt alters each byte of the stream in turn, which must either fail or, when
+// the altered byte doesn't matter, decompress to the original content
+func TestDecompressCorrupt(t *testing.T) {
+	expected := testText(20000)
+	for _, name := range []string{"text-crc32.xz", "text-blocks.xz"} {
+		in := readTestStream(t, name)
+		for i := range in {
+			for _, mask := range []byte{0x01, 0x80, 0xff} {
+				corrupt := append([]byte(nil), in...)
+				corrupt[i] ^= mask
+				out, err := Decompress(corrupt, len(expected))
+				if err == nil && !bytes.Equal(out, expected) {
+					t.Errorf("%s with byte %d altered by %#x decompressed to different content", name, i, mask)
+				}
+			}
+		}
+	}
+}
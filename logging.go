@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// levelWriter receives the lines of the standard logger, which are marked by the I!, W!, E!,
+// and D! prefixes of their level, and drops debug lines unless enabled. The prefixes are written
+// as the name of the level, which is colored when writing to a terminal unless noColor is set.
+type levelWriter struct {
+	out     io.Writer
+	debug   bool
+	noColor bool
+	// sink, when set, receives each message and the name of its level rather than out, such as
+	// to send them to syslog
+	sink func(level string, message string) error
+}
+
+var logWriter = &levelWriter{out: os.Stdout, noColor: os.Getenv("NO_COLOR") != ""}
+
+// logLevel is how the lines marked with a prefix are written
+type logLevel struct {
+	prefix []byte
+	name   string
+	// color is the ANSI SGR parameter of the name
+	color string
+}
+
+// logDateTime is an example of the date and time that the standard logger starts each line with
+const logDateTime = "2006/01/02 15:04:05 "
+
+var logLevels = []logLevel{
+	{prefix: []byte(" D! "), name: "DEBUG", color: "90"},
+	{prefix: []byte(" I! "), name: "INFO", color: "36"},
+	{prefix: []byte(" W! "), name: "WARN", color: "33"},
+	{prefix: []byte(" E! "), name: "ERROR", color: "31"},
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	// the prefix follows the date and time, so is the first of any in the line
+	var level *logLevel
+	i := -1
+	for j := range logLevels {
+		at := bytes.Index(p, logLevels[j].prefix)
+		if at >= 0 && (i < 0 || at < i) {
+			level, i = &logLevels[j], at
+		}
+	}
+	if level != nil && level.name == "DEBUG" && !w.debug {
+		return len(p), nil
+	}
+	if w.sink != nil {
+		return w.writeSink(p, level, i)
+	}
+	if level == nil {
+		return w.out.Write(p)
+	}
+
+	// padded to align the messages
+	name := level.name + strings.Repeat(" ", len("ERROR")-len(level.name))
+	if !w.noColor && isTerminal(w.out) {
+		name = "\x1b[" + level.color + "m" + level.name + "\x1b[0m" + name[len(level.name):]
+	}
+	var line bytes.Buffer
+	line.Grow(len(p) + len(name))
+	line.Write(p[:i+1])
+	line.WriteString(name)
+	line.WriteByte(' ')
+	line.Write(p[i+len(level.prefix):])
+	_, err := w.out.Write(line.Bytes())
+	return len(p), err
+}
+
+// isTerminal determines if the writer is a terminal rather than, such as in CI, a file or pipe
+func isTerminal(out io.Writer) bool {
+	file, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := file.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// writeSink passes the message of the line, without the date and time that the sink adds itself,
+// to the sink, where a line without a level prefix is sent as INFO
+func (w *levelWriter) writeSink(p []byte, level *logLevel, prefixAt int) (int, error) {
+	levelName := "INFO"
+	var message []byte
+	if level != nil {
+		levelName = level.name
+		message = p[prefixAt+len(level.prefix):]
+	} else if len(p) >= len(logDateTime) {
+		message = p[len(logDateTime):]
+	} else {
+		message = p
+	}
+	err := w.sink(levelName, string(bytes.TrimRight(message, "\n")))
+	return len(p), err
+}
@@ -1,24 +1,26 @@
 package main
 
 import (
-	"archive/tar"
-	"archive/zip"
-	"bytes"
-	"compress/gzip"
+	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"errors"
 	"flag"
 	"fmt"
+	"github.com/itzg/easy-add/internal/githubapi"
+	"github.com/itzg/easy-add/internal/keychain"
+	"github.com/itzg/easy-add/pkg/easyadd"
+	"github.com/itzg/easy-add/pkg/fetch"
 	"github.com/itzg/go-flagsfiller"
-	"html/template"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"path"
+	"os/signal"
+	"runtime"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 var (
@@ -26,209 +28,332 @@ var (
 	commit  = "HEAD"
 )
 
-var args struct {
-	From    string            `usage:"[URL] of a tar.gz or zip archive to download. May contain Go template references to 'var' entries."`
-	Var     map[string]string `usage:"Sets variables that can be referenced in 'from' and 'file'. Format is [name=value]"`
-	File    string            `usage:"The [path] to executable to extract within archive. May contain Go template references to 'var' entries."`
-	To      string            `usage:"The [path] where executable will be placed" default:"/usr/local/bin"`
-	Mkdirs  bool              `usage:"Attempt to create the directory path specified by to"`
-	Version bool              `usage:"Show version and exit"`
+// networkFlags are shared by the commands that download archives
+type networkFlags struct {
+	Proxy               string        `usage:"[URL] of a proxy to use for downloads rather than what HTTPS_PROXY, HTTP_PROXY, and NO_PROXY declare"`
+	CaCert              []string      `usage:"[path] of a PEM file with additional CA certificates to trust. Can be repeated."`
+	UnixSocket          string        `usage:"[path] of a Unix domain socket to connect to for all HTTP requests, such as of a local artifact proxy"`
+	Ipv4                bool          `flag:"ipv4" env:"EASY_ADD_IPV4" usage:"Only connect to IPv4 addresses of download hosts"`
+	Ipv6                bool          `flag:"ipv6" env:"EASY_ADD_IPV6" usage:"Only connect to IPv6 addresses of download hosts"`
+	Dns                 string        `flag:"dns" env:"EASY_ADD_DNS" usage:"The [host:port] of a DNS server to resolve download hosts with rather than those of the system, where the port defaults to 53"`
+	Retries             int           `usage:"How many [times] to repeat a download that fails to connect or responds with a retry-on status" default:"2"`
+	RetryOn             string        `usage:"Comma separated HTTP response [statuses] of downloads to retry, where 5xx matches any server error" default:"408,429,5xx"`
+	MaxRetryWait        time.Duration `usage:"The longest [duration] to wait when a 429 or 503 response asks to be retried later with Retry-After" default:"1m"`
+	AllowHttp           bool          `usage:"Permit downloads over unencrypted HTTP from hosts other than this one, which are otherwise refused since what is downloaded is typically run as root"`
+	StrictDigests       bool          `usage:"Refuse checksums other than sha256, sha384, and sha512, such as md5 and sha1, and TLS versions below 1.2, for FIPS constrained environments"`
+	PreferHttps         bool          `usage:"Try the https equivalent of http URLs first, falling back to them only when allow-http permits"`
+	Policy              string        `usage:"[path] of a policy file restricting the hosts that archives are downloaded from and how they must be verified, which defaults to /etc/easy-add/policy.yaml when it exists"`
+	AuditLog            string        `usage:"Appends a JSON line describing each install, such as who ran it and the digest of what was installed where, to the file at the given [path]"`
+	GhAuth              bool          `usage:"Use the token that the gh CLI is logged in with, unless GITHUB_TOKEN is set, for GitHub API requests and release assets of private repositories"`
+	GithubRateLimitWait time.Duration `usage:"The longest [duration] to wait for an exceeded GitHub API rate limit to reset rather than failing"`
+	Debug               bool          `usage:"Include debug messages, such as the remaining GitHub API rate limit"`
+	DebugHttp           bool          `usage:"Include debug messages of each HTTP request and response, such as redirects and headers, with credentials redacted"`
+	NoColor             bool          `usage:"Don't color the level of log messages written to a terminal, which is also the case when NO_COLOR is set"`
+	OtlpEndpoint        string        `usage:"Base [URL] of an OTLP/HTTP receiver, such as http://localhost:4318, to export traces and metrics of the run to, which defaults to OTEL_EXPORTER_OTLP_ENDPOINT"`
+	LogDest             string        `usage:"Where log messages are [written]: console, or syslog to have them captured by the system journal with the priority of their level" default:"console"`
+	MaxIdleConns        int           `usage:"The maximum [count] of idle connections kept open to each host for reuse across downloads" default:"4"`
 }
 
-type ArchiveType int
+var (
+	networkArgs networkFlags
+
+	httpClientOnce sync.Once
+	httpClient     *http.Client
+	httpClientErr  error
 
-const (
-	TarGz ArchiveType = iota
-	Zip
+	policyOnce   sync.Once
+	sharedPolicy *easyadd.Policy
+	policyErr    error
 )
 
-func main() {
+// sharedHTTPClient creates a client from networkArgs once, so that connections are reused
+// across the downloads of a command, such as the tools of a manifest
+func sharedHTTPClient() (*http.Client, error) {
+	httpClientOnce.Do(func() {
+		if networkArgs.Ipv4 && networkArgs.Ipv6 {
+			httpClientErr = &usageError{"only one of ipv4 and ipv6 can be given"}
+			return
+		}
+		ipVersion := 0
+		if networkArgs.Ipv4 {
+			ipVersion = 4
+		} else if networkArgs.Ipv6 {
+			ipVersion = 6
+		}
 
-	err := flagsfiller.Parse(&args)
-	if err != nil {
-		log.Fatal(err)
-	}
+		retryOn, err := fetch.ParseStatusCodes(networkArgs.RetryOn)
+		if err != nil {
+			httpClientErr = err
+			return
+		}
 
-	if args.Version {
-		fmt.Printf("version=%s, commit=%s\n", version, commit)
-		return
-	}
+		policy, err := loadPolicy()
+		if err != nil {
+			httpClientErr = err
+			return
+		}
 
-	if args.From == "" || args.File == "" {
-		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "from and file are required")
-		flag.Usage()
-		os.Exit(2)
-	}
+		var minTLSVersion uint16
+		if networkArgs.StrictDigests {
+			minTLSVersion = tls.VersionTLS12
+		}
+		httpClient, httpClientErr = fetch.NewHTTPClient(fetch.ClientOptions{
+			Proxy:               networkArgs.Proxy,
+			CACertFiles:         networkArgs.CaCert,
+			MaxIdleConnsPerHost: networkArgs.MaxIdleConns,
+			UnixSocket:          networkArgs.UnixSocket,
+			IPVersion:           ipVersion,
+			DNSServer:           networkArgs.Dns,
+			Retries:             networkArgs.Retries,
+			RetryOn:             retryOn,
+			MaxRetryWait:        networkArgs.MaxRetryWait,
+			MinTLSVersion:       minTLSVersion,
+			AllowHTTP:           allowHTTP(),
+			CheckRedirect:       policy.CheckRedirect,
+			DebugHTTP:           networkArgs.DebugHttp,
+		})
+		if httpClientErr == nil {
+			httpClient.Transport = githubapi.PrivateAssets(keychain.Authorize(httpClient.Transport))
+		}
+	})
+	return httpClient, httpClientErr
+}
 
-	log.SetOutput(os.Stdout)
+// defaultPolicyPath is the policy file that applies when the policy flag isn't given, such as
+// one that an organization provisions on its hosts
+const defaultPolicyPath = "/etc/easy-add/policy.yaml"
+
+// loadPolicy reads the policy file given by networkArgs once, or otherwise defaultPolicyPath when
+// it exists, where nil is no policy
+func loadPolicy() (*easyadd.Policy, error) {
+	policyOnce.Do(func() {
+		policyPath := networkArgs.Policy
+		if policyPath == "" {
+			if runtime.GOOS == "windows" {
+				return
+			}
+			if _, err := os.Stat(defaultPolicyPath); os.IsNotExist(err) {
+				return
+			}
+			policyPath = defaultPolicyPath
+		}
+		sharedPolicy, policyErr = easyadd.ReadPolicy(policyPath)
+		if policyErr == nil {
+			log.Printf("D! Applying policy %s", policyPath)
+		}
+	})
+	return sharedPolicy, policyErr
+}
 
-	from, err := evaluateFromTemplate(args.From, args.Var)
-	if err != nil {
-		log.Fatalf("failed to evaluate 'from': %s", err)
-	}
+// allowHTTP determines if downloads may use unencrypted HTTP, which is also the case when
+// connecting over a Unix domain socket since the traffic never crosses a network
+func allowHTTP() bool {
+	return networkArgs.AllowHttp || networkArgs.UnixSocket != ""
+}
 
-	file, err := evaluateFromTemplate(args.File, args.Var)
-	if err != nil {
-		log.Fatalf("failed to evaluate 'file': %s", err)
-	}
+// command is a subcommand of easy-add where the fields of args declare its flags
+type command struct {
+	name    string
+	usage   string
+	summary string
+	args    interface{}
+	// network includes the flags of networkArgs
+	network bool
+	run     func(ctx context.Context, flagSet *flag.FlagSet) error
+}
 
-	archiveType, err := getArchiveType(from)
-	if err != nil {
-		log.Fatal(err)
-	}
+// usageError is reported along with the usage of the command
+type usageError struct {
+	msg string
+}
 
-	if args.Mkdirs {
-		err := os.MkdirAll(args.To, 0755)
-		if err != nil {
-			log.Fatal(err)
-		}
-	}
+func (e *usageError) Error() string {
+	return e.msg
+}
 
-	log.Printf("I! Retrieving %s", from)
-	client, err := setupHttpClient()
-	if err != nil {
-		log.Fatal(err)
+func commands() []*command {
+	return []*command{
+		getCommand(),
+		catalogCommand(),
+		listCommand(),
+		verifyCommand(),
+		lockCommand(),
+		bundleCommand(),
+		applyCommand(),
+		exportCommand(),
+		importCommand(),
+		removeCommand(),
+		upgradeCommand(),
+		genCommand(),
+		doctorCommand(),
+		loginCommand(),
+		completionCommand(),
 	}
-	resp, err := client.Get(from)
-	if err != nil {
-		log.Fatal(err)
+}
+
+func main() {
+	cmds := commands()
+
+	// the original flags-only form is the get command
+	name := "get"
+	cmdArgs := os.Args[1:]
+	if len(cmdArgs) > 0 && !strings.HasPrefix(cmdArgs[0], "-") {
+		name, cmdArgs = cmdArgs[0], cmdArgs[1:]
 	}
-	//noinspection GoUnhandledErrorResult
-	defer resp.Body.Close()
 
-	if resp.StatusCode == 200 {
-		outFilePath, err := processArchive(archiveType, resp.Body, file, args.To)
-		if err != nil {
-			log.Fatalf("E! %v", err)
-		}
-		log.Printf("I! Extracted file to %s", outFilePath)
-	} else {
-		log.Fatalf("E! Failed to retrieve archive: %s", resp.Status)
+	if name == "help" {
+		printCommands(os.Stdout, cmds)
+		return
+	}
+	cmd := findCommand(cmds, name)
+	if cmd == nil {
+		// a tool from the catalog, such as easy-add jq@1.7.1
+		cmd, cmdArgs = findCommand(cmds, "get"), os.Args[1:]
 	}
-}
 
-func evaluateFromTemplate(fromTemplate string, vars map[string]string) (string, error) {
-	tmpl, err := template.New("from").Parse(fromTemplate)
+	flagSet, err := newFlagSet(cmd, cmds)
 	if err != nil {
-		return "", err
+		log.Fatal(err)
 	}
+	// exits on error
+	_ = flagSet.Parse(cmdArgs)
 
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, vars)
+	err = applyNetworkArgs()
 	if err != nil {
-		return "", err
+		log.Fatal(err)
 	}
+	log.SetOutput(logWriter)
 
-	return buf.String(), nil
-}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func setupHttpClient() (*http.Client, error) {
-	certPool, err := x509.SystemCertPool()
+	ctx, finishTelemetry := startTelemetry(ctx, cmd.name)
+	err = cmd.run(ctx, flagSet)
+	finishTelemetry(err)
 	if err != nil {
-		log.Printf("W! %v", err)
-		certPool = x509.NewCertPool()
-	}
-	for _, pem := range extraCerts {
-		if !certPool.AppendCertsFromPEM([]byte(pem)) {
-			return nil, errors.New("Unable to add Github CA cert")
+		code := exitCode(err)
+		if ctx.Err() != nil {
+			code = exitInterrupted
 		}
+		var usageErr *usageError
+		switch {
+		case jsonErrors:
+			writeJSONError(os.Stderr, err, code)
+		case errors.As(err, &usageErr):
+			_, _ = fmt.Fprintln(flagSet.Output(), usageErr.msg)
+			flagSet.Usage()
+		case code == exitInterrupted:
+			log.Printf("E! Interrupted: %v", err)
+		default:
+			log.Printf("E! %v", err)
+		}
+		os.Exit(code)
 	}
-
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{RootCAs: certPool},
-		},
-	}
-
-	return client, nil
 }
 
-func processArchive(t ArchiveType, reader io.Reader, file string, to string) (string, error) {
-	switch t {
-	case TarGz:
-		return processTarGz(reader, file, to)
-	case Zip:
-		return processZip(reader, file, to)
+// applyNetworkArgs configures what the network flags declare beyond the HTTP client
+func applyNetworkArgs() error {
+	logWriter.debug = networkArgs.Debug || networkArgs.DebugHttp
+	logWriter.noColor = logWriter.noColor || networkArgs.NoColor
+	switch networkArgs.LogDest {
+	case "", "console":
+	case "syslog":
+		sink, err := openSyslog()
+		if err != nil {
+			return fmt.Errorf("unable to log to syslog: %w", err)
+		}
+		logWriter.sink = sink
 	default:
-		return "", errors.New("invalid archive type")
+		return fmt.Errorf("log-dest must be console or syslog, not '%s'", networkArgs.LogDest)
 	}
+	githubapi.MaxRateLimitWait = networkArgs.GithubRateLimitWait
+	githubapi.UseGHAuth = networkArgs.GhAuth
+	return nil
 }
 
-func processZip(reader io.Reader, file string, to string) (string, error) {
-	body, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return "", fmt.Errorf("failed to read: %w", err)
-	}
-
-	zipReader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
-	if err != nil {
-		return "", fmt.Errorf("failed to read zip content: %w", err)
-	}
-
-	for _, zipFile := range zipReader.File {
-		if zipFile.Name == file {
-			return extractExeFromZip(zipFile, file, to, zipFile.FileInfo())
+func findCommand(cmds []*command, name string) *command {
+	for _, cmd := range cmds {
+		if cmd.name == name {
+			return cmd
 		}
 	}
-
-	return "", errors.New("unable to find requested file in archive")
+	return nil
 }
 
-func extractExeFromZip(file *zip.File, filename string, to string, fileInfo os.FileInfo) (string, error) {
-	r, err := file.Open()
-	if err != nil {
-		return "", fmt.Errorf("unable to open zip file: %w", err)
+func printCommands(out io.Writer, cmds []*command) {
+	_, _ = fmt.Fprintln(out, "Usage: easy-add [command] [flags]\n       easy-add [flags] TOOL[@VERSION]")
+	_, _ = fmt.Fprintln(out, "\nCommands:")
+	for _, cmd := range cmds {
+		_, _ = fmt.Fprintf(out, "  %-12s %s\n", cmd.name, cmd.summary)
 	}
-	//noinspection GoUnhandledErrorResult
-	defer r.Close()
-
-	return extractExe(r, filename, to, fileInfo)
+	_, _ = fmt.Fprintln(out, "\nThe get command is used when no command is given. Use easy-add [command] --help for the flags of each.")
 }
 
-func processTarGz(reader io.Reader, file string, to string) (string, error) {
-	gzipReader, err := gzip.NewReader(reader)
+// newFlagSet declares the flags of the command, which are pre-set from environment variables
+// and config files
+func newFlagSet(cmd *command, cmds []*command) (*flag.FlagSet, error) {
+	flagSet := flag.NewFlagSet("easy-add "+cmd.name, flag.ExitOnError)
+
+	err := declareFlags(flagSet, cmd, &networkArgs, flagsfiller.WithEnv("EasyAdd"))
 	if err != nil {
-		return "", fmt.Errorf("failed to read gzip content: %w", err)
+		return nil, err
+	}
+	targets := []interface{}{cmd.args}
+	if cmd.network {
+		targets = append(targets, &networkArgs)
+	}
+	err = applyConfigFiles(flagSet, knownFlagNames(), targets...)
+	if err != nil {
+		return nil, err
 	}
 
-	tarReader := tar.NewReader(gzipReader)
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			return "", errors.New("unable to find requested file in archive")
-		}
-
-		if header.Name == file {
-			return extractExe(tarReader, file, to, header.FileInfo())
+	flagSet.Usage = func() {
+		out := flagSet.Output()
+		_, _ = fmt.Fprintf(out, "Usage: easy-add %s %s\n\n%s\n\nFlags:\n", cmd.name, cmd.usage, cmd.summary)
+		flagSet.PrintDefaults()
+		if cmd.name == "get" {
+			_, _ = fmt.Fprintln(out)
+			printCommands(out, cmds)
 		}
 	}
+	return flagSet, nil
 }
 
-func extractExe(reader io.Reader, filename string, to string, fileInfo os.FileInfo) (string, error) {
-	outPath := path.Join(to, path.Base(filename))
-
-	file, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE, 0755)
+func declareFlags(flagSet *flag.FlagSet, cmd *command, network *networkFlags, options ...flagsfiller.FillerOption) error {
+	filler := flagsfiller.New(options...)
+	err := filler.Fill(flagSet, cmd.args)
 	if err != nil {
-		return "", fmt.Errorf("unable to create destination file: %w", err)
+		return err
 	}
-	//noinspection GoUnhandledErrorResult
-	defer file.Close()
-
-	_, err = io.Copy(file, reader)
-	if err != nil {
-		return "", fmt.Errorf("unable to copy extracted file content: %w", err)
+	if cmd.network {
+		return filler.Fill(flagSet, network)
 	}
-
-	return outPath, nil
+	return nil
 }
 
-func getArchiveType(url string) (ArchiveType, error) {
-	url = strings.ToLower(url)
-	if strings.HasSuffix(url, ".tar.gz") || strings.HasSuffix(url, ".tgz") {
-		return TarGz, nil
-	} else if strings.HasSuffix(url, ".zip") {
-		return Zip, nil
-	} else {
-		return -1, errors.New("only supports processing archives tar-gzipped files with tar.gz or tgz suffix, or zipped files with zip suffix")
+// knownFlagNames collects the flag names of all commands, which are declared on separate
+// instances to leave the args being parsed untouched
+func knownFlagNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, cmd := range commands() {
+		flagSet := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		if declareFlags(flagSet, cmd, &networkFlags{}) != nil {
+			continue
+		}
+		flagSet.VisitAll(func(f *flag.Flag) {
+			names[f.Name] = true
+		})
 	}
+	return names
+}
+
+// flagWasSet determines if the named flag was given on the command line
+func flagWasSet(flagSet *flag.FlagSet, name string) bool {
+	set := false
+	flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
@@ -0,0 +1,255 @@
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"strings"
+)
+
+const (
+	intro         = "age-encryption.org/v1\n"
+	x25519Label   = "age-encryption.org/v1/X25519"
+	fileKeySize   = 16
+	nonceSize     = 16
+	chunkSize     = 64 * 1024
+	encChunkSize  = chunkSize + chacha20poly1305.Overhead
+	maxHeaderLine = 1024
+)
+
+// ErrNoIdentityMatched indicates none of the identities are a recipient of the file
+var ErrNoIdentityMatched = errors.New("the file is not encrypted to any of the age identities")
+
+var b64 = base64.RawStdEncoding.Strict()
+
+// stanza is a recipient entry of the header
+type stanza struct {
+	kind string
+	args []string
+	body []byte
+}
+
+// Decrypt reads the header of the age encrypted content, unwraps its file key with one of the
+// identities, and returns a reader of the plaintext. Reading fails if the payload was modified.
+func Decrypt(src io.Reader, identities []*Identity) (io.Reader, error) {
+	r := bufio.NewReader(src)
+
+	stanzas, headerBytes, mac, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileKey []byte
+	for _, s := range stanzas {
+		if s.kind != "X25519" {
+			continue
+		}
+		for _, identity := range identities {
+			fileKey, err = identity.unwrap(s)
+			if err != nil {
+				return nil, err
+			}
+			if fileKey != nil {
+				break
+			}
+		}
+		if fileKey != nil {
+			break
+		}
+	}
+	if fileKey == nil {
+		return nil, ErrNoIdentityMatched
+	}
+
+	headerMAC := hmac.New(sha256.New, deriveKey(fileKey, nil, "header"))
+	headerMAC.Write(headerBytes)
+	if !hmac.Equal(headerMAC.Sum(nil), mac) {
+		return nil, errors.New("age header was modified")
+	}
+
+	nonce := make([]byte, nonceSize)
+	_, err = io.ReadFull(r, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age payload nonce: %w", err)
+	}
+	aead, err := chacha20poly1305.New(deriveKey(fileKey, nonce, "payload"))
+	if err != nil {
+		return nil, err
+	}
+	return &payloadReader{src: r, aead: aead}, nil
+}
+
+// readHeader returns the stanzas, the header bytes covered by the MAC, and the MAC
+func readHeader(r *bufio.Reader) ([]*stanza, []byte, []byte, error) {
+	var header bytes.Buffer
+	readLine := func() (string, error) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("failed to read age header: %w", err)
+		}
+		if len(line) > maxHeaderLine {
+			return "", errors.New("invalid age header: line too long")
+		}
+		header.WriteString(line)
+		return line, nil
+	}
+
+	line, err := readLine()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if line != intro {
+		return nil, nil, nil, errors.New("not an age encrypted file")
+	}
+
+	var stanzas []*stanza
+	line, err = readLine()
+	for err == nil {
+		if strings.HasPrefix(line, "--- ") {
+			mac, err := b64.DecodeString(strings.TrimSuffix(line[4:], "\n"))
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("invalid age header MAC: %w", err)
+			}
+			// the MAC covers the header up to and including ---
+			covered := header.Bytes()[:header.Len()-len(line)+3]
+			return stanzas, covered, mac, nil
+		}
+		if !strings.HasPrefix(line, "-> ") {
+			return nil, nil, nil, errors.New("invalid age header: malformed stanza")
+		}
+		fields := strings.Fields(line[3:])
+		if len(fields) == 0 {
+			return nil, nil, nil, errors.New("invalid age header: stanza without a type")
+		}
+		s := &stanza{kind: fields[0], args: fields[1:]}
+
+		// the body is wrapped at 64 columns and ends with a shorter, possibly empty, line
+		for {
+			line, err = readLine()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			text := strings.TrimSuffix(line, "\n")
+			decoded, err := b64.DecodeString(text)
+			if err != nil || len(text) > 64 {
+				return nil, nil, nil, errors.New("invalid age header: malformed stanza body")
+			}
+			s.body = append(s.body, decoded...)
+			if len(text) < 64 {
+				break
+			}
+		}
+		stanzas = append(stanzas, s)
+
+		line, err = readLine()
+	}
+	return nil, nil, nil, err
+}
+
+// unwrap returns the file key when the stanza is for this identity, or nil otherwise
+func (i *Identity) unwrap(s *stanza) ([]byte, error) {
+	if len(s.args) != 1 {
+		return nil, errors.New("invalid age X25519 stanza")
+	}
+	share, err := b64.DecodeString(s.args[0])
+	if err != nil || len(share) != curve25519.PointSize {
+		return nil, errors.New("invalid age X25519 stanza")
+	}
+	if len(s.body) != fileKeySize+chacha20poly1305.Overhead {
+		return nil, errors.New("invalid age X25519 stanza")
+	}
+
+	shared, err := curve25519.X25519(i.secretKey, share)
+	if err != nil {
+		return nil, fmt.Errorf("invalid age X25519 stanza: %w", err)
+	}
+	salt := make([]byte, 0, len(share)+len(i.publicKey))
+	salt = append(append(salt, share...), i.publicKey...)
+
+	aead, err := chacha20poly1305.New(deriveKey(shared, salt, x25519Label))
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), s.body, nil)
+	if err != nil {
+		// encrypted to a different recipient
+		return nil, nil
+	}
+	return fileKey, nil
+}
+
+func deriveKey(secret []byte, salt []byte, info string) []byte {
+	key := make([]byte, chacha20poly1305.KeySize)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key)
+	return key
+}
+
+// payloadReader decrypts the STREAM chunks of the payload
+type payloadReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	counter uint64
+	buf     []byte
+	plain   []byte
+	done    bool
+}
+
+func (p *payloadReader) Read(out []byte) (int, error) {
+	for len(p.plain) == 0 {
+		if p.done {
+			return 0, io.EOF
+		}
+		err := p.readChunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(out, p.plain)
+	p.plain = p.plain[n:]
+	return n, nil
+}
+
+func (p *payloadReader) readChunk() error {
+	if p.buf == nil {
+		p.buf = make([]byte, encChunkSize)
+	}
+	n, err := io.ReadFull(p.src, p.buf)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		last = true
+	case err != nil:
+		return fmt.Errorf("failed to read age payload: %w", err)
+	default:
+		// a full chunk is the last one only when nothing follows
+		if _, peekErr := p.src.Peek(1); peekErr == io.EOF {
+			last = true
+		}
+	}
+	if n < chacha20poly1305.Overhead || (last && n == chacha20poly1305.Overhead && p.counter > 0) {
+		return errors.New("age payload is truncated")
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], p.counter)
+	if last {
+		nonce[11] = 1
+	}
+	p.plain, err = p.aead.Open(p.buf[:0], nonce, p.buf[:n], nil)
+	if err != nil {
+		return errors.New("age payload was modified or truncated")
+	}
+	p.counter++
+	p.done = last
+	return nil
+}
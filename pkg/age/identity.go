@@ -0,0 +1,85 @@
+// Package age decrypts files encrypted with age (https://age-encryption.org/v1) to X25519
+// recipients. age only provides confidentiality, so a decrypted file is no more authentic than
+// whoever could encrypt to the recipient.
+package age
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/curve25519"
+	"io"
+	"os"
+	"strings"
+)
+
+const secretKeyPrefix = "age-secret-key-"
+
+// Identity is the X25519 private key of a recipient
+type Identity struct {
+	secretKey []byte
+	publicKey []byte
+}
+
+// ParseIdentity parses an AGE-SECRET-KEY-1... key
+func ParseIdentity(s string) (*Identity, error) {
+	hrp, data, err := decodeBech32(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identity: %w", err)
+	}
+	if hrp != secretKeyPrefix {
+		return nil, errors.New("invalid age identity: not a secret key")
+	}
+	if len(data) != curve25519.ScalarSize {
+		return nil, errors.New("invalid age identity: wrong key length")
+	}
+
+	publicKey, err := curve25519.X25519(data, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identity: %w", err)
+	}
+	return &Identity{secretKey: data, publicKey: publicKey}, nil
+}
+
+// ParseIdentities parses the identities in the format written by age-keygen, where blank lines
+// and those starting with # are ignored
+func ParseIdentities(r io.Reader) ([]*Identity, error) {
+	var identities []*Identity
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := ParseIdentity(line)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("no age identities were found")
+	}
+	return identities, nil
+}
+
+// LoadIdentities reads the identities of the given files
+func LoadIdentities(paths []string) ([]*Identity, error) {
+	var identities []*Identity
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read age identities: %w", err)
+		}
+		loaded, err := ParseIdentities(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		identities = append(identities, loaded...)
+	}
+	return identities, nil
+}
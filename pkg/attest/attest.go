@@ -0,0 +1,132 @@
+// Package attest verifies Sigstore-signed in-toto attestations, such as SLSA provenance and
+// GitHub artifact attestations, along with cosign blob signatures against the public-good
+// Sigstore instance.
+//
+// The signing certificate is verified against the embedded Fulcio certificate authorities at
+// the time the transparency log recorded the signature, which is established by the log's
+// signed entry timestamp. Inclusion proofs and checkpoints are not verified, so the log is
+// trusted to have published what it promised. Attestations without a log entry, such as the
+// older slsa-github-generator envelopes, can only be verified as of their certificate's issue time.
+package attest
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// Bundle is a signed attestation along with what's needed to verify it
+type Bundle struct {
+	Envelope Envelope
+	// Certificate signed the envelope
+	Certificate *x509.Certificate
+	// Entries are from the transparency log, if any
+	Entries []LogEntry
+}
+
+// Envelope is a DSSE envelope
+type Envelope struct {
+	PayloadType string `json:"payloadType"`
+	// Payload is base64 encoded
+	Payload    string      `json:"payload"`
+	Signatures []Signature `json:"signatures"`
+}
+
+// Signature is of a DSSE envelope, where Cert is set by slsa-github-generator
+type Signature struct {
+	KeyID string `json:"keyid"`
+	// Sig is base64 encoded
+	Sig  string `json:"sig"`
+	Cert string `json:"cert,omitempty"`
+}
+
+// LogEntry is a transparency log entry of a bundle
+type LogEntry struct {
+	LogIndex int64 `json:"logIndex,string"`
+	LogID    struct {
+		KeyID string `json:"keyId"`
+	} `json:"logId"`
+	IntegratedTime   int64 `json:"integratedTime,string"`
+	InclusionPromise struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	CanonicalizedBody string `json:"canonicalizedBody"`
+}
+
+type bundleJSON struct {
+	MediaType            string `json:"mediaType"`
+	VerificationMaterial struct {
+		X509CertificateChain struct {
+			Certificates []struct {
+				RawBytes string `json:"rawBytes"`
+			} `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		Certificate struct {
+			RawBytes string `json:"rawBytes"`
+		} `json:"certificate"`
+		TlogEntries []LogEntry `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	DsseEnvelope *Envelope `json:"dsseEnvelope"`
+}
+
+// ParseBundle parses either a Sigstore bundle or a DSSE envelope whose signature includes its certificate
+func ParseBundle(content []byte) (*Bundle, error) {
+	var parsed bundleJSON
+	err := json.Unmarshal(content, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse attestation: %w", err)
+	}
+
+	if parsed.MediaType == "" {
+		// a bare envelope
+		var envelope Envelope
+		err = json.Unmarshal(content, &envelope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse attestation: %w", err)
+		}
+		if len(envelope.Signatures) == 0 || envelope.Signatures[0].Cert == "" {
+			return nil, fmt.Errorf("attestation envelope doesn't include a signing certificate")
+		}
+		block, _ := pem.Decode([]byte(envelope.Signatures[0].Cert))
+		if block == nil {
+			return nil, fmt.Errorf("attestation signing certificate is not PEM encoded")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse attestation signing certificate: %w", err)
+		}
+		return &Bundle{Envelope: envelope, Certificate: cert}, nil
+	}
+
+	if !strings.HasPrefix(parsed.MediaType, "application/vnd.dev.sigstore.bundle") {
+		return nil, fmt.Errorf("unsupported attestation media type %s", parsed.MediaType)
+	}
+	if parsed.DsseEnvelope == nil {
+		return nil, fmt.Errorf("attestation bundle doesn't contain a DSSE envelope")
+	}
+
+	rawCert := parsed.VerificationMaterial.Certificate.RawBytes
+	if rawCert == "" && len(parsed.VerificationMaterial.X509CertificateChain.Certificates) > 0 {
+		rawCert = parsed.VerificationMaterial.X509CertificateChain.Certificates[0].RawBytes
+	}
+	if rawCert == "" {
+		return nil, fmt.Errorf("attestation bundle doesn't contain a signing certificate")
+	}
+	der, err := base64.StdEncoding.DecodeString(rawCert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode attestation signing certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse attestation signing certificate: %w", err)
+	}
+
+	return &Bundle{
+		Envelope:    *parsed.DsseEnvelope,
+		Certificate: cert,
+		Entries:     parsed.VerificationMaterial.TlogEntries,
+	}, nil
+}
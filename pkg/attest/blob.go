@@ -0,0 +1,58 @@
+package attest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ParseCertificate parses a PEM certificate, such as written by cosign sign-blob
+// --output-certificate, which may itself be base64 encoded
+func ParseCertificate(content []byte) (*x509.Certificate, error) {
+	content = bytes.TrimSpace(content)
+	if !bytes.HasPrefix(content, []byte("-----BEGIN")) {
+		decoded, err := base64.StdEncoding.DecodeString(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing certificate: %w", err)
+		}
+		content = decoded
+	}
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("invalid signing certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// VerifyBlob checks the base64 encoded signature, as made by cosign sign-blob, of a blob with
+// the given SHA256 digest along with the certificate that signed it. Since a detached signature
+// has no transparency log entry, the certificate is verified as of its issue time.
+func VerifyBlob(cert *x509.Certificate, signature []byte, digest []byte) (*Identity, error) {
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         certPool(fulcioRoots),
+		Intermediates: certPool(fulcioIntermediates),
+		CurrentTime:   cert.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("signing certificate was not issued by Sigstore: %w", err)
+	}
+
+	publicKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key type %T", cert.PublicKey)
+	}
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !ecdsa.VerifyASN1(publicKey, digest, sig) {
+		return nil, errors.New("signature is not valid")
+	}
+
+	return certIdentity(cert)
+}
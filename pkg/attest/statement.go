@@ -0,0 +1,122 @@
+package attest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	inTotoPayloadType = "application/vnd.in-toto+json"
+	slsaV02           = "https://slsa.dev/provenance/v0.2"
+	slsaV1            = "https://slsa.dev/provenance/v1"
+)
+
+// Statement is an in-toto statement where, for SLSA provenance, BuilderID and SourceRepository
+// are extracted from the predicate
+type Statement struct {
+	PredicateType string
+	Subjects      []Subject
+	// BuilderID identifies what produced the subjects, such as
+	// https://github.com/actions/runner/github-hosted
+	BuilderID string
+	// SourceRepository is the URI of the repository that was built
+	SourceRepository string
+}
+
+// Subject is an artifact that a statement describes
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaPredicate struct {
+	// v0.2
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	Invocation struct {
+		ConfigSource struct {
+			URI string `json:"uri"`
+		} `json:"configSource"`
+	} `json:"invocation"`
+
+	// v1
+	BuildDefinition struct {
+		ExternalParameters struct {
+			Workflow struct {
+				Repository string `json:"repository"`
+			} `json:"workflow"`
+			Source struct {
+				URI string `json:"uri"`
+			} `json:"source"`
+		} `json:"externalParameters"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+	} `json:"runDetails"`
+}
+
+func parseStatement(payloadType string, payload []byte) (*Statement, error) {
+	if payloadType != inTotoPayloadType {
+		return nil, fmt.Errorf("unsupported attestation payload type %s", payloadType)
+	}
+
+	var parsed struct {
+		PredicateType string          `json:"predicateType"`
+		Subject       []Subject       `json:"subject"`
+		Predicate     json.RawMessage `json:"predicate"`
+	}
+	err := json.Unmarshal(payload, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse attestation statement: %w", err)
+	}
+
+	statement := &Statement{
+		PredicateType: parsed.PredicateType,
+		Subjects:      parsed.Subject,
+	}
+	if parsed.PredicateType == slsaV02 || parsed.PredicateType == slsaV1 {
+		var predicate slsaPredicate
+		err = json.Unmarshal(parsed.Predicate, &predicate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SLSA provenance: %w", err)
+		}
+		if parsed.PredicateType == slsaV02 {
+			statement.BuilderID = predicate.Builder.ID
+			statement.SourceRepository = trimRef(predicate.Invocation.ConfigSource.URI)
+		} else {
+			statement.BuilderID = predicate.RunDetails.Builder.ID
+			statement.SourceRepository = predicate.BuildDefinition.ExternalParameters.Workflow.Repository
+			if statement.SourceRepository == "" {
+				statement.SourceRepository = trimRef(predicate.BuildDefinition.ExternalParameters.Source.URI)
+			}
+		}
+	}
+	return statement, nil
+}
+
+// trimRef removes the @ref from a URI like git+https://github.com/owner/repo@refs/tags/v1.0.0
+func trimRef(uri string) string {
+	if i := strings.LastIndex(uri, "@"); i >= 0 {
+		return uri[:i]
+	}
+	return uri
+}
+
+// IsSLSAProvenance determines if the statement's predicate is SLSA provenance
+func (s *Statement) IsSLSAProvenance() bool {
+	return s.PredicateType == slsaV02 || s.PredicateType == slsaV1
+}
+
+// HasSubject determines if the statement describes an artifact with the given sha256 digest
+func (s *Statement) HasSubject(sha256 string) bool {
+	for _, subject := range s.Subjects {
+		if strings.EqualFold(subject.Digest["sha256"], sha256) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package attest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Identity is what the Fulcio signing certificate asserts about the workflow that signed
+type Identity struct {
+	// SubjectURI is the workflow that signed, such as
+	// https://github.com/owner/repo/.github/workflows/release.yml@refs/tags/v1.0.0
+	SubjectURI string
+	// Issuer is the OIDC issuer, such as https://token.actions.githubusercontent.com
+	Issuer string
+	// SourceRepository is the URI of the repository that was built, when the certificate declares it
+	SourceRepository string
+}
+
+var (
+	oidIssuerV1         = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidWorkflowRepo     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 5}
+	oidIssuerV2         = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+	oidSourceRepository = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 12}
+)
+
+// Verify checks the signature of the bundle's envelope, the certificate that signed it, and
+// any transparency log entries. It returns the identity asserted by the certificate along
+// with the verified statement.
+func Verify(bundle *Bundle) (*Identity, *Statement, error) {
+	envelope := bundle.Envelope
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode attestation payload: %w", err)
+	}
+
+	signedAt, err := verifyLogEntries(bundle, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = bundle.Certificate.Verify(x509.VerifyOptions{
+		Roots:         certPool(fulcioRoots),
+		Intermediates: certPool(fulcioIntermediates),
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("attestation signing certificate was not issued by Sigstore: %w", err)
+	}
+
+	publicKey, ok := bundle.Certificate.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported attestation signing key type %T", bundle.Certificate.PublicKey)
+	}
+	digest := sha256.Sum256(pae(envelope.PayloadType, payload))
+	verified := false
+	for _, signature := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(signature.Sig)
+		if err == nil && ecdsa.VerifyASN1(publicKey, digest[:], sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, nil, fmt.Errorf("attestation signature is not valid")
+	}
+
+	identity, err := certIdentity(bundle.Certificate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statement, err := parseStatement(envelope.PayloadType, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return identity, statement, nil
+}
+
+// verifyLogEntries checks the signed entry timestamps of the bundle and returns when the
+// signature was logged or, without any entries, when the certificate was issued
+func verifyLogEntries(bundle *Bundle, payload []byte) (time.Time, error) {
+	if len(bundle.Entries) == 0 {
+		return bundle.Certificate.NotBefore, nil
+	}
+
+	payloadHash := sha256.Sum256(payload)
+	for _, entry := range bundle.Entries {
+		keyPEM, exists := rekorKeys[entry.LogID.KeyID]
+		if !exists {
+			return time.Time{}, fmt.Errorf("attestation was logged to an unknown transparency log %s", entry.LogID.KeyID)
+		}
+		key, err := parsePublicKey(keyPEM)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		logID, err := base64.StdEncoding.DecodeString(entry.LogID.KeyID)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid transparency log ID: %w", err)
+		}
+		// the fields are marshalled in sorted order and without whitespace, which is the canonical form
+		canonical, err := json.Marshal(struct {
+			Body           string `json:"body"`
+			IntegratedTime int64  `json:"integratedTime"`
+			LogID          string `json:"logID"`
+			LogIndex       int64  `json:"logIndex"`
+		}{
+			Body:           entry.CanonicalizedBody,
+			IntegratedTime: entry.IntegratedTime,
+			LogID:          hex.EncodeToString(logID),
+			LogIndex:       entry.LogIndex,
+		})
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		set, err := base64.StdEncoding.DecodeString(entry.InclusionPromise.SignedEntryTimestamp)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid signed entry timestamp: %w", err)
+		}
+		canonicalHash := sha256.Sum256(canonical)
+		ecdsaKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || !ecdsa.VerifyASN1(ecdsaKey, canonicalHash[:], set) {
+			return time.Time{}, fmt.Errorf("transparency log entry %d has an invalid signed entry timestamp", entry.LogIndex)
+		}
+
+		body, err := base64.StdEncoding.DecodeString(entry.CanonicalizedBody)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid transparency log entry body: %w", err)
+		}
+		if !bytes.Contains(body, []byte(hex.EncodeToString(payloadHash[:]))) {
+			return time.Time{}, fmt.Errorf("transparency log entry %d is not of this attestation", entry.LogIndex)
+		}
+
+		signedAt := time.Unix(entry.IntegratedTime, 0)
+		if signedAt.Before(bundle.Certificate.NotBefore) || signedAt.After(bundle.Certificate.NotAfter) {
+			return time.Time{}, fmt.Errorf("attestation was logged outside of its certificate's validity")
+		}
+		return signedAt, nil
+	}
+	return time.Time{}, nil
+}
+
+// pae is the DSSE pre-authentication encoding that is signed
+func pae(payloadType string, payload []byte) []byte {
+	var b bytes.Buffer
+	_, _ = fmt.Fprintf(&b, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	b.Write(payload)
+	return b.Bytes()
+}
+
+func certIdentity(cert *x509.Certificate) (*Identity, error) {
+	identity := &Identity{}
+	if len(cert.URIs) > 0 {
+		identity.SubjectURI = cert.URIs[0].String()
+	} else if len(cert.EmailAddresses) > 0 {
+		identity.SubjectURI = cert.EmailAddresses[0]
+	}
+
+	var workflowRepo string
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			_, _ = asn1.Unmarshal(ext.Value, &identity.Issuer)
+		case ext.Id.Equal(oidIssuerV1):
+			if identity.Issuer == "" {
+				identity.Issuer = string(ext.Value)
+			}
+		case ext.Id.Equal(oidSourceRepository):
+			_, _ = asn1.Unmarshal(ext.Value, &identity.SourceRepository)
+		case ext.Id.Equal(oidWorkflowRepo):
+			workflowRepo = string(ext.Value)
+		}
+	}
+	if identity.SourceRepository == "" && workflowRepo != "" {
+		identity.SourceRepository = "https://github.com/" + workflowRepo
+	}
+
+	if identity.SubjectURI == "" || identity.Issuer == "" {
+		return nil, fmt.Errorf("attestation signing certificate is missing its identity")
+	}
+	return identity, nil
+}
+
+// RepoMatches determines if the repository URI, such as https://github.com/owner/repo, is the
+// given repo, which may omit the scheme and host such as owner/repo
+func RepoMatches(repositoryURI string, repo string) bool {
+	normalize := func(s string) string {
+		s = strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(s), "/"), ".git")
+		s = strings.TrimPrefix(s, "git+")
+		s = strings.TrimPrefix(s, "https://")
+		return strings.TrimPrefix(s, "github.com/")
+	}
+	return repositoryURI != "" && normalize(repositoryURI) == normalize(repo)
+}
@@ -0,0 +1,262 @@
+// Package bundle packs verified archives into a single tar file, along with an index of how to
+// install them, for carrying to hosts that can't download them, such as those that are air-gapped
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// IndexName is the path of the index within a bundle
+const IndexName = "bundle.yaml"
+
+// archivesDir is where archives are placed within a bundle
+const archivesDir = "archives"
+
+// Index describes the archives of a bundle
+type Index struct {
+	// To is the directory where the tools are installed, which defaults to install.DefaultDir
+	To    string   `yaml:"to,omitempty"`
+	Tools []*Entry `yaml:"tools"`
+}
+
+// Entry is the archive of a tool for a platform
+type Entry struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	// Platform is given as os/arch using Go's names
+	Platform string `yaml:"platform"`
+	// Archive is the path of the archive within the bundle
+	Archive string `yaml:"archive"`
+	// From is the URL template that the archive was downloaded from, which is evaluated with Vars
+	From   string            `yaml:"from"`
+	File   string            `yaml:"file"`
+	Format string            `yaml:"format,omitempty"`
+	Vars   map[string]string `yaml:"vars,omitempty"`
+	// Checksum is the digest of the archive as algorithm:hex
+	Checksum string `yaml:"checksum"`
+}
+
+// Writer adds archives to a bundle, which is complete once closed
+type Writer struct {
+	file  *os.File
+	tw    *tar.Writer
+	index Index
+}
+
+// Create starts a bundle at the given path, where to is recorded in its index
+func Create(path string, to string) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create bundle: %w", err)
+	}
+	return &Writer{
+		file:  file,
+		tw:    tar.NewWriter(file),
+		index: Index{To: to},
+	}, nil
+}
+
+// Add copies the archive at the given path into the bundle and records the entry for it, where
+// the Archive of the entry is set
+func (w *Writer) Add(entry *Entry, archivePath string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	entry.Archive = path.Join(archivesDir, entry.Name, strings.ReplaceAll(entry.Platform, "/", "-"),
+		filepath.Base(archivePath))
+	err = w.tw.WriteHeader(&tar.Header{
+		Name:    entry.Archive,
+		Mode:    0644,
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write bundle: %w", err)
+	}
+	_, err = io.Copy(w.tw, f)
+	if err != nil {
+		return fmt.Errorf("unable to write bundle: %w", err)
+	}
+	w.index.Tools = append(w.index.Tools, entry)
+	return nil
+}
+
+// Close writes the index, which is placed last in the bundle, and closes the file. The bundle
+// is removed if that fails.
+func (w *Writer) Close() error {
+	err := w.writeIndex()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(w.file.Name())
+		return fmt.Errorf("unable to write bundle: %w", err)
+	}
+	return nil
+}
+
+func (w *Writer) writeIndex() error {
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	err := encoder.Encode(&w.index)
+	if err != nil {
+		return err
+	}
+	err = w.tw.WriteHeader(&tar.Header{
+		Name:    IndexName,
+		Mode:    0644,
+		Size:    int64(content.Len()),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.tw.Write(content.Bytes())
+	if err != nil {
+		return err
+	}
+	return w.tw.Close()
+}
+
+// Abort closes and removes a bundle that won't be completed
+func (w *Writer) Abort() {
+	_ = w.file.Close()
+	_ = os.Remove(w.file.Name())
+}
+
+// Extract unpacks the bundle at the given path into dir and returns its index, where the Archive
+// of each entry is then the path of its file within dir
+func Extract(bundlePath string, dir string) (*Index, error) {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open bundle: %w", err)
+	}
+	//noinspection GoUnhandledErrorResult
+	defer f.Close()
+
+	var index *Index
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read bundle %s: %w", bundlePath, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if header.Name == IndexName {
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read bundle %s: %w", bundlePath, err)
+			}
+			index = &Index{}
+			err = yaml.Unmarshal(content, index)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse index of bundle %s: %w", bundlePath, err)
+			}
+			continue
+		}
+
+		dest, err := within(dir, header.Name)
+		if err != nil {
+			return nil, err
+		}
+		err = extractFile(tr, dest)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if index == nil {
+		return nil, fmt.Errorf("%s is not a bundle since it has no %s", bundlePath, IndexName)
+	}
+
+	for _, entry := range index.Tools {
+		entry.Archive, err = within(dir, entry.Archive)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+// within resolves the path of a bundle entry within dir, refusing those that would be outside of it
+func within(dir string, name string) (string, error) {
+	cleaned := path.Clean(name)
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("bundle entry %s is outside of the bundle", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(cleaned)), nil
+}
+
+func extractFile(r io.Reader, dest string) error {
+	err := os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Select returns the entries for the platform, given as os/arch, which are limited to the named
+// tools when names are given
+func (i *Index) Select(platform string, names []string) ([]*Entry, error) {
+	var selected []*Entry
+	found := make(map[string]bool)
+	for _, entry := range i.Tools {
+		if entry.Platform != platform {
+			continue
+		}
+		if len(names) > 0 && !contains(names, entry.Name) {
+			continue
+		}
+		found[entry.Name] = true
+		selected = append(selected, entry)
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("%s for %s is not in the bundle", name, platform)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("the bundle has no tools for %s", platform)
+	}
+	return selected, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package catalog
+
+// builtin is the curated catalog shipped with easy-add
+var builtin = &Catalog{
+	Tools: map[string]*Tool{
+		"easy-add": {
+			Repo:    "itzg/easy-add",
+			License: "MIT",
+			From:    "https://github.com/itzg/easy-add/releases/download/{{.version}}/easy-add_{{.os}}_{{.arch}}",
+			File:    "easy-add",
+			Arch: map[string]string{
+				"arm": "armv7",
+			},
+			Format: "binary",
+		},
+		"helm": {
+			Repo:      "helm/helm",
+			License:   "Apache-2.0",
+			TagPrefix: "v",
+			From:      "https://get.helm.sh/helm-v{{.version}}-{{.os}}-{{.arch}}.tar.gz",
+			File:      "{{.os}}-{{.arch}}/helm",
+		},
+		"jq": {
+			Repo:      "jqlang/jq",
+			License:   "MIT",
+			TagPrefix: "jq-",
+			From:      "https://github.com/jqlang/jq/releases/download/jq-{{.version}}/jq-{{.os}}-{{.arch}}",
+			File:      "jq",
+			Format:    "binary",
+			OS: map[string]string{
+				"darwin": "macos",
+			},
+			Arch: map[string]string{
+				"arm": "armhf",
+			},
+		},
+		"kubectl": {
+			Repo:      "kubernetes/kubernetes",
+			License:   "Apache-2.0",
+			TagPrefix: "v",
+			From:      "https://dl.k8s.io/release/v{{.version}}/bin/{{.os}}/{{.arch}}/kubectl",
+			File:      "kubectl",
+			Format:    "binary",
+		},
+		"mc-monitor": {
+			Repo: "itzg/mc-monitor",
+			From: "https://github.com/itzg/mc-monitor/releases/download/{{.version}}/mc-monitor_{{.version}}_{{.os}}_{{.arch}}.tar.gz",
+			File: "mc-monitor",
+		},
+		"rcon-cli": {
+			Repo: "itzg/rcon-cli",
+			From: "https://github.com/itzg/rcon-cli/releases/download/{{.version}}/rcon-cli_{{.version}}_{{.os}}_{{.arch}}.tar.gz",
+			File: "rcon-cli",
+		},
+		"restify": {
+			Repo: "itzg/restify",
+			From: "https://github.com/itzg/restify/releases/download/{{.version}}/restify_{{.version}}_{{.os}}_{{.arch}}.tar.gz",
+			File: "restify",
+		},
+		"yq": {
+			Repo:      "mikefarah/yq",
+			License:   "MIT",
+			TagPrefix: "v",
+			From:      "https://github.com/mikefarah/yq/releases/download/v{{.version}}/yq_{{.os}}_{{.arch}}",
+			File:      "yq",
+			Format:    "binary",
+		},
+	},
+}
+
+// Builtin returns the curated catalog shipped with easy-add
+func Builtin() *Catalog {
+	return builtin
+}
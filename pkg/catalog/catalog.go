@@ -0,0 +1,95 @@
+// Package catalog names tools along with how to download them, so they can be installed by a short name
+package catalog
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Catalog declares tools by name
+type Catalog struct {
+	Tools map[string]*Tool `yaml:"tools"`
+}
+
+// Tool declares how to download a tool. From and File are Go templates that may reference
+// the version, os, and arch variables.
+type Tool struct {
+	// Repo is the GitHub owner/name whose latest release is used when no version is given
+	Repo string `yaml:"repo,omitempty"`
+	// TagPrefix is removed from the release tag to give the version, such as v
+	TagPrefix string `yaml:"tagPrefix,omitempty"`
+	From      string `yaml:"from"`
+	File      string `yaml:"file"`
+	// Format of the download, such as binary, which is otherwise detected from the suffix of From
+	Format string `yaml:"format,omitempty"`
+	// OS and Arch map Go's names for the current platform to those used by the tool's releases
+	OS   map[string]string `yaml:"os,omitempty"`
+	Arch map[string]string `yaml:"arch,omitempty"`
+	// License is the SPDX license ID of the tool, if known
+	License string `yaml:"license,omitempty"`
+	// Checksums are the digests of downloads, as algorithm:hex, by version and then by os/arch
+	// using Go's names, such as linux/amd64
+	Checksums map[string]map[string]string `yaml:"checksums,omitempty"`
+}
+
+// Lookup finds the named tool
+func (c *Catalog) Lookup(name string) (*Tool, error) {
+	tool, exists := c.Tools[name]
+	if !exists {
+		return nil, fmt.Errorf("%s is not in the catalog", name)
+	}
+	return tool, nil
+}
+
+// Names returns the names of the tools in sorted order
+func (c *Catalog) Names() []string {
+	names := make([]string, 0, len(c.Tools))
+	for name := range c.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Vars are the template variables of the tool at the given version for the current platform
+func (t *Tool) Vars(version string) map[string]string {
+	return t.PlatformVars(version, runtime.GOOS, runtime.GOARCH)
+}
+
+// PlatformVars are the template variables of the tool at the given version for the platform
+// given by Go's names
+func (t *Tool) PlatformVars(version string, goos string, goarch string) map[string]string {
+	vars := map[string]string{
+		"version": version,
+		"os":      goos,
+		"arch":    goarch,
+	}
+	if os, exists := t.OS[goos]; exists {
+		vars["os"] = os
+	}
+	if arch, exists := t.Arch[goarch]; exists {
+		vars["arch"] = arch
+	}
+	return vars
+}
+
+// Checksum returns the declared digest of the download for the version on the current platform, if any
+func (t *Tool) Checksum(version string) string {
+	return t.PlatformChecksum(version, runtime.GOOS, runtime.GOARCH)
+}
+
+// PlatformChecksum returns the declared digest of the download for the version on the platform
+// given by Go's names, if any
+func (t *Tool) PlatformChecksum(version string, goos string, goarch string) string {
+	return t.Checksums[version][goos+"/"+goarch]
+}
+
+// ParseSpec splits a tool reference given as name or name@version
+func ParseSpec(spec string) (name string, version string) {
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		return spec[:i], spec[i+1:]
+	}
+	return spec, ""
+}
@@ -0,0 +1,77 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/internal/githubapi"
+	"net/http"
+	"strings"
+)
+
+// Asset is a file attached to a GitHub release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
+}
+
+// LatestVersion resolves the version of the tool's latest GitHub release. The GITHUB_TOKEN
+// environment variable, when set, is used to avoid anonymous rate limits.
+func (t *Tool) LatestVersion(ctx context.Context, client *http.Client) (string, error) {
+	if t.Repo == "" {
+		return "", fmt.Errorf("a version is required since the tool doesn't declare a repo")
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	err := githubapi.Get(ctx, client, "/repos/"+t.Repo+"/releases/latest", &release)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve latest release of %s: %w", t.Repo, err)
+	}
+	return strings.TrimPrefix(release.TagName, t.TagPrefix), nil
+}
+
+// Versions lists the versions of the tool's most recent GitHub releases, up to 100, other than
+// drafts and pre-releases. Releases whose tags lack the TagPrefix are skipped.
+func (t *Tool) Versions(ctx context.Context, client *http.Client) ([]string, error) {
+	if t.Repo == "" {
+		return nil, fmt.Errorf("the tool doesn't declare a repo to list releases of")
+	}
+
+	var releases []struct {
+		TagName    string `json:"tag_name"`
+		Draft      bool   `json:"draft"`
+		Prerelease bool   `json:"prerelease"`
+	}
+	err := githubapi.Get(ctx, client, "/repos/"+t.Repo+"/releases?per_page=100", &releases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve releases of %s: %w", t.Repo, err)
+	}
+
+	var versions []string
+	for _, release := range releases {
+		if release.Draft || release.Prerelease || !strings.HasPrefix(release.TagName, t.TagPrefix) {
+			continue
+		}
+		versions = append(versions, strings.TrimPrefix(release.TagName, t.TagPrefix))
+	}
+	return versions, nil
+}
+
+// ReleaseAssets lists the assets of the tool's GitHub release of the version
+func (t *Tool) ReleaseAssets(ctx context.Context, client *http.Client, version string) ([]Asset, error) {
+	if t.Repo == "" {
+		return nil, fmt.Errorf("the tool doesn't declare a repo to list release assets of")
+	}
+
+	var release struct {
+		Assets []Asset `json:"assets"`
+	}
+	tag := t.TagPrefix + version
+	err := githubapi.Get(ctx, client, "/repos/"+t.Repo+"/releases/tags/"+tag, &release)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve release %s of %s: %w", tag, t.Repo, err)
+	}
+	return release.Assets, nil
+}
@@ -0,0 +1,34 @@
+package catalog
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+)
+
+// Parse reads the YAML content of a catalog where source identifies it in errors
+func Parse(content []byte, source string) (*Catalog, error) {
+	var c Catalog
+	err := yaml.Unmarshal(content, &c)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse catalog %s: %w", source, err)
+	}
+
+	for name, tool := range c.Tools {
+		if tool == nil || tool.From == "" || tool.File == "" {
+			return nil, fmt.Errorf("tool %s in catalog %s requires from and file", name, source)
+		}
+	}
+	return &c, nil
+}
+
+// Merge returns a catalog with the tools of both where those of other replace any of the same name
+func (c *Catalog) Merge(other *Catalog) *Catalog {
+	merged := &Catalog{Tools: make(map[string]*Tool, len(c.Tools)+len(other.Tools))}
+	for name, tool := range c.Tools {
+		merged.Tools[name] = tool
+	}
+	for name, tool := range other.Tools {
+		merged.Tools[name] = tool
+	}
+	return merged
+}
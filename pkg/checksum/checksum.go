@@ -0,0 +1,115 @@
+// Package checksum verifies downloaded content against an expected digest
+package checksum
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/crypto/blake2b"
+	"hash"
+	"strings"
+)
+
+// Checksum is the expected digest of some content
+type Checksum struct {
+	Algorithm string
+	Expected  []byte
+}
+
+// Parse parses a checksum given as algorithm:hex, such as sha256:e3b0c442... where algorithm is
+// sha256, sha384, sha512, sha1, md5, or blake2b, or as a Subresource Integrity value
+func Parse(value string) (*Checksum, error) {
+	if IsIntegrity(value) {
+		return ParseIntegrity(value)
+	}
+
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("checksum '%s' must be formatted as algorithm:hex", value)
+	}
+
+	expected, err := hex.DecodeString(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, fmt.Errorf("checksum '%s' does not contain a valid hex digest: %w", value, err)
+	}
+
+	return newChecksum(strings.ToLower(parts[0]), expected)
+}
+
+// newChecksum validates the algorithm and digest length
+func newChecksum(algorithm string, expected []byte) (*Checksum, error) {
+	c := &Checksum{
+		Algorithm: algorithm,
+		Expected:  expected,
+	}
+
+	h, err := c.NewHash()
+	if err != nil {
+		return nil, err
+	}
+	if h.Size() != len(expected) {
+		return nil, fmt.Errorf("%s checksum must be %d bytes, but was %d", c.Algorithm, h.Size(), len(expected))
+	}
+
+	return c, nil
+}
+
+// String formats the checksum as algorithm:hex
+func (c *Checksum) String() string {
+	return c.Algorithm + ":" + hex.EncodeToString(c.Expected)
+}
+
+// NewHash creates a hash for computing the actual digest to pass to Verify
+func (c *Checksum) NewHash() (hash.Hash, error) {
+	switch c.Algorithm {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha384":
+		return sha512.New384(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	case "blake2b":
+		// BLAKE2b digests are variable length, so go by what was given
+		return blake2b.New(len(c.Expected), nil)
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm '%s'", c.Algorithm)
+	}
+}
+
+// IsFIPSApproved reports if the algorithm is of the SHA-2 family approved by FIPS 180-4, unlike
+// md5 and sha1, for which collisions have been found, and blake2b
+func (c *Checksum) IsFIPSApproved() bool {
+	switch c.Algorithm {
+	case "sha256", "sha384", "sha512":
+		return true
+	default:
+		return false
+	}
+}
+
+// Verify compares the actual digest against the expected one, returning a MismatchError when they differ
+func (c *Checksum) Verify(actual []byte) error {
+	if !bytes.Equal(c.Expected, actual) {
+		return &MismatchError{Algorithm: c.Algorithm, Expected: c.Expected, Actual: actual}
+	}
+	return nil
+}
+
+// MismatchError reports content that doesn't have the expected digest
+type MismatchError struct {
+	Algorithm string
+	Expected  []byte
+	Actual    []byte
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %x, but was %x", e.Algorithm, e.Expected, e.Actual)
+}
@@ -0,0 +1,67 @@
+package checksum
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// integrityStrength orders the algorithms of Subresource Integrity, where the strongest given is used
+var integrityStrength = map[string]int{
+	"sha256": 1,
+	"sha384": 2,
+	"sha512": 3,
+}
+
+// IsIntegrity determines if the value is in the Subresource Integrity form of algorithm-base64,
+// such as sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=, where the base64 must be a digest
+// of the algorithm so that a file named like one, such as sha256-sums.txt, isn't mistaken for it
+func IsIntegrity(value string) bool {
+	for _, item := range strings.Fields(value) {
+		if _, err := parseIntegrityItem(item); err != nil {
+			return false
+		}
+	}
+	return strings.TrimSpace(value) != ""
+}
+
+// ParseIntegrity parses a Subresource Integrity value, as used by npm and web manifests. Of
+// several space separated digests, that of the strongest algorithm is used, and options
+// following a ? are ignored.
+func ParseIntegrity(value string) (*Checksum, error) {
+	var strongest *Checksum
+	for _, item := range strings.Fields(value) {
+		c, err := parseIntegrityItem(item)
+		if err != nil {
+			return nil, err
+		}
+		if strongest == nil || integrityStrength[c.Algorithm] > integrityStrength[strongest.Algorithm] {
+			strongest = c
+		}
+	}
+	if strongest == nil {
+		return nil, fmt.Errorf("integrity '%s' contains no digests", value)
+	}
+	return strongest, nil
+}
+
+// parseIntegrityItem parses one of the space separated digests of a Subresource Integrity value
+func parseIntegrityItem(item string) (*Checksum, error) {
+	if i := strings.Index(item, "?"); i >= 0 {
+		item = item[:i]
+	}
+	parts := strings.SplitN(item, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("integrity '%s' must be formatted as algorithm-base64", item)
+	}
+	algorithm := strings.ToLower(parts[0])
+	if integrityStrength[algorithm] == 0 {
+		return nil, fmt.Errorf("unsupported integrity algorithm '%s'", parts[0])
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("integrity '%s' does not contain a valid base64 digest: %w", item, err)
+	}
+	return newChecksum(algorithm, expected)
+}
@@ -0,0 +1,29 @@
+package checksum
+
+import "testing"
+
+func TestIsIntegrity(t *testing.T) {
+	for value, expected := range map[string]bool{
+		"sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=":                                                                         true,
+		"sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=?ct=application/gzip":                                                     true,
+		"sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU= sha384-OLBgp1GsljhM2TJ+sbHjaiH9txEUvgdDTAzHv2P24donTt6/529l+9Ua0vFImLlb": true,
+		// files named like integrity values are paths rather than digests
+		"sha256-sums.txt":              false,
+		"sha512-checksums":             false,
+		"SHA256-SUMS":                  false,
+		"sha256-47DEQpj8HBSa":          false,
+		"md5-1B2M2Y8AsgTpgAmY7PhCfg==": false,
+		"sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855": false,
+		"": false,
+	} {
+		if actual := IsIntegrity(value); actual != expected {
+			t.Errorf("IsIntegrity(%q) is %v", value, actual)
+		}
+	}
+}
+
+func TestFileNamedLikeIntegrityIsNotInline(t *testing.T) {
+	if IsInline("sha256-sums.txt") {
+		t.Error("sha256-sums.txt is taken as an inline checksum rather than a file of sums")
+	}
+}
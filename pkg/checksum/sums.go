@@ -0,0 +1,112 @@
+package checksum
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// algorithmsByLength infers the algorithm of a bare hex digest from its length
+var algorithmsByLength = map[int]string{
+	32:  "md5",
+	40:  "sha1",
+	64:  "sha256",
+	96:  "sha384",
+	128: "sha512",
+}
+
+// bsdSumLine matches the tagged lines of shasum --tag and BSD tools, such as SHA256 (name) = hex
+var bsdSumLine = regexp.MustCompile(`^([A-Za-z0-9-]+) \((.+)\) = ([0-9a-fA-F]+)$`)
+
+// IsInline determines if the value is a checksum given as algorithm:hex, or as a Subresource
+// Integrity value, rather than the path or URL of a file containing one
+func IsInline(value string) bool {
+	if IsIntegrity(value) {
+		return true
+	}
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	_, err := (&Checksum{Algorithm: strings.ToLower(parts[0]), Expected: make([]byte, 32)}).NewHash()
+	return err == nil
+}
+
+// ParseContent finds the checksum of the named file within content that is either a single
+// digest, given as hex or algorithm:hex, or a list of sums, such as written by sha256sum or
+// shasum --tag. The algorithm of hex digests is inferred from their length. Files listed with
+// a leading ./ or within a directory, such as dist/tool.tar.gz, are matched by their base name
+// when none are listed as exactly the name.
+func ParseContent(content []byte, name string) (*Checksum, error) {
+	trimmed := strings.TrimSpace(string(content))
+	if trimmed != "" && !strings.ContainsAny(trimmed, " \t\n") {
+		if IsInline(trimmed) {
+			return Parse(trimmed)
+		}
+		return parseHex(trimmed)
+	}
+
+	var exact *Checksum
+	var byBase []string
+	var byBaseValue string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() && exact == nil {
+		line := strings.TrimSpace(scanner.Text())
+		var listed, value string
+		if match := bsdSumLine.FindStringSubmatch(line); match != nil {
+			listed = match[2]
+			value = strings.ToLower(strings.Replace(match[1], "-", "", 1)) + ":" + match[3]
+		} else {
+			i := strings.IndexAny(line, " \t")
+			if i < 0 {
+				continue
+			}
+			// sha256sum marks files read in binary mode with a leading *
+			listed = strings.TrimPrefix(strings.TrimLeft(line[i:], " \t"), "*")
+			value = line[:i]
+		}
+
+		listed = strings.TrimPrefix(listed, "./")
+		switch {
+		case listed == name:
+			checksum, err := parseSum(value)
+			if err != nil {
+				return nil, err
+			}
+			exact = checksum
+		case path.Base(listed) == path.Base(name):
+			byBase = append(byBase, listed)
+			byBaseValue = value
+		}
+	}
+
+	switch {
+	case exact != nil:
+		return exact, nil
+	case len(byBase) == 1:
+		return parseSum(byBaseValue)
+	case len(byBase) > 1:
+		return nil, fmt.Errorf("more than one checksum matches the name %s: %s", name, strings.Join(byBase, ", "))
+	default:
+		return nil, fmt.Errorf("no checksum of %s was found", name)
+	}
+}
+
+// parseSum parses the digest of a line of a list of sums, which is algorithm:hex when tagged
+func parseSum(value string) (*Checksum, error) {
+	if strings.Contains(value, ":") {
+		return Parse(value)
+	}
+	return parseHex(value)
+}
+
+func parseHex(digest string) (*Checksum, error) {
+	algorithm, known := algorithmsByLength[len(digest)]
+	if !known {
+		return nil, fmt.Errorf("unable to determine the algorithm of the %d character digest %s", len(digest), digest)
+	}
+	return Parse(algorithm + ":" + digest)
+}
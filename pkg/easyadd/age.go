@@ -0,0 +1,41 @@
+package easyadd
+
+import (
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/internal/ctxio"
+	"github.com/itzg/easy-add/pkg/age"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"io"
+	"io/ioutil"
+	"log"
+)
+
+// decryptArchive replaces the downloaded archive with its decrypted content, which keeps the
+// digest of what was downloaded
+func (s *source) decryptArchive(ctx context.Context, archive *fetch.Archive) (*fetch.Archive, error) {
+	defer archive.Remove()
+
+	plaintext, err := age.Decrypt(archive.File, s.ageIdentities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt archive: %w", err)
+	}
+
+	file, err := ioutil.TempFile("", "easy-add-*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file for decrypted archive: %w", err)
+	}
+	decrypted := &fetch.Archive{File: file, SHA256: archive.SHA256}
+
+	_, err = io.Copy(file, ctxio.NewReader(ctx, plaintext))
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		decrypted.Remove()
+		return nil, fmt.Errorf("failed to decrypt archive: %w", err)
+	}
+
+	log.Printf("I! Decrypted age encrypted archive")
+	return decrypted, nil
+}
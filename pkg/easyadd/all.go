@@ -0,0 +1,110 @@
+package easyadd
+
+import (
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"github.com/itzg/easy-add/pkg/install"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// installAll extracts every entry that matches the requested file into the directory of
+// installOpts, at its path within the archive or, when flattening, directly within it. Each
+// file keeps its permissions from the archive, and the verify args and capabilities of
+// installOpts aren't used since they're meant for a single executable.
+func (s *source) installAll(ctx context.Context, archive *fetch.Archive, installOpts *install.Options,
+	mkdirs func(dir string) error) ([]ExtractedFile, error) {
+
+	err := s.resolveTopDir(ctx, archive)
+	if err != nil {
+		return nil, err
+	}
+	_, err = archive.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	names, err := extract.ResolveEntries(ctx, s.format, archive.File, s.entryWithin(s.file, s.match), s.match)
+	if err != nil {
+		return nil, err
+	}
+
+	dests := make([]string, len(names))
+	entriesByDest := make(map[string]string)
+	for i, name := range names {
+		dests[i], err = entryDest(s.stripTopDirOf(name), s.flatten)
+		if err != nil {
+			return nil, err
+		}
+		if existing, exists := entriesByDest[dests[i]]; exists {
+			return nil, fmt.Errorf("%s and %s would both be installed as %s", existing, name, dests[i])
+		}
+		entriesByDest[dests[i]] = name
+	}
+
+	if mkdirs == nil {
+		mkdirs = func(dir string) error {
+			return install.Mkdirs(dir, nil, nil)
+		}
+	}
+
+	entriesOpts := make([]install.Options, len(names))
+	indexes := make(map[string]int, len(names))
+	for i, name := range names {
+		entryOpts := *installOpts
+		entryOpts.To = filepath.Join(installOpts.To, filepath.Dir(dests[i]))
+		entryOpts.Name = filepath.Base(dests[i])
+		entryOpts.VerifyArgs = nil
+		entryOpts.Capabilities = nil
+		if entryOpts.To != installOpts.To {
+			err = mkdirs(entryOpts.To)
+			if err != nil {
+				return nil, err
+			}
+		}
+		entriesOpts[i] = entryOpts
+		indexes[extract.NormalizeEntryName(name)] = i
+	}
+
+	// in one pass over the archive, while keeping the results in the order of the names
+	extracted := make([]ExtractedFile, len(names))
+	err = extract.ExtractAll(s.extractContext(ctx), s.format, archive.File, names,
+		func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+			i := indexes[extract.NormalizeEntryName(name)]
+			entryOpts := entriesOpts[i]
+			entryOpts.Mode = info.Mode().Perm()
+			if s.normalizeModes {
+				entryOpts.Mode = install.NormalizedMode(entryOpts.Mode)
+			}
+			entryOpts.Owner = s.entryOwner(info)
+			installed, err := install.Install(ctx, content, name, info.Size(), &entryOpts)
+			if err != nil {
+				return err
+			}
+			log.Printf("I! Extracted %s to %s with sha256:%s", name, installed.Path, installed.SHA256)
+			extracted[i] = ExtractedFile{Entry: name, Path: installed.Path, SHA256: installed.SHA256}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return extracted, nil
+}
+
+// entryDest is the path, relative to the install directory, of an archive entry, which is
+// refused when it would be placed outside of that directory
+func entryDest(name string, flatten bool) (string, error) {
+	entryPath := path.Clean(extract.NormalizeEntryName(name))
+	if flatten {
+		return path.Base(entryPath), nil
+	}
+	if entryPath == ".." || strings.HasPrefix(entryPath, "../") {
+		return "", fmt.Errorf("%s would be placed outside of the install directory", name)
+	}
+	return filepath.FromSlash(entryPath), nil
+}
@@ -0,0 +1,112 @@
+package easyadd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/itzg/easy-add/internal/githubapi"
+	"github.com/itzg/easy-add/pkg/attest"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// gitHubActionsIssuer is the OIDC issuer of the certificates that sign GitHub artifact attestations
+const gitHubActionsIssuer = "https://token.actions.githubusercontent.com"
+
+// AttestationOptions declares the GitHub artifact attestation required of an archive, as would
+// be checked by gh attestation verify
+type AttestationOptions struct {
+	// Repo, such as owner/repo, is where the attestations are retrieved from and must have been
+	// built. It defaults to that of a GitHub release download URL.
+	Repo string
+	// SignerWorkflow, when set, is the workflow that must have signed, such as
+	// owner/repo/.github/workflows/release.yml, optionally with an @ref
+	SignerWorkflow string
+}
+
+func (s *source) verifyAttestation(ctx context.Context, archive *fetch.Archive) error {
+	opts := s.attestation
+	repo := opts.Repo
+	if repo == "" {
+		repo = gitHubReleaseRepo(s.fromURL)
+		if repo == "" {
+			return fmt.Errorf("the attestation repo needs to be given since %s is not a GitHub release", s.from)
+		}
+	}
+
+	client := s.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var resp struct {
+		Attestations []struct {
+			Bundle json.RawMessage `json:"bundle"`
+		} `json:"attestations"`
+	}
+	err := githubapi.Get(ctx, client, fmt.Sprintf("/repos/%s/attestations/sha256:%s", repo, archive.SHA256), &resp)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve attestations of archive from %s: %w", repo, err)
+	}
+	if len(resp.Attestations) == 0 {
+		return fmt.Errorf("%s has no attestations of the archive", repo)
+	}
+
+	var lastErr error
+	for _, attestation := range resp.Attestations {
+		lastErr = verifyAttestationBundle(attestation.Bundle, archive.SHA256, repo, opts.SignerWorkflow)
+		if lastErr == nil {
+			log.Printf("I! Verified GitHub attestation of archive built by %s", repo)
+			return nil
+		}
+	}
+	return fmt.Errorf("attestation of archive from %s: %w", repo, lastErr)
+}
+
+func verifyAttestationBundle(content []byte, archiveSHA256 string, repo string, signerWorkflow string) error {
+	bundle, err := attest.ParseBundle(content)
+	if err != nil {
+		return err
+	}
+	identity, statement, err := attest.Verify(bundle)
+	if err != nil {
+		return err
+	}
+
+	if !statement.HasSubject(archiveSHA256) {
+		return fmt.Errorf("it doesn't describe the archive with sha256:%s", archiveSHA256)
+	}
+	if !statement.IsSLSAProvenance() {
+		return fmt.Errorf("it has the predicate type %s rather than SLSA provenance", statement.PredicateType)
+	}
+	if identity.Issuer != gitHubActionsIssuer {
+		return fmt.Errorf("it was signed by an identity from %s rather than GitHub Actions", identity.Issuer)
+	}
+	if !attest.RepoMatches(identity.SourceRepository, repo) {
+		return fmt.Errorf("it was built from %s rather than %s", identity.SourceRepository, repo)
+	}
+	if signerWorkflow != "" && !workflowMatches(identity.SubjectURI, signerWorkflow) {
+		return fmt.Errorf("it was signed by %s rather than %s", identity.SubjectURI, signerWorkflow)
+	}
+	return nil
+}
+
+// workflowMatches determines if the signing workflow URI, such as
+// https://github.com/owner/repo/.github/workflows/release.yml@refs/tags/v1.0.0, is the expected
+// workflow, which may omit the scheme and host as well as the ref
+func workflowMatches(subjectURI string, expected string) bool {
+	expected = strings.TrimPrefix(strings.TrimPrefix(expected, "https://"), "github.com/")
+	actual := strings.TrimPrefix(subjectURI, "https://github.com/")
+	if strings.Contains(expected, "@") {
+		return actual == expected
+	}
+	return trimWorkflowRef(actual) == expected
+}
+
+func trimWorkflowRef(workflow string) string {
+	if i := strings.LastIndex(workflow, "@"); i >= 0 {
+		return workflow[:i]
+	}
+	return workflow
+}
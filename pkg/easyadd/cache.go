@@ -0,0 +1,67 @@
+package easyadd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cachePath is where the archive is kept in the cache, which is in a directory named by the
+// digest of its requested URL so that archives with the same name don't collide
+func (s *source) cachePath() string {
+	key := sha256.Sum256([]byte(s.cacheKey))
+	return filepath.Join(s.cacheDir, hex.EncodeToString(key[:]), s.archiveName())
+}
+
+// cachedArchive retrieves the archive from the cache when it is there and still matches the
+// digest recorded when it was cached, along with any expected checksum. Otherwise, the cache
+// entry is dropped and nil is returned, so the archive is retrieved again.
+func (s *source) cachedArchive(ctx context.Context) *fetch.Archive {
+	cachePath := s.cachePath()
+	recorded, err := ioutil.ReadFile(cachePath + ".sha256")
+	if err != nil {
+		return nil
+	}
+
+	cacheURL, err := localFileURL(cachePath)
+	if err == nil {
+		var archive *fetch.Archive
+		archive, err = fetch.Download(ctx, &fetch.FileFetcher{}, cacheURL, s.checksum, s.maxSize)
+		if err == nil && archive.SHA256 != strings.TrimSpace(string(recorded)) {
+			archive.Remove()
+			err = errors.New("its content changed since it was cached")
+		}
+		if err == nil {
+			log.Printf("I! Using cached archive of %s", s.from)
+			return archive
+		}
+	}
+
+	log.Printf("W! Retrieving %s again since the cached archive is invalid: %v", s.from, err)
+	_ = os.Remove(cachePath + ".sha256")
+	_ = os.Remove(cachePath)
+	return nil
+}
+
+// cacheArchive stores the archive in the cache along with its digest. Failing to do so only
+// means it will be retrieved again next time.
+func (s *source) cacheArchive(ctx context.Context, archive *fetch.Archive) {
+	cachePath := s.cachePath()
+	err := os.MkdirAll(filepath.Dir(cachePath), 0755)
+	if err == nil {
+		err = archive.SaveAs(ctx, cachePath)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(cachePath+".sha256", []byte(archive.SHA256+"\n"), 0644)
+	}
+	if err != nil {
+		log.Printf("W! Unable to cache archive of %s: %v", s.from, err)
+	}
+}
@@ -0,0 +1,25 @@
+package easyadd
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCachePathKeptWhenRetrievedOverHTTPS(t *testing.T) {
+	from := "http://example.com/tool.tar.gz"
+	fromURL, err := url.Parse(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &source{from: from, cacheKey: from, fromURL: fromURL, cacheDir: t.TempDir()}
+	looked := s.cachePath()
+
+	// as fetchArchive does once the https equivalent was retrieved
+	httpsURL := *fromURL
+	httpsURL.Scheme = "https"
+	s.from, s.fromURL, s.archiveURL = httpsURL.String(), &httpsURL, &httpsURL
+
+	if stored := s.cachePath(); stored != looked {
+		t.Errorf("archive is cached at %s but looked up at %s", stored, looked)
+	}
+}
@@ -0,0 +1,140 @@
+package easyadd
+
+import (
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/checksum"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"path"
+)
+
+// maxReferenceSize limits what is read of a checksum or signature file, which is at most a list of sums
+const maxReferenceSize = 1 << 20
+
+// retrieveChecksum reads the file or URL given as the checksum and finds the digest of the archive within it
+func (s *source) retrieveChecksum(ctx context.Context) (*checksum.Checksum, error) {
+	content, err := s.readReference(ctx, s.checksumRef, "checksum")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.signatureVerifiers()) > 0 {
+		err = s.verifySignatures(ctx, content, s.checksumRef)
+		if err != nil {
+			return nil, categorized(CategoryVerification, err)
+		}
+	}
+
+	c, err := checksum.ParseContent(content, path.Base(s.fromURL.Path))
+	if err != nil {
+		return nil, categorized(CategoryChecksum, fmt.Errorf("invalid checksum from %s: %w", s.checksumRef, err))
+	}
+	err = checkStrictDigest(c, s.strictDigests)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// checkStrictDigest refuses a checksum of an algorithm other than SHA-2 when strict digests are
+// required, such as in FIPS constrained environments
+func checkStrictDigest(c *checksum.Checksum, strict bool) error {
+	if !strict || c.IsFIPSApproved() {
+		return nil
+	}
+	return categorized(CategoryChecksum, fmt.Errorf("refusing the %s checksum of the archive since strict digests require sha256, sha384, or sha512", c.Algorithm))
+}
+
+// ReadReference reads the content of a local path or URL, such as of a catalog, which is described
+// by what for errors. The HTTPClient, AllowHTTP, PreferHTTPS, and Policy of the options apply to
+// it as they do to the archive.
+func ReadReference(ctx context.Context, opts Options, ref string, what string) ([]byte, error) {
+	s := &source{
+		client:      opts.HTTPClient,
+		allowHTTP:   opts.AllowHTTP,
+		preferHTTPS: opts.PreferHTTPS,
+		policy:      opts.Policy,
+	}
+	content, err := s.readReference(ctx, ref, what)
+	if err != nil {
+		return nil, categorized(CategoryDownload, err)
+	}
+	return content, nil
+}
+
+// readReference reads the content of a local path or URL, which is described by what for errors
+func (s *source) readReference(ctx context.Context, ref string, what string) ([]byte, error) {
+	return s.readReferenceUpTo(ctx, ref, what, maxReferenceSize)
+}
+
+// readReferenceUpTo is readReference for content that can be larger than maxReferenceSize, which
+// fails when the URL gives more than limit bytes
+func (s *source) readReferenceUpTo(ctx context.Context, ref string, what string, limit int64) ([]byte, error) {
+	u, err := url.Parse(ref)
+	// a single letter scheme is a Windows drive
+	if err != nil || len(u.Scheme) <= 1 {
+		content, err := ioutil.ReadFile(ref)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", what, err)
+		}
+		return content, nil
+	}
+
+	err = s.policy.checkHost(u)
+	if err != nil {
+		return nil, err
+	}
+	if httpsURL := s.httpsEquivalent(u); httpsURL != nil {
+		content, err := s.readURL(ctx, httpsURL, what, limit)
+		if err == nil {
+			return content, nil
+		}
+		err = s.fallBackToHTTP(ctx, u, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = checkHTTP(u, s.allowHTTP, s.policy)
+	if err != nil {
+		return nil, err
+	}
+	return s.readURL(ctx, u, what, limit)
+}
+
+// readURL retrieves the content at the URL, which is described by what for errors, failing when
+// it is larger than limit rather than reading part of it
+func (s *source) readURL(ctx context.Context, u *url.URL, what string, limit int64) ([]byte, error) {
+	log.Printf("I! Retrieving %s from %s", what, u.Redacted())
+	fetcher, err := fetch.ForURL(u, s.client)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fetcher.Fetch(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve %s: %w", what, err)
+	}
+	//noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve %s: %w", what, err)
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("the %s from %s is larger than %d bytes", what, u.Redacted(), limit)
+	}
+	return content, nil
+}
+
+// withSuffix appends the suffix to the path of a URL, ahead of any query, or otherwise to the local path
+func withSuffix(ref string, suffix string) string {
+	u, err := url.Parse(ref)
+	if err != nil || len(u.Scheme) <= 1 {
+		return ref + suffix
+	}
+	u.Path += suffix
+	return u.String()
+}
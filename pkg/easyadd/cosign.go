@@ -0,0 +1,62 @@
+package easyadd
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/attest"
+	"io"
+	"log"
+)
+
+// CosignOptions declares the keyless cosign signature, as made by cosign sign-blob in a GitHub
+// Actions workflow, required of the checksum file or, when the checksum isn't given by a file or
+// URL, of the archive itself. The certificate and signature are those of what's signed with .pem
+// and .sig appended.
+type CosignOptions struct {
+	// Repo, such as owner/repo, is where the signing workflow must have run. It defaults to that
+	// of a GitHub release download URL.
+	Repo string
+}
+
+func (s *source) verifyCosignSignature(ctx context.Context, signed io.Reader, signedRef string) error {
+	repo := s.cosign.Repo
+	if repo == "" {
+		repo = gitHubReleaseRepo(s.fromURL)
+		if repo == "" {
+			return fmt.Errorf("the cosign repo needs to be given since %s is not a GitHub release", s.from)
+		}
+	}
+
+	certContent, err := s.readReference(ctx, withSuffix(signedRef, ".pem"), "cosign certificate")
+	if err != nil {
+		return err
+	}
+	sigContent, err := s.readReference(ctx, withSuffix(signedRef, ".sig"), "cosign signature")
+	if err != nil {
+		return err
+	}
+	cert, err := attest.ParseCertificate(certContent)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, signed); err != nil {
+		return fmt.Errorf("failed to read signed content: %w", err)
+	}
+	identity, err := attest.VerifyBlob(cert, sigContent, h.Sum(nil))
+	if err != nil {
+		return fmt.Errorf("cosign signature of %s: %w", signedRef, err)
+	}
+	if identity.Issuer != gitHubActionsIssuer {
+		return fmt.Errorf("cosign signature of %s was made by an identity from %s rather than GitHub Actions",
+			signedRef, identity.Issuer)
+	}
+	if !attest.RepoMatches(identity.SourceRepository, repo) {
+		return fmt.Errorf("cosign signature of %s was made in %s rather than %s",
+			signedRef, identity.SourceRepository, repo)
+	}
+	log.Printf("I! Verified cosign signature of %s by %s", signedRef, identity.SubjectURI)
+	return nil
+}
@@ -0,0 +1,1114 @@
+// Package easyadd downloads an archive, extracts a file from it, and installs that file.
+// It is what the easy-add command uses and can be embedded by other Go tools.
+package easyadd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/itzg/easy-add/internal/dirlock"
+	"github.com/itzg/easy-add/pkg/age"
+	"github.com/itzg/easy-add/pkg/checksum"
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"github.com/itzg/easy-add/pkg/install"
+	"github.com/itzg/easy-add/pkg/telemetry"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Options declares what to install and how. From and either File or Mappings are required, and
+// both From and File may contain Go template references to Vars entries.
+type Options struct {
+	// From is the URL of a tar.gz or zip archive to download with a fetcher registered for its scheme
+	From string
+	// ArchivePath, when set, is the path of an archive that was already downloaded, which is used
+	// rather than retrieving From. From then only names the archive, such as for detecting its
+	// format and finding it in a list of sums, and defaults to the path.
+	ArchivePath string
+	// Parts are the URLs of the further parts of an archive that was split, which are retrieved in
+	// order after From and joined with it. When From is numbered, such as tool.zip.001, the parts
+	// numbered after it are retrieved without being given here. Each may contain Go template
+	// references to Vars entries.
+	Parts []string
+	// File is the path of the file to extract within the archive
+	File string
+	// Match is how File is compared with the entries of the archive, which defaults to extract.MatchExact
+	Match extract.Match
+	// Pick is how one entry is chosen when File matches several, which defaults to failing
+	Pick extract.Pick
+	// PreferStatic narrows the entries that File matches to the statically linked ELF binaries
+	// among them, when there are any, before Pick is applied
+	PreferStatic bool
+	// All installs every entry that matches File, rather than requiring only one to match, at
+	// their paths within the archive under To
+	All bool
+	// Flatten is All, but with each file placed directly in To, which fails when two of them
+	// have the same name
+	Flatten bool
+	// StripTopDir treats File and the entries of Mappings as relative to the one directory that
+	// every entry of the archive is within, such as tool-1.2.3, which is done without being set for
+	// source archives of GitHub repositories
+	StripTopDir bool
+	// Mappings extract more entries of the same archive, each to its own path
+	Mappings []Mapping
+	// Format of the archive, such as extract.Binary, which is otherwise detected from the suffix of From
+	Format extract.Format
+	Vars   map[string]string
+	// To is the directory where the file will be placed, which defaults to install.DefaultDir
+	To string
+	// Root, when set, is a directory, such as a mounted root filesystem, that To, the destinations
+	// of Mappings, and Links are within. Links and launchers refer to files as seen from within it.
+	Root string
+	// Writer, when set, receives the content of File, such as os.Stdout, rather than it being
+	// installed in To
+	Writer io.Writer
+	// PreserveOwner gives installed files the numeric user and group ID recorded for their tar
+	// archive entries, which is skipped with a warning unless running as root
+	PreserveOwner bool
+	// OwnerMap, when set along with PreserveOwner while not running as root, gives installed files
+	// the owners that those recorded for their tar archive entries map to
+	OwnerMap *install.OwnerMap
+	// Reproducible, when set, normalizes the times, permissions, and owners of what's installed
+	Reproducible *ReproducibleOptions
+	// Mkdirs creates the directory To when missing, optionally with DirMode and Owner
+	Mkdirs  bool
+	DirMode *os.FileMode
+	// Owner is given as user[:group] by name or ID
+	Owner string
+	// ZipPassword decrypts encrypted zip entries
+	ZipPassword string
+	// AgeIdentityFiles are paths of age identities, as written by age-keygen, that decrypt an
+	// age encrypted archive after it is verified
+	AgeIdentityFiles []string
+	// Checksum is the expected digest of the archive given as algorithm:hex or the path or URL of
+	// a file containing either a digest or a list of sums that includes the archive. It may contain
+	// Go template references to Vars entries.
+	Checksum string
+	// Preflight checks the archive, such as with an HTTP HEAD request, before downloading it
+	Preflight bool
+	// MaxDownloadSize, when above zero, fails the download of a larger archive
+	MaxDownloadSize int64
+	// CacheDir, when set, is a directory where downloaded archives are kept and reused by later
+	// installs of the same URL, once they are verified to be unchanged
+	CacheDir string
+	// KeepArchiveDir, when set, is a directory where a copy of the downloaded archive is kept,
+	// once verified, under the name it was downloaded with
+	KeepArchiveDir string
+	// Setcap is given in the textual form used by setcap, such as cap_net_bind_service=+ep
+	Setcap           string
+	SELinuxType      string
+	RequireArchMatch bool
+	// TargetOS and TargetArch, named like GOOS and GOARCH, are the platform that extracted
+	// binaries are checked against rather than the current one, such as to populate a root
+	// filesystem for another architecture
+	TargetOS   string
+	TargetArch string
+	// VerifyArgs, when non-empty, are used to run the extracted file where a non-zero exit fails the install
+	VerifyArgs []string
+	// MinVersion, when set, skips the download when the file already installed in To reports at least its version
+	MinVersion *MinVersionOptions
+	// JavaLauncher, when set, is the name of a script to create alongside the installed file, a
+	// jar, that runs it with java. Links then point at the script. When the format isn't given,
+	// a From named with .jar is installed as is rather than extracted from.
+	JavaLauncher string
+	// Versioned, when set, installs into a directory of the version within To rather than directly in To
+	Versioned *VersionedLayout
+	// Links are paths of symbolic links to create or update to point at the installed file
+	Links []string
+	// ExecAfter is a shell command to run after installing, which may also reference the 'path' of the installed file
+	ExecAfter     string
+	NoPathWarning bool
+	// AllowHTTP permits downloads over unencrypted HTTP from hosts other than this one
+	AllowHTTP bool
+	// PreferHTTPS tries the https equivalent of http URLs first, falling back to them only when
+	// AllowHTTP permits
+	PreferHTTPS bool
+	// Policy, when set, restricts where the archive may be retrieved from and how it's verified
+	Policy *Policy
+	// StrictDigests refuses checksums other than SHA-2, such as md5 and sha1, and a client created
+	// with Proxy and CACertFiles then requires TLS 1.2 or later
+	StrictDigests bool
+	// Proxy and CACertFiles customize the HTTP client as described by fetch.ClientOptions
+	Proxy       string
+	CACertFiles []string
+	// Provenance, when set, requires verified SLSA provenance of the archive before anything is extracted
+	Provenance *ProvenanceOptions
+	// Attestation, when set, requires a verified GitHub artifact attestation of the archive before anything is extracted
+	Attestation *AttestationOptions
+	// SSHSignature, when set, requires a verified SSH signature of the checksum file or archive
+	SSHSignature *SSHSignatureOptions
+	// Minisign, when set, requires a verified minisign signature of the checksum file or archive
+	Minisign *MinisignOptions
+	// PGP, when set, requires a verified PGP signature of the checksum file or archive
+	PGP *PGPOptions
+	// Cosign, when set, requires a verified keyless cosign signature of the checksum file or archive
+	Cosign *CosignOptions
+	// Verify, when VerifyAuto, requires only one of the SSHSignature, Minisign, PGP, and Cosign
+	// signatures, skipping those that aren't found alongside the signed file
+	Verify string
+	// HTTPClient, when set, is used for http and https URLs instead of a client created with Proxy and CACertFiles.
+	// The Policy only applies to the URLs it's redirected to when it's created with Policy.CheckRedirect.
+	HTTPClient *http.Client
+}
+
+// Result describes the installed file
+type Result struct {
+	From   string   `json:"from"`
+	Path   string   `json:"path,omitempty"`
+	SHA256 string   `json:"sha256,omitempty"`
+	Links  []string `json:"links,omitempty"`
+	// Launcher is the path of the script created for JavaLauncher
+	Launcher string `json:"launcher,omitempty"`
+	// Current and BinLink are the links created for the Versioned layout
+	Current string `json:"current,omitempty"`
+	BinLink string `json:"binLink,omitempty"`
+	// ArchiveSHA256 is the digest of the downloaded archive
+	ArchiveSHA256 string `json:"archiveSha256,omitempty"`
+	// Skipped is set when the file already installed satisfied MinVersion, so nothing was downloaded
+	Skipped bool `json:"skipped,omitempty"`
+	// Extracted are the entries installed by All, where Path and SHA256 describe the first of them
+	Extracted []ExtractedFile `json:"extracted,omitempty"`
+	// Mapped are the entries installed by Mappings, where Path and SHA256 describe the first of
+	// them when File isn't given
+	Mapped []ExtractedFile `json:"mapped,omitempty"`
+	// Stats measure how long each step of the install took
+	Stats Stats `json:"stats"`
+}
+
+// Stats measure an install, such as to find the tools that slow down an image build. Durations
+// are given in nanoseconds when encoded as JSON.
+type Stats struct {
+	// DownloadDuration includes retrieving the checksum and verifying the archive
+	DownloadDuration time.Duration `json:"downloadDuration"`
+	// DownloadedBytes is the size of the retrieved archive, which is zero when it was cached or local
+	DownloadedBytes int64 `json:"downloadedBytes"`
+	// ExtractDuration includes installing the extracted files
+	ExtractDuration time.Duration `json:"extractDuration"`
+	// InstalledBytes is the total size of the installed files
+	InstalledBytes int64 `json:"installedBytes"`
+}
+
+// ProbeResult describes the archive without downloading it
+type ProbeResult struct {
+	From string `json:"from"`
+	// ContentLength is the size of the archive in bytes or -1 when unknown
+	ContentLength int64  `json:"contentLength"`
+	ContentType   string `json:"contentType,omitempty"`
+}
+
+// source is the resolved archive to download and the file to extract from it
+type source struct {
+	from string
+	// cacheKey is the from that was requested, which keys the cache even once from is rewritten
+	// to the https equivalent it was retrieved from
+	cacheKey string
+	fromURL  *url.URL
+	// archiveURL is where the archive is retrieved from, which is fromURL unless given a local archive
+	archiveURL *url.URL
+	file       string
+	mappings   []Mapping
+	// stripTopDir makes requested entries relative to topDir, which is resolved from the archive
+	stripTopDir bool
+	topDir      string
+	// all and flatten install every entry that matches file
+	all      bool
+	flatten  bool
+	format   extract.Format
+	fetcher  fetch.Fetcher
+	checksum *checksum.Checksum
+	// checksumRef is the path or URL of the checksum, which is retrieved before downloading
+	checksumRef string
+	// client is for http and https URLs, which is nil for other schemes
+	client       *http.Client
+	vars         map[string]string
+	provenance   *ProvenanceOptions
+	attestation  *AttestationOptions
+	sshSignature *SSHSignatureOptions
+	minisign     *MinisignOptions
+	pgp          *PGPOptions
+	cosign       *CosignOptions
+	verifyAuto   bool
+	zipPassword  string
+	// ageIdentities decrypt the archive when given
+	ageIdentities []*age.Identity
+	match         extract.Match
+	pick          extract.Pick
+	preferStatic  bool
+	preflight     bool
+	maxSize       int64
+	// cacheDir is where archives are cached, when given
+	cacheDir string
+	// keepArchiveDir is where the verified archive is copied to, when given
+	keepArchiveDir string
+	// allowHTTP permits retrieving the archive and references over unencrypted HTTP
+	allowHTTP bool
+	// preferHTTPS tries the https equivalent of http URLs first, where httpsFetcher retrieves
+	// that of the archive until it has fallen back to http
+	preferHTTPS  bool
+	httpsFetcher fetch.Fetcher
+	policy       *Policy
+	// strictDigests refuses checksums other than SHA-2
+	strictDigests bool
+	// preserveOwner gives installed files the owner recorded for their archive entries
+	preserveOwner bool
+	// ownerMap, when set, maps the preserved owners to those given installed files
+	ownerMap *install.OwnerMap
+	// owner, when set, is given installed files whose owner isn't preserved
+	owner *install.Owner
+	// normalizeModes installs entries with 0755 or 0644 rather than their recorded permissions
+	normalizeModes bool
+	// downloadedBytes is the size of the archive when it was retrieved rather than cached or local
+	downloadedBytes int64
+}
+
+// Install downloads the archive, extracts the requested file, and installs it as declared by the options
+func Install(ctx context.Context, opts Options) (result *Result, err error) {
+	ctx, span := telemetry.Start(ctx, "install")
+	var src *source
+	defer func() {
+		err = src.describe(err)
+		span.End(err)
+	}()
+
+	if opts.To == "" {
+		opts.To = install.DefaultDir()
+	}
+
+	src, err = resolveSource(&opts)
+	if err != nil {
+		return nil, err
+	}
+	err = opts.Policy.checkVerification(&opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.To = inRoot(opts.Root, opts.To)
+	if opts.PreserveOwner {
+		if install.CanChown() {
+			src.preserveOwner = true
+		} else if opts.OwnerMap != nil {
+			src.preserveOwner = true
+			src.ownerMap = opts.OwnerMap
+		} else {
+			log.Printf("W! Not preserving the owners of archive entries since only root can give files to other users, unless mapped by owner-map")
+		}
+	}
+	if opts.Reproducible != nil {
+		src.normalizeModes = true
+		if install.CanChown() {
+			src.owner = install.NewOwner(0, 0)
+		}
+		if opts.DirMode == nil {
+			// rather than filtered by the umask
+			dirMode := os.FileMode(0755)
+			opts.DirMode = &dirMode
+		}
+	}
+	for i := range src.mappings {
+		src.mappings[i].Dest = inRoot(opts.Root, src.mappings[i].Dest)
+	}
+
+	if opts.Writer != nil {
+		return writeFile(ctx, src, &opts)
+	}
+	if opts.JavaLauncher != "" && (src.all || src.file == "") {
+		return nil, errors.New("a java launcher requires a single file to install")
+	}
+
+	// where the installed file is found on later runs, such as to check its version
+	installedDir := opts.To
+	if opts.Versioned != nil {
+		err = opts.Versioned.validate()
+		if err != nil {
+			return nil, err
+		}
+		installedDir = VersionedBinDir(opts.To)
+	}
+
+	if opts.MinVersion != nil {
+		if src.file == "" {
+			return nil, errors.New("a file is required to check its installed version")
+		}
+		existing, err := opts.MinVersion.satisfiedBy(ctx, installedDir, src)
+		if err != nil || existing != nil {
+			return existing, err
+		}
+	}
+
+	installOpts := &install.Options{
+		To:               opts.To,
+		TargetOS:         opts.TargetOS,
+		TargetArch:       opts.TargetArch,
+		RequireArchMatch: opts.RequireArchMatch,
+		VerifyArgs:       opts.VerifyArgs,
+		SELinuxType:      opts.SELinuxType,
+	}
+	if opts.Setcap != "" {
+		installOpts.Capabilities, err = install.ParseCapabilities(opts.Setcap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var mkdirs func(dir string) error
+	var owner *install.Owner
+	if opts.Mkdirs {
+		if opts.Owner != "" {
+			owner, err = install.ParseOwner(opts.Owner)
+			if err != nil {
+				return nil, err
+			}
+		}
+		mkdirs = func(dir string) error {
+			return install.Mkdirs(dir, opts.DirMode, owner)
+		}
+
+		err = mkdirs(opts.To)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the directories of the versioned layout are created within to, which must exist
+	layoutMkdirs := func(dir string) error {
+		return install.Mkdirs(dir, opts.DirMode, owner)
+	}
+	if opts.Versioned != nil {
+		if _, err = os.Stat(opts.To); err != nil {
+			return nil, err
+		}
+		installOpts.To = filepath.Join(opts.Versioned.ToolDir(opts.To), opts.Versioned.Version)
+		err = layoutMkdirs(installOpts.To)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	downloadStart := time.Now()
+	archive, err := src.download(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Remove()
+
+	// keeps the files, links, and layout of concurrent installs into the same directory from interleaving
+	unlock, err := dirlock.Lock(ctx, opts.To)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	result = &Result{
+		From:          src.from,
+		ArchiveSHA256: archive.SHA256,
+		Stats: Stats{
+			DownloadDuration: time.Since(downloadStart),
+			DownloadedBytes:  src.downloadedBytes,
+		},
+	}
+	extractStart := time.Now()
+	err = src.installFiles(ctx, archive, installOpts, mkdirs, result)
+	if err != nil {
+		return nil, err
+	}
+	result.Stats.ExtractDuration = time.Since(extractStart)
+	result.Stats.InstalledBytes = installedBytes(result)
+
+	linked := result.Path
+	if opts.JavaLauncher != "" {
+		var launcher *install.File
+		launcher, err = installJavaLauncher(ctx, result.Path, opts.JavaLauncher, opts.Root)
+		if err != nil {
+			return nil, err
+		}
+		result.Launcher = launcher.Path
+		linked = launcher.Path
+	}
+
+	// the file that is expected to be found through the PATH
+	onPath := linked
+	if opts.Versioned != nil {
+		// links follow the current version rather than staying on this one
+		linked, err = opts.Versioned.link(opts.To, linked, layoutMkdirs, result)
+		if err != nil {
+			return nil, err
+		}
+		if result.BinLink != "" {
+			onPath = result.BinLink
+		}
+	}
+
+	for _, link := range opts.Links {
+		link = inRoot(opts.Root, link)
+		err = install.CreateLink(withinRoot(opts.Root, linked), link)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("I! Linked %s to %s", link, linked)
+		result.Links = append(result.Links, link)
+	}
+
+	// the PATH of this host doesn't apply to files within another root
+	if !opts.NoPathWarning && opts.Root == "" {
+		install.WarnIfNotOnPath(onPath, result.Links)
+	}
+
+	if opts.ExecAfter != "" {
+		err = runExecAfter(ctx, opts.ExecAfter, opts.Vars, result.Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Reproducible != nil {
+		err = opts.Reproducible.normalizeModTimes(result, opts.Root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// installFiles extracts and installs the requested file, or every matching entry, along with
+// the mappings, recording what was installed in the result
+func (s *source) installFiles(ctx context.Context, archive *fetch.Archive, installOpts *install.Options,
+	mkdirs func(dir string) error, result *Result) (err error) {
+
+	ctx, span := telemetry.Start(ctx, "extract")
+	defer func() {
+		span.End(err)
+	}()
+	span.SetAttribute("easy_add.format", string(s.format))
+
+	if s.all {
+		result.Extracted, err = s.installAll(ctx, archive, installOpts, mkdirs)
+		if err != nil {
+			return err
+		}
+		result.Path = result.Extracted[0].Path
+		result.SHA256 = result.Extracted[0].SHA256
+	} else if s.file != "" {
+		var installed *install.File
+		err = s.extract(ctx, archive,
+			func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+				fileOpts := *installOpts
+				fileOpts.Owner = s.entryOwner(info)
+				installed, err = install.Install(ctx, content, name, info.Size(), &fileOpts)
+				return err
+			})
+		if err != nil {
+			return err
+		}
+		log.Printf("I! Extracted file to %s with sha256:%s", installed.Path, installed.SHA256)
+		result.Path = installed.Path
+		result.SHA256 = installed.SHA256
+	}
+
+	result.Mapped, err = s.installMappings(ctx, archive, installOpts, mkdirs)
+	if err != nil {
+		return err
+	}
+	if s.file == "" {
+		result.Path = result.Mapped[0].Path
+		result.SHA256 = result.Mapped[0].SHA256
+	}
+	return nil
+}
+
+// Resolve downloads the archive and confirms the requested file is within it, but doesn't install
+// anything. Only the From and ArchiveSHA256 of the result are set.
+func Resolve(ctx context.Context, opts Options) (_ *Result, err error) {
+	var src *source
+	defer func() {
+		err = src.describe(err)
+	}()
+
+	src, err = resolveSource(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := src.download(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Remove()
+
+	located := func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+		log.Printf("I! Located %s in archive", name)
+		return nil
+	}
+	if src.all {
+		_, err = archive.Seek(0, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+		names, err := extract.ResolveEntries(ctx, src.format, archive.File, src.file, src.match)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			log.Printf("I! Located %s in archive", name)
+		}
+	} else if src.file != "" {
+		err = src.extract(ctx, archive, located)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, mapping := range src.mappings {
+		err = src.extractEntry(ctx, archive, mapping.Entry, extract.MatchGlob, located)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %s: %w", mapping.Entry, err)
+		}
+	}
+
+	return &Result{
+		From:          src.from,
+		ArchiveSHA256: archive.SHA256,
+	}, nil
+}
+
+// Probe confirms the archive exists and reports its size and type, without downloading it
+func Probe(ctx context.Context, opts Options) (*ProbeResult, error) {
+	src, err := resolveSource(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := src.probe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ProbeResult{
+		From:          src.from,
+		ContentLength: info.ContentLength,
+		ContentType:   info.ContentType,
+	}, nil
+}
+
+func resolveSource(opts *Options) (*source, error) {
+	if (opts.From == "" && opts.ArchivePath == "") || (opts.File == "" && len(opts.Mappings) == 0) {
+		return nil, errors.New("from and either file or mappings are required")
+	}
+
+	var archiveURL *url.URL
+	if opts.ArchivePath != "" {
+		var err error
+		archiveURL, err = localFileURL(opts.ArchivePath)
+		if err != nil {
+			return nil, err
+		}
+		if opts.From == "" {
+			opts.From = archiveURL.String()
+		}
+	}
+
+	from, err := EvaluateTemplate(opts.From, opts.Vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate 'from': %w", err)
+	}
+
+	file, err := EvaluateTemplate(opts.File, opts.Vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate 'file': %w", err)
+	}
+
+	mappings, err := evaluateMappings(opts.Mappings, opts.Vars)
+	if err != nil {
+		return nil, err
+	}
+
+	fromURL, err := url.Parse(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'from' URL: %w", err)
+	}
+
+	var partURLs []*url.URL
+	for _, part := range opts.Parts {
+		part, err = EvaluateTemplate(part, opts.Vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate part: %w", err)
+		}
+		partURL, err := url.Parse(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid part URL: %w", err)
+		}
+		partURLs = append(partURLs, partURL)
+	}
+	if archiveURL == nil {
+		for _, u := range append([]*url.URL{fromURL}, partURLs...) {
+			err = opts.Policy.checkHost(u)
+			if err != nil {
+				return nil, err
+			}
+			// when https is preferred, the http URLs are only checked if falling back to them
+			if !opts.PreferHTTPS {
+				err = checkHTTP(u, opts.AllowHTTP, opts.Policy)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	_, split := fetch.SplitName(fromURL.Path)
+	if len(partURLs) > 0 && archiveURL != nil {
+		return nil, errors.New("parts can't be given along with a local archive")
+	}
+
+	var ageIdentities []*age.Identity
+	if len(opts.AgeIdentityFiles) > 0 {
+		ageIdentities, err = age.LoadIdentities(opts.AgeIdentityFiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	format := opts.Format
+	sourceFormat, sourceArchive := gitHubSourceArchive(fromURL)
+	if format == "" && sourceFormat != "" {
+		format = sourceFormat
+	}
+	if format == "" {
+		// only the path, since query strings such as the signature of a pre-signed URL follow the suffix
+		name, _ := fetch.SplitName(fromURL.Path)
+		if strings.HasSuffix(name, ".age") {
+			if ageIdentities == nil {
+				return nil, errors.New("the archive is age encrypted, which requires an age identity to decrypt")
+			}
+			name = strings.TrimSuffix(name, ".age")
+		}
+		if opts.JavaLauncher != "" && strings.HasSuffix(strings.ToLower(name), ".jar") {
+			format = extract.Binary
+		} else {
+			format, err = extract.DetectFormat(name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	client := opts.HTTPClient
+	scheme := strings.ToLower(fromURL.Scheme)
+	if client == nil && (scheme == "http" || scheme == "https" || scheme == "oci") {
+		clientOpts := fetch.ClientOptions{
+			Proxy:         opts.Proxy,
+			CACertFiles:   opts.CACertFiles,
+			AllowHTTP:     opts.AllowHTTP,
+			CheckRedirect: opts.Policy.CheckRedirect,
+		}
+		if opts.StrictDigests {
+			clientOpts.MinTLSVersion = tls.VersionTLS12
+		}
+		client, err = fetch.NewHTTPClient(clientOpts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if archiveURL == nil {
+		archiveURL = fromURL
+	}
+	fetcher, err := fetch.ForURL(archiveURL, client)
+	if err != nil {
+		return nil, err
+	}
+	if split || len(partURLs) > 0 {
+		fetcher = &fetch.PartsFetcher{Fetcher: fetcher, Parts: partURLs}
+	}
+	var httpsFetcher fetch.Fetcher
+	if opts.PreferHTTPS && strings.EqualFold(archiveURL.Scheme, "http") {
+		httpsFetcher, err = fetch.ForURL(&url.URL{Scheme: "https"}, client)
+		if err != nil {
+			return nil, err
+		}
+		if split || len(partURLs) > 0 {
+			httpsParts := make([]*url.URL, len(partURLs))
+			for i, partURL := range partURLs {
+				httpsParts[i] = withHTTPS(partURL)
+			}
+			httpsFetcher = &fetch.PartsFetcher{Fetcher: httpsFetcher, Parts: httpsParts}
+		}
+	}
+
+	if opts.Verify != "" && opts.Verify != VerifyAuto {
+		return nil, fmt.Errorf("unsupported verify mode %q", opts.Verify)
+	}
+
+	var expectedChecksum *checksum.Checksum
+	var checksumRef string
+	if checksum.IsInline(opts.Checksum) {
+		expectedChecksum, err = checksum.Parse(opts.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		err = checkStrictDigest(expectedChecksum, opts.StrictDigests)
+		if err != nil {
+			return nil, err
+		}
+	} else if opts.Checksum != "" {
+		checksumRef, err = EvaluateTemplate(opts.Checksum, opts.Vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate 'checksum': %w", err)
+		}
+	}
+
+	return &source{
+		from:           from,
+		cacheKey:       from,
+		fromURL:        fromURL,
+		archiveURL:     archiveURL,
+		file:           file,
+		mappings:       mappings,
+		all:            (opts.All || opts.Flatten) && file != "",
+		flatten:        opts.Flatten,
+		stripTopDir:    opts.StripTopDir || sourceArchive,
+		format:         format,
+		fetcher:        fetcher,
+		checksum:       expectedChecksum,
+		checksumRef:    checksumRef,
+		client:         client,
+		vars:           opts.Vars,
+		provenance:     opts.Provenance,
+		attestation:    opts.Attestation,
+		sshSignature:   opts.SSHSignature,
+		minisign:       opts.Minisign,
+		pgp:            opts.PGP,
+		cosign:         opts.Cosign,
+		verifyAuto:     opts.Verify == VerifyAuto,
+		zipPassword:    opts.ZipPassword,
+		ageIdentities:  ageIdentities,
+		match:          opts.Match,
+		pick:           opts.Pick,
+		preferStatic:   opts.PreferStatic,
+		preflight:      opts.Preflight,
+		maxSize:        opts.MaxDownloadSize,
+		keepArchiveDir: opts.KeepArchiveDir,
+		cacheDir:       opts.CacheDir,
+		allowHTTP:      opts.AllowHTTP,
+		preferHTTPS:    opts.PreferHTTPS,
+		policy:         opts.Policy,
+		strictDigests:  opts.StrictDigests,
+		httpsFetcher:   httpsFetcher,
+	}, nil
+}
+
+// installedBytes totals the size of the files installed for the result
+func installedBytes(result *Result) int64 {
+	paths := []string{result.Path}
+	if len(result.Extracted) > 0 {
+		paths = nil
+		for _, extracted := range result.Extracted {
+			paths = append(paths, extracted.Path)
+		}
+	}
+	for _, mapped := range result.Mapped {
+		if mapped.Path != result.Path {
+			paths = append(paths, mapped.Path)
+		}
+	}
+
+	var total int64
+	for _, p := range paths {
+		if stat, err := os.Stat(p); err == nil {
+			total += stat.Size()
+		}
+	}
+	return total
+}
+
+// localFileURL converts the path of a local file to a file URL
+func localFileURL(filePath string) (*url.URL, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive path: %w", err)
+	}
+	absPath = filepath.ToSlash(absPath)
+	if !strings.HasPrefix(absPath, "/") {
+		// a Windows drive, such as C:/downloads, needs a leading slash in the URL path
+		absPath = "/" + absPath
+	}
+	return &url.URL{Scheme: "file", Path: absPath}, nil
+}
+
+func (s *source) probe(ctx context.Context) (*fetch.Info, error) {
+	info, err := s.probeArchive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	size := "unknown size"
+	if info.ContentLength >= 0 {
+		size = fmt.Sprintf("%d bytes", info.ContentLength)
+	}
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "unknown type"
+	}
+	log.Printf("I! Archive is %s of %s", size, contentType)
+	if strings.HasPrefix(contentType, "text/html") {
+		log.Printf("W! The server describes the archive as HTML, which may be an error or login page")
+	}
+
+	return info, fetch.CheckSize(info.ContentLength, s.maxSize)
+}
+
+func (s *source) download(ctx context.Context) (archive *fetch.Archive, err error) {
+	ctx, span := telemetry.Start(ctx, "download")
+	defer func() {
+		span.End(err)
+	}()
+	span.SetAttribute("url.full", s.archiveURL.Redacted())
+
+	if s.preflight {
+		_, err := s.probe(ctx)
+		if err != nil {
+			return nil, categorized(CategoryDownload, err)
+		}
+	}
+
+	if s.checksumRef != "" {
+		s.checksum, err = s.retrieveChecksum(ctx)
+		if err != nil {
+			return nil, categorized(CategoryDownload, err)
+		}
+	}
+
+	// a local archive is used as is
+	useCache := s.cacheDir != "" && s.archiveURL == s.fromURL
+	if useCache {
+		archive = s.cachedArchive(ctx)
+	}
+	downloaded := archive == nil
+	span.SetAttribute("easy_add.cached", strconv.FormatBool(!downloaded))
+	if downloaded {
+		archive, err = s.fetchArchive(ctx)
+		if err != nil {
+			return nil, categorized(CategoryDownload, err)
+		}
+		if s.archiveURL == s.fromURL {
+			if stat, err := archive.Stat(); err == nil {
+				s.downloadedBytes = stat.Size()
+				telemetry.Add(ctx, "easy_add.download.bytes", "By", s.downloadedBytes)
+			}
+		}
+	}
+	if s.checksum != nil {
+		log.Printf("I! Verified %s checksum of archive", s.checksum.Algorithm)
+	}
+	err = s.verifyArchive(ctx, archive)
+	if err != nil {
+		archive.Remove()
+		return nil, categorized(CategoryVerification, err)
+	}
+	if useCache && downloaded {
+		s.cacheArchive(ctx, archive)
+	}
+	if s.keepArchiveDir != "" {
+		err = s.keepArchive(ctx, archive)
+		if err != nil {
+			archive.Remove()
+			return nil, err
+		}
+	}
+	if s.ageIdentities != nil {
+		return s.decryptArchive(ctx, archive)
+	}
+	return archive, nil
+}
+
+// verifyArchive checks the signatures, provenance, and attestation of the archive that are required
+func (s *source) verifyArchive(ctx context.Context, archive *fetch.Archive) (err error) {
+	verifySignatures := len(s.signatureVerifiers()) > 0 && s.checksumRef == ""
+	if !verifySignatures && s.provenance == nil && s.attestation == nil {
+		return nil
+	}
+	ctx, span := telemetry.Start(ctx, "verify")
+	defer func() {
+		span.End(err)
+	}()
+
+	if verifySignatures {
+		err = s.verifySignaturesOfArchive(ctx, archive)
+		if err != nil {
+			return err
+		}
+	}
+	if s.provenance != nil {
+		err = s.verifyProvenance(ctx, archive)
+		if err != nil {
+			return err
+		}
+	}
+	if s.attestation != nil {
+		return s.verifyAttestation(ctx, archive)
+	}
+	return nil
+}
+
+// keepArchive copies the archive, as downloaded, into keepArchiveDir
+func (s *source) keepArchive(ctx context.Context, archive *fetch.Archive) error {
+	name := s.archiveName()
+	err := os.MkdirAll(s.keepArchiveDir, 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create directory for kept archive: %w", err)
+	}
+	keptPath := filepath.Join(s.keepArchiveDir, name)
+	err = archive.SaveAs(ctx, keptPath)
+	if err != nil {
+		return err
+	}
+	log.Printf("I! Kept archive at %s", keptPath)
+	return nil
+}
+
+// archiveName is the file name of the archive from its URL, which is that of the whole archive
+// when it was split into numbered parts
+func (s *source) archiveName() string {
+	name, _ := fetch.SplitName(path.Base(s.fromURL.Path))
+	if name == "/" || name == "." {
+		return "archive"
+	}
+	return name
+}
+
+// extract locates the requested file in the archive, by first resolving the entry name when
+// not matched exactly, and passes its content to the handler
+func (s *source) extract(ctx context.Context, archive *fetch.Archive, handler extract.Handler) error {
+	return s.extractEntry(ctx, archive, s.file, s.match, handler)
+}
+
+// extractEntry is extract for any entry of the archive, which can be called repeatedly
+func (s *source) extractEntry(ctx context.Context, archive *fetch.Archive, requested string, match extract.Match,
+	handler extract.Handler) error {
+
+	ctx = s.extractContext(ctx)
+
+	err := s.resolveTopDir(ctx, archive)
+	if err != nil {
+		return err
+	}
+	_, err = archive.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	matched, err := extract.ResolveEntries(ctx, s.format, archive.File, s.entryWithin(requested, match), match)
+	if err != nil {
+		return err
+	}
+	file, err := s.pickEntry(ctx, archive, requested, matched)
+	if err != nil {
+		return err
+	}
+	if file != requested {
+		log.Printf("I! Matched %s to %s in archive", requested, file)
+	}
+
+	return extract.Extract(ctx, s.format, archive.File, file, handler)
+}
+
+// pickEntry chooses one of the entries that matched the requested file, logging them when there
+// are several to choose from
+func (s *source) pickEntry(ctx context.Context, archive *fetch.Archive, requested string, matched []string) (string, error) {
+	if len(matched) > 1 && s.preferStatic {
+		var err error
+		matched, err = s.staticEntries(ctx, archive, requested, matched)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(matched) == 1 {
+		return matched[0], nil
+	}
+	if s.pick == extract.PickError || s.pick == "" {
+		return "", fmt.Errorf("%s matches several files in the archive: %s", requested, strings.Join(matched, ", "))
+	}
+
+	var sizes map[string]int64
+	if s.pick == extract.PickLargest {
+		var err error
+		sizes, err = extract.Sizes(ctx, s.format, archive.File, matched)
+		if err != nil {
+			return "", fmt.Errorf("unable to find the sizes of the files matching %s: %w", requested, err)
+		}
+		_, err = archive.Seek(0, io.SeekStart)
+		if err != nil {
+			return "", err
+		}
+	}
+	picked := s.pick.Choose(matched, sizes)
+	log.Printf("I! %s matches several files in the archive, picked %s as %s of: %s",
+		requested, picked, s.pick, strings.Join(matched, ", "))
+	return picked, nil
+}
+
+// staticEntries narrows the entries to those that are statically linked ELF binaries, or returns
+// them all when none are
+func (s *source) staticEntries(ctx context.Context, archive *fetch.Archive, requested string, entries []string) ([]string, error) {
+	var static []string
+	err := extract.ExtractAll(ctx, s.format, archive.File, entries, func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+		// debug/elf needs random access, so the entry is read into memory
+		buf, err := ioutil.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		if isStatic, _ := install.StaticallyLinked(bytes.NewReader(buf)); isStatic {
+			static = append(static, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect the files matching %s: %w", requested, err)
+	}
+	_, err = archive.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(static) == 0 {
+		log.Printf("W! None of the files matching %s are statically linked: %s", requested, strings.Join(entries, ", "))
+		return entries, nil
+	}
+	// ExtractAll calls the handler in the order of the archive
+	sort.Strings(static)
+	log.Printf("I! Preferring the statically linked %s of the files matching %s: %s",
+		strings.Join(static, ", "), requested, strings.Join(entries, ", "))
+	return static, nil
+}
+
+// extractContext provides the password for encrypted entries, when given
+func (s *source) extractContext(ctx context.Context) context.Context {
+	if s.zipPassword != "" {
+		return extract.WithPassword(ctx, s.zipPassword)
+	}
+	return ctx
+}
+
+// EvaluateTemplate processes the given text as a Go template with vars as its context
+func EvaluateTemplate(text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New("from").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, vars)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,101 @@
+package easyadd
+
+import (
+	"errors"
+	"github.com/itzg/easy-add/pkg/checksum"
+	"github.com/itzg/easy-add/pkg/extract"
+	"os"
+)
+
+// ErrorCategory classifies why an install failed, such as for a wrapper script to react to
+type ErrorCategory string
+
+const (
+	// CategoryDownload is a failure to retrieve the archive or the files that verify it, where a
+	// fetch.StatusError within the error gives the HTTP response
+	CategoryDownload ErrorCategory = "download"
+	// CategoryChecksum is an archive that doesn't match its checksum, or a checksum that couldn't be found
+	CategoryChecksum ErrorCategory = "checksum"
+	// CategoryVerification is a signature, provenance, or attestation that failed to verify
+	CategoryVerification ErrorCategory = "verification"
+	// CategoryPolicy is an install that the Policy refuses
+	CategoryPolicy ErrorCategory = "policy"
+	// CategoryNotFound is a requested file that isn't within the archive
+	CategoryNotFound ErrorCategory = "not-found"
+	// CategoryFilesystem is a failure to read or write local files, such as when installing
+	CategoryFilesystem ErrorCategory = "filesystem"
+	// CategoryOther is any other failure
+	CategoryOther ErrorCategory = "other"
+)
+
+// Error is a failure of an install along with the category of what failed. The errors returned
+// by Install and Resolve are always, or wrap, an Error.
+type Error struct {
+	Category ErrorCategory
+	// URL is that of the archive, when known
+	URL string
+	// Entry is the requested file within the archive, when known
+	Entry string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Categorize determines the category of an error returned by this package
+func Categorize(err error) ErrorCategory {
+	var mismatchErr *checksum.MismatchError
+	var installErr *Error
+	var pathErr *os.PathError
+	var linkErr *os.LinkError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &mismatchErr):
+		return CategoryChecksum
+	case errors.As(err, &installErr):
+		return installErr.Category
+	case errors.Is(err, extract.ErrNotFound):
+		return CategoryNotFound
+	case errors.As(err, &pathErr), errors.As(err, &linkErr):
+		return CategoryFilesystem
+	default:
+		return CategoryOther
+	}
+}
+
+// categorized marks the error with the category unless it already has one
+func categorized(category ErrorCategory, err error) error {
+	var installErr *Error
+	if err == nil || errors.As(err, &installErr) {
+		return err
+	}
+	return &Error{Category: category, Err: err}
+}
+
+// describe ensures the error is an Error with the archive and requested file of the source, which
+// may be nil when it wasn't resolved
+func (s *source) describe(err error) error {
+	if err == nil {
+		return nil
+	}
+	var installErr *Error
+	if !errors.As(err, &installErr) {
+		installErr = &Error{Category: Categorize(err), Err: err}
+		err = installErr
+	}
+	if s != nil {
+		if installErr.URL == "" {
+			installErr.URL = s.fromURL.Redacted()
+		}
+		if installErr.Entry == "" {
+			installErr.Entry = s.file
+		}
+	}
+	return err
+}
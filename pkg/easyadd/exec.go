@@ -0,0 +1,42 @@
+package easyadd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// runExecAfter runs the given shell command, which may contain Go template references to
+// 'var' entries along with 'path' for the location of the installed file
+func runExecAfter(ctx context.Context, command string, vars map[string]string, installedPath string) error {
+	templateVars := make(map[string]string, len(vars)+1)
+	for k, v := range vars {
+		templateVars[k] = v
+	}
+	templateVars["path"] = installedPath
+
+	command, err := EvaluateTemplate(command, templateVars)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate 'exec-after': %w", err)
+	}
+
+	log.Printf("I! Running %s", command)
+	cmd := shellCommand(ctx, command)
+	cmd.Stdout = log.Writer()
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("exec-after command failed: %w", err)
+	}
+	return nil
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
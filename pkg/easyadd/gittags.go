@@ -0,0 +1,103 @@
+package easyadd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxRefsSize limits what is read of the refs of a git repository, which GitHub lists along with
+// those of every pull request ahead of the tags
+const maxRefsSize = 64 << 20
+
+// LatestGitTag lists the tags of the git repository at the http or https URL, such as
+// https://github.com/owner/repo, and returns the newest one whose name matches the pattern along
+// with its version, as described by newestTag. The HTTPClient, AllowHTTP, PreferHTTPS, and
+// Policy of the options apply to it as they do to the archive.
+func LatestGitTag(ctx context.Context, opts Options, remote string, pattern string) (tag string, version string, err error) {
+	u, err := url.Parse(remote)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", "", fmt.Errorf("git tags can only be listed from an http or https URL, not %s", remote)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", "", fmt.Errorf("invalid tag pattern %s: %w", pattern, err)
+	}
+
+	s := &source{
+		client:      opts.HTTPClient,
+		allowHTTP:   opts.AllowHTTP,
+		preferHTTPS: opts.PreferHTTPS,
+		policy:      opts.Policy,
+	}
+	refsURL := *u
+	refsURL.Path = strings.TrimSuffix(refsURL.Path, "/") + "/info/refs"
+	refsURL.RawQuery = "service=git-upload-pack"
+	content, err := s.readReferenceUpTo(ctx, refsURL.String(), "git tags", maxRefsSize)
+	if err != nil {
+		return "", "", categorized(CategoryDownload, err)
+	}
+	tags, err := parseGitTags(content)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to list the tags of %s: %w", u.Redacted(), err)
+	}
+
+	tag, version = newestTag(tags, pattern)
+	if tag == "" {
+		return "", "", fmt.Errorf("none of the %d tags of %s are versions, other than pre-releases, matching %s",
+			len(tags), u.Redacted(), pattern)
+	}
+	return tag, version, nil
+}
+
+// parseGitTags finds the names of the tags in the ref advertisement of the smart HTTP protocol of
+// git, or the info/refs file of a repository served as is, where peeled tags are only listed once
+func parseGitTags(content []byte) ([]string, error) {
+	var refs []string
+	if len(content) >= 4 && strings.HasPrefix(string(content[4:]), "# service=") {
+		for len(content) > 0 {
+			if len(content) < 4 {
+				return nil, errors.New("truncated ref advertisement")
+			}
+			length, err := strconv.ParseUint(string(content[:4]), 16, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pkt-line length %q", content[:4])
+			}
+			if length == 0 {
+				// a flush, which follows the service line and ends the refs
+				content = content[4:]
+				continue
+			}
+			if length < 4 || int(length) > len(content) {
+				return nil, errors.New("truncated ref advertisement")
+			}
+			line := string(content[4:length])
+			content = content[length:]
+			// the first ref is followed by the capabilities of the server
+			if i := strings.IndexByte(line, 0); i >= 0 {
+				line = line[:i]
+			}
+			refs = append(refs, strings.TrimSuffix(line, "\n"))
+		}
+	} else {
+		refs = strings.Split(string(content), "\n")
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		fields := strings.Fields(ref)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "refs/tags/") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(fields[1], "refs/tags/"), "^{}")
+		if !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags, nil
+}
@@ -0,0 +1,51 @@
+package easyadd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// pktLine gives the line as a pkt-line of the git protocol
+func pktLine(line string) string {
+	return fmt.Sprintf("%04x%s", len(line)+4, line)
+}
+
+func TestLatestGitTagPastPullRequests(t *testing.T) {
+	const oid = "0123456789abcdef0123456789abcdef01234567"
+	var advertisement bytes.Buffer
+	advertisement.WriteString(pktLine("# service=git-upload-pack\n") + "0000")
+	advertisement.WriteString(pktLine(oid + " HEAD\x00multi_ack side-band-64k\n"))
+	// as GitHub lists the refs of every pull request ahead of the tags
+	for i := 1; advertisement.Len() <= 2*maxReferenceSize; i++ {
+		advertisement.WriteString(pktLine(fmt.Sprintf("%s refs/pull/%d/head\n", oid, i)))
+	}
+	for _, tag := range []string{"v1.2.0", "v1.10.0", "v1.10.0^{}", "v2.0.0-rc.1"} {
+		advertisement.WriteString(pktLine(oid + " refs/tags/" + tag + "\n"))
+	}
+	advertisement.WriteString("0000")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/owner/repo/info/refs" || r.URL.Query().Get("service") != "git-upload-pack" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		_, _ = w.Write(advertisement.Bytes())
+	}))
+	defer server.Close()
+
+	tag, version, err := LatestGitTag(context.Background(), Options{
+		HTTPClient: server.Client(),
+		AllowHTTP:  true,
+	}, server.URL+"/owner/repo", "v*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "v1.10.0" || version != "1.10.0" {
+		t.Errorf("newest tag is %s at %s rather than v1.10.0", tag, version)
+	}
+}
@@ -0,0 +1,120 @@
+package easyadd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/checksum"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// checkHTTP refuses a URL of unencrypted HTTP, since what's downloaded is typically run as root,
+// unless allowed or it's of this host, where the traffic never crosses a network. A policy that
+// requires https refuses it regardless.
+func checkHTTP(u *url.URL, allowed bool, policy *Policy) error {
+	if !strings.EqualFold(u.Scheme, "http") {
+		return nil
+	}
+	if policy != nil && policy.RequireHTTPS {
+		return policyViolation("refusing to download %s over unencrypted HTTP since the policy requires https", u.Redacted())
+	}
+	if allowed || isLoopback(u.Hostname()) {
+		return nil
+	}
+	return fmt.Errorf("refusing to download %s over unencrypted HTTP, which needs to be allowed such as with allow-http", u.Redacted())
+}
+
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// httpsEquivalent is the https URL to try ahead of an http one when that's preferred, or nil
+// otherwise
+func (s *source) httpsEquivalent(u *url.URL) *url.URL {
+	if !s.preferHTTPS || !strings.EqualFold(u.Scheme, "http") {
+		return nil
+	}
+	return withHTTPS(u)
+}
+
+// withHTTPS is the URL with the https scheme when it's http, where an explicit port 80 is dropped
+// for the default of https
+func withHTTPS(u *url.URL) *url.URL {
+	if !strings.EqualFold(u.Scheme, "http") {
+		return u
+	}
+	upgraded := *u
+	upgraded.Scheme = "https"
+	if upgraded.Port() == "80" {
+		upgraded.Host = strings.TrimSuffix(upgraded.Host, ":80")
+	}
+	return &upgraded
+}
+
+// fallBackToHTTP determines if the http URL may be used after its https equivalent failed with
+// the given error, which is only when HTTP is allowed for it
+func (s *source) fallBackToHTTP(ctx context.Context, u *url.URL, httpsErr error) error {
+	var mismatchErr *checksum.MismatchError
+	if ctx.Err() != nil || errors.As(httpsErr, &mismatchErr) {
+		// the https content being different is no reason to trust that of http
+		return httpsErr
+	}
+	err := checkHTTP(u, s.allowHTTP, s.policy)
+	if err != nil {
+		return fmt.Errorf("%v; %w", httpsErr, err)
+	}
+	log.Printf("W! Falling back to %s since its https equivalent failed: %v", u.Redacted(), httpsErr)
+	return nil
+}
+
+// probeArchive checks the archive with a HEAD request, trying its https equivalent first when
+// that's preferred
+func (s *source) probeArchive(ctx context.Context) (*fetch.Info, error) {
+	if httpsURL := s.httpsEquivalent(s.archiveURL); httpsURL != nil && s.httpsFetcher != nil {
+		log.Printf("I! Checking %s", httpsURL.Redacted())
+		info, err := fetch.Probe(ctx, s.httpsFetcher, httpsURL)
+		if err == nil {
+			return info, nil
+		}
+		err = s.fallBackToHTTP(ctx, s.archiveURL, err)
+		if err != nil {
+			return nil, err
+		}
+		// once fallen back, the archive is only retrieved over http
+		s.httpsFetcher = nil
+	}
+
+	log.Printf("I! Checking %s", s.archiveURL)
+	return fetch.Probe(ctx, s.fetcher, s.archiveURL)
+}
+
+// fetchArchive downloads the archive, trying its https equivalent first when that's preferred
+func (s *source) fetchArchive(ctx context.Context) (*fetch.Archive, error) {
+	if httpsURL := s.httpsEquivalent(s.archiveURL); httpsURL != nil && s.httpsFetcher != nil {
+		log.Printf("I! Retrieving %s", httpsURL.Redacted())
+		archive, err := fetch.Download(ctx, s.httpsFetcher, httpsURL, s.checksum, s.maxSize)
+		if err == nil {
+			s.from = httpsURL.String()
+			s.fromURL = httpsURL
+			s.archiveURL = httpsURL
+			return archive, nil
+		}
+		err = s.fallBackToHTTP(ctx, s.archiveURL, err)
+		if err != nil {
+			return nil, err
+		}
+		// once fallen back, the archive is only retrieved over http
+		s.httpsFetcher = nil
+	}
+
+	log.Printf("I! Retrieving %s", s.archiveURL)
+	return fetch.Download(ctx, s.fetcher, s.archiveURL, s.checksum, s.maxSize)
+}
@@ -0,0 +1,57 @@
+package easyadd
+
+import (
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/install"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// JavaLauncherName is the file name of the launcher script requested as name, which on Windows
+// is a batch file
+func JavaLauncherName(name string) string {
+	if runtime.GOOS == "windows" && !strings.HasSuffix(strings.ToLower(name), ".cmd") {
+		return name + ".cmd"
+	}
+	return name
+}
+
+// installJavaLauncher writes a script into the directory of the installed jar that runs it with
+// java, or that of JAVA_HOME when set, passing along JAVA_OPTS and the script's arguments. The
+// script refers to the jar as seen from within root, when given.
+func installJavaLauncher(ctx context.Context, jarPath string, name string, root string) (*install.File, error) {
+	jarPath, err := filepath.Abs(jarPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve path of jar: %w", err)
+	}
+	scriptJarPath := withinRoot(root, jarPath)
+
+	var script string
+	if runtime.GOOS == "windows" {
+		script = "@echo off\r\n" +
+			"set JAVA=java\r\n" +
+			"if defined JAVA_HOME set \"JAVA=%JAVA_HOME%\\bin\\java\"\r\n" +
+			"\"%JAVA%\" %JAVA_OPTS% -jar \"" + scriptJarPath + "\" %*\r\n"
+	} else {
+		script = "#!/bin/sh\n" +
+			"exec \"${JAVA_HOME:+$JAVA_HOME/bin/}java\" $JAVA_OPTS -jar " + shellQuote(scriptJarPath) + " \"$@\"\n"
+	}
+
+	launcher, err := install.Install(ctx, strings.NewReader(script), name, int64(len(script)), &install.Options{
+		To:   filepath.Dir(jarPath),
+		Name: JavaLauncherName(name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to install java launcher: %w", err)
+	}
+	log.Printf("I! Created java launcher %s for %s", launcher.Path, jarPath)
+	return launcher, nil
+}
+
+// shellQuote quotes the value as a single word for a POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
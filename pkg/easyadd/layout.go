@@ -0,0 +1,84 @@
+package easyadd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/install"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// CurrentLink is the name of the link, within the directory of a tool installed with the
+// versioned layout, that points at the installed version
+const CurrentLink = "current"
+
+// VersionedLayout installs a tool into a directory per version, <to>/<name>/<version>, so that
+// versions are kept side by side. The current link within <to>/<name> points at the installed
+// version, and a link within <to>/bin points at the installed file by way of it, so that
+// rolling back is only a matter of pointing current at another version.
+type VersionedLayout struct {
+	Name    string
+	Version string
+}
+
+// ToolDir is the directory within to that holds the versions of the tool
+func (l *VersionedLayout) ToolDir(to string) string {
+	return filepath.Join(to, l.Name)
+}
+
+// VersionedBinDir is the directory within to of the links to the installed files of each tool
+func VersionedBinDir(to string) string {
+	return filepath.Join(to, "bin")
+}
+
+func (l *VersionedLayout) validate() error {
+	if l.Name == "" {
+		return errors.New("a name is required to install with the versioned layout")
+	}
+	if l.Version == "" {
+		return errors.New("a version variable is required to install with the versioned layout")
+	}
+	for _, part := range []string{l.Name, l.Version} {
+		if part == "." || part == ".." || part == "bin" || strings.ContainsAny(part, `/\`) {
+			return fmt.Errorf("'%s' can't be used as a directory of the versioned layout", part)
+		}
+	}
+	return nil
+}
+
+// link points the current link of the tool at the directory of the version and, when a file was
+// installed there, links to it from the bin directory. It returns the path of the installed file
+// by way of the current link.
+func (l *VersionedLayout) link(to string, installedPath string, mkdirs func(dir string) error, result *Result) (string, error) {
+	toolDir := l.ToolDir(to)
+	versionDir := filepath.Join(toolDir, l.Version)
+	result.Current = filepath.Join(toolDir, CurrentLink)
+	err := install.CreateRelativeLink(versionDir, result.Current)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("I! Linked %s to version %s", result.Current, l.Version)
+
+	if installedPath == "" {
+		return "", nil
+	}
+	rel, err := filepath.Rel(versionDir, installedPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve %s within %s: %w", installedPath, versionDir, err)
+	}
+	viaCurrent := filepath.Join(result.Current, rel)
+
+	binDir := VersionedBinDir(to)
+	err = mkdirs(binDir)
+	if err != nil {
+		return "", err
+	}
+	result.BinLink = filepath.Join(binDir, filepath.Base(viaCurrent))
+	err = install.CreateRelativeLink(viaCurrent, result.BinLink)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("I! Linked %s to %s", result.BinLink, viaCurrent)
+	return viaCurrent, nil
+}
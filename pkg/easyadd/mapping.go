@@ -0,0 +1,162 @@
+package easyadd
+
+import (
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"github.com/itzg/easy-add/pkg/install"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Mapping extracts an archive entry to a path of its own, in addition to File
+type Mapping struct {
+	// Entry is the path of the entry within the archive or a pattern, as supported by path.Match,
+	// that matches only one entry. It may contain Go template references to Vars entries.
+	Entry string
+	// Dest is the absolute path, including the file name, where the entry is installed. It may
+	// contain Go template references to Vars entries.
+	Dest string
+}
+
+// ExtractedFile describes an archive entry installed by a Mapping or along with All
+type ExtractedFile struct {
+	Entry  string `json:"entry"`
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// ParseMapping parses a mapping given as entry=dest, such as tool-*/bin/tool=/usr/local/bin/tool
+func ParseMapping(spec string) (Mapping, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Mapping{}, fmt.Errorf("invalid mapping '%s', must be entry=dest", spec)
+	}
+	return Mapping{Entry: parts[0], Dest: parts[1]}, nil
+}
+
+func (m Mapping) String() string {
+	return m.Entry + "=" + m.Dest
+}
+
+// evaluateMappings evaluates the templates of the mappings and confirms the destinations are absolute
+func evaluateMappings(mappings []Mapping, vars map[string]string) ([]Mapping, error) {
+	var evaluated []Mapping
+	for _, mapping := range mappings {
+		entry, err := EvaluateTemplate(mapping.Entry, vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate mapped entry: %w", err)
+		}
+		dest, err := EvaluateTemplate(mapping.Dest, vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate mapped destination: %w", err)
+		}
+		if !filepath.IsAbs(dest) {
+			return nil, fmt.Errorf("the destination of %s must be an absolute path", entry)
+		}
+		evaluated = append(evaluated, Mapping{Entry: entry, Dest: dest})
+	}
+	return evaluated, nil
+}
+
+// installMappings extracts each mapped entry of the archive to its destination, listing and
+// reading the archive only once for all of them. The verify args and capabilities of installOpts
+// only apply to File, so they aren't used for these.
+func (s *source) installMappings(ctx context.Context, archive *fetch.Archive, installOpts *install.Options,
+	mkdirs func(dir string) error) ([]ExtractedFile, error) {
+
+	if len(s.mappings) == 0 {
+		return nil, nil
+	}
+	ctx = s.extractContext(ctx)
+	err := s.resolveTopDir(ctx, archive)
+	if err != nil {
+		return nil, err
+	}
+	names, err := extract.List(ctx, s.format, archive.File)
+	if err != nil {
+		return nil, err
+	}
+
+	mappedOpts := make([]install.Options, len(s.mappings))
+	// indexes are of the mappings of each entry, since more than one may map the same entry
+	indexes := make(map[string][]int)
+	var entries []string
+	for i, mapping := range s.mappings {
+		requested := s.entryWithin(mapping.Entry, extract.MatchGlob)
+		matched, err := extract.MatchEntries(names, requested, extract.MatchGlob)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %s: %w", mapping.Entry, err)
+		}
+		entry, err := s.pickEntry(ctx, archive, mapping.Entry, matched)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %s: %w", mapping.Entry, err)
+		}
+		if entry != mapping.Entry {
+			log.Printf("I! Matched %s to %s in archive", mapping.Entry, entry)
+		}
+
+		opts := *installOpts
+		opts.To = filepath.Dir(mapping.Dest)
+		opts.Name = filepath.Base(mapping.Dest)
+		opts.VerifyArgs = nil
+		opts.Capabilities = nil
+		if mkdirs != nil {
+			err := mkdirs(opts.To)
+			if err != nil {
+				return nil, err
+			}
+		}
+		mappedOpts[i] = opts
+
+		key := extract.NormalizeEntryName(entry)
+		if _, exists := indexes[key]; !exists {
+			entries = append(entries, entry)
+		}
+		indexes[key] = append(indexes[key], i)
+	}
+
+	mapped := make([]ExtractedFile, len(s.mappings))
+	err = extract.ExtractAll(ctx, s.format, archive.File, entries,
+		func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+			var first *install.File
+			for _, i := range indexes[extract.NormalizeEntryName(name)] {
+				opts := mappedOpts[i]
+				opts.Owner = s.entryOwner(info)
+				var installed *install.File
+				var err error
+				if first == nil {
+					installed, err = install.Install(ctx, content, name, info.Size(), &opts)
+					first = installed
+				} else {
+					// the content was already consumed, so copy what it was installed as
+					installed, err = installCopy(ctx, first.Path, name, info.Size(), &opts)
+				}
+				if err != nil {
+					return fmt.Errorf("mapping %s: %w", s.mappings[i].Entry, err)
+				}
+				log.Printf("I! Extracted %s to %s with sha256:%s", name, installed.Path, installed.SHA256)
+				mapped[i] = ExtractedFile{Entry: name, Path: installed.Path, SHA256: installed.SHA256}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return mapped, nil
+}
+
+// installCopy installs the content of an already installed file
+func installCopy(ctx context.Context, path string, name string, size int64, opts *install.Options) (*install.File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer file.Close()
+	return install.Install(ctx, file, name, size, opts)
+}
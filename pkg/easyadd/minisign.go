@@ -0,0 +1,54 @@
+package easyadd
+
+import (
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/minisign"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// MinisignOptions declares the minisign signature required of the checksum file or, when the
+// checksum isn't given by a file or URL, of the archive itself. The signature is that of what's
+// signed with .minisig appended.
+type MinisignOptions struct {
+	// PublicKey is the base64 public key, as given to minisign -P, or the path of a public key file
+	PublicKey string
+}
+
+func (s *source) verifyMinisign(ctx context.Context, signed io.Reader, signedRef string) error {
+	publicKey, err := loadMinisignKey(s.minisign.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	sigRef := withSuffix(signedRef, ".minisig")
+	content, err := s.readReference(ctx, sigRef, "minisign signature")
+	if err != nil {
+		return err
+	}
+	signature, err := minisign.Parse(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", sigRef, err)
+	}
+	err = signature.Verify(signed, publicKey)
+	if err != nil {
+		return fmt.Errorf("minisign signature of %s: %w", signedRef, err)
+	}
+	log.Printf("I! Verified minisign signature of %s by key %s", signedRef, publicKey)
+	return nil
+}
+
+// loadMinisignKey parses the key itself or reads it from the file at that path
+func loadMinisignKey(key string) (*minisign.PublicKey, error) {
+	if _, err := os.Stat(key); err == nil {
+		content, err := ioutil.ReadFile(key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read minisign public key: %w", err)
+		}
+		key = string(content)
+	}
+	return minisign.ParsePublicKey(key)
+}
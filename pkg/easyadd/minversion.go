@@ -0,0 +1,87 @@
+package easyadd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/install"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+)
+
+// MinVersionOptions skip the install when the file already installed reports a version that
+// is at least Version
+type MinVersionOptions struct {
+	Version string
+	// Args are used to run the installed file to report its version, which defaults to --version
+	Args []string
+	// Regex finds the version in the output of running the file, where the first group, if any,
+	// is the version. It defaults to install.DefaultVersionRegex.
+	Regex string
+}
+
+// satisfiedBy determines if the file that would be replaced already has the minimum version,
+// in which case the result describes that file. Failing to get its version only leads to it
+// being reinstalled.
+func (m *MinVersionOptions) satisfiedBy(ctx context.Context, to string, src *source) (*Result, error) {
+	versionRegex := install.DefaultVersionRegex
+	if m.Regex != "" {
+		versionRegex = m.Regex
+	}
+	re, err := regexp.Compile(versionRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version regex: %w", err)
+	}
+	args := m.Args
+	if len(args) == 0 {
+		args = []string{"--version"}
+	}
+
+	installedPath := filepath.Join(to, path.Base(src.file))
+	if _, err := os.Stat(installedPath); err != nil {
+		return nil, nil
+	}
+
+	version, err := install.InstalledVersion(ctx, installedPath, args, re)
+	if err != nil {
+		log.Printf("W! Reinstalling since the version of %s is unknown: %v", installedPath, err)
+		return nil, nil
+	}
+	if install.CompareVersions(version, m.Version) < 0 {
+		log.Printf("I! Installed version %s of %s is older than %s", version, installedPath, m.Version)
+		return nil, nil
+	}
+
+	digest, err := installedSHA256(installedPath)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("I! Skipping download since %s is already version %s", installedPath, version)
+	return &Result{
+		From:    src.from,
+		Path:    installedPath,
+		SHA256:  digest,
+		Skipped: true,
+	}, nil
+}
+
+func installedSHA256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer file.Close()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,24 @@
+package easyadd
+
+import (
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/install"
+	"os"
+)
+
+// entryOwner is the owner recorded for the archive entry, or what the owner map maps it to, when
+// the options preserve it, or otherwise the owner given to installed files, where nil leaves them
+// owned by this process
+func (s *source) entryOwner(info os.FileInfo) *install.Owner {
+	if !s.preserveOwner {
+		return s.owner
+	}
+	uid, gid, ok := extract.EntryOwner(info)
+	if !ok {
+		return s.owner
+	}
+	if s.ownerMap != nil {
+		return s.ownerMap.Owner(uid, gid)
+	}
+	return install.NewOwner(uid, gid)
+}
@@ -0,0 +1,31 @@
+package easyadd
+
+import (
+	"archive/tar"
+	"github.com/itzg/easy-add/pkg/install"
+	"testing"
+)
+
+func TestEntryOwnerMapped(t *testing.T) {
+	ownerMap, err := install.ParseOwnerMap("0:0=1000:1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &source{preserveOwner: true, ownerMap: ownerMap}
+
+	mapped := s.entryOwner((&tar.Header{Name: "bin/tool", Uid: 0, Gid: 0}).FileInfo())
+	if mapped == nil || *mapped != *install.NewOwner(1000, 1000) {
+		t.Errorf("root's entry was given %v rather than 1000:1000", mapped)
+	}
+	if unmapped := s.entryOwner((&tar.Header{Name: "bin/other", Uid: 33, Gid: 33}).FileInfo()); unmapped != nil {
+		t.Errorf("unmapped entry was given %v rather than being left to the current user", unmapped)
+	}
+}
+
+func TestEntryOwnerPreserved(t *testing.T) {
+	s := &source{preserveOwner: true}
+	owner := s.entryOwner((&tar.Header{Name: "bin/tool", Uid: 33, Gid: 44}).FileInfo())
+	if owner == nil || *owner != *install.NewOwner(33, 44) {
+		t.Errorf("entry was given %v rather than its recorded 33:44", owner)
+	}
+}
@@ -0,0 +1,64 @@
+package easyadd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"golang.org/x/crypto/openpgp"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+)
+
+// PGPOptions declares the ASCII armored PGP signature required of the checksum file or, when the
+// checksum isn't given by a file or URL, of the archive itself. The signature is that of what's
+// signed with .asc appended.
+type PGPOptions struct {
+	// KeyringFile is the path of the public keys, armored or binary, any of which may have signed
+	KeyringFile string
+}
+
+func (s *source) verifyPGPSignature(ctx context.Context, signed io.Reader, signedRef string) error {
+	keyring, err := loadPGPKeyring(s.pgp.KeyringFile)
+	if err != nil {
+		return err
+	}
+
+	sigRef := withSuffix(signedRef, ".asc")
+	armored, err := s.readReference(ctx, sigRef, "PGP signature")
+	if err != nil {
+		return err
+	}
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, signed, bytes.NewReader(armored))
+	if err != nil {
+		return fmt.Errorf("PGP signature of %s: %w", signedRef, err)
+	}
+
+	var names []string
+	for name := range signer.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	signedBy := signer.PrimaryKey.KeyIdString()
+	if len(names) > 0 {
+		signedBy = names[0]
+	}
+	log.Printf("I! Verified PGP signature of %s by %s", signedRef, signedBy)
+	return nil
+}
+
+func loadPGPKeyring(path string) (openpgp.EntityList, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read PGP keys: %w", err)
+	}
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+	if err != nil {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(content))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid PGP keys in %s: %w", path, err)
+	}
+	return keyring, nil
+}
@@ -0,0 +1,135 @@
+package easyadd
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// VerificationLevel is how thoroughly an archive is verified before anything is extracted from it
+type VerificationLevel string
+
+const (
+	VerificationNone VerificationLevel = "none"
+	// VerificationChecksum is a Checksum of the archive
+	VerificationChecksum VerificationLevel = "checksum"
+	// VerificationSignature is a signature of the archive or its checksum file, or its provenance
+	// or attestation
+	VerificationSignature VerificationLevel = "signature"
+)
+
+var verificationRanks = map[VerificationLevel]int{
+	"":                    0,
+	VerificationNone:      0,
+	VerificationChecksum:  1,
+	VerificationSignature: 2,
+}
+
+// Policy restricts where archives may be retrieved from and how they're verified, such as for an
+// organization to enforce on every install. It's checked before anything is downloaded.
+type Policy struct {
+	// AllowedHosts, when not empty, are the hosts that archives and their checksum and signature
+	// files may be retrieved from, where each is a pattern as matched by path.Match, such as
+	// *.example.com. The URLs they redirect to are checked by clients created with CheckRedirect.
+	AllowedHosts []string `yaml:"allowedHosts"`
+	// RequireHTTPS refuses http URLs, even when AllowHTTP is set or they're of this host
+	RequireHTTPS bool `yaml:"requireHTTPS"`
+	// RequireVerification is the least verification that archives must have to be installed
+	RequireVerification VerificationLevel `yaml:"requireVerification"`
+}
+
+// ReadPolicy reads a policy from a YAML file, where unknown fields are rejected so that a
+// misspelled restriction isn't silently ignored
+func ReadPolicy(path string) (*Policy, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read policy: %w", err)
+	}
+
+	policy := &Policy{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	// an empty file is an empty policy
+	if len(bytes.TrimSpace(content)) > 0 {
+		err = decoder.Decode(policy)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse policy %s: %w", path, err)
+		}
+	}
+	err = policy.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func (p *Policy) validate() error {
+	for _, pattern := range p.AllowedHosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allowed host '%s': %w", pattern, err)
+		}
+	}
+	if _, known := verificationRanks[p.RequireVerification]; !known {
+		return fmt.Errorf("unknown verification level '%s', which must be none, checksum, or signature", p.RequireVerification)
+	}
+	return nil
+}
+
+// checkHost refuses a URL whose host isn't allowed, where local files are always allowed
+func (p *Policy) checkHost(u *url.URL) error {
+	if p == nil || len(p.AllowedHosts) == 0 || u.Scheme == "file" {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range p.AllowedHosts {
+		if matched, _ := path.Match(strings.ToLower(pattern), host); matched {
+			return nil
+		}
+	}
+	return policyViolation("refusing to download %s since the policy doesn't allow its host", u.Redacted())
+}
+
+// CheckRedirect refuses a URL that is redirected to when its host isn't allowed, or it's http when
+// https is required, which is given as fetch.ClientOptions.CheckRedirect to hold every hop of a
+// download to the policy
+func (p *Policy) CheckRedirect(u *url.URL) error {
+	err := p.checkHost(u)
+	if err != nil {
+		return err
+	}
+	if p != nil && p.RequireHTTPS && strings.EqualFold(u.Scheme, "http") {
+		return policyViolation("refusing to download %s over unencrypted HTTP since the policy requires https", u.Redacted())
+	}
+	return nil
+}
+
+// checkVerification refuses to install an archive that isn't verified as thoroughly as required
+func (p *Policy) checkVerification(opts *Options) error {
+	if p == nil {
+		return nil
+	}
+	level := VerificationNone
+	switch {
+	case opts.SSHSignature != nil || opts.Minisign != nil || opts.PGP != nil || opts.Cosign != nil ||
+		opts.Provenance != nil || opts.Attestation != nil:
+		level = VerificationSignature
+	case opts.Checksum != "":
+		level = VerificationChecksum
+	}
+	if verificationRanks[level] >= verificationRanks[p.RequireVerification] {
+		return nil
+	}
+	missing := "a signature"
+	if p.RequireVerification == VerificationChecksum {
+		missing = "a checksum"
+	}
+	return policyViolation("refusing to install without %s since the policy requires %s verification", missing, p.RequireVerification)
+}
+
+func policyViolation(format string, args ...interface{}) error {
+	return &Error{Category: CategoryPolicy, Err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,51 @@
+package easyadd
+
+import (
+	"context"
+	"errors"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheckedOnRedirect(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("sha256:0123 tool.tar.gz"))
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the same server by another name, as a download is redirected to a CDN
+		http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1)+r.URL.Path, http.StatusFound)
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		name    string
+		policy  *Policy
+		refused bool
+	}{
+		{name: "no policy"},
+		{name: "allowed", policy: &Policy{AllowedHosts: []string{"127.0.0.1", "localhost"}}},
+		{name: "not allowed", policy: &Policy{AllowedHosts: []string{"127.0.0.1"}}, refused: true},
+		{name: "requires https", policy: &Policy{RequireHTTPS: true}, refused: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := fetch.NewHTTPClient(fetch.ClientOptions{CheckRedirect: test.policy.CheckRedirect})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// only the client is given the policy, so that the redirect is all that's checked
+			_, err = ReadReference(context.Background(), Options{HTTPClient: client}, server.URL+"/tool.tar.gz.sha256", "checksum")
+
+			var installErr *Error
+			switch {
+			case !test.refused && err != nil:
+				t.Errorf("redirect was refused: %v", err)
+			case test.refused && (!errors.As(err, &installErr) || installErr.Category != CategoryPolicy):
+				t.Errorf("redirect gave %v rather than a policy violation", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package easyadd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/attest"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultProvenanceBuilder is the prefix of the builder identity of the SLSA level 3 generators
+// that projects use with goreleaser and GitHub Actions
+const DefaultProvenanceBuilder = "https://github.com/slsa-framework/slsa-github-generator/"
+
+// ProvenanceOptions declares the SLSA provenance required of an archive
+type ProvenanceOptions struct {
+	// URL of the provenance, such as a .intoto.jsonl release asset, which may contain Go template
+	// references to Vars entries. When empty, it is discovered alongside the archive.
+	URL string
+	// BuilderID is the prefix that the builder ID, and the workflow that signed, must have,
+	// which defaults to DefaultProvenanceBuilder
+	BuilderID string
+	// SourceRepo is the repository, such as owner/repo, that must have been built. It defaults
+	// to that of a GitHub release download URL.
+	SourceRepo string
+}
+
+func (s *source) verifyProvenance(ctx context.Context, archive *fetch.Archive) error {
+	opts := s.provenance
+	builderID := opts.BuilderID
+	if builderID == "" {
+		builderID = DefaultProvenanceBuilder
+	}
+	sourceRepo := opts.SourceRepo
+	if sourceRepo == "" {
+		sourceRepo = gitHubReleaseRepo(s.fromURL)
+		if sourceRepo == "" {
+			return fmt.Errorf("the source repo of the provenance needs to be given since %s is not a GitHub release", s.from)
+		}
+	}
+
+	var candidates []string
+	if opts.URL != "" {
+		provenanceURL, err := EvaluateTemplate(opts.URL, s.vars)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate provenance URL: %w", err)
+		}
+		candidates = []string{provenanceURL}
+	} else {
+		candidates = provenanceCandidates(s.fromURL)
+	}
+
+	var lastErr error
+	for _, candidate := range candidates {
+		content, err := s.fetchProvenance(ctx, candidate)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		err = verifyProvenanceContent(content, archive.SHA256, builderID, sourceRepo)
+		if err != nil {
+			return fmt.Errorf("provenance %s: %w", candidate, err)
+		}
+		log.Printf("I! Verified SLSA provenance of archive from %s built by %s", sourceRepo, builderID)
+		return nil
+	}
+	return fmt.Errorf("unable to retrieve provenance: %w", lastErr)
+}
+
+// maxProvenanceSize limits what is read of a provenance, which can hold several attestations along
+// with their certificates
+const maxProvenanceSize = 16 << 20
+
+func (s *source) fetchProvenance(ctx context.Context, ref string) ([]byte, error) {
+	return s.readReferenceUpTo(ctx, ref, "provenance", maxProvenanceSize)
+}
+
+// verifyProvenanceContent looks for an attestation, one per line, of the archive that verifies
+// and declares the expected builder and source repo
+func verifyProvenanceContent(content []byte, archiveSHA256 string, builderID string, sourceRepo string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), len(content)+1)
+
+	found := false
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		bundle, err := attest.ParseBundle(line)
+		if err != nil {
+			return err
+		}
+		identity, statement, err := attest.Verify(bundle)
+		if err != nil {
+			return err
+		}
+		if !statement.IsSLSAProvenance() || !statement.HasSubject(archiveSHA256) {
+			continue
+		}
+		found = true
+
+		if !strings.HasPrefix(identity.SubjectURI, builderID) {
+			return fmt.Errorf("signed by %s rather than a builder starting with %s", identity.SubjectURI, builderID)
+		}
+		if !strings.HasPrefix(statement.BuilderID, builderID) {
+			return fmt.Errorf("built by %s rather than a builder starting with %s", statement.BuilderID, builderID)
+		}
+		if !attest.RepoMatches(identity.SourceRepository, sourceRepo) {
+			return fmt.Errorf("signed for the repository %s rather than %s", identity.SourceRepository, sourceRepo)
+		}
+		if statement.SourceRepository != "" && !attest.RepoMatches(statement.SourceRepository, sourceRepo) {
+			return fmt.Errorf("built from %s rather than %s", statement.SourceRepository, sourceRepo)
+		}
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no SLSA provenance describes the archive with sha256:%s", archiveSHA256)
+	}
+	return nil
+}
+
+// provenanceCandidates are where provenance is typically published alongside the archive
+// by slsa-github-generator
+func provenanceCandidates(archiveURL *url.URL) []string {
+	withSuffix := *archiveURL
+	withSuffix.Path += ".intoto.jsonl"
+	multiple := *archiveURL
+	multiple.Path = path.Join(path.Dir(archiveURL.Path), "multiple.intoto.jsonl")
+	return []string{withSuffix.String(), multiple.String()}
+}
+
+// gitHubReleaseRepo returns owner/repo of a URL like
+// https://github.com/owner/repo/releases/download/v1.0.0/tool.tar.gz or empty otherwise
+func gitHubReleaseRepo(u *url.URL) string {
+	if !strings.EqualFold(u.Host, "github.com") {
+		return ""
+	}
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if len(parts) < 4 || parts[2] != "releases" {
+		return ""
+	}
+	return parts[0] + "/" + parts[1]
+}
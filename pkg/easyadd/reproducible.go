@@ -0,0 +1,48 @@
+package easyadd
+
+import (
+	"github.com/itzg/easy-add/pkg/install"
+	"log"
+	"time"
+)
+
+// ReproducibleOptions normalizes what an install writes so that it's identical across runs, such
+// as for the layers of an image to be bit-for-bit reproducible. Installed files, links, and the
+// directories containing them are given ModTime, files get 0755 or 0644 permissions, and, when
+// running as root, files are owned by root rather than the owners of their archive entries.
+type ReproducibleOptions struct {
+	ModTime time.Time
+}
+
+// writtenPaths are the files and links created by the install of the result
+func writtenPaths(result *Result) []string {
+	paths := []string{result.Path, result.Launcher, result.Current, result.BinLink}
+	for _, extracted := range result.Extracted {
+		paths = append(paths, extracted.Path)
+	}
+	for _, mapped := range result.Mapped {
+		paths = append(paths, mapped.Path)
+	}
+	paths = append(paths, result.Links...)
+
+	var written []string
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		if p != "" && !seen[p] {
+			seen[p] = true
+			written = append(written, p)
+		}
+	}
+	return written
+}
+
+// normalizeModTimes sets the times of what the install wrote, and the directories containing
+// them up to root, to those of the options
+func (r *ReproducibleOptions) normalizeModTimes(result *Result, root string) error {
+	err := install.SetModTimes(writtenPaths(result), root, r.ModTime)
+	if err != nil {
+		return err
+	}
+	log.Printf("D! Set modification times of installed files to %s", r.ModTime.UTC().Format(time.RFC3339))
+	return nil
+}
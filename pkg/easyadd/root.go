@@ -0,0 +1,36 @@
+package easyadd
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// inRoot is the path on this host of the path within the root directory, where an empty root is
+// that of this host
+func inRoot(root string, p string) string {
+	if root == "" {
+		return p
+	}
+	return filepath.Join(root, p)
+}
+
+// withinRoot is the path, as seen from within the root directory, of the path on this host, such
+// as for a link that is followed once the root is mounted as /
+func withinRoot(root string, hostPath string) string {
+	if root == "" {
+		return hostPath
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return hostPath
+	}
+	absPath, err := filepath.Abs(hostPath)
+	if err != nil {
+		return hostPath
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return hostPath
+	}
+	return string(filepath.Separator) + rel
+}
@@ -0,0 +1,90 @@
+package easyadd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"io"
+	"log"
+	"strings"
+)
+
+// VerifyAuto is the verify mode where only one of the configured signatures is required, which
+// are looked for alongside the signed file, rather than all of them
+const VerifyAuto = "auto"
+
+// signatureVerifier verifies one kind of detached signature of what's at signedRef
+type signatureVerifier struct {
+	kind   string
+	verify func(ctx context.Context, signed io.Reader, signedRef string) error
+}
+
+// signatureVerifiers are those of the configured signatures, in the order they are attempted
+func (s *source) signatureVerifiers() []signatureVerifier {
+	var verifiers []signatureVerifier
+	if s.sshSignature != nil {
+		verifiers = append(verifiers, signatureVerifier{"SSH", s.verifySSHSignature})
+	}
+	if s.minisign != nil {
+		verifiers = append(verifiers, signatureVerifier{"minisign", s.verifyMinisign})
+	}
+	if s.pgp != nil {
+		verifiers = append(verifiers, signatureVerifier{"PGP", s.verifyPGPSignature})
+	}
+	if s.cosign != nil {
+		verifiers = append(verifiers, signatureVerifier{"cosign", s.verifyCosignSignature})
+	}
+	return verifiers
+}
+
+// verifySignatures verifies the content at signedRef
+func (s *source) verifySignatures(ctx context.Context, content []byte, signedRef string) error {
+	return s.verifySignaturesOf(ctx, bytes.NewReader(content), signedRef)
+}
+
+// verifySignaturesOfArchive verifies the downloaded archive
+func (s *source) verifySignaturesOfArchive(ctx context.Context, archive *fetch.Archive) error {
+	err := s.verifySignaturesOf(ctx, archive.File, s.from)
+	if err != nil {
+		return err
+	}
+	_, err = archive.Seek(0, io.SeekStart)
+	return err
+}
+
+// verifySignaturesOf requires every configured signature or, with verifyAuto, the first one
+// that's found to verify
+func (s *source) verifySignaturesOf(ctx context.Context, signed io.ReadSeeker, signedRef string) error {
+	verifiers := s.signatureVerifiers()
+	var kinds []string
+	var lastErr error
+	for _, verifier := range verifiers {
+		kinds = append(kinds, verifier.kind)
+		if _, err := signed.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		err := verifier.verify(ctx, signed, signedRef)
+		switch {
+		case err == nil:
+			if s.verifyAuto {
+				return nil
+			}
+		case !s.verifyAuto:
+			return err
+		case fetch.IsNotFound(err):
+			log.Printf("D! No %s signature alongside %s: %v", verifier.kind, signedRef, err)
+		default:
+			log.Printf("W! %s signature of %s did not verify: %v", verifier.kind, signedRef, err)
+			lastErr = err
+		}
+	}
+
+	if !s.verifyAuto {
+		return nil
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	return fmt.Errorf("no %s signature was found alongside %s", strings.Join(kinds, " or "), signedRef)
+}
@@ -0,0 +1,66 @@
+package easyadd
+
+import (
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/sshsig"
+	"io"
+	"log"
+	"strings"
+)
+
+// DefaultSSHNamespace is what ssh-keygen -Y sign uses for signing files
+const DefaultSSHNamespace = "file"
+
+// SSHSignatureOptions declares the SSH signature, as made by ssh-keygen -Y sign, required of the
+// checksum file or, when the checksum isn't given by a file or URL, of the archive itself
+type SSHSignatureOptions struct {
+	// AllowedSignersFile is the path of the allowed signers, in the format used by ssh-keygen -Y verify
+	AllowedSignersFile string
+	// URL or path of the signature, which may contain Go template references to Vars entries.
+	// It defaults to that of what is signed with .sig appended.
+	URL string
+	// Namespace of the signature, which defaults to DefaultSSHNamespace
+	Namespace string
+	// Identity, when given, is the principal that the signer must be allowed for
+	Identity string
+}
+
+func (s *source) verifySSHSignature(ctx context.Context, signed io.Reader, signedRef string) error {
+	opts := s.sshSignature
+	signers, err := sshsig.LoadAllowedSigners(opts.AllowedSignersFile)
+	if err != nil {
+		return err
+	}
+
+	sigRef := withSuffix(signedRef, ".sig")
+	if opts.URL != "" {
+		sigRef, err = EvaluateTemplate(opts.URL, s.vars)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate SSH signature URL: %w", err)
+		}
+	}
+	armored, err := s.readReference(ctx, sigRef, "SSH signature")
+	if err != nil {
+		return err
+	}
+	signature, err := sshsig.Parse(armored)
+	if err != nil {
+		return fmt.Errorf("%s: %w", sigRef, err)
+	}
+
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = DefaultSSHNamespace
+	}
+	principals, err := signature.Verify(signed, namespace, signers, opts.Identity)
+	if err != nil {
+		return err
+	}
+	signer := opts.Identity
+	if signer == "" {
+		signer = strings.Join(principals, ",")
+	}
+	log.Printf("I! Verified SSH signature of %s by %s", signedRef, signer)
+	return nil
+}
@@ -0,0 +1,83 @@
+package easyadd
+
+import (
+	"context"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"github.com/itzg/easy-add/pkg/install"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// LatestRegistryTag lists the tags of the repository of an OCI registry, such as
+// ghcr.io/owner/tool, and returns the newest one whose name matches the pattern along with its
+// version, as described by newestTag. Credentials are those of the Docker CLI, and the Policy of
+// the options applies to the registry as it does to the archive.
+func LatestRegistryTag(ctx context.Context, opts Options, repository string, pattern string) (tag string, version string, err error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", "", fmt.Errorf("invalid tag pattern %s: %w", pattern, err)
+	}
+	// like the Docker CLI, a repository without a registry is one of Docker Hub
+	registry := "docker.io"
+	name := strings.TrimPrefix(repository, "oci://")
+	if slash := strings.Index(name, "/"); slash >= 0 && (strings.ContainsAny(name[:slash], ".:") || name[:slash] == "localhost") {
+		registry = name[:slash]
+	}
+	err = opts.Policy.checkHost(&url.URL{Scheme: "oci", Host: registry})
+	if err != nil {
+		return "", "", err
+	}
+
+	fetcher := &fetch.OCIFetcher{Client: opts.HTTPClient}
+	tags, err := fetcher.Tags(ctx, repository)
+	if err != nil {
+		return "", "", categorized(CategoryDownload, err)
+	}
+	tag, version = newestTag(tags, pattern)
+	if tag == "" {
+		return "", "", fmt.Errorf("none of the %d tags of %s are versions, other than pre-releases, matching %s",
+			len(tags), repository, pattern)
+	}
+	return tag, version, nil
+}
+
+// newestTag finds the newest of the tags whose name matches the pattern, as matched by
+// path.Match such as v*, returning it along with its version. The version of a tag is the tag
+// without the literal prefix of the pattern ahead of any digits, such as 1.2.3 for v1.2.3, and
+// tags are compared by it. Tags whose version isn't one, such as latest, or is a pre-release,
+// such as 1.2.0-rc.1, are skipped. It returns empty when no tag is found.
+func newestTag(tags []string, pattern string) (tag string, version string) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	// the prefix ends before any of the version that the pattern gives, such as the v of v1.*
+	prefix := pattern
+	if i := strings.IndexAny(prefix, `*?[\`); i >= 0 {
+		prefix = prefix[:i]
+	}
+	if i := strings.IndexAny(prefix, "0123456789"); i >= 0 {
+		prefix = prefix[:i]
+	}
+
+	for _, candidate := range tags {
+		if matched, _ := path.Match(pattern, candidate); !matched {
+			continue
+		}
+		candidateVersion := strings.TrimPrefix(candidate, prefix)
+		if !isRelease(candidateVersion) {
+			continue
+		}
+		if tag == "" || install.CompareVersions(candidateVersion, version) > 0 {
+			tag, version = candidate, candidateVersion
+		}
+	}
+	return tag, version
+}
+
+// isRelease determines if the version, with or without a leading v, starts with a number and has
+// no pre-release suffix, such as the -rc.1 of 1.2.0-rc.1
+func isRelease(version string) bool {
+	version = strings.TrimPrefix(version, "v")
+	return version != "" && version[0] >= '0' && version[0] <= '9' && !strings.Contains(version, "-")
+}
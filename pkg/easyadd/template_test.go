@@ -0,0 +1,17 @@
+package easyadd
+
+import "testing"
+
+func TestEvaluateTemplateDoesNotEscape(t *testing.T) {
+	got, err := EvaluateTemplate(`{{.path}} --version && echo '{{.version}}' > /tmp/out`, map[string]string{
+		"path":    "/usr/local/bin/tool",
+		"version": "1.2.3+build<4>&",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `/usr/local/bin/tool --version && echo '1.2.3+build<4>&' > /tmp/out`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package easyadd
+
+import (
+	"context"
+	"errors"
+	"github.com/itzg/easy-add/pkg/extract"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// gitHubSourceArchive determines if the URL is of a source archive of a GitHub repository, such as
+// https://github.com/owner/repo/archive/refs/tags/v1.0.0.tar.gz or its codeload.github.com
+// equivalent, whose entries are all within a directory named for the repository and ref. The
+// format is returned when the URL names it without a suffix, as codeload URLs do.
+func gitHubSourceArchive(u *url.URL) (extract.Format, bool) {
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if len(parts) < 4 {
+		return "", false
+	}
+	switch {
+	case strings.EqualFold(u.Host, "github.com"):
+		return "", parts[2] == "archive"
+	case strings.EqualFold(u.Host, "codeload.github.com"):
+		switch parts[2] {
+		case "tar.gz", "legacy.tar.gz":
+			return extract.TarGz, true
+		case "zip", "legacy.zip":
+			return extract.Zip, true
+		}
+	}
+	return "", false
+}
+
+// resolveTopDir determines, when stripping it, the directory that every entry of the archive is
+// within, which requested entries are then relative to
+func (s *source) resolveTopDir(ctx context.Context, archive *fetch.Archive) error {
+	if !s.stripTopDir || s.topDir != "" {
+		return nil
+	}
+	names, err := extract.List(s.extractContext(ctx), s.format, archive.File)
+	if err != nil {
+		return err
+	}
+
+	topDir := ""
+	for _, name := range names {
+		name = extract.NormalizeEntryName(name)
+		slash := strings.Index(name, "/")
+		if slash <= 0 || (topDir != "" && name[:slash] != topDir) {
+			return errors.New("the entries of the archive are not all within one top-level directory")
+		}
+		topDir = name[:slash]
+	}
+	if topDir == "" {
+		return errors.New("the archive has no entries within a top-level directory")
+	}
+
+	log.Printf("D! Entries are relative to %s in archive", topDir)
+	s.topDir = topDir
+	return nil
+}
+
+// entryWithin gives the requested entry, which may be a pattern, as the entry within the top-level
+// directory when stripping it. A suffix already matches within any directory, so is left as is.
+func (s *source) entryWithin(requested string, match extract.Match) string {
+	if s.topDir == "" || match == extract.MatchSuffix {
+		return requested
+	}
+	topDir := s.topDir
+	if match == extract.MatchGlob {
+		topDir = escapeGlob(topDir)
+	}
+	return topDir + "/" + extract.NormalizeEntryName(requested)
+}
+
+// stripTopDirOf removes the top-level directory from the name of an archive entry when stripping it
+func (s *source) stripTopDirOf(name string) string {
+	if s.topDir == "" {
+		return name
+	}
+	return strings.TrimPrefix(extract.NormalizeEntryName(name), s.topDir+"/")
+}
+
+// escapeGlob quotes the characters of the name that path.Match would otherwise treat as a pattern
+func escapeGlob(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package easyadd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/itzg/easy-add/internal/jsonpath"
+	"strings"
+)
+
+// VersionFromURL retrieves the JSON document at the URL, or local path, and returns the version
+// found at the JSONPath within it, such as $.tag_name of the latest.json a project publishes.
+// The HTTPClient, AllowHTTP, PreferHTTPS, and Policy of the options apply to it as they do to
+// the archive.
+func VersionFromURL(ctx context.Context, opts Options, ref string, path string) (string, error) {
+	s := &source{
+		client:      opts.HTTPClient,
+		allowHTTP:   opts.AllowHTTP,
+		preferHTTPS: opts.PreferHTTPS,
+		policy:      opts.Policy,
+	}
+	content, err := s.readReference(ctx, ref, "version document")
+	if err != nil {
+		return "", categorized(CategoryDownload, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	// keeps versions given as numbers, such as 1.10, as written
+	decoder.UseNumber()
+	var doc interface{}
+	err = decoder.Decode(&doc)
+	if err != nil {
+		return "", fmt.Errorf("version document %s is not JSON: %w", ref, err)
+	}
+	value, err := jsonpath.Select(doc, path)
+	if err != nil {
+		return "", fmt.Errorf("unable to find the version in %s: %w", ref, err)
+	}
+
+	var version string
+	switch value := value.(type) {
+	case string:
+		version = strings.TrimSpace(value)
+	case json.Number:
+		version = value.String()
+	default:
+		return "", fmt.Errorf("%s of %s is neither a string nor a number", path, ref)
+	}
+	if version == "" {
+		return "", fmt.Errorf("%s of %s is empty rather than a version", path, ref)
+	}
+	return version, nil
+}
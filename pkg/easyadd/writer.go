@@ -0,0 +1,53 @@
+package easyadd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/itzg/easy-add/internal/ctxio"
+	"io"
+	"log"
+	"os"
+)
+
+// writeFile downloads the archive and copies the requested file to opts.Writer rather than
+// installing it, so only the options about what to extract apply
+func writeFile(ctx context.Context, src *source, opts *Options) (*Result, error) {
+	switch {
+	case src.all || len(src.mappings) > 0:
+		return nil, errors.New("only one file can be written rather than installed")
+	case opts.MinVersion != nil || len(opts.Links) > 0 || opts.ExecAfter != "":
+		return nil, errors.New("min version, links, and exec after require the file to be installed")
+	case len(opts.VerifyArgs) > 0 || opts.Setcap != "" || opts.SELinuxType != "":
+		return nil, errors.New("verify args, capabilities, and SELinux type require the file to be installed")
+	}
+
+	archive, err := src.download(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Remove()
+
+	hasher := sha256.New()
+	err = src.extract(ctx, archive,
+		func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+			_, err := io.Copy(io.MultiWriter(opts.Writer, hasher), ctxio.NewReader(ctx, content))
+			if err != nil {
+				return fmt.Errorf("unable to write extracted file: %w", err)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	log.Printf("I! Wrote extracted file with sha256:%s", digest)
+
+	return &Result{
+		From:          src.from,
+		SHA256:        digest,
+		ArchiveSHA256: archive.SHA256,
+	}, nil
+}
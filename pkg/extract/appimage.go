@@ -0,0 +1,74 @@
+package extract
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// appImageExtractor handles AppImages, which are an ELF runtime followed by a squashfs filesystem.
+// A requested file given as a path, such as usr/bin/tool, is extracted from the filesystem and
+// otherwise, like binaryExtractor, the requested file names the AppImage itself to install.
+type appImageExtractor struct{}
+
+func (a *appImageExtractor) Extract(ctx context.Context, archive *os.File, file string, handler Handler) error {
+	if !strings.Contains(NormalizeEntryName(file), "/") {
+		return (&binaryExtractor{}).Extract(ctx, archive, file, handler)
+	}
+	fs, err := appImageFilesystem(archive)
+	if err != nil {
+		return err
+	}
+	return extractFromSquashfs(ctx, fs, file, handler)
+}
+
+func (a *appImageExtractor) List(ctx context.Context, archive *os.File) ([]string, error) {
+	fs, err := appImageFilesystem(archive)
+	if err != nil {
+		return nil, err
+	}
+	return listSquashfs(ctx, fs)
+}
+
+// appImageFilesystem locates the squashfs filesystem of the AppImage, which starts right after the
+// section headers of its ELF runtime
+func appImageFilesystem(archive *os.File) (io.ReaderAt, error) {
+	stat, err := archive.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+
+	var ident [16]byte
+	_, err = archive.ReadAt(ident[:], 0)
+	if err != nil || string(ident[:4]) != "\x7fELF" {
+		return nil, errors.New("not an AppImage, since it doesn't start with an ELF runtime")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if ident[5] == 2 {
+		order = binary.BigEndian
+	}
+
+	var offset int64
+	if ident[4] == 2 {
+		var header [64]byte
+		_, err = archive.ReadAt(header[:], 0)
+		offset = int64(order.Uint64(header[0x28:])) +
+			int64(order.Uint16(header[0x3a:]))*int64(order.Uint16(header[0x3c:]))
+	} else {
+		var header [52]byte
+		_, err = archive.ReadAt(header[:], 0)
+		offset = int64(order.Uint32(header[0x20:])) +
+			int64(order.Uint16(header[0x2e:]))*int64(order.Uint16(header[0x30:]))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AppImage runtime: %w", err)
+	}
+	if offset <= 0 || offset >= stat.Size() {
+		return nil, errors.New("the AppImage has no embedded filesystem")
+	}
+	return io.NewSectionReader(archive, offset, stat.Size()-offset), nil
+}
@@ -0,0 +1,19 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// binaryExtractor handles downloads that are the executable itself rather than an archive,
+// where the requested file names the executable to install
+type binaryExtractor struct{}
+
+func (b *binaryExtractor) Extract(ctx context.Context, archive *os.File, file string, handler Handler) error {
+	info, err := archive.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to access downloaded file: %w", err)
+	}
+	return handler(ctx, file, info, archive)
+}
@@ -0,0 +1,141 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CorruptError indicates the archive is damaged, such as by being truncated or altered, where
+// Problem describes how and where
+type CorruptError struct {
+	Format  Format
+	Problem string
+	Err     error
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("corrupt %s archive: %s", e.Format, e.Problem)
+}
+
+func (e *CorruptError) Unwrap() error {
+	return e.Err
+}
+
+// countingReader counts the bytes read from the archive and whether its end was reached
+type countingReader struct {
+	io.Reader
+	n   int64
+	eof bool
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+// tarEntryReader is the content of a tar entry, which records how much of it was read and the
+// error reading it failed with, if any, to tell that apart from a failure of the handler
+type tarEntryReader struct {
+	io.Reader
+	header *tar.Header
+	read   int64
+	err    error
+}
+
+func (r *tarEntryReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// corruptTarGz describes the damage that the error reading a tar.gz archive is from, where
+// entry is the last one read, if any. Any other error is returned as is.
+func corruptTarGz(err error, archive *countingReader, entry *tarEntryReader) error {
+	var corruptInput flate.CorruptInputError
+	var problem string
+	switch {
+	case errors.Is(err, gzip.ErrChecksum):
+		problem = "the gzip checksum doesn't match the decompressed content"
+	case errors.Is(err, gzip.ErrHeader):
+		if entry == nil {
+			problem = "it doesn't start with a gzip header"
+		} else {
+			problem = "invalid gzip header of a member"
+		}
+	case errors.As(err, &corruptInput):
+		problem = fmt.Sprintf("the compressed data is invalid within the first %d bytes", archive.n)
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		if archive.eof {
+			problem = fmt.Sprintf("it ends after %d bytes, within its gzip stream, as when the download was truncated", archive.n)
+		} else {
+			problem = "the tar stream ends early"
+		}
+	case errors.Is(err, tar.ErrHeader):
+		problem = "invalid tar header"
+	default:
+		return err
+	}
+
+	if entry != nil {
+		if entry.err != nil && entry.read < entry.header.Size {
+			problem += fmt.Sprintf(", within entry %s after %d of its %d bytes", entry.header.Name, entry.read, entry.header.Size)
+		} else {
+			problem += fmt.Sprintf(", after entry %s", entry.header.Name)
+		}
+	}
+	return &CorruptError{Format: TarGz, Problem: problem, Err: err}
+}
+
+// corruptZip describes the damage that the error reading the central directory of a zip archive
+// of the given size is from. Any other error is returned as is.
+func corruptZip(err error, size int64) error {
+	if !errors.Is(err, zip.ErrFormat) {
+		return err
+	}
+	return &CorruptError{
+		Format:  Zip,
+		Problem: fmt.Sprintf("its %d bytes end without a valid central directory, as when the download was truncated", size),
+		Err:     err,
+	}
+}
+
+// zipEntryReader is the content of a zip entry, where errors from damage to it are described
+// with CorruptError
+type zipEntryReader struct {
+	io.Reader
+	file *zip.File
+	read int64
+}
+
+func (r *zipEntryReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+	if err == nil || err == io.EOF {
+		return n, err
+	}
+
+	var corruptInput flate.CorruptInputError
+	var problem string
+	switch {
+	case errors.Is(err, zip.ErrChecksum):
+		problem = fmt.Sprintf("entry %s doesn't match its CRC-32 checksum", r.file.Name)
+	case errors.As(err, &corruptInput), errors.Is(err, io.ErrUnexpectedEOF):
+		problem = fmt.Sprintf("the compressed data of entry %s is invalid after %d of its %d bytes",
+			r.file.Name, r.read, r.file.UncompressedSize64)
+	default:
+		return n, err
+	}
+	return n, &CorruptError{Format: Zip, Problem: problem, Err: err}
+}
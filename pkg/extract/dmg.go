@@ -0,0 +1,344 @@
+package extract
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	dmgSectorSize = 512
+	// dmgMaxChunkSectors bounds the size a single chunk can decompress to
+	dmgMaxChunkSectors = 1 << 17
+
+	dmgChunkZero       = 0x00000000
+	dmgChunkRaw        = 0x00000001
+	dmgChunkIgnore     = 0x00000002
+	dmgChunkADC        = 0x80000004
+	dmgChunkZlib       = 0x80000005
+	dmgChunkBzip2      = 0x80000006
+	dmgChunkLZFSE      = 0x80000007
+	dmgChunkLZMA       = 0x80000008
+	dmgChunkComment    = 0x7ffffffe
+	dmgChunkTerminator = 0xffffffff
+)
+
+// dmgExtractor handles macOS disk images that contain an HFS+ volume
+type dmgExtractor struct{}
+
+func (d *dmgExtractor) Extract(ctx context.Context, archive *os.File, file string, handler Handler) error {
+	volume, err := openDmgVolume(archive)
+	if err != nil {
+		return err
+	}
+	return volume.extract(ctx, file, handler)
+}
+
+func (d *dmgExtractor) List(ctx context.Context, archive *os.File) ([]string, error) {
+	volume, err := openDmgVolume(archive)
+	if err != nil {
+		return nil, err
+	}
+	return volume.list(ctx)
+}
+
+// dmgChunk is a run of sectors of the disk image and where its content is stored in the file
+type dmgChunk struct {
+	kind             uint32
+	sector           uint64
+	sectorCount      uint64
+	compressedOffset uint64
+	compressedLength uint64
+}
+
+// dmgPartition is a range of sectors described by one blkx table of the disk image
+type dmgPartition struct {
+	name        string
+	sector      uint64
+	sectorCount uint64
+}
+
+// dmgImage reads the disk within a UDIF disk image, decompressing its chunks as needed
+type dmgImage struct {
+	file       io.ReaderAt
+	chunks     []dmgChunk
+	partitions []dmgPartition
+	// cached is the last chunk decompressed, which is likely read from again
+	cached        *dmgChunk
+	cachedContent []byte
+}
+
+// openDmgVolume locates the HFS+ volume within the disk image
+func openDmgVolume(archive *os.File) (*hfsVolume, error) {
+	image, err := openDmg(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, partition := range image.partitions {
+		start := int64(partition.sector) * dmgSectorSize
+		size := int64(partition.sectorCount) * dmgSectorSize
+		section := io.NewSectionReader(image, start, size)
+		// an unsupported chunk fails these reads, which is reported rather than overlooked
+		var signature [4]byte
+		_, err := section.ReadAt(signature[:2], 1024)
+		if err == nil && isHFSPlusSignature(signature[:2]) {
+			return openHFSVolume(section)
+		} else if err != nil && err != io.EOF {
+			return nil, err
+		}
+		_, err = section.ReadAt(signature[:], 32)
+		if err == nil && string(signature[:]) == "NXSB" {
+			return nil, errors.New("the disk image contains an APFS volume, which is not supported, only HFS+ volumes are")
+		}
+	}
+	return nil, errors.New("the disk image does not contain an HFS+ volume")
+}
+
+func openDmg(archive *os.File) (*dmgImage, error) {
+	stat, err := archive.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+	if stat.Size() < dmgSectorSize {
+		return nil, errors.New("not a disk image")
+	}
+	trailer := make([]byte, dmgSectorSize)
+	_, err = archive.ReadAt(trailer, stat.Size()-dmgSectorSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disk image trailer: %w", err)
+	}
+	if string(trailer[:4]) != "koly" {
+		return nil, errors.New("not a disk image, since it has no UDIF trailer")
+	}
+	dataForkOffset := binary.BigEndian.Uint64(trailer[24:])
+	xmlOffset := binary.BigEndian.Uint64(trailer[216:])
+	xmlLength := binary.BigEndian.Uint64(trailer[224:])
+	if xmlLength == 0 || xmlOffset+xmlLength > uint64(stat.Size()) {
+		return nil, errors.New("the disk image has no property list describing its content")
+	}
+
+	plist := make([]byte, xmlLength)
+	_, err = archive.ReadAt(plist, int64(xmlOffset))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disk image property list: %w", err)
+	}
+	tables, err := dmgBlockTables(plist)
+	if err != nil {
+		return nil, err
+	}
+
+	image := &dmgImage{file: archive}
+	for _, table := range tables {
+		err = image.addBlockTable(table.name, table.data, dataForkOffset)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(image.chunks, func(i, j int) bool {
+		return image.chunks[i].sector < image.chunks[j].sector
+	})
+	return image, nil
+}
+
+type dmgBlockTable struct {
+	name string
+	data []byte
+}
+
+// dmgBlockTables finds the blkx entries of the resource-fork dictionary of the property list,
+// each of which is a dictionary with the Name and Data of a block table
+func dmgBlockTables(plist []byte) ([]dmgBlockTable, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(plist))
+	var tables []dmgBlockTable
+	var key, name string
+	var data []byte
+	inBlkx := false
+	depth, blkxDepth := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid disk image property list: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			var text string
+			switch t.Name.Local {
+			case "key", "string", "data":
+				err = decoder.DecodeElement(&text, &t)
+				depth--
+				if err != nil {
+					return nil, fmt.Errorf("invalid disk image property list: %w", err)
+				}
+			case "array":
+				if key == "blkx" {
+					inBlkx = true
+					blkxDepth = depth
+				}
+			}
+
+			switch {
+			case t.Name.Local == "key":
+				key = text
+			case inBlkx && t.Name.Local == "string" && key == "Name":
+				name = text
+			case inBlkx && t.Name.Local == "data" && key == "Data":
+				data, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+				if err != nil {
+					return nil, fmt.Errorf("invalid block table in disk image: %w", err)
+				}
+			}
+		case xml.EndElement:
+			if inBlkx && t.Name.Local == "dict" && depth == blkxDepth+1 {
+				if data != nil {
+					tables = append(tables, dmgBlockTable{name: name, data: data})
+				}
+				name, data = "", nil
+			}
+			if inBlkx && t.Name.Local == "array" && depth == blkxDepth {
+				inBlkx = false
+			}
+			depth--
+		}
+	}
+	if len(tables) == 0 {
+		return nil, errors.New("the disk image has no block tables")
+	}
+	return tables, nil
+}
+
+// addBlockTable adds the chunks of a mish block table, which describes a partition of the disk
+func (d *dmgImage) addBlockTable(name string, table []byte, dataForkOffset uint64) error {
+	const headerSize = 204
+	if len(table) < headerSize || string(table[:4]) != "mish" {
+		return errors.New("invalid block table in disk image")
+	}
+	firstSector := binary.BigEndian.Uint64(table[8:])
+	sectorCount := binary.BigEndian.Uint64(table[16:])
+	dataOffset := binary.BigEndian.Uint64(table[24:])
+	count := binary.BigEndian.Uint32(table[200:])
+	if uint64(len(table)) < headerSize+uint64(count)*40 {
+		return errors.New("invalid block table in disk image")
+	}
+	d.partitions = append(d.partitions, dmgPartition{name: name, sector: firstSector, sectorCount: sectorCount})
+
+	for i := uint32(0); i < count; i++ {
+		entry := table[headerSize+i*40:]
+		chunk := dmgChunk{
+			kind:             binary.BigEndian.Uint32(entry),
+			sector:           firstSector + binary.BigEndian.Uint64(entry[8:]),
+			sectorCount:      binary.BigEndian.Uint64(entry[16:]),
+			compressedOffset: dataForkOffset + dataOffset + binary.BigEndian.Uint64(entry[24:]),
+			compressedLength: binary.BigEndian.Uint64(entry[32:]),
+		}
+		switch chunk.kind {
+		case dmgChunkComment, dmgChunkTerminator:
+			continue
+		}
+		if chunk.sectorCount == 0 {
+			continue
+		}
+		if chunk.sectorCount > dmgMaxChunkSectors {
+			return errors.New("invalid chunk in disk image")
+		}
+		d.chunks = append(d.chunks, chunk)
+	}
+	return nil
+}
+
+// ReadAt reads the disk, where sectors not described by any chunk read as zeros
+func (d *dmgImage) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := uint64(off) + uint64(n)
+		sector := pos / dmgSectorSize
+		i := sort.Search(len(d.chunks), func(i int) bool {
+			return d.chunks[i].sector+d.chunks[i].sectorCount > sector
+		})
+		if i == len(d.chunks) {
+			return n, io.EOF
+		}
+		chunk := &d.chunks[i]
+		if chunk.sector > sector {
+			// a gap before the next chunk
+			gap := chunk.sector*dmgSectorSize - pos
+			if gap > uint64(len(p)-n) {
+				gap = uint64(len(p) - n)
+			}
+			for j := uint64(0); j < gap; j++ {
+				p[n] = 0
+				n++
+			}
+			continue
+		}
+
+		content, err := d.chunkContent(chunk)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], content[pos-chunk.sector*dmgSectorSize:])
+	}
+	return n, nil
+}
+
+func (d *dmgImage) chunkContent(chunk *dmgChunk) ([]byte, error) {
+	if d.cached == chunk {
+		return d.cachedContent, nil
+	}
+	size := int(chunk.sectorCount * dmgSectorSize)
+	var content []byte
+	switch chunk.kind {
+	case dmgChunkZero, dmgChunkIgnore:
+		content = make([]byte, size)
+	case dmgChunkRaw, dmgChunkZlib, dmgChunkBzip2:
+		if chunk.compressedLength > uint64(size)*2+1024 {
+			return nil, errors.New("invalid chunk in disk image")
+		}
+		compressed := make([]byte, chunk.compressedLength)
+		_, err := d.file.ReadAt(compressed, int64(chunk.compressedOffset))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read disk image: %w", err)
+		}
+		var r io.Reader = bytes.NewReader(compressed)
+		switch chunk.kind {
+		case dmgChunkZlib:
+			r, err = zlib.NewReader(r)
+		case dmgChunkBzip2:
+			r = bzip2.NewReader(r)
+		}
+		if err == nil {
+			content, err = ioutil.ReadAll(io.LimitReader(r, int64(size)))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress disk image: %w", err)
+		}
+		if len(content) < size {
+			content = append(content, make([]byte, size-len(content))...)
+		}
+	case dmgChunkLZFSE:
+		return nil, errors.New("disk images compressed with LZFSE (ULFO) are not supported, only those uncompressed or compressed with zlib (UDZO) or bzip2 (UDBZ)")
+	case dmgChunkLZMA:
+		return nil, errors.New("disk images compressed with LZMA (ULMO) are not supported, only those uncompressed or compressed with zlib (UDZO) or bzip2 (UDBZ)")
+	case dmgChunkADC:
+		return nil, errors.New("disk images compressed with ADC are not supported")
+	default:
+		return nil, fmt.Errorf("unsupported disk image chunk type %#x", chunk.kind)
+	}
+	d.cached, d.cachedContent = chunk, content
+	return content, nil
+}
@@ -0,0 +1,356 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	hfsTestBlockSize = 512
+	hfsTestModTime   = 1600000000
+)
+
+var (
+	hfsTestTool   = []byte("#!/bin/sh\necho tool\n")
+	hfsTestReadme = []byte("read me\n")
+)
+
+// hfsTestVolume builds an HFS+ volume of nine 512 byte blocks, with the catalog in blocks 4 to 6
+// and the content of bin/tool and README in blocks 7 and 8. The catalog has two leaf nodes, so
+// walking it follows the link between them.
+func hfsTestVolume() []byte {
+	volume := make([]byte, 9*hfsTestBlockSize)
+	header := volume[1024:]
+	copy(header, "H+")
+	binary.BigEndian.PutUint16(header[2:], 4)
+	binary.BigEndian.PutUint32(header[40:], hfsTestBlockSize)
+	binary.BigEndian.PutUint32(header[44:], 9)
+	putHFSTestFork(header[272:], 3*hfsTestBlockSize, 4, 3)
+
+	const nodeSize = hfsTestBlockSize
+	catalog := volume[4*hfsTestBlockSize : 7*hfsTestBlockSize]
+	// the header node, which is node 0
+	catalog[8] = 1
+	binary.BigEndian.PutUint16(catalog[10:], 3)
+	record := catalog[14:]
+	binary.BigEndian.PutUint16(record, 1)
+	binary.BigEndian.PutUint32(record[2:], 1)
+	binary.BigEndian.PutUint32(record[6:], 4)
+	binary.BigEndian.PutUint32(record[10:], 1)
+	binary.BigEndian.PutUint32(record[14:], 2)
+	binary.BigEndian.PutUint16(record[18:], nodeSize)
+	binary.BigEndian.PutUint16(record[20:], 516)
+	binary.BigEndian.PutUint32(record[22:], 3)
+
+	putHFSTestLeaf(catalog[nodeSize:2*nodeSize], 2,
+		hfsTestFolder(1, "Tool", hfsRootFolderID),
+		hfsTestFolder(hfsRootFolderID, "bin", 16),
+		hfsTestFile(16, "tool", 17, 0755, 7, len(hfsTestTool)),
+	)
+	putHFSTestLeaf(catalog[2*nodeSize:], 0,
+		hfsTestFile(hfsRootFolderID, "README", 18, 0644, 8, len(hfsTestReadme)),
+	)
+
+	copy(volume[7*hfsTestBlockSize:], hfsTestTool)
+	copy(volume[8*hfsTestBlockSize:], hfsTestReadme)
+	return volume
+}
+
+func putHFSTestFork(data []byte, size int, startBlock uint32, blockCount uint32) {
+	binary.BigEndian.PutUint64(data, uint64(size))
+	binary.BigEndian.PutUint32(data[12:], blockCount)
+	binary.BigEndian.PutUint32(data[16:], startBlock)
+	binary.BigEndian.PutUint32(data[20:], blockCount)
+}
+
+func putHFSTestLeaf(node []byte, next uint32, records ...[]byte) {
+	binary.BigEndian.PutUint32(node, next)
+	node[8] = hfsLeafNode
+	node[9] = 1
+	binary.BigEndian.PutUint16(node[10:], uint16(len(records)))
+	pos := 14
+	for i, record := range records {
+		binary.BigEndian.PutUint16(node[len(node)-2*(i+1):], uint16(pos))
+		pos += copy(node[pos:], record)
+	}
+	binary.BigEndian.PutUint16(node[len(node)-2*(len(records)+1):], uint16(pos))
+}
+
+func hfsTestKey(parentID uint32, name string) []byte {
+	units := utf16.Encode([]rune(name))
+	key := make([]byte, 8+2*len(units))
+	binary.BigEndian.PutUint16(key, uint16(6+2*len(units)))
+	binary.BigEndian.PutUint32(key[2:], parentID)
+	binary.BigEndian.PutUint16(key[6:], uint16(len(units)))
+	for i, unit := range units {
+		binary.BigEndian.PutUint16(key[8+2*i:], unit)
+	}
+	return key
+}
+
+func hfsTestFolder(parentID uint32, name string, folderID uint32) []byte {
+	data := make([]byte, 88)
+	binary.BigEndian.PutUint16(data, hfsFolderRecord)
+	binary.BigEndian.PutUint32(data[8:], folderID)
+	return append(hfsTestKey(parentID, name), data...)
+}
+
+func hfsTestFile(parentID uint32, name string, fileID uint32, mode uint16, block uint32, size int) []byte {
+	data := make([]byte, 248)
+	binary.BigEndian.PutUint16(data, hfsFileRecord)
+	binary.BigEndian.PutUint32(data[8:], fileID)
+	binary.BigEndian.PutUint32(data[16:], hfsTestModTime+hfsEpochOffset)
+	binary.BigEndian.PutUint16(data[42:], 0x8000|mode)
+	putHFSTestFork(data[88:], size, block, 1)
+	return append(hfsTestKey(parentID, name), data...)
+}
+
+// dmgTestChunk is a run of sectors of the disk and how the image stores them
+type dmgTestChunk struct {
+	kind    uint32
+	sectors int
+}
+
+// dmgTestImage wraps the disk in a UDIF disk image, storing consecutive runs of its sectors as
+// the chunks describe
+func dmgTestImage(t *testing.T, disk []byte, chunks ...dmgTestChunk) []byte {
+	var dataFork bytes.Buffer
+	table := make([]byte, 204+40*(len(chunks)+1))
+	copy(table, "mish")
+	binary.BigEndian.PutUint32(table[4:], 1)
+	sector := 0
+	for i, chunk := range chunks {
+		content := disk[sector*dmgSectorSize : (sector+chunk.sectors)*dmgSectorSize]
+		var stored []byte
+		switch chunk.kind {
+		case dmgChunkZero:
+		case dmgChunkZlib:
+			var buf bytes.Buffer
+			zw := zlib.NewWriter(&buf)
+			_, err := zw.Write(content)
+			if err == nil {
+				err = zw.Close()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored = buf.Bytes()
+		default:
+			stored = content
+		}
+
+		entry := table[204+40*i:]
+		binary.BigEndian.PutUint32(entry, chunk.kind)
+		binary.BigEndian.PutUint64(entry[8:], uint64(sector))
+		binary.BigEndian.PutUint64(entry[16:], uint64(chunk.sectors))
+		binary.BigEndian.PutUint64(entry[24:], uint64(dataFork.Len()))
+		binary.BigEndian.PutUint64(entry[32:], uint64(len(stored)))
+		dataFork.Write(stored)
+		sector += chunk.sectors
+	}
+	binary.BigEndian.PutUint32(table[204+40*len(chunks):], dmgChunkTerminator)
+	binary.BigEndian.PutUint64(table[16:], uint64(sector))
+	binary.BigEndian.PutUint32(table[200:], uint32(len(chunks)+1))
+
+	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>resource-fork</key>
+	<dict>
+		<key>blkx</key>
+		<array>
+			<dict>
+				<key>Attributes</key>
+				<string>0x0050</string>
+				<key>Data</key>
+				<data>%s</data>
+				<key>Name</key>
+				<string>disk image (Apple_HFS : 1)</string>
+			</dict>
+		</array>
+	</dict>
+</dict>
+</plist>
+`, base64.StdEncoding.EncodeToString(table))
+
+	trailer := make([]byte, dmgSectorSize)
+	copy(trailer, "koly")
+	binary.BigEndian.PutUint32(trailer[4:], 4)
+	binary.BigEndian.PutUint32(trailer[8:], dmgSectorSize)
+	binary.BigEndian.PutUint64(trailer[32:], uint64(dataFork.Len()))
+	binary.BigEndian.PutUint64(trailer[216:], uint64(dataFork.Len()))
+	binary.BigEndian.PutUint64(trailer[224:], uint64(len(plist)))
+
+	return append(append(dataFork.Bytes(), plist...), trailer...)
+}
+
+// writeTestArchive writes the archive content to a file in a temporary directory and opens it,
+// also for writing, so tests can alter it
+func writeTestArchive(t *testing.T, name string, content []byte) *os.File {
+	path := filepath.Join(t.TempDir(), name)
+	err := ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		//noinspection GoUnhandledErrorResult
+		archive.Close()
+	})
+	return archive
+}
+
+func TestDmgListAndExtract(t *testing.T) {
+	image := dmgTestImage(t, hfsTestVolume(),
+		dmgTestChunk{kind: dmgChunkZero, sectors: 2},
+		dmgTestChunk{kind: dmgChunkRaw, sectors: 3},
+		dmgTestChunk{kind: dmgChunkZlib, sectors: 4},
+	)
+	archive := writeTestArchive(t, "tool.dmg", image)
+	ctx := context.Background()
+
+	names, err := (&dmgExtractor{}).List(ctx, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"bin/tool", "README"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("listed %v rather than %v", names, expected)
+	}
+
+	err = Extract(ctx, Dmg, archive, "bin/tool", func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+		if name != "bin/tool" {
+			t.Errorf("extracted %s", name)
+		}
+		if info.Mode() != 0755 {
+			t.Errorf("extracted with mode %v", info.Mode())
+		}
+		if !info.ModTime().Equal(time.Unix(hfsTestModTime, 0)) {
+			t.Errorf("extracted with modification time %v", info.ModTime())
+		}
+		data, err := ioutil.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(data, hfsTestTool) {
+			t.Errorf("extracted %q", data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Extract(ctx, Dmg, archive, "bin/missing", func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+		t.Errorf("extracted %s", name)
+		return nil
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("extracting a missing file gave %v", err)
+	}
+}
+
+func TestDmgUnsupported(t *testing.T) {
+	apfs := make([]byte, 8*dmgSectorSize)
+	copy(apfs[32:], "NXSB")
+
+	tests := []struct {
+		name     string
+		image    []byte
+		expected string
+	}{
+		{
+			name:     "apfs",
+			image:    dmgTestImage(t, apfs, dmgTestChunk{kind: dmgChunkRaw, sectors: 8}),
+			expected: "APFS volume",
+		},
+		{
+			name: "lzfse",
+			image: dmgTestImage(t, hfsTestVolume(),
+				dmgTestChunk{kind: dmgChunkZero, sectors: 2},
+				dmgTestChunk{kind: dmgChunkLZFSE, sectors: 7},
+			),
+			expected: "LZFSE (ULFO) are not supported",
+		},
+		{
+			name: "lzma",
+			image: dmgTestImage(t, hfsTestVolume(),
+				dmgTestChunk{kind: dmgChunkLZMA, sectors: 9},
+			),
+			expected: "LZMA (ULMO) are not supported",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive := writeTestArchive(t, "tool.dmg", test.image)
+			_, err := (&dmgExtractor{}).List(context.Background(), archive)
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("listing gave %v rather than an error about %s", err, test.expected)
+			}
+		})
+	}
+}
+
+func TestDmgTruncated(t *testing.T) {
+	image := dmgTestImage(t, hfsTestVolume(),
+		dmgTestChunk{kind: dmgChunkRaw, sectors: 5},
+		dmgTestChunk{kind: dmgChunkZlib, sectors: 4},
+	)
+	for _, size := range []int{0, 100, dmgSectorSize, len(image) / 2, len(image) - 1} {
+		archive := writeTestArchive(t, "tool.dmg", image[:size])
+		_, err := (&dmgExtractor{}).List(context.Background(), archive)
+		if err == nil {
+			t.Errorf("listing the image truncated to %d bytes succeeded", size)
+		}
+	}
+
+	// a volume cut short within its catalog, but wrapped in an intact image
+	volume := hfsTestVolume()[:5*hfsTestBlockSize]
+	archive := writeTestArchive(t, "tool.dmg", dmgTestImage(t, volume, dmgTestChunk{kind: dmgChunkRaw, sectors: 5}))
+	_, err := (&dmgExtractor{}).List(context.Background(), archive)
+	if err == nil {
+		t.Error("listing a truncated volume succeeded")
+	}
+}
+
+// TestDmgCorrupt alters each byte of an image in turn, which must fail or succeed but never panic
+func TestDmgCorrupt(t *testing.T) {
+	image := dmgTestImage(t, hfsTestVolume(),
+		dmgTestChunk{kind: dmgChunkZero, sectors: 2},
+		dmgTestChunk{kind: dmgChunkRaw, sectors: 5},
+		dmgTestChunk{kind: dmgChunkZlib, sectors: 2},
+	)
+	archive := writeTestArchive(t, "tool.dmg", image)
+	ctx := context.Background()
+	for i := range image {
+		_, err := archive.WriteAt([]byte{image[i] ^ 0xff}, int64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = (&dmgExtractor{}).List(ctx, archive)
+		_ = Extract(ctx, Dmg, archive, "bin/tool", func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+			_, err := io.Copy(ioutil.Discard, content)
+			return err
+		})
+		_, err = archive.WriteAt(image[i:i+1], int64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
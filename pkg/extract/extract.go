@@ -0,0 +1,222 @@
+// Package extract locates a requested file within a downloaded archive
+package extract
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Format names an archive format, such as tar.gz
+type Format string
+
+const (
+	TarGz Format = "tar.gz"
+	Zip   Format = "zip"
+	// Binary is a download of the executable itself, which is never detected from a name
+	Binary Format = "binary"
+	// AppImage is installed itself, like Binary, or has a file extracted from its embedded filesystem
+	AppImage Format = "appimage"
+	// Squashfs is a squashfs filesystem image, such as a snap
+	Squashfs Format = "squashfs"
+	// Dmg is a macOS disk image containing an HFS+ volume
+	Dmg Format = "dmg"
+	// Pkg is a flat macOS installer package, whose entries are the files its payloads install
+	Pkg Format = "pkg"
+	// Nupkg is a NuGet package, which is a zip archive whose entry names are percent-encoded
+	Nupkg Format = "nupkg"
+	// Whl is a Python wheel, where a native executable is located in the scripts of its data
+	Whl Format = "whl"
+)
+
+// ErrNotFound indicates the requested file is not within the archive
+var ErrNotFound = errors.New("unable to find requested file in archive")
+
+// Handler is given the content of the archive entry that matched the requested file
+type Handler func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error
+
+// EntryOwner is the numeric user and group ID recorded for the archive entry described by the
+// info given to a Handler, which only tar archives record
+func EntryOwner(info os.FileInfo) (uid int, gid int, ok bool) {
+	header, ok := info.Sys().(*tar.Header)
+	if !ok {
+		return 0, 0, false
+	}
+	return header.Uid, header.Gid, true
+}
+
+// Extractor locates the requested file within an archive of a particular format
+type Extractor interface {
+	Extract(ctx context.Context, archive *os.File, file string, handler Handler) error
+}
+
+// ExtractorFunc adapts a function to an Extractor
+type ExtractorFunc func(ctx context.Context, archive *os.File, file string, handler Handler) error
+
+func (f ExtractorFunc) Extract(ctx context.Context, archive *os.File, file string, handler Handler) error {
+	return f(ctx, archive, file, handler)
+}
+
+var (
+	registryMu sync.RWMutex
+	extractors = make(map[Format]Extractor)
+	// suffixes maps a lowercase file suffix to the format of archives named with it
+	suffixes = make(map[string]Format)
+)
+
+func init() {
+	Register(TarGz, &tarGzExtractor{}, ".tar.gz", ".tgz")
+	Register(Zip, &zipExtractor{}, ".zip", ".jar")
+	Register(Binary, &binaryExtractor{})
+	Register(AppImage, &appImageExtractor{}, ".appimage")
+	Register(Squashfs, &squashfsExtractor{}, ".squashfs", ".sqfs", ".snap")
+	Register(Dmg, &dmgExtractor{}, ".dmg")
+	Register(Pkg, &pkgExtractor{}, ".pkg")
+	Register(Nupkg, &nupkgExtractor{}, ".nupkg")
+	Register(Whl, &whlExtractor{}, ".whl")
+}
+
+// Register makes the extractor available for the format, which is detected from archives
+// named with any of the given suffixes. Registering an existing format replaces its extractor.
+func Register(format Format, extractor Extractor, nameSuffixes ...string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	extractors[format] = extractor
+	for _, suffix := range nameSuffixes {
+		suffixes[strings.ToLower(suffix)] = format
+	}
+}
+
+// ParseFormat validates that an extractor is registered for the named format
+func ParseFormat(name string) (Format, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if _, exists := extractors[Format(name)]; exists {
+		return Format(name), nil
+	}
+	var names []string
+	for format := range extractors {
+		names = append(names, string(format))
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown format '%s', which must be one of %s", name, strings.Join(names, ", "))
+}
+
+// DetectFormat determines the format of an archive from the suffix of its name or URL path,
+// where the longest registered suffix wins. Query strings and fragments of URLs are expected
+// to be removed beforehand.
+func DetectFormat(name string) (Format, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	name = strings.ToLower(name)
+	var detected Format
+	longest := 0
+	for suffix, format := range suffixes {
+		if len(suffix) > longest && strings.HasSuffix(name, suffix) {
+			detected = format
+			longest = len(suffix)
+		}
+	}
+	if longest == 0 {
+		return "", fmt.Errorf("unsupported archive type, the name must end with one of %s",
+			strings.Join(registeredSuffixes(), ", "))
+	}
+	return detected, nil
+}
+
+func registeredSuffixes() []string {
+	var names []string
+	for suffix := range suffixes {
+		names = append(names, suffix)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Extract locates the requested file in the archive, using the extractor registered for its
+// format, and passes the file's content to the handler
+func Extract(ctx context.Context, format Format, archive *os.File, file string, handler Handler) error {
+	registryMu.RLock()
+	extractor, exists := extractors[format]
+	registryMu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("no extractor is registered for %s archives", format)
+	}
+	return extractor.Extract(ctx, archive, file, handler)
+}
+
+// MultiExtractor is implemented by extractors that can extract several files in one pass over
+// the archive, which matters for tar archives since they can only be read from their start
+type MultiExtractor interface {
+	ExtractAll(ctx context.Context, archive *os.File, files []string, handler Handler) error
+}
+
+// ExtractAll passes the content of each of the requested files in the archive to the handler, in
+// one pass over the archive when its extractor supports that and otherwise by extracting each in
+// turn. The files are expected to be entry names, such as those returned by ResolveEntries, and
+// the handler is called in the order of the archive rather than of the files.
+func ExtractAll(ctx context.Context, format Format, archive *os.File, files []string, handler Handler) error {
+	registryMu.RLock()
+	extractor, exists := extractors[format]
+	registryMu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("no extractor is registered for %s archives", format)
+	}
+	if multi, ok := extractor.(MultiExtractor); ok {
+		_, err := archive.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		return multi.ExtractAll(ctx, archive, files, handler)
+	}
+
+	for _, file := range files {
+		_, err := archive.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		err = extractor.Extract(ctx, archive, file, handler)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EntryMatches determines if the archive entry name is the requested file, where any leading
+// "./" or "/" of either is ignored. On Windows, the requested file also matches an entry with
+// an added .exe suffix.
+func EntryMatches(entryName string, file string) bool {
+	entryName = NormalizeEntryName(entryName)
+	file = NormalizeEntryName(file)
+	if entryName == file {
+		return true
+	}
+	return runtime.GOOS == "windows" &&
+		!strings.HasSuffix(strings.ToLower(file), ".exe") &&
+		entryName == file+".exe"
+}
+
+// NormalizeEntryName removes any leading "./" and "/" from an archive entry name, such as
+// ./bin/tool, since archives vary in how they record them
+func NormalizeEntryName(name string) string {
+	for {
+		trimmed := strings.TrimPrefix(strings.TrimLeft(name, "/"), "./")
+		if trimmed == name {
+			return name
+		}
+		name = trimmed
+	}
+}
@@ -0,0 +1,79 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// gzipMembers reads every member of a gzip stream, as written by concatenating gzip files or by
+// pigz, where zero padding after the last member is ignored rather than being an invalid header
+type gzipMembers struct {
+	r      *bufio.Reader
+	member *gzip.Reader
+}
+
+func newGzipReader(archive io.Reader) (io.Reader, error) {
+	r := bufio.NewReader(archive)
+	member, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip content: %w", err)
+	}
+	member.Multistream(false)
+	return &gzipMembers{r: r, member: member}, nil
+}
+
+func (g *gzipMembers) Read(p []byte) (int, error) {
+	for {
+		n, err := g.member.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+
+		more, err := g.nextMember()
+		if err != nil {
+			return n, err
+		}
+		if !more {
+			return n, io.EOF
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// nextMember starts reading the next member, if there is one after any zero padding
+func (g *gzipMembers) nextMember() (bool, error) {
+	for {
+		b, err := g.r.ReadByte()
+		if err == io.EOF {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if b != 0 {
+			err = g.r.UnreadByte()
+			if err != nil {
+				return false, err
+			}
+			break
+		}
+	}
+
+	magic, err := g.r.Peek(len(gzipMagic))
+	if err != nil || !bytes.Equal(magic, gzipMagic) {
+		return false, errors.New("failed to read gzip content: unexpected data after the last member")
+	}
+	err = g.member.Reset(g.r)
+	if err != nil {
+		return false, fmt.Errorf("failed to read gzip content: %w", err)
+	}
+	g.member.Multistream(false)
+	return true, nil
+}
@@ -0,0 +1,382 @@
+package extract
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	hfsRootFolderID = 2
+	// hfsEpochOffset is the number of seconds from 1904, when HFS+ dates start, until 1970
+	hfsEpochOffset = 2082844800
+
+	hfsFolderRecord = 1
+	hfsFileRecord   = 2
+
+	hfsLeafNode = 0xff
+	// hfsCompressedFlag marks files whose content is compressed into an extended attribute
+	hfsCompressedFlag = 0x20
+)
+
+func isHFSPlusSignature(signature []byte) bool {
+	return string(signature) == "H+" || string(signature) == "HX"
+}
+
+// hfsExtent is a run of allocation blocks of a fork
+type hfsExtent struct {
+	startBlock uint32
+	blockCount uint32
+}
+
+// hfsFork is the content of a file as described by its fork data
+type hfsFork struct {
+	size    uint64
+	blocks  uint32
+	extents []hfsExtent
+}
+
+func parseHFSFork(data []byte) hfsFork {
+	fork := hfsFork{
+		size:   binary.BigEndian.Uint64(data),
+		blocks: binary.BigEndian.Uint32(data[12:]),
+	}
+	for i := 0; i < 8; i++ {
+		extent := hfsExtent{
+			startBlock: binary.BigEndian.Uint32(data[16+i*8:]),
+			blockCount: binary.BigEndian.Uint32(data[20+i*8:]),
+		}
+		if extent.blockCount == 0 {
+			break
+		}
+		fork.extents = append(fork.extents, extent)
+	}
+	return fork
+}
+
+// hfsFile is a regular file of the volume
+type hfsFile struct {
+	name     string
+	parentID uint32
+	fileID   uint32
+	mode     os.FileMode
+	modTime  time.Time
+	flags    uint8
+	fork     hfsFork
+}
+
+// hfsVolume reads the files of an HFS+ or HFSX volume
+type hfsVolume struct {
+	r         io.ReaderAt
+	blockSize uint32
+	catalog   hfsFork
+	extents   hfsFork
+}
+
+func openHFSVolume(r io.ReaderAt) (*hfsVolume, error) {
+	header := make([]byte, 512)
+	_, err := r.ReadAt(header, 1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HFS+ volume header: %w", err)
+	}
+	if !isHFSPlusSignature(header[:2]) {
+		return nil, errors.New("not an HFS+ volume")
+	}
+	v := &hfsVolume{
+		r:         r,
+		blockSize: binary.BigEndian.Uint32(header[40:]),
+		extents:   parseHFSFork(header[192:]),
+		catalog:   parseHFSFork(header[272:]),
+	}
+	if v.blockSize < 512 || v.blockSize&(v.blockSize-1) != 0 {
+		return nil, fmt.Errorf("invalid HFS+ block size %d", v.blockSize)
+	}
+	return v, nil
+}
+
+// forkReader reads the content of the fork, including extents beyond the first eight that are
+// recorded in the extents overflow file
+func (v *hfsVolume) forkReader(fork hfsFork, fileID uint32) (*io.SectionReader, error) {
+	extents := fork.extents
+	var total uint64
+	for _, extent := range extents {
+		total += uint64(extent.blockCount)
+	}
+	if total < uint64(fork.blocks) {
+		if fileID == 0 {
+			return nil, errors.New("the HFS+ extents file has overflow extents")
+		}
+		overflow, err := v.overflowExtents(fileID)
+		if err != nil {
+			return nil, err
+		}
+		extents = append(extents, overflow...)
+	}
+	if fork.size > uint64(fork.blocks)*uint64(v.blockSize) {
+		return nil, errors.New("invalid HFS+ fork size")
+	}
+	return io.NewSectionReader(&hfsExtentReader{v: v, extents: extents}, 0, int64(fork.size)), nil
+}
+
+// hfsExtentReader reads the allocation blocks of extents as one run of content
+type hfsExtentReader struct {
+	v       *hfsVolume
+	extents []hfsExtent
+}
+
+func (e *hfsExtentReader) ReadAt(p []byte, off int64) (int, error) {
+	blockSize := int64(e.v.blockSize)
+	n := 0
+	var extentStart int64
+	for _, extent := range e.extents {
+		extentSize := int64(extent.blockCount) * blockSize
+		pos := off + int64(n)
+		if pos < extentStart+extentSize {
+			m, err := e.v.r.ReadAt(p[n:minInt64(int64(len(p)), int64(n)+extentStart+extentSize-pos)],
+				int64(extent.startBlock)*blockSize+pos-extentStart)
+			n += m
+			if err != nil {
+				return n, err
+			}
+			if n == len(p) {
+				return n, nil
+			}
+		}
+		extentStart += extentSize
+	}
+	return n, io.EOF
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// overflowExtents finds the extents of the data fork of the file beyond its first eight
+func (v *hfsVolume) overflowExtents(fileID uint32) ([]hfsExtent, error) {
+	r, err := v.forkReader(v.extents, 0)
+	if err != nil {
+		return nil, err
+	}
+	type keyedExtents struct {
+		startBlock uint32
+		extents    []hfsExtent
+	}
+	var found []keyedExtents
+	err = walkHFSLeaves(r, func(record []byte) error {
+		// key length, fork type, pad, file ID, and start block precede the eight extents
+		if len(record) < 12+64 || record[2] != 0 || binary.BigEndian.Uint32(record[4:]) != fileID {
+			return nil
+		}
+		fork := make([]byte, 80)
+		copy(fork[16:], record[12:12+64])
+		found = append(found, keyedExtents{
+			startBlock: binary.BigEndian.Uint32(record[8:]),
+			extents:    parseHFSFork(fork).extents,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].startBlock < found[j].startBlock
+	})
+	var extents []hfsExtent
+	for _, f := range found {
+		extents = append(extents, f.extents...)
+	}
+	return extents, nil
+}
+
+// walkHFSLeaves visits every record of the leaf nodes of the B-tree, in order
+func walkHFSLeaves(tree *io.SectionReader, visit func(record []byte) error) error {
+	header := make([]byte, 14+106)
+	_, err := tree.ReadAt(header, 0)
+	if err != nil {
+		return fmt.Errorf("failed to read HFS+ B-tree header: %w", err)
+	}
+	firstLeaf := binary.BigEndian.Uint32(header[14+10:])
+	nodeSize := int(binary.BigEndian.Uint16(header[14+18:]))
+	totalNodes := binary.BigEndian.Uint32(header[14+22:])
+	if nodeSize < 512 || nodeSize > 32768 {
+		return fmt.Errorf("invalid HFS+ B-tree node size %d", nodeSize)
+	}
+
+	node := make([]byte, nodeSize)
+	visited := uint32(0)
+	for current := firstLeaf; current != 0; current = binary.BigEndian.Uint32(node) {
+		visited++
+		if visited > totalNodes {
+			return errors.New("invalid HFS+ B-tree, its leaf nodes form a cycle")
+		}
+		_, err = tree.ReadAt(node, int64(current)*int64(nodeSize))
+		if err != nil {
+			return fmt.Errorf("failed to read HFS+ B-tree node: %w", err)
+		}
+		if node[8] != hfsLeafNode {
+			return errors.New("invalid HFS+ B-tree leaf node")
+		}
+		count := int(binary.BigEndian.Uint16(node[10:]))
+		if 14+count*2 > nodeSize {
+			return errors.New("invalid HFS+ B-tree leaf node")
+		}
+		for i := 0; i < count; i++ {
+			start := int(binary.BigEndian.Uint16(node[nodeSize-2*(i+1):]))
+			end := int(binary.BigEndian.Uint16(node[nodeSize-2*(i+2):]))
+			if start < 14 || end > nodeSize-2*(count+1) || start >= end {
+				return errors.New("invalid HFS+ B-tree record")
+			}
+			err = visit(node[start:end])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readCatalog collects the regular files of the volume along with the names and parents of its folders
+func (v *hfsVolume) readCatalog(ctx context.Context) ([]hfsFile, map[uint32]hfsFile, error) {
+	r, err := v.forkReader(v.catalog, 4)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files []hfsFile
+	folders := make(map[uint32]hfsFile)
+	err = walkHFSLeaves(r, func(record []byte) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		keyLength := int(binary.BigEndian.Uint16(record))
+		if keyLength < 6 || 2+keyLength+2 > len(record) {
+			return errors.New("invalid HFS+ catalog record")
+		}
+		parentID := binary.BigEndian.Uint32(record[2:])
+		nameLength := int(binary.BigEndian.Uint16(record[6:]))
+		if 8+nameLength*2 > 2+keyLength {
+			return errors.New("invalid HFS+ catalog record")
+		}
+		units := make([]uint16, nameLength)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(record[8+i*2:])
+		}
+		// a slash within a name is presented as a colon, as macOS does
+		name := strings.Replace(string(utf16.Decode(units)), "/", ":", -1)
+
+		data := record[2+keyLength:]
+		switch int16(binary.BigEndian.Uint16(data)) {
+		case hfsFolderRecord:
+			if len(data) < 88 {
+				return errors.New("invalid HFS+ folder record")
+			}
+			folders[binary.BigEndian.Uint32(data[8:])] = hfsFile{name: name, parentID: parentID}
+		case hfsFileRecord:
+			if len(data) < 248 {
+				return errors.New("invalid HFS+ file record")
+			}
+			fileMode := binary.BigEndian.Uint16(data[42:])
+			if fileMode&0xf000 != 0 && fileMode&0xf000 != 0x8000 {
+				// symbolic links and other special files
+				return nil
+			}
+			mode := os.FileMode(fileMode) & os.ModePerm
+			if fileMode == 0 {
+				mode = 0644
+			}
+			files = append(files, hfsFile{
+				name:     name,
+				parentID: parentID,
+				fileID:   binary.BigEndian.Uint32(data[8:]),
+				mode:     mode,
+				modTime:  time.Unix(int64(binary.BigEndian.Uint32(data[16:]))-hfsEpochOffset, 0),
+				flags:    data[32+9],
+				fork:     parseHFSFork(data[88:]),
+			})
+		}
+		return nil
+	})
+	return files, folders, err
+}
+
+// hfsFilePath is the path of the file from the root folder of the volume
+func hfsFilePath(file hfsFile, folders map[uint32]hfsFile) (string, error) {
+	names := []string{file.name}
+	for parentID, depth := file.parentID, 0; parentID != hfsRootFolderID; depth++ {
+		folder, exists := folders[parentID]
+		if !exists || depth > 256 {
+			return "", errors.New("invalid HFS+ catalog, a file is not within the root folder")
+		}
+		names = append(names, folder.name)
+		parentID = folder.parentID
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return path.Join(names...), nil
+}
+
+func (v *hfsVolume) extract(ctx context.Context, requested string, handler Handler) error {
+	files, folders, err := v.readCatalog(ctx)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name, err := hfsFilePath(file, folders)
+		if err != nil {
+			return err
+		}
+		if !EntryMatches(name, requested) {
+			continue
+		}
+		if file.flags&hfsCompressedFlag != 0 {
+			return fmt.Errorf("%s uses HFS+ file compression, which is not supported", name)
+		}
+		content, err := v.forkReader(file.fork, file.fileID)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, name, &hfsFileInfo{name: name, file: file}, content)
+	}
+	return ErrNotFound
+}
+
+func (v *hfsVolume) list(ctx context.Context) ([]string, error) {
+	files, folders, err := v.readCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		name, err := hfsFilePath(file, folders)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// hfsFileInfo describes a regular file of an HFS+ volume
+type hfsFileInfo struct {
+	name string
+	file hfsFile
+}
+
+func (i *hfsFileInfo) Name() string       { return path.Base(i.name) }
+func (i *hfsFileInfo) Size() int64        { return int64(i.file.fork.size) }
+func (i *hfsFileInfo) Mode() os.FileMode  { return i.file.mode }
+func (i *hfsFileInfo) ModTime() time.Time { return i.file.modTime }
+func (i *hfsFileInfo) IsDir() bool        { return false }
+func (i *hfsFileInfo) Sys() interface{}   { return nil }
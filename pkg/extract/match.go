@@ -0,0 +1,233 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Match is how the requested file is matched against archive entry names
+type Match string
+
+const (
+	// MatchExact requires the entry name to be the requested file
+	MatchExact Match = "exact"
+	// MatchSuffix allows the requested file to be the trailing path elements of the entry name,
+	// such as bin/tool matching tool-1.2.3/bin/tool
+	MatchSuffix Match = "suffix"
+	// MatchGlob treats the requested file as a pattern, as supported by path.Match, such as
+	// tool-*/bin/tool
+	MatchGlob Match = "glob"
+)
+
+// Pick is how one entry is chosen when the requested file matches several
+type Pick string
+
+const (
+	// PickError fails rather than choosing among the entries
+	PickError Pick = "error"
+	// PickFirst chooses the first entry in sorted order
+	PickFirst Pick = "first"
+	// PickShortestPath chooses the entry with the shortest name, such as bin/tool over
+	// bin/completions/tool
+	PickShortestPath Pick = "shortest-path"
+	// PickLargest chooses the entry with the most content
+	PickLargest Pick = "largest"
+)
+
+// Lister is implemented by extractors that can list the files within an archive, which is
+// needed for matching other than MatchExact
+type Lister interface {
+	List(ctx context.Context, archive *os.File) ([]string, error)
+}
+
+// ParseMatch validates the name of a Match, where empty is MatchExact
+func ParseMatch(name string) (Match, error) {
+	switch Match(name) {
+	case "", MatchExact:
+		return MatchExact, nil
+	case MatchSuffix, MatchGlob:
+		return Match(name), nil
+	default:
+		return "", fmt.Errorf("unsupported match '%s', must be exact, suffix, or glob", name)
+	}
+}
+
+// ParsePick validates the name of a Pick, where empty is PickError
+func ParsePick(name string) (Pick, error) {
+	switch Pick(name) {
+	case "", PickError:
+		return PickError, nil
+	case PickFirst, PickShortestPath, PickLargest:
+		return Pick(name), nil
+	default:
+		return "", fmt.Errorf("unsupported pick '%s', must be first, shortest-path, largest, or error", name)
+	}
+}
+
+// Choose picks one of the names, which are in sorted order, where sizes are needed for
+// PickLargest. Ties go to the earliest name so that the choice doesn't depend on the order of
+// the archive. It returns empty for PickError.
+func (p Pick) Choose(names []string, sizes map[string]int64) string {
+	if len(names) == 0 {
+		return ""
+	}
+	chosen := names[0]
+	switch p {
+	case PickFirst:
+	case PickShortestPath:
+		for _, name := range names[1:] {
+			if len(NormalizeEntryName(name)) < len(NormalizeEntryName(chosen)) {
+				chosen = name
+			}
+		}
+	case PickLargest:
+		for _, name := range names[1:] {
+			if sizes[name] > sizes[chosen] {
+				chosen = name
+			}
+		}
+	default:
+		return ""
+	}
+	return chosen
+}
+
+// Sizes finds the size of each of the named entries of the archive, such as for PickLargest
+func Sizes(ctx context.Context, format Format, archive *os.File, names []string) (map[string]int64, error) {
+	sizes := make(map[string]int64, len(names))
+	err := ExtractAll(ctx, format, archive, names, func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+		sizes[name] = info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// List returns the names of the files within the archive, using the extractor registered for
+// its format. The archive is positioned back at its start afterwards.
+func List(ctx context.Context, format Format, archive *os.File) ([]string, error) {
+	registryMu.RLock()
+	extractor, exists := extractors[format]
+	registryMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("no extractor is registered for %s archives", format)
+	}
+	lister, ok := extractor.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("the contents of %s archives can't be listed", format)
+	}
+
+	_, err := archive.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	names, err := lister.List(ctx, archive)
+	if err != nil {
+		return nil, err
+	}
+	_, err = archive.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// ResolveEntry finds the one entry of the archive that matches the requested file and returns
+// its name, which can then be extracted exactly
+func ResolveEntry(ctx context.Context, format Format, archive *os.File, file string, match Match) (string, error) {
+	matched, err := ResolveEntries(ctx, format, archive, file, match)
+	if err != nil {
+		return "", err
+	}
+	if len(matched) > 1 {
+		return "", fmt.Errorf("%s matches several files in the archive: %s", file, strings.Join(matched, ", "))
+	}
+	return matched[0], nil
+}
+
+// ResolveEntries finds every entry of the archive that matches the requested file and returns
+// their names in sorted order, which can then be extracted exactly
+func ResolveEntries(ctx context.Context, format Format, archive *os.File, file string, match Match) ([]string, error) {
+	if match == MatchExact || match == "" {
+		return []string{file}, nil
+	}
+	matches, err := matcher(file, match)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := List(ctx, format, archive)
+	if err != nil {
+		return nil, err
+	}
+	return matchEntries(names, file, matches)
+}
+
+// MatchEntries finds every entry, of the names listed by List, that matches the requested file
+// and returns them in sorted order, such as to resolve several files of the archive while only
+// listing it once
+func MatchEntries(names []string, file string, match Match) ([]string, error) {
+	if match == MatchExact || match == "" {
+		return []string{file}, nil
+	}
+	matches, err := matcher(file, match)
+	if err != nil {
+		return nil, err
+	}
+	return matchEntries(names, file, matches)
+}
+
+// matcher is how entry names are matched against the requested file for a match other than MatchExact
+func matcher(file string, match Match) (func(entryName string, file string) bool, error) {
+	if match != MatchGlob {
+		return entryMatchesSuffix, nil
+	}
+	if _, err := path.Match(file, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", file, err)
+	}
+	return entryMatchesGlob, nil
+}
+
+func matchEntries(names []string, file string, matches func(entryName string, file string) bool) ([]string, error) {
+	var matched []string
+	for _, name := range names {
+		if matches(name, file) {
+			matched = append(matched, name)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, ErrNotFound
+	}
+	sort.Strings(matched)
+	return matched, nil
+}
+
+// entryMatchesSuffix determines if the requested file is the entry or its trailing path elements
+func entryMatchesSuffix(entryName string, file string) bool {
+	if EntryMatches(entryName, file) {
+		return true
+	}
+	entryName = NormalizeEntryName(entryName)
+	file = NormalizeEntryName(file)
+	for i := 0; i < len(entryName); i++ {
+		if entryName[i] == '/' && EntryMatches(entryName[i+1:], file) {
+			return true
+		}
+	}
+	return false
+}
+
+// entryMatchesGlob determines if the entry name matches the pattern, where any leading "./" or
+// "/" of either is ignored
+func entryMatchesGlob(entryName string, pattern string) bool {
+	matched, _ := path.Match(NormalizeEntryName(pattern), NormalizeEntryName(entryName))
+	return matched
+}
@@ -0,0 +1,69 @@
+package extract
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// nupkgExtractor handles NuGet packages, which are zip archives laid out as Open Packaging
+// Conventions packages. Entry names are percent-encoded within the zip, such as
+// tools/net8.0/any/tool%2Bextra.dll, so they're matched and listed decoded, and the parts
+// describing the package itself, such as [Content_Types].xml, aren't entries.
+type nupkgExtractor struct{}
+
+func (n *nupkgExtractor) Extract(ctx context.Context, archive *os.File, file string, handler Handler) error {
+	files, err := openZipFiles(archive)
+	if err != nil {
+		return err
+	}
+
+	for _, zipFile := range files {
+		name := nupkgEntryName(zipFile.Name)
+		if !isNupkgMetadata(name) && EntryMatches(name, file) {
+			return extractFromZip(ctx, zipFile, func(ctx context.Context, _ string, info os.FileInfo, content io.Reader) error {
+				return handler(ctx, name, info, content)
+			})
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (n *nupkgExtractor) List(ctx context.Context, archive *os.File) ([]string, error) {
+	files, err := openZipFiles(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, zipFile := range files {
+		name := nupkgEntryName(zipFile.Name)
+		if !zipFile.FileInfo().IsDir() && !isNupkgMetadata(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// nupkgEntryName decodes the percent-encoding of a package part name, leaving names that
+// aren't validly encoded as they are
+func nupkgEntryName(name string) string {
+	decoded, err := url.PathUnescape(name)
+	if err != nil {
+		return name
+	}
+	return decoded
+}
+
+// isNupkgMetadata determines if the entry is one of the parts that describe the package rather
+// than being content of it. The .nuspec at the root is kept since it's useful on its own.
+func isNupkgMetadata(name string) bool {
+	name = NormalizeEntryName(name)
+	return name == "[Content_Types].xml" ||
+		name == ".signature.p7s" ||
+		strings.HasPrefix(name, "_rels/") ||
+		strings.HasPrefix(name, "package/")
+}
@@ -0,0 +1,298 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/itzg/easy-add/internal/xz"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+// pbzxMaxChunkSize bounds the size of a decompressed chunk of a pbzx payload
+const pbzxMaxChunkSize = 64 << 20
+
+// pkgExtractor handles flat macOS installer packages, which are xar archives where each component
+// package has a Payload that is a cpio archive of the files it installs. Entries are the paths of
+// those files, such as usr/local/bin/tool.
+type pkgExtractor struct{}
+
+func (p *pkgExtractor) Extract(ctx context.Context, archive *os.File, file string, handler Handler) error {
+	found := false
+	err := walkPkgPayloads(ctx, archive, func(name string, info os.FileInfo, content io.Reader) (bool, error) {
+		if !EntryMatches(name, file) {
+			return true, nil
+		}
+		found = true
+		return false, handler(ctx, name, info, content)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *pkgExtractor) List(ctx context.Context, archive *os.File) ([]string, error) {
+	var names []string
+	err := walkPkgPayloads(ctx, archive, func(name string, info os.FileInfo, content io.Reader) (bool, error) {
+		names = append(names, name)
+		return true, nil
+	})
+	return names, err
+}
+
+// walkPkgPayloads visits the regular files of the payload of each component package, stopping
+// when visit returns false
+func walkPkgPayloads(ctx context.Context, archive *os.File,
+	visit func(name string, info os.FileInfo, content io.Reader) (bool, error)) error {
+
+	x, err := openXar(archive)
+	if err != nil {
+		return err
+	}
+	payloads := 0
+	for _, entry := range x.entries {
+		if path.Base(entry.name) != "Payload" {
+			continue
+		}
+		payloads++
+		more, err := walkPkgPayload(ctx, x, entry, visit)
+		if !more || err != nil {
+			return err
+		}
+	}
+	if payloads == 0 {
+		return errors.New("the package has no payload")
+	}
+	return nil
+}
+
+func walkPkgPayload(ctx context.Context, x *xarArchive, entry xarEntry,
+	visit func(name string, info os.FileInfo, content io.Reader) (bool, error)) (bool, error) {
+
+	content, err := x.open(entry)
+	if err != nil {
+		return false, err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer content.Close()
+
+	payload, err := decompressPayload(bufio.NewReader(content))
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", entry.name, err)
+	}
+	more, err := walkCpio(ctx, payload, visit)
+	if err == nil && more {
+		// the checksums of the compressed streams follow the cpio trailer, so reading to their
+		// end confirms the payload is intact
+		_, err = io.Copy(ioutil.Discard, payload)
+		if err == nil {
+			_, err = io.Copy(ioutil.Discard, content)
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", entry.name, err)
+	}
+	return more, nil
+}
+
+// decompressPayload detects how the payload is compressed, which is gzip or, for newer packages, pbzx
+func decompressPayload(r *bufio.Reader) (io.Reader, error) {
+	magic, err := r.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case magic[0] == 0x1f && magic[1] == 0x8b:
+		return gzip.NewReader(r)
+	case string(magic) == "pbzx":
+		_, _ = r.Discard(12)
+		return &pbzxReader{r: r}, nil
+	default:
+		return r, nil
+	}
+}
+
+// pbzxReader reads a pbzx stream, which is a series of chunks that are each an xz stream or, when
+// compression wouldn't reduce their size, stored as is
+type pbzxReader struct {
+	r     io.Reader
+	chunk []byte
+	done  bool
+}
+
+func (p *pbzxReader) Read(b []byte) (int, error) {
+	for len(p.chunk) == 0 {
+		if p.done {
+			return 0, io.EOF
+		}
+		var header [16]byte
+		_, err := io.ReadFull(p.r, header[:])
+		if err == io.EOF {
+			p.done = true
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		flags := binary.BigEndian.Uint64(header[:8])
+		length := binary.BigEndian.Uint64(header[8:])
+		if length > pbzxMaxChunkSize {
+			return 0, errors.New("invalid pbzx chunk")
+		}
+		chunk := make([]byte, length)
+		_, err = io.ReadFull(p.r, chunk)
+		if err != nil {
+			return 0, err
+		}
+		if bytes.HasPrefix(chunk, []byte("\xfd7zXZ\x00")) {
+			chunk, err = xz.Decompress(chunk, pbzxMaxChunkSize)
+			if err != nil {
+				return 0, err
+			}
+		}
+		p.chunk = chunk
+		// the flags of the last chunk don't mark another to follow
+		p.done = flags&(1<<24) == 0
+	}
+	n := copy(b, p.chunk)
+	p.chunk = p.chunk[n:]
+	return n, nil
+}
+
+// walkCpio visits the regular files of a cpio archive in the odc or newc format
+func walkCpio(ctx context.Context, r io.Reader,
+	visit func(name string, info os.FileInfo, content io.Reader) (bool, error)) (bool, error) {
+
+	br := bufio.NewReader(r)
+	for {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		header, err := readCpioHeader(br)
+		if err != nil {
+			return false, err
+		}
+		if header.name == "TRAILER!!!" {
+			return true, nil
+		}
+
+		content := io.LimitReader(br, header.size)
+		if header.mode&0170000 == 0100000 {
+			name := NormalizeEntryName(path.Clean("/" + header.name))
+			more, err := visit(name, &cpioFileInfo{name: name, header: header}, content)
+			if !more || err != nil {
+				return more, err
+			}
+		}
+		_, err = io.Copy(ioutil.Discard, content)
+		if err != nil {
+			return false, err
+		}
+		_, err = br.Discard(header.padding)
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+type cpioHeader struct {
+	name    string
+	mode    int64
+	mtime   int64
+	size    int64
+	padding int
+}
+
+func readCpioHeader(r *bufio.Reader) (*cpioHeader, error) {
+	peeked, err := r.Peek(6)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpio archive: %w", err)
+	}
+	magic := string(peeked)
+
+	var fields []int64
+	var nameSize int64
+	header := &cpioHeader{}
+	switch magic {
+	case "070707":
+		// odc, where each field is octal
+		raw := make([]byte, 76)
+		_, err = io.ReadFull(r, raw)
+		if err == nil {
+			fields, err = parseCpioFields(raw[6:], []int{6, 6, 6, 6, 6, 6, 6, 11, 6, 11}, 8)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpio header: %w", err)
+		}
+		header.mode, header.mtime, nameSize, header.size = fields[2], fields[7], fields[8], fields[9]
+	case "070701", "070702":
+		// newc, where each field is hex and the name and content are padded to four bytes
+		raw := make([]byte, 110)
+		_, err = io.ReadFull(r, raw)
+		if err == nil {
+			fields, err = parseCpioFields(raw[6:], []int{8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8}, 16)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpio header: %w", err)
+		}
+		header.mode, header.mtime, header.size, nameSize = fields[1], fields[5], fields[6], fields[11]
+		header.padding = int((4 - header.size%4) % 4)
+	default:
+		return nil, errors.New("invalid cpio archive")
+	}
+	if nameSize <= 0 || nameSize > 4096 || header.size < 0 {
+		return nil, errors.New("invalid cpio header")
+	}
+
+	name := make([]byte, nameSize)
+	_, err = io.ReadFull(r, name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpio header: %w", err)
+	}
+	header.name = string(bytes.TrimRight(name, "\x00"))
+	if magic != "070707" {
+		_, err = r.Discard(int((4 - (110+nameSize)%4) % 4))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpio header: %w", err)
+		}
+	}
+	return header, nil
+}
+
+func parseCpioFields(raw []byte, widths []int, base int) ([]int64, error) {
+	fields := make([]int64, len(widths))
+	pos := 0
+	for i, width := range widths {
+		value, err := strconv.ParseInt(string(raw[pos:pos+width]), base, 64)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = value
+		pos += width
+	}
+	return fields, nil
+}
+
+// cpioFileInfo describes a regular file of a cpio archive
+type cpioFileInfo struct {
+	name   string
+	header *cpioHeader
+}
+
+func (i *cpioFileInfo) Name() string       { return path.Base(i.name) }
+func (i *cpioFileInfo) Size() int64        { return i.header.size }
+func (i *cpioFileInfo) Mode() os.FileMode  { return os.FileMode(i.header.mode) & os.ModePerm }
+func (i *cpioFileInfo) ModTime() time.Time { return time.Unix(i.header.mtime, 0) }
+func (i *cpioFileInfo) IsDir() bool        { return false }
+func (i *cpioFileInfo) Sys() interface{}   { return nil }
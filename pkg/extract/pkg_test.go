@@ -0,0 +1,134 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The packages in testdata were created by bsdtar --format xar from a Tool.pkg component, whose
+// Payload is a cpio archive of usr/local/bin/tool and usr/local/share/README. That of tool.pkg
+// is compressed with gzip and that of tool-pbzx.pkg is pbzx, with one xz chunk and one stored chunk.
+
+var (
+	pkgTestTool    = []byte("#!/bin/sh\necho tool\n")
+	pkgTestModTime = time.Unix(1600000000, 0)
+)
+
+func openTestdata(t *testing.T, name string) *os.File {
+	archive, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		//noinspection GoUnhandledErrorResult
+		archive.Close()
+	})
+	return archive
+}
+
+func TestPkgListAndExtract(t *testing.T) {
+	for _, name := range []string{"tool.pkg", "tool-pbzx.pkg"} {
+		t.Run(name, func(t *testing.T) {
+			archive := openTestdata(t, name)
+			ctx := context.Background()
+
+			names, err := (&pkgExtractor{}).List(ctx, archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := []string{"usr/local/bin/tool", "usr/local/share/README"}; !reflect.DeepEqual(names, expected) {
+				t.Errorf("listed %v rather than %v", names, expected)
+			}
+
+			err = Extract(ctx, Pkg, archive, "usr/local/bin/tool", func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+				if info.Mode() != 0755 {
+					t.Errorf("extracted with mode %v", info.Mode())
+				}
+				if !info.ModTime().Equal(pkgTestModTime) {
+					t.Errorf("extracted with modification time %v", info.ModTime())
+				}
+				data, err := ioutil.ReadAll(content)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(data, pkgTestTool) {
+					t.Errorf("extracted %q", data)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = Extract(ctx, Pkg, archive, "usr/local/bin/missing", func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+				t.Errorf("extracted %s", name)
+				return nil
+			})
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("extracting a missing file gave %v", err)
+			}
+		})
+	}
+}
+
+func TestPkgTruncated(t *testing.T) {
+	for _, name := range []string{"tool.pkg", "tool-pbzx.pkg"} {
+		content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive := writeTestArchive(t, name, content)
+		x, err := openXar(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the archive ends with unused space after the content of its last entry
+		var end int64
+		for _, entry := range x.entries {
+			if entryEnd := x.heapStart + entry.data.Offset + entry.data.Length; entryEnd > end {
+				end = entryEnd
+			}
+		}
+		for size := int(end) - 1; size >= 0; size-- {
+			err = archive.Truncate(int64(size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = (&pkgExtractor{}).List(context.Background(), archive)
+			if err == nil {
+				t.Errorf("listing %s truncated to %d bytes succeeded", name, size)
+			}
+		}
+	}
+}
+
+// TestPkgCorrupt alters each byte of the package in turn, which must fail or succeed but never panic
+func TestPkgCorrupt(t *testing.T) {
+	for _, name := range []string{"tool.pkg", "tool-pbzx.pkg"} {
+		content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive := writeTestArchive(t, name, content)
+		ctx := context.Background()
+		for i := range content {
+			_, err = archive.WriteAt([]byte{content[i] ^ 0xff}, int64(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = (&pkgExtractor{}).List(ctx, archive)
+			_, err = archive.WriteAt(content[i:i+1], int64(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,565 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/itzg/easy-add/internal/xz"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+const (
+	squashfsMagic         = 0x73717368
+	squashfsMetadataSize  = 8192
+	squashfsNoFragment    = 0xffffffff
+	squashfsUncompressed  = 1 << 24
+	squashfsMetadataPlain = 1 << 15
+
+	squashfsDirType     = 1
+	squashfsFileType    = 2
+	squashfsExtDirType  = 8
+	squashfsExtFileType = 9
+)
+
+var squashfsCompressors = map[uint16]string{
+	1: "gzip", 2: "lzma", 3: "lzo", 4: "xz", 5: "lz4", 6: "zstd",
+}
+
+// squashfsSuperblock is the header of a squashfs 4.0 filesystem
+type squashfsSuperblock struct {
+	Magic               uint32
+	InodeCount          uint32
+	ModTime             uint32
+	BlockSize           uint32
+	FragmentCount       uint32
+	Compressor          uint16
+	BlockLog            uint16
+	Flags               uint16
+	IDCount             uint16
+	VersionMajor        uint16
+	VersionMinor        uint16
+	RootInode           uint64
+	BytesUsed           uint64
+	IDTableStart        uint64
+	XattrIDTableStart   uint64
+	InodeTableStart     uint64
+	DirectoryTableStart uint64
+	FragmentTableStart  uint64
+	ExportTableStart    uint64
+}
+
+// squashfs reads the files of a squashfs filesystem, where every offset is relative to the
+// start of r
+type squashfs struct {
+	r          io.ReaderAt
+	super      squashfsSuperblock
+	decompress func(compressed []byte, limit int) ([]byte, error)
+}
+
+// squashfsInode is the part of an inode needed to list directories and read files
+type squashfsInode struct {
+	kind  uint16
+	mode  os.FileMode
+	mtime time.Time
+	// size of a file or, for a directory, its listing
+	size uint64
+	// blocksStart of a file or, for a directory, the metadata block of its listing
+	blocksStart    uint64
+	blockSizes     []uint32
+	fragment       uint32
+	fragmentOffset uint32
+	// listingOffset is where the listing of a directory starts within its metadata block
+	listingOffset uint16
+}
+
+func openSquashfs(r io.ReaderAt) (*squashfs, error) {
+	fs := &squashfs{r: r}
+	err := binary.Read(io.NewSectionReader(r, 0, 96), binary.LittleEndian, &fs.super)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read squashfs superblock: %w", err)
+	}
+	if fs.super.Magic != squashfsMagic {
+		return nil, errors.New("not a squashfs filesystem")
+	}
+	if fs.super.VersionMajor != 4 {
+		return nil, fmt.Errorf("unsupported squashfs version %d.%d", fs.super.VersionMajor, fs.super.VersionMinor)
+	}
+	if fs.super.BlockSize == 0 || fs.super.BlockSize > 1<<20 {
+		return nil, fmt.Errorf("invalid squashfs block size %d", fs.super.BlockSize)
+	}
+	var last [1]byte
+	if fs.super.BytesUsed < 96 {
+		return nil, errors.New("invalid squashfs superblock")
+	} else if _, err := r.ReadAt(last[:], int64(fs.super.BytesUsed)-1); err != nil {
+		return nil, errors.New("the squashfs filesystem is truncated")
+	}
+
+	switch fs.super.Compressor {
+	case 1:
+		fs.decompress = inflateZlib
+	case 4:
+		fs.decompress = xz.Decompress
+	default:
+		name, known := squashfsCompressors[fs.super.Compressor]
+		if !known {
+			name = fmt.Sprintf("type %d", fs.super.Compressor)
+		}
+		return nil, fmt.Errorf("squashfs compressed with %s is not supported", name)
+	}
+	return fs, nil
+}
+
+func inflateZlib(compressed []byte, limit int) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer zr.Close()
+	content, err := ioutil.ReadAll(io.LimitReader(zr, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > limit {
+		return nil, errors.New("block exceeds its maximum size")
+	}
+	return content, nil
+}
+
+// walk visits each regular file of the filesystem along with its path, stopping when visit
+// returns false
+func (fs *squashfs) walk(ctx context.Context, visit func(name string, inode *squashfsInode) (bool, error)) error {
+	root, err := fs.readInode(fs.super.RootInode)
+	if err != nil {
+		return err
+	}
+	visited := map[uint64]bool{fs.super.RootInode: true}
+	_, err = fs.walkDir(ctx, "", root, visit, visited, 0)
+	return err
+}
+
+// walkDir visits the files within the directory, where visited holds the directories already
+// walked, which a corrupt filesystem could otherwise lead back to
+func (fs *squashfs) walkDir(ctx context.Context, dir string, inode *squashfsInode,
+	visit func(name string, inode *squashfsInode) (bool, error), visited map[uint64]bool, depth int) (bool, error) {
+
+	if depth > 256 {
+		return false, errors.New("squashfs directories are nested too deeply")
+	}
+	entries, err := fs.readDir(inode)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		child, err := fs.readInode(entry.inodeRef)
+		if err != nil {
+			return false, err
+		}
+		name := path.Join(dir, entry.name)
+		switch child.kind {
+		case squashfsDirType, squashfsExtDirType:
+			if visited[entry.inodeRef] {
+				return false, errors.New("invalid squashfs directory, it contains itself")
+			}
+			visited[entry.inodeRef] = true
+			more, err := fs.walkDir(ctx, name, child, visit, visited, depth+1)
+			if !more || err != nil {
+				return more, err
+			}
+		case squashfsFileType, squashfsExtFileType:
+			more, err := visit(name, child)
+			if !more || err != nil {
+				return more, err
+			}
+		}
+	}
+	return true, nil
+}
+
+type squashfsDirEntry struct {
+	name     string
+	inodeRef uint64
+}
+
+func (fs *squashfs) readDir(inode *squashfsInode) ([]squashfsDirEntry, error) {
+	// the recorded size includes three bytes for the . and .. entries that aren't stored
+	if inode.size <= 3 {
+		return nil, nil
+	}
+	r, err := fs.metadata(fs.super.DirectoryTableStart+inode.blocksStart, inode.listingOffset)
+	if err != nil {
+		return nil, err
+	}
+	// the listing is read as it decompresses, rather than allocated up front from its recorded size
+	listing, err := ioutil.ReadAll(io.LimitReader(r, int64(inode.size-3)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read squashfs directory: %w", err)
+	}
+	if uint64(len(listing)) < inode.size-3 {
+		return nil, fmt.Errorf("failed to read squashfs directory: %w", io.ErrUnexpectedEOF)
+	}
+
+	var entries []squashfsDirEntry
+	lr := bytes.NewReader(listing)
+	for lr.Len() > 0 {
+		var header struct {
+			Count      uint32
+			Start      uint32
+			InodeStart uint32
+		}
+		err = binary.Read(lr, binary.LittleEndian, &header)
+		if err != nil {
+			return nil, fmt.Errorf("invalid squashfs directory: %w", err)
+		}
+		for i := uint32(0); i <= header.Count; i++ {
+			var entry struct {
+				Offset      uint16
+				InodeOffset int16
+				Type        uint16
+				NameSize    uint16
+			}
+			err = binary.Read(lr, binary.LittleEndian, &entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid squashfs directory: %w", err)
+			}
+			name := make([]byte, int(entry.NameSize)+1)
+			_, err = io.ReadFull(lr, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid squashfs directory: %w", err)
+			}
+			if bytes.IndexByte(name, '/') >= 0 || string(name) == "." || string(name) == ".." {
+				return nil, fmt.Errorf("invalid squashfs entry name %q", name)
+			}
+			entries = append(entries, squashfsDirEntry{
+				name:     string(name),
+				inodeRef: uint64(header.Start)<<16 | uint64(entry.Offset),
+			})
+		}
+	}
+	return entries, nil
+}
+
+func (fs *squashfs) readInode(ref uint64) (*squashfsInode, error) {
+	r, err := fs.metadata(fs.super.InodeTableStart+(ref>>16), uint16(ref&0xffff))
+	if err != nil {
+		return nil, err
+	}
+
+	var header struct {
+		Type        uint16
+		Permissions uint16
+		UID         uint16
+		GID         uint16
+		ModTime     uint32
+		InodeNumber uint32
+	}
+	err = binary.Read(r, binary.LittleEndian, &header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid squashfs inode: %w", err)
+	}
+	inode := &squashfsInode{
+		kind:  header.Type,
+		mode:  os.FileMode(header.Permissions) & os.ModePerm,
+		mtime: time.Unix(int64(header.ModTime), 0),
+	}
+
+	switch header.Type {
+	case squashfsDirType:
+		var dir struct {
+			BlockIndex  uint32
+			LinkCount   uint32
+			FileSize    uint16
+			BlockOffset uint16
+			ParentInode uint32
+		}
+		err = binary.Read(r, binary.LittleEndian, &dir)
+		inode.size, inode.blocksStart, inode.listingOffset = uint64(dir.FileSize), uint64(dir.BlockIndex), dir.BlockOffset
+		inode.mode |= os.ModeDir
+	case squashfsExtDirType:
+		var dir struct {
+			LinkCount   uint32
+			FileSize    uint32
+			BlockIndex  uint32
+			ParentInode uint32
+			IndexCount  uint16
+			BlockOffset uint16
+			XattrIndex  uint32
+		}
+		err = binary.Read(r, binary.LittleEndian, &dir)
+		inode.size, inode.blocksStart, inode.listingOffset = uint64(dir.FileSize), uint64(dir.BlockIndex), dir.BlockOffset
+		inode.mode |= os.ModeDir
+	case squashfsFileType:
+		var file struct {
+			BlocksStart    uint32
+			Fragment       uint32
+			FragmentOffset uint32
+			FileSize       uint32
+		}
+		err = binary.Read(r, binary.LittleEndian, &file)
+		inode.blocksStart, inode.fragment, inode.fragmentOffset, inode.size =
+			uint64(file.BlocksStart), file.Fragment, file.FragmentOffset, uint64(file.FileSize)
+	case squashfsExtFileType:
+		var file struct {
+			BlocksStart    uint64
+			FileSize       uint64
+			Sparse         uint64
+			LinkCount      uint32
+			Fragment       uint32
+			FragmentOffset uint32
+			XattrIndex     uint32
+		}
+		err = binary.Read(r, binary.LittleEndian, &file)
+		inode.blocksStart, inode.fragment, inode.fragmentOffset, inode.size =
+			file.BlocksStart, file.Fragment, file.FragmentOffset, file.FileSize
+	default:
+		return inode, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid squashfs inode: %w", err)
+	}
+
+	if header.Type == squashfsFileType || header.Type == squashfsExtFileType {
+		blockSize := uint64(fs.super.BlockSize)
+		count := inode.size / blockSize
+		if inode.fragment == squashfsNoFragment && inode.size%blockSize != 0 {
+			count++
+		}
+		if count > fs.super.BytesUsed {
+			return nil, errors.New("invalid squashfs file size")
+		}
+		inode.blockSizes = make([]uint32, count)
+		err = binary.Read(r, binary.LittleEndian, inode.blockSizes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid squashfs inode: %w", err)
+		}
+	}
+	return inode, nil
+}
+
+// metadata reads the metadata blocks starting at the given position, from the offset within
+// the first one onward
+func (fs *squashfs) metadata(start uint64, offset uint16) (io.Reader, error) {
+	r := &squashfsMetadataReader{fs: fs, next: start}
+	_, err := io.CopyN(ioutil.Discard, r, int64(offset))
+	if err != nil {
+		return nil, fmt.Errorf("invalid squashfs metadata reference: %w", err)
+	}
+	return r, nil
+}
+
+type squashfsMetadataReader struct {
+	fs   *squashfs
+	next uint64
+	buf  []byte
+}
+
+func (m *squashfsMetadataReader) Read(p []byte) (int, error) {
+	if len(m.buf) == 0 {
+		var header [2]byte
+		_, err := m.fs.r.ReadAt(header[:], int64(m.next))
+		if err != nil {
+			return 0, err
+		}
+		size := binary.LittleEndian.Uint16(header[:])
+		block := make([]byte, size&^squashfsMetadataPlain)
+		_, err = m.fs.r.ReadAt(block, int64(m.next)+2)
+		if err != nil {
+			return 0, err
+		}
+		m.next += 2 + uint64(len(block))
+		if size&squashfsMetadataPlain == 0 {
+			block, err = m.fs.decompress(block, squashfsMetadataSize)
+			if err != nil {
+				return 0, fmt.Errorf("failed to decompress squashfs metadata: %w", err)
+			}
+		}
+		if len(block) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		m.buf = block
+	}
+	n := copy(p, m.buf)
+	m.buf = m.buf[n:]
+	return n, nil
+}
+
+// open provides the content of a regular file
+func (fs *squashfs) open(inode *squashfsInode) io.Reader {
+	return &squashfsFileReader{fs: fs, inode: inode, pos: inode.blocksStart, remaining: inode.size}
+}
+
+type squashfsFileReader struct {
+	fs        *squashfs
+	inode     *squashfsInode
+	block     int
+	pos       uint64
+	remaining uint64
+	buf       []byte
+}
+
+func (f *squashfsFileReader) Read(p []byte) (int, error) {
+	for len(f.buf) == 0 {
+		if f.remaining == 0 {
+			return 0, io.EOF
+		}
+		var err error
+		if f.block < len(f.inode.blockSizes) {
+			f.buf, err = f.readBlock(f.inode.blockSizes[f.block])
+			f.block++
+		} else if f.inode.fragment != squashfsNoFragment {
+			f.buf, err = f.readFragment()
+		} else {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		if uint64(len(f.buf)) > f.remaining {
+			f.buf = f.buf[:f.remaining]
+		}
+		f.remaining -= uint64(len(f.buf))
+	}
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	return n, nil
+}
+
+func (f *squashfsFileReader) readBlock(sizeWord uint32) ([]byte, error) {
+	blockSize := int(f.fs.super.BlockSize)
+	size := sizeWord &^ squashfsUncompressed
+	if size == 0 {
+		// a sparse block of zeros
+		return make([]byte, blockSize), nil
+	}
+	block, err := f.fs.readData(f.pos, sizeWord)
+	f.pos += uint64(size)
+	return block, err
+}
+
+func (f *squashfsFileReader) readFragment() ([]byte, error) {
+	var entry struct {
+		Start  uint64
+		Size   uint32
+		Unused uint32
+	}
+	index := uint64(f.inode.fragment)
+	if index >= uint64(f.fs.super.FragmentCount) {
+		return nil, errors.New("invalid squashfs fragment")
+	}
+	var pointer [8]byte
+	_, err := f.fs.r.ReadAt(pointer[:], int64(f.fs.super.FragmentTableStart+index/512*8))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read squashfs fragment table: %w", err)
+	}
+	r, err := f.fs.metadata(binary.LittleEndian.Uint64(pointer[:]), uint16(index%512*16))
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Read(r, binary.LittleEndian, &entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read squashfs fragment table: %w", err)
+	}
+
+	block, err := f.fs.readData(entry.Start, entry.Size)
+	if err != nil {
+		return nil, err
+	}
+	tail := f.remaining
+	if uint64(f.inode.fragmentOffset)+tail > uint64(len(block)) {
+		return nil, errors.New("invalid squashfs fragment")
+	}
+	return block[f.inode.fragmentOffset : uint64(f.inode.fragmentOffset)+tail], nil
+}
+
+// readData reads a data or fragment block, which is compressed unless marked otherwise by its size word
+func (fs *squashfs) readData(pos uint64, sizeWord uint32) ([]byte, error) {
+	size := sizeWord &^ squashfsUncompressed
+	if size > fs.super.BlockSize+fs.super.BlockSize/2 {
+		return nil, errors.New("invalid squashfs block size")
+	}
+	block := make([]byte, size)
+	_, err := fs.r.ReadAt(block, int64(pos))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read squashfs block: %w", err)
+	}
+	if sizeWord&squashfsUncompressed != 0 {
+		return block, nil
+	}
+	block, err = fs.decompress(block, int(fs.super.BlockSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress squashfs block: %w", err)
+	}
+	return block, nil
+}
+
+// squashfsFileInfo describes a regular file of a squashfs filesystem
+type squashfsFileInfo struct {
+	name  string
+	inode *squashfsInode
+}
+
+func (i *squashfsFileInfo) Name() string       { return path.Base(i.name) }
+func (i *squashfsFileInfo) Size() int64        { return int64(i.inode.size) }
+func (i *squashfsFileInfo) Mode() os.FileMode  { return i.inode.mode }
+func (i *squashfsFileInfo) ModTime() time.Time { return i.inode.mtime }
+func (i *squashfsFileInfo) IsDir() bool        { return false }
+func (i *squashfsFileInfo) Sys() interface{}   { return nil }
+
+// extractFromSquashfs locates the requested file within the squashfs filesystem and passes its
+// content to the handler
+func extractFromSquashfs(ctx context.Context, r io.ReaderAt, file string, handler Handler) error {
+	fs, err := openSquashfs(r)
+	if err != nil {
+		return err
+	}
+	found := false
+	err = fs.walk(ctx, func(name string, inode *squashfsInode) (bool, error) {
+		if !EntryMatches(name, file) {
+			return true, nil
+		}
+		found = true
+		return false, handler(ctx, name, &squashfsFileInfo{name: name, inode: inode}, fs.open(inode))
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// listSquashfs returns the paths of the regular files within the squashfs filesystem
+func listSquashfs(ctx context.Context, r io.ReaderAt) ([]string, error) {
+	fs, err := openSquashfs(r)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	err = fs.walk(ctx, func(name string, inode *squashfsInode) (bool, error) {
+		names = append(names, name)
+		return true, nil
+	})
+	return names, err
+}
+
+// squashfsExtractor handles squashfs filesystems, such as snaps
+type squashfsExtractor struct{}
+
+func (s *squashfsExtractor) Extract(ctx context.Context, archive *os.File, file string, handler Handler) error {
+	return extractFromSquashfs(ctx, archive, file, handler)
+}
+
+func (s *squashfsExtractor) List(ctx context.Context, archive *os.File) ([]string, error) {
+	return listSquashfs(ctx, archive)
+}
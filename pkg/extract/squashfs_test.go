@@ -0,0 +1,294 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	squashfsTestBlockSize = 4096
+	squashfsTestModTime   = 1600000000
+)
+
+var (
+	// squashfsTestBlock is the content of the xz stream in testdata/squashfs-block.xz
+	squashfsTestBlock  = bytes.Repeat([]byte("0123456789abcdef"), squashfsTestBlockSize/16)
+	squashfsTestTail   = []byte("the end of the tool\n")
+	squashfsTestReadme = []byte("read me\n")
+)
+
+// squashfsTestCompressor provides what the image stores for a block and whether it is compressed
+type squashfsTestCompressor func(t *testing.T, block []byte) ([]byte, bool)
+
+func squashfsTestZlib(t *testing.T, block []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := zw.Write(block)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), true
+}
+
+// squashfsTestXz only has the one block compressed by the xz command, so stores any other uncompressed
+func squashfsTestXz(t *testing.T, block []byte) ([]byte, bool) {
+	if !bytes.Equal(block, squashfsTestBlock) {
+		return block, false
+	}
+	stored, err := ioutil.ReadFile(filepath.Join("testdata", "squashfs-block.xz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored, true
+}
+
+type squashfsTestInodeHeader struct {
+	Type        uint16
+	Permissions uint16
+	UID         uint16
+	GID         uint16
+	ModTime     uint32
+	InodeNumber uint32
+}
+
+// squashfsTestImage builds a filesystem of README, stored in an uncompressed block, and bin/tool,
+// stored in a full block and a fragment, where README has an extended inode
+func squashfsTestImage(t *testing.T, compressor uint16, compress squashfsTestCompressor) []byte {
+	var image bytes.Buffer
+	image.Write(make([]byte, 96))
+	write := func(w io.Writer, values ...interface{}) {
+		for _, value := range values {
+			err := binary.Write(w, binary.LittleEndian, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	sizeWord := func(stored []byte, compressed bool) uint32 {
+		if compressed {
+			return uint32(len(stored))
+		}
+		return uint32(len(stored)) | squashfsUncompressed
+	}
+	metadata := func(data []byte) []byte {
+		stored, compressed := compress(t, data)
+		header := uint16(len(stored))
+		if !compressed {
+			header |= squashfsMetadataPlain
+		}
+		block := make([]byte, 2, 2+len(stored))
+		binary.LittleEndian.PutUint16(block, header)
+		return append(block, stored...)
+	}
+
+	toolStart := image.Len()
+	stored, compressed := compress(t, squashfsTestBlock)
+	image.Write(stored)
+	toolBlock := sizeWord(stored, compressed)
+
+	fragmentStart := image.Len()
+	stored, compressed = compress(t, squashfsTestTail)
+	image.Write(stored)
+	fragmentSize := sizeWord(stored, compressed)
+
+	readmeStart := image.Len()
+	image.Write(squashfsTestReadme)
+
+	var inodes bytes.Buffer
+	toolRef := inodes.Len()
+	write(&inodes, squashfsTestInodeHeader{Type: squashfsFileType, Permissions: 0755, ModTime: squashfsTestModTime, InodeNumber: 1},
+		struct{ BlocksStart, Fragment, FragmentOffset, FileSize uint32 }{
+			uint32(toolStart), 0, 0, uint32(len(squashfsTestBlock) + len(squashfsTestTail)),
+		},
+		[]uint32{toolBlock})
+	readmeRef := inodes.Len()
+	write(&inodes, squashfsTestInodeHeader{Type: squashfsExtFileType, Permissions: 0644, ModTime: squashfsTestModTime, InodeNumber: 2},
+		struct {
+			BlocksStart, FileSize, Sparse                   uint64
+			LinkCount, Fragment, FragmentOffset, XattrIndex uint32
+		}{uint64(readmeStart), uint64(len(squashfsTestReadme)), 0, 1, squashfsNoFragment, 0, 0xffffffff},
+		[]uint32{uint32(len(squashfsTestReadme)) | squashfsUncompressed})
+	// the directory inodes follow, each of which is 32 bytes
+	binRef := inodes.Len()
+	rootRef := binRef + 32
+
+	type dirHeader struct{ Count, Start, InodeStart uint32 }
+	type dirEntry struct {
+		Offset      uint16
+		InodeOffset int16
+		Type        uint16
+		NameSize    uint16
+	}
+	var listings bytes.Buffer
+	write(&listings, dirHeader{0, 0, 1}, dirEntry{uint16(toolRef), 0, squashfsFileType, 3}, []byte("tool"))
+	binListing := listings.Len()
+	write(&listings, dirHeader{1, 0, 2},
+		dirEntry{uint16(readmeRef), 0, squashfsFileType, 5}, []byte("README"),
+		dirEntry{uint16(binRef), 1, squashfsDirType, 2}, []byte("bin"))
+
+	type dirInode struct {
+		BlockIndex  uint32
+		LinkCount   uint32
+		FileSize    uint16
+		BlockOffset uint16
+		ParentInode uint32
+	}
+	write(&inodes,
+		squashfsTestInodeHeader{Type: squashfsDirType, Permissions: 0755, ModTime: squashfsTestModTime, InodeNumber: 3},
+		dirInode{0, 2, uint16(binListing + 3), 0, 4},
+		squashfsTestInodeHeader{Type: squashfsDirType, Permissions: 0755, ModTime: squashfsTestModTime, InodeNumber: 4},
+		dirInode{0, 3, uint16(listings.Len() - binListing + 3), uint16(binListing), 5})
+
+	super := squashfsSuperblock{
+		Magic:             squashfsMagic,
+		InodeCount:        4,
+		ModTime:           squashfsTestModTime,
+		BlockSize:         squashfsTestBlockSize,
+		FragmentCount:     1,
+		Compressor:        compressor,
+		BlockLog:          12,
+		IDCount:           1,
+		VersionMajor:      4,
+		RootInode:         uint64(rootRef),
+		XattrIDTableStart: 0xffffffffffffffff,
+		ExportTableStart:  0xffffffffffffffff,
+	}
+	super.InodeTableStart = uint64(image.Len())
+	image.Write(metadata(inodes.Bytes()))
+	super.DirectoryTableStart = uint64(image.Len())
+	image.Write(metadata(listings.Bytes()))
+
+	var fragments bytes.Buffer
+	write(&fragments, struct {
+		Start        uint64
+		Size, Unused uint32
+	}{uint64(fragmentStart), fragmentSize, 0})
+	fragmentTable := image.Len()
+	image.Write(metadata(fragments.Bytes()))
+	super.FragmentTableStart = uint64(image.Len())
+	write(&image, uint64(fragmentTable))
+
+	idTable := image.Len()
+	image.Write(metadata(make([]byte, 4)))
+	super.IDTableStart = uint64(image.Len())
+	write(&image, uint64(idTable))
+
+	super.BytesUsed = uint64(image.Len())
+	var header bytes.Buffer
+	write(&header, super)
+	content := image.Bytes()
+	copy(content, header.Bytes())
+	return content
+}
+
+func TestSquashfsListAndExtract(t *testing.T) {
+	tests := []struct {
+		name       string
+		compressor uint16
+		compress   squashfsTestCompressor
+	}{
+		{name: "gzip", compressor: 1, compress: squashfsTestZlib},
+		{name: "xz", compressor: 4, compress: squashfsTestXz},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			image := bytes.NewReader(squashfsTestImage(t, test.compressor, test.compress))
+			ctx := context.Background()
+
+			names, err := listSquashfs(ctx, image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := []string{"README", "bin/tool"}; !reflect.DeepEqual(names, expected) {
+				t.Errorf("listed %v rather than %v", names, expected)
+			}
+
+			for _, file := range []struct {
+				name    string
+				mode    os.FileMode
+				content []byte
+			}{
+				{name: "bin/tool", mode: 0755, content: append(append([]byte(nil), squashfsTestBlock...), squashfsTestTail...)},
+				{name: "README", mode: 0644, content: squashfsTestReadme},
+			} {
+				err = extractFromSquashfs(ctx, image, file.name, func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+					if info.Mode() != file.mode {
+						t.Errorf("%s extracted with mode %v", name, info.Mode())
+					}
+					if !info.ModTime().Equal(time.Unix(squashfsTestModTime, 0)) {
+						t.Errorf("%s extracted with modification time %v", name, info.ModTime())
+					}
+					data, err := ioutil.ReadAll(content)
+					if err != nil {
+						return err
+					}
+					if !bytes.Equal(data, file.content) {
+						t.Errorf("%s extracted as %q", name, data)
+					}
+					return nil
+				})
+				if err != nil {
+					t.Errorf("%s: %v", file.name, err)
+				}
+			}
+
+			err = extractFromSquashfs(ctx, image, "bin/missing", func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+				t.Errorf("extracted %s", name)
+				return nil
+			})
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("extracting a missing file gave %v", err)
+			}
+		})
+	}
+}
+
+func TestSquashfsUnsupportedCompressor(t *testing.T) {
+	image := squashfsTestImage(t, 6, squashfsTestZlib)
+	_, err := listSquashfs(context.Background(), bytes.NewReader(image))
+	if err == nil || !strings.Contains(err.Error(), "zstd is not supported") {
+		t.Errorf("listing a zstd filesystem gave %v", err)
+	}
+}
+
+func TestSquashfsTruncated(t *testing.T) {
+	image := squashfsTestImage(t, 1, squashfsTestZlib)
+	for size := 0; size < len(image); size++ {
+		_, err := listSquashfs(context.Background(), bytes.NewReader(image[:size]))
+		if err == nil {
+			t.Errorf("listing the filesystem truncated to %d bytes succeeded", size)
+		}
+	}
+}
+
+// TestSquashfsCorrupt alters each byte of the filesystem in turn, which must fail or succeed but
+// never panic
+func TestSquashfsCorrupt(t *testing.T) {
+	image := squashfsTestImage(t, 1, squashfsTestZlib)
+	ctx := context.Background()
+	for i := range image {
+		for _, mask := range []byte{0x01, 0x80, 0xff} {
+			corrupt := append([]byte(nil), image...)
+			corrupt[i] ^= mask
+			_, _ = listSquashfs(ctx, bytes.NewReader(corrupt))
+			_ = extractFromSquashfs(ctx, bytes.NewReader(corrupt), "bin/tool", func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+				_, err := io.Copy(ioutil.Discard, content)
+				return err
+			})
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package extract
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type tarGzExtractor struct{}
+
+func (t *tarGzExtractor) Extract(ctx context.Context, archive *os.File, file string, handler Handler) error {
+	found := false
+	err := readTarGz(archive, func(header *tar.Header, content io.Reader) (bool, error) {
+		if !EntryMatches(header.Name, file) {
+			return true, nil
+		}
+		found = true
+		return false, handler(ctx, header.Name, header.FileInfo(), content)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ExtractAll reads the tar stream until every requested file has been passed to the handler,
+// rather than once for each of them
+func (t *tarGzExtractor) ExtractAll(ctx context.Context, archive *os.File, files []string, handler Handler) error {
+	remaining := make(map[string]bool, len(files))
+	for _, file := range files {
+		remaining[file] = true
+	}
+	err := readTarGz(archive, func(header *tar.Header, content io.Reader) (bool, error) {
+		for file := range remaining {
+			if EntryMatches(header.Name, file) {
+				delete(remaining, file)
+				return len(remaining) > 0, handler(ctx, header.Name, header.FileInfo(), content)
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(remaining) > 0 {
+		missing := make([]string, 0, len(remaining))
+		for file := range remaining {
+			missing = append(missing, file)
+		}
+		sort.Strings(missing)
+		return fmt.Errorf("%w: %s", ErrNotFound, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (t *tarGzExtractor) List(ctx context.Context, archive *os.File) ([]string, error) {
+	var names []string
+	err := readTarGz(archive, func(header *tar.Header, content io.Reader) (bool, error) {
+		if header.Typeflag == tar.TypeReg {
+			names = append(names, header.Name)
+		}
+		return true, nil
+	})
+	return names, err
+}
+
+// tarBlockSize is the size of the records of a tar stream, including its end-of-archive marker
+const tarBlockSize = 512
+
+// readTarGz passes each entry of the gzipped tar stream to visit until it returns false. As
+// with the --ignore-zeros option of GNU tar, the entries of tar archives concatenated after the
+// end-of-archive marker of the first, such as by cat a.tar.gz b.tar.gz, are read too.
+func readTarGz(archive io.Reader, visit func(header *tar.Header, content io.Reader) (bool, error)) error {
+	counted := &countingReader{Reader: archive}
+	gzipReader, err := newGzipReader(counted)
+	if err != nil {
+		return corruptTarGz(err, counted, nil)
+	}
+
+	stream := bufio.NewReader(gzipReader)
+	var entry *tarEntryReader
+	for {
+		tarReader := tar.NewReader(stream)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return corruptTarGz(fmt.Errorf("failed to read tar content: %w", err), counted, entry)
+			}
+
+			entry = &tarEntryReader{Reader: tarReader, header: header}
+			more, err := visit(header, entry)
+			if err != nil && entry.err != nil {
+				// the handler failed since the content couldn't be read
+				return corruptTarGz(entry.err, counted, entry)
+			}
+			if err != nil || !more {
+				return err
+			}
+		}
+
+		more, err := skipTarPadding(stream)
+		if err != nil {
+			return corruptTarGz(err, counted, entry)
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// skipTarPadding skips the zero blocks that follow an end-of-archive marker and reports if
+// another tar archive follows them
+func skipTarPadding(stream *bufio.Reader) (bool, error) {
+	zeros := make([]byte, tarBlockSize)
+	for {
+		block, err := stream.Peek(tarBlockSize)
+		if err == io.EOF {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("failed to read tar content: %w", err)
+		}
+		if !bytes.Equal(block, zeros) {
+			return true, nil
+		}
+		_, err = stream.Discard(tarBlockSize)
+		if err != nil {
+			return false, err
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package extract
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// whlExtractor handles Python wheels, which are zip archives. Tools distributed on PyPI with a
+// native executable, such as ruff and uv, place it in the scripts directory of the wheel's data,
+// such as ruff-0.4.0.data/scripts/ruff, so a requested file that is only a name, such as ruff,
+// is also located there. Files of that directory are installed as executables, as pip would.
+type whlExtractor struct{}
+
+func (w *whlExtractor) Extract(ctx context.Context, archive *os.File, file string, handler Handler) error {
+	files, err := openZipFiles(archive)
+	if err != nil {
+		return err
+	}
+
+	zipFile := findWheelEntry(files, file)
+	if zipFile == nil {
+		return ErrNotFound
+	}
+	if !isWheelScript(zipFile.Name) {
+		return extractFromZip(ctx, zipFile, handler)
+	}
+	return extractFromZip(ctx, zipFile, func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+		return handler(ctx, name, &wheelScriptInfo{info}, content)
+	})
+}
+
+func (w *whlExtractor) List(ctx context.Context, archive *os.File) ([]string, error) {
+	return (&zipExtractor{}).List(ctx, archive)
+}
+
+func findWheelEntry(files []*zip.File, file string) *zip.File {
+	for _, zipFile := range files {
+		if EntryMatches(zipFile.Name, file) {
+			return zipFile
+		}
+	}
+	if strings.Contains(NormalizeEntryName(file), "/") {
+		return nil
+	}
+	for _, zipFile := range files {
+		if isWheelScript(zipFile.Name) && EntryMatches(path.Base(zipFile.Name), file) {
+			return zipFile
+		}
+	}
+	return nil
+}
+
+// isWheelScript determines if the entry is within the scripts directory of the wheel's data,
+// which is named like ruff-0.4.0.data/scripts/
+func isWheelScript(name string) bool {
+	parts := strings.Split(NormalizeEntryName(name), "/")
+	return len(parts) == 3 && strings.HasSuffix(parts[0], ".data") && parts[1] == "scripts"
+}
+
+// wheelScriptInfo marks a script of a wheel as executable, since wheels don't always record
+// the permissions of their entries
+type wheelScriptInfo struct {
+	os.FileInfo
+}
+
+func (i *wheelScriptInfo) Mode() os.FileMode {
+	return i.FileInfo.Mode() | 0755
+}
@@ -0,0 +1,116 @@
+package extract
+
+import (
+	"compress/bzip2"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+const (
+	xarMagic = 0x78617221
+	// xarMaxTOCSize bounds the size of the table of contents, which is read into memory
+	xarMaxTOCSize = 64 << 20
+)
+
+// xarFile is an entry of the table of contents of a xar archive
+type xarFile struct {
+	Name  string    `xml:"name"`
+	Type  string    `xml:"type"`
+	Data  *xarData  `xml:"data"`
+	Files []xarFile `xml:"file"`
+}
+
+type xarData struct {
+	Length   int64 `xml:"length"`
+	Offset   int64 `xml:"offset"`
+	Size     int64 `xml:"size"`
+	Encoding struct {
+		Style string `xml:"style,attr"`
+	} `xml:"encoding"`
+}
+
+// xarEntry is a regular file of a xar archive along with its path
+type xarEntry struct {
+	name string
+	data xarData
+}
+
+// xarArchive reads the files of a xar archive, such as a flat macOS installer package
+type xarArchive struct {
+	r         io.ReaderAt
+	heapStart int64
+	entries   []xarEntry
+}
+
+func openXar(archive *os.File) (*xarArchive, error) {
+	header := make([]byte, 28)
+	_, err := archive.ReadAt(header, 0)
+	if err != nil || binary.BigEndian.Uint32(header) != xarMagic {
+		return nil, errors.New("not a xar archive")
+	}
+	headerSize := int64(binary.BigEndian.Uint16(header[4:]))
+	tocLength := binary.BigEndian.Uint64(header[8:])
+	tocSize := binary.BigEndian.Uint64(header[16:])
+	if headerSize < 28 || tocLength > xarMaxTOCSize || tocSize > xarMaxTOCSize {
+		return nil, errors.New("invalid xar header")
+	}
+
+	zr, err := zlib.NewReader(io.NewSectionReader(archive, headerSize, int64(tocLength)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xar table of contents: %w", err)
+	}
+	//noinspection GoUnhandledErrorResult
+	defer zr.Close()
+	var toc struct {
+		Files []xarFile `xml:"toc>file"`
+	}
+	err = xml.NewDecoder(io.LimitReader(zr, int64(tocSize))).Decode(&toc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xar table of contents: %w", err)
+	}
+
+	x := &xarArchive{r: archive, heapStart: headerSize + int64(tocLength)}
+	x.addEntries("", toc.Files, 0)
+	return x, nil
+}
+
+func (x *xarArchive) addEntries(dir string, files []xarFile, depth int) {
+	if depth > 256 {
+		return
+	}
+	for _, file := range files {
+		name := path.Join(dir, file.Name)
+		switch file.Type {
+		case "directory":
+			x.addEntries(name, file.Files, depth+1)
+		case "file":
+			if file.Data != nil {
+				x.entries = append(x.entries, xarEntry{name: name, data: *file.Data})
+			}
+		}
+	}
+}
+
+// open provides the extracted content of the entry
+func (x *xarArchive) open(entry xarEntry) (io.ReadCloser, error) {
+	content := io.NewSectionReader(x.r, x.heapStart+entry.data.Offset, entry.data.Length)
+	switch entry.data.Encoding.Style {
+	case "", "application/octet-stream":
+		return ioutil.NopCloser(content), nil
+	case "application/x-gzip":
+		// despite its name, xar stores zlib streams
+		return zlib.NewReader(content)
+	case "application/x-bzip2":
+		return ioutil.NopCloser(bzip2.NewReader(content)), nil
+	default:
+		return nil, fmt.Errorf("%s of the xar archive is encoded with %s, which is not supported",
+			entry.name, entry.data.Encoding.Style)
+	}
+}
@@ -0,0 +1,75 @@
+package extract
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+)
+
+type zipExtractor struct{}
+
+func (z *zipExtractor) Extract(ctx context.Context, archive *os.File, file string, handler Handler) error {
+	zipFiles, err := openZipFiles(archive)
+	if err != nil {
+		return err
+	}
+
+	for _, zipFile := range zipFiles {
+		if EntryMatches(zipFile.Name, file) {
+			return extractFromZip(ctx, zipFile, handler)
+		}
+	}
+
+	return ErrNotFound
+}
+
+func extractFromZip(ctx context.Context, file *zip.File, handler Handler) error {
+	// bit 0 of the flags marks encrypted entries
+	if file.Flags&0x1 != 0 {
+		r, err := openEncryptedZip(file, PasswordFrom(ctx))
+		if err != nil {
+			return err
+		}
+		return handler(ctx, file.Name, file.FileInfo(), &zipEntryReader{Reader: r, file: file})
+	}
+
+	r, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("unable to open zip file: %w", err)
+	}
+	//noinspection GoUnhandledErrorResult
+	defer r.Close()
+
+	return handler(ctx, file.Name, file.FileInfo(), &zipEntryReader{Reader: r, file: file})
+}
+
+func (z *zipExtractor) List(ctx context.Context, archive *os.File) ([]string, error) {
+	zipFiles, err := openZipFiles(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, zipFile := range zipFiles {
+		if !zipFile.FileInfo().IsDir() {
+			names = append(names, zipFile.Name)
+		}
+	}
+	return names, nil
+}
+
+// openZipFiles reads the central directory of a zip archive, including for the formats that
+// are zip archives with their own layout
+func openZipFiles(archive *os.File) ([]*zip.File, error) {
+	stat, err := archive.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+
+	zipReader, err := zip.NewReader(archive, stat.Size())
+	if err != nil {
+		return nil, corruptZip(fmt.Errorf("failed to read zip content: %w", err), stat.Size())
+	}
+	return zipReader.File, nil
+}
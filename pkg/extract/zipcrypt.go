@@ -0,0 +1,285 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// ErrPasswordRequired indicates the requested file is encrypted but no password was given
+var ErrPasswordRequired = errors.New("the requested file is encrypted, so a password is required")
+
+// ErrWrongPassword indicates the password can't decrypt the requested file
+var ErrWrongPassword = errors.New("incorrect password for the requested file")
+
+type passwordKey struct{}
+
+// WithPassword provides the password that extractors use to decrypt entries
+func WithPassword(ctx context.Context, password string) context.Context {
+	return context.WithValue(ctx, passwordKey{}, password)
+}
+
+// PasswordFrom returns the password provided by WithPassword, if any
+func PasswordFrom(ctx context.Context) string {
+	password, _ := ctx.Value(passwordKey{}).(string)
+	return password
+}
+
+const (
+	// zipMethodAES is the compression method of entries encrypted with WinZip AES
+	zipMethodAES = 99
+	// zipExtraAES is the extra field header ID of WinZip AES parameters
+	zipExtraAES = 0x9901
+)
+
+// openEncryptedZip decrypts and decompresses the entry, which is encrypted with either the
+// traditional PKWARE cipher or WinZip AES
+func openEncryptedZip(file *zip.File, password string) (io.Reader, error) {
+	if password == "" {
+		return nil, ErrPasswordRequired
+	}
+
+	raw, err := file.OpenRaw()
+	if err != nil {
+		return nil, fmt.Errorf("unable to open zip file: %w", err)
+	}
+
+	method := file.Method
+	var decrypted io.Reader
+	checkCRC := true
+	if method == zipMethodAES {
+		var aesMethod uint16
+		decrypted, aesMethod, checkCRC, err = decryptZipAES(file, raw, password)
+		if err != nil {
+			return nil, err
+		}
+		method = aesMethod
+	} else {
+		decrypted, err = decryptZipCrypto(file, raw, password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var content io.Reader
+	switch method {
+	case zip.Store:
+		content = decrypted
+	case zip.Deflate:
+		content = flate.NewReader(decrypted)
+	default:
+		return nil, fmt.Errorf("unsupported compression method %d of encrypted zip entry", method)
+	}
+
+	if method != zip.Store {
+		// decompression ends without reading to the end of the decrypted data, which must be
+		// read for the authentication code of AES to be checked
+		content = &drainingReader{r: content, rest: decrypted}
+	}
+	if checkCRC {
+		content = &crcCheckReader{r: content, hash: crc32.NewIEEE(), expected: file.CRC32}
+	}
+	return content, nil
+}
+
+// decryptZipCrypto implements the traditional PKWARE encryption, which is weak but still used
+func decryptZipCrypto(file *zip.File, raw io.Reader, password string) (io.Reader, error) {
+	keys := [3]uint32{0x12345678, 0x23456789, 0x34567890}
+	update := func(b byte) {
+		keys[0] = crc32.IEEETable[byte(keys[0])^b] ^ (keys[0] >> 8)
+		keys[1] = (keys[1]+(keys[0]&0xff))*134775813 + 1
+		keys[2] = crc32.IEEETable[byte(keys[2])^byte(keys[1]>>24)] ^ (keys[2] >> 8)
+	}
+	decryptByte := func(c byte) byte {
+		temp := uint16(keys[2] | 2)
+		p := c ^ byte((temp*(temp^1))>>8)
+		update(p)
+		return p
+	}
+	for i := 0; i < len(password); i++ {
+		update(password[i])
+	}
+
+	header := make([]byte, 12)
+	_, err := io.ReadFull(raw, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	for i := range header {
+		header[i] = decryptByte(header[i])
+	}
+	// the last header byte checks the password against the CRC or, when the sizes follow
+	// the data, the modification time
+	check := byte(file.CRC32 >> 24)
+	if file.Flags&0x8 != 0 {
+		check = byte(file.ModifiedTime >> 8)
+	}
+	if header[11] != check {
+		return nil, ErrWrongPassword
+	}
+
+	return readerFunc(func(p []byte) (int, error) {
+		n, err := raw.Read(p)
+		for i := 0; i < n; i++ {
+			p[i] = decryptByte(p[i])
+		}
+		return n, err
+	}), nil
+}
+
+// decryptZipAES implements WinZip AES encryption and returns the decrypted reader along with
+// the actual compression method and whether the CRC is also set, which AE-2 omits
+func decryptZipAES(file *zip.File, raw io.Reader, password string) (io.Reader, uint16, bool, error) {
+	var vendorVersion, actualMethod uint16
+	var strength byte
+	found := false
+	extra := file.Extra
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+size {
+			break
+		}
+		if id == zipExtraAES && size >= 7 {
+			vendorVersion = binary.LittleEndian.Uint16(extra[4:])
+			strength = extra[8]
+			actualMethod = binary.LittleEndian.Uint16(extra[9:])
+			found = true
+		}
+		extra = extra[4+size:]
+	}
+	if !found || strength < 1 || strength > 3 {
+		return nil, 0, false, fmt.Errorf("invalid AES parameters of encrypted zip entry")
+	}
+
+	keyLen := 8 + 8*int(strength)
+	saltLen := keyLen / 2
+	salt := make([]byte, saltLen+2)
+	_, err := io.ReadFull(raw, salt)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+
+	derived := pbkdf2.Key([]byte(password), salt[:saltLen], 1000, 2*keyLen+2, sha1.New)
+	if !bytes.Equal(derived[2*keyLen:], salt[saltLen:]) {
+		return nil, 0, false, ErrWrongPassword
+	}
+
+	block, err := aes.NewCipher(derived[:keyLen])
+	if err != nil {
+		return nil, 0, false, err
+	}
+	mac := hmac.New(sha1.New, derived[keyLen:2*keyLen])
+
+	// the encrypted data is followed by a 10 byte authentication code
+	dataLen := int64(file.CompressedSize64) - int64(saltLen+2) - 10
+	if dataLen < 0 {
+		return nil, 0, false, fmt.Errorf("invalid size of encrypted zip entry")
+	}
+	return &aesCTRReader{
+		data:   io.LimitReader(raw, dataLen),
+		raw:    raw,
+		block:  block,
+		mac:    mac,
+		stream: make([]byte, aes.BlockSize),
+		used:   aes.BlockSize,
+	}, actualMethod, vendorVersion == 1, nil
+}
+
+// aesCTRReader decrypts with the little-endian counter mode of WinZip AES and checks the
+// authentication code once all data is read
+type aesCTRReader struct {
+	data    io.Reader
+	raw     io.Reader
+	block   cipher.Block
+	mac     hash.Hash
+	counter [aes.BlockSize]byte
+	stream  []byte
+	used    int
+	// authenticated is set once the authentication code has been checked
+	authenticated bool
+}
+
+func (a *aesCTRReader) Read(p []byte) (int, error) {
+	n, err := a.data.Read(p)
+	a.mac.Write(p[:n])
+	for i := 0; i < n; i++ {
+		if a.used == aes.BlockSize {
+			for j := range a.counter {
+				a.counter[j]++
+				if a.counter[j] != 0 {
+					break
+				}
+			}
+			a.block.Encrypt(a.stream, a.counter[:])
+			a.used = 0
+		}
+		p[i] ^= a.stream[a.used]
+		a.used++
+	}
+
+	if err == io.EOF && !a.authenticated {
+		code, readErr := ioutil.ReadAll(a.raw)
+		if readErr != nil {
+			return n, readErr
+		}
+		if len(code) < 10 || !hmac.Equal(code[:10], a.mac.Sum(nil)[:10]) {
+			return n, fmt.Errorf("authentication of encrypted zip entry failed")
+		}
+		a.authenticated = true
+	}
+	return n, err
+}
+
+// drainingReader reads the rest of another reader once r is read to its end
+type drainingReader struct {
+	r       io.Reader
+	rest    io.Reader
+	drained bool
+}
+
+func (d *drainingReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err == io.EOF && !d.drained {
+		d.drained = true
+		_, drainErr := io.Copy(ioutil.Discard, d.rest)
+		if drainErr != nil {
+			return n, drainErr
+		}
+	}
+	return n, err
+}
+
+type crcCheckReader struct {
+	r        io.Reader
+	hash     hash.Hash32
+	expected uint32
+}
+
+func (c *crcCheckReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF && c.hash.Sum32() != c.expected {
+		return n, fmt.Errorf("checksum of encrypted zip entry doesn't match")
+	}
+	return n, err
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
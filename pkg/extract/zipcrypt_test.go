@@ -0,0 +1,119 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The zip archives in testdata hold usr/local/bin/tool encrypted with the password secret, where
+// tool-aes.zip and tool-zipcrypto.zip were created by bsdtar, which deflates the entry, and
+// tool-zipcrypto-stored.zip by zip -P, which stores it and writes its sizes after the data
+var zipCryptTestArchives = []string{"tool-aes.zip", "tool-zipcrypto.zip", "tool-zipcrypto-stored.zip"}
+
+// extractZipCryptTest extracts the tool with the password, returning its content
+func extractZipCryptTest(archive *os.File, password string) ([]byte, error) {
+	ctx := context.Background()
+	if password != "" {
+		ctx = WithPassword(ctx, password)
+	}
+	var extracted []byte
+	err := Extract(ctx, Zip, archive, "usr/local/bin/tool", func(ctx context.Context, name string, info os.FileInfo, content io.Reader) error {
+		var err error
+		extracted, err = ioutil.ReadAll(content)
+		return err
+	})
+	return extracted, err
+}
+
+func TestZipDecrypt(t *testing.T) {
+	for _, name := range zipCryptTestArchives {
+		t.Run(name, func(t *testing.T) {
+			extracted, err := extractZipCryptTest(openTestdata(t, name), "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(extracted, pkgTestTool) {
+				t.Errorf("extracted %q", extracted)
+			}
+		})
+	}
+}
+
+func TestZipDecryptPassword(t *testing.T) {
+	for _, name := range zipCryptTestArchives {
+		t.Run(name, func(t *testing.T) {
+			archive := openTestdata(t, name)
+			_, err := extractZipCryptTest(archive, "wrong")
+			if !errors.Is(err, ErrWrongPassword) {
+				t.Errorf("extracting with the wrong password gave %v", err)
+			}
+			_, err = extractZipCryptTest(archive, "")
+			if !errors.Is(err, ErrPasswordRequired) {
+				t.Errorf("extracting without a password gave %v", err)
+			}
+		})
+	}
+}
+
+func TestZipDecryptTruncated(t *testing.T) {
+	for _, name := range zipCryptTestArchives {
+		content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive := writeTestArchive(t, name, content)
+		for size := len(content) - 1; size >= 0; size-- {
+			err = archive.Truncate(int64(size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = extractZipCryptTest(archive, "secret")
+			if err == nil {
+				t.Errorf("extracting from %s truncated to %d bytes succeeded", name, size)
+			}
+		}
+	}
+}
+
+// TestZipDecryptCorrupt alters each byte of the encrypted entry in turn, which the password check,
+// CRC, or authentication code must detect
+func TestZipDecryptCorrupt(t *testing.T) {
+	for _, name := range zipCryptTestArchives {
+		content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, err := zr.File[0].DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := start + int64(zr.File[0].CompressedSize64)
+
+		archive := writeTestArchive(t, name, content)
+		for i := start; i < end; i++ {
+			_, err = archive.WriteAt([]byte{content[i] ^ 0xff}, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = extractZipCryptTest(archive, "secret")
+			if err == nil {
+				t.Errorf("extracting from %s with byte %d altered succeeded", name, i)
+			}
+			_, err = archive.WriteAt(content[i:i+1], i)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package fetch
 
 // Bundle just enough intermediate CA certs to verify github.com and Amazon S3
 var extraCerts = []string{
@@ -0,0 +1,212 @@
+// Package fetch retrieves archives to be extracted
+package fetch
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClientOptions customizes the client created by NewHTTPClient
+type ClientOptions struct {
+	// Proxy is the URL of a proxy to use rather than what is declared by the HTTPS_PROXY,
+	// HTTP_PROXY, and NO_PROXY environment variables
+	Proxy string
+	// CACertFiles are paths of PEM files containing additional CA certificates to trust
+	CACertFiles []string
+	// MaxIdleConnsPerHost is how many idle connections to each host are kept for reuse, where
+	// zero uses DefaultMaxIdleConnsPerHost
+	MaxIdleConnsPerHost int
+	// UnixSocket is the path of a Unix domain socket to which all connections are made, such as
+	// of a local artifact proxy, in which case Proxy and the proxy environment variables are ignored
+	UnixSocket string
+	// IPVersion restricts connections to IPv4 when 4 or IPv6 when 6, where zero allows either
+	IPVersion int
+	// DNSServer is the host:port of a DNS server used to resolve hosts, rather than those of
+	// the system, where the port defaults to 53
+	DNSServer string
+	// Retries is how many times a request is repeated after failing to connect or responding
+	// with one of the RetryOn statuses, where zero disables retries
+	Retries int
+	// RetryOn are the response statuses that are retried, which defaults to DefaultRetryOn
+	RetryOn *StatusCodes
+	// MaxRetryWait caps how long to wait when a response asks to be retried later with a
+	// Retry-After header, which defaults to DefaultMaxRetryWait
+	MaxRetryWait time.Duration
+	// MinTLSVersion is the lowest TLS version accepted, such as tls.VersionTLS12, where zero
+	// leaves the default of crypto/tls
+	MinTLSVersion uint16
+	// AllowHTTP follows redirects to http URLs of hosts other than this one, which are otherwise
+	// refused so that what was requested over https isn't then downloaded unencrypted
+	AllowHTTP bool
+	// CheckRedirect, when set, is called with the URL of each redirect, which isn't followed when
+	// it returns an error, such as to hold it to the hosts that downloads are allowed from
+	CheckRedirect func(u *url.URL) error
+	// DebugHTTP logs each request and response, along with their headers, at debug level with
+	// credentials redacted
+	DebugHTTP bool
+}
+
+// ProxyFunc is how NewHTTPClient determines the proxy of each request, which is that of the
+// given URL or, when empty, what the proxy environment variables declare
+func ProxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// NewResolver creates a resolver that queries the DNS server at host:port, where the port
+// defaults to 53, rather than those of the system
+func NewResolver(server string) *net.Resolver {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	dnsDialer := &net.Dialer{Timeout: 10 * time.Second}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dnsDialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// DefaultMaxIdleConnsPerHost allows a few downloads from the same host, such as GitHub
+// release assets, to reuse connections
+const DefaultMaxIdleConnsPerHost = 4
+
+// NewHTTPClient creates a client that trusts the system certificates along with the bundled
+// intermediate certificates needed for github.com and Amazon S3. The client attempts HTTP/2 and
+// keeps connections alive, so it should be shared across downloads.
+func NewHTTPClient(opts ClientOptions) (*http.Client, error) {
+	certPool, err := x509.SystemCertPool()
+	if err != nil {
+		log.Printf("W! %v", err)
+		certPool = x509.NewCertPool()
+	}
+	for _, pem := range extraCerts {
+		if !certPool.AppendCertsFromPEM([]byte(pem)) {
+			return nil, errors.New("Unable to add Github CA cert")
+		}
+	}
+	for _, certFile := range opts.CACertFiles {
+		pem, err := ioutil.ReadFile(certFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA certificates: %w", err)
+		}
+		if !certPool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no CA certificates could be loaded from %s", certFile)
+		}
+	}
+
+	proxy, err := ProxyFunc(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	maxIdlePerHost := opts.MaxIdleConnsPerHost
+	if maxIdlePerHost <= 0 {
+		maxIdlePerHost = DefaultMaxIdleConnsPerHost
+	}
+
+	// start from the default transport for its timeouts and HTTP/2 support, which would
+	// otherwise be disabled by the custom TLS config
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = &tls.Config{RootCAs: certPool, MinVersion: opts.MinTLSVersion}
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxIdleConnsPerHost = maxIdlePerHost
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if opts.DNSServer != "" {
+		dialer.Resolver = NewResolver(opts.DNSServer)
+		transport.DialContext = dialer.DialContext
+	}
+	switch {
+	case opts.UnixSocket != "":
+		if opts.IPVersion != 0 {
+			return nil, errors.New("an IP version can't be required along with a Unix socket")
+		}
+		socket := opts.UnixSocket
+		transport.Proxy = nil
+		// the host of the URL is still used for the Host header and TLS server name
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	case opts.IPVersion == 4 || opts.IPVersion == 6:
+		network := fmt.Sprintf("tcp%d", opts.IPVersion)
+		transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+	case opts.IPVersion != 0:
+		return nil, fmt.Errorf("unsupported IP version %d", opts.IPVersion)
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if opts.DebugHTTP {
+		roundTripper = &debugTransport{next: transport}
+	}
+	checkRedirect := func(req *http.Request, via []*http.Request) error {
+		err := checkRedirect(req, via, opts.AllowHTTP)
+		if err == nil && opts.CheckRedirect != nil {
+			err = opts.CheckRedirect(req.URL)
+		}
+		return err
+	}
+	if opts.Retries > 0 {
+		retryOn := opts.RetryOn
+		if retryOn == nil {
+			retryOn, _ = ParseStatusCodes(DefaultRetryOn)
+		}
+		maxWait := opts.MaxRetryWait
+		if maxWait <= 0 {
+			maxWait = DefaultMaxRetryWait
+		}
+		return &http.Client{
+			Transport: &retryTransport{
+				next:    roundTripper,
+				retries: opts.Retries,
+				retryOn: retryOn,
+				maxWait: maxWait,
+			},
+			CheckRedirect: checkRedirect,
+		}, nil
+	}
+	return &http.Client{Transport: roundTripper, CheckRedirect: checkRedirect}, nil
+}
+
+// maxRedirects is as many redirects as http.Client follows by default
+const maxRedirects = 10
+
+// checkRedirect refuses to follow a redirect to an http URL, unless allowed or of this host, along
+// with more than maxRedirects of them
+func checkRedirect(req *http.Request, via []*http.Request, allowHTTP bool) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if !allowHTTP && strings.EqualFold(req.URL.Scheme, "http") && !isLoopback(req.URL.Hostname()) {
+		return fmt.Errorf("refusing to follow the redirect to %s over unencrypted HTTP, which needs to be allowed such as with allow-http",
+			redactURL(req.URL))
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
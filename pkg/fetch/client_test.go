@@ -0,0 +1,70 @@
+package fetch
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tlsServerClient creates a client by NewHTTPClient with the options that trusts the certificate of the server
+func tlsServerClient(t *testing.T, server *httptest.Server, opts ClientOptions) *http.Client {
+	certFile := filepath.Join(t.TempDir(), "server.pem")
+	err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.CACertFiles = []string{certFile}
+	client, err := NewHTTPClient(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestRedirectToHTTPRefused(t *testing.T) {
+	target := ""
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target, http.StatusFound)
+	}))
+	defer server.Close()
+
+	// refused before connecting, so the host needn't exist
+	target = "http://downloads.example.invalid/tool.tar.gz"
+	_, err := tlsServerClient(t, server, ClientOptions{}).Get(server.URL + "/tool.tar.gz")
+	if err == nil || !strings.Contains(err.Error(), "refusing to follow the redirect to "+target) {
+		t.Errorf("redirect to http gave %v", err)
+	}
+	_, err = tlsServerClient(t, server, ClientOptions{AllowHTTP: true}).Get(server.URL + "/tool.tar.gz")
+	if err == nil || strings.Contains(err.Error(), "refusing") {
+		t.Errorf("allowed redirect to http of a missing host gave %v", err)
+	}
+}
+
+func TestRedirectToLoopbackHTTPFollowed(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer plain.Close()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+"/tool.tar.gz", http.StatusFound)
+	}))
+	defer server.Close()
+
+	resp, err := tlsServerClient(t, server, ClientOptions{}).Get(server.URL + "/tool.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "content" {
+		t.Errorf("read %q", content)
+	}
+}
@@ -0,0 +1,95 @@
+package fetch
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redactedHeaders are those whose values are credentials, which are never logged
+var redactedHeaders = map[string]bool{
+	"Authorization":        true,
+	"Proxy-Authorization":  true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"Private-Token":        true,
+	"X-Api-Key":            true,
+	"X-Amz-Security-Token": true,
+	"X-Jfrog-Art-Api":      true,
+}
+
+// redactedParams are the substrings of query parameter names, such as of pre-signed URLs, whose
+// values are credentials
+var redactedParams = []string{"signature", "token", "credential", "secret", "password", "key", "sig"}
+
+// debugTransport logs each request and its response, including the hops of redirects and every
+// attempt of retries since it's given each of those separately
+type debugTransport struct {
+	next http.RoundTripper
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	log.Printf("D! HTTP > %s %s", req.Method, redactURL(req.URL))
+	logHeaders(">", req.Header)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		log.Printf("D! HTTP ! %s %s failed after %s: %v", req.Method, redactURL(req.URL),
+			time.Since(start).Round(time.Millisecond), err)
+		return resp, err
+	}
+
+	log.Printf("D! HTTP < %s %s in %s", resp.Proto, resp.Status, time.Since(start).Round(time.Millisecond))
+	logHeaders("<", resp.Header)
+	return resp, nil
+}
+
+func logHeaders(direction string, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			switch {
+			case redactedHeaders[name]:
+				value = "REDACTED"
+			case name == "Location" || name == "Referer":
+				if u, err := url.Parse(value); err == nil {
+					value = redactURL(u)
+				}
+			}
+			log.Printf("D! HTTP %s %s: %s", direction, name, value)
+		}
+	}
+}
+
+// redactURL formats the URL without the password of its user info and the values of query
+// parameters that are credentials, such as the signature of a pre-signed URL
+func redactURL(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	changed := false
+	for name, values := range query {
+		lower := strings.ToLower(name)
+		for _, param := range redactedParams {
+			if strings.Contains(lower, param) {
+				for i := range values {
+					values[i] = "REDACTED"
+				}
+				changed = true
+				break
+			}
+		}
+	}
+	if changed {
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.Redacted()
+}
@@ -0,0 +1,144 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/itzg/easy-add/internal/ctxio"
+	"github.com/itzg/easy-add/internal/diskspace"
+	"github.com/itzg/easy-add/pkg/checksum"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Archive is a downloaded archive held in a temporary file
+type Archive struct {
+	*os.File
+	// SHA256 is the hex encoded digest of the archive, regardless of the expected checksum
+	SHA256 string
+}
+
+// Remove closes and removes the temporary file
+func (a *Archive) Remove() {
+	_ = a.Close()
+	_ = os.Remove(a.Name())
+}
+
+// Download retrieves the archive at the URL, using the given fetcher, into a temporary file and,
+// if given, verifies it against the expected checksum. A maxSize above zero fails the download
+// of a larger archive. The caller is responsible for removing the returned archive.
+func Download(ctx context.Context, fetcher Fetcher, u *url.URL, expected *checksum.Checksum, maxSize int64) (*Archive, error) {
+	resp, err := fetcher.Fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	err = CheckSize(resp.ContentLength, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	err = diskspace.Check(os.TempDir(), resp.ContentLength)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile("", "easy-add-*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file for archive: %w", err)
+	}
+	archive := &Archive{File: file}
+	success := false
+	defer func() {
+		if !success {
+			archive.Remove()
+		}
+	}()
+
+	sha256Hasher := sha256.New()
+	writer := io.MultiWriter(file, sha256Hasher)
+	var hasher hash.Hash
+	if expected != nil {
+		hasher, err = expected.NewHash()
+		if err != nil {
+			return nil, err
+		}
+		writer = io.MultiWriter(writer, hasher)
+	}
+
+	var body io.Reader = ctxio.NewReader(ctx, checkLength(resp))
+	if maxSize > 0 {
+		// the content length isn't always known or truthful, so read one more byte to detect excess
+		body = io.LimitReader(body, maxSize+1)
+	}
+	written, err := io.Copy(writer, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download archive: %w", err)
+	}
+	if maxSize > 0 && written > maxSize {
+		return nil, fmt.Errorf("archive exceeds the maximum download size of %d bytes", maxSize)
+	}
+
+	if expected != nil {
+		err = expected.Verify(hasher.Sum(nil))
+		if err != nil {
+			return nil, err
+		}
+	}
+	archive.SHA256 = hex.EncodeToString(sha256Hasher.Sum(nil))
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	success = true
+	return archive, nil
+}
+
+// SaveAs copies the archive to the given path, which is replaced only once the copy is complete,
+// and positions the archive back at its start. It fails early when the filesystem of the path
+// can't hold the archive.
+func (a *Archive) SaveAs(ctx context.Context, path string) error {
+	stat, err := a.Stat()
+	if err != nil {
+		return err
+	}
+	err = diskspace.Check(filepath.Dir(path), stat.Size())
+	if err != nil {
+		return err
+	}
+	_, err = a.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), ".easy-add-*-"+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("unable to create copy of archive: %w", err)
+	}
+	tempPath := file.Name()
+	_, err = io.Copy(file, ctxio.NewReader(ctx, a.File))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("unable to copy archive to %s: %w", path, err)
+	}
+
+	_, err = a.Seek(0, io.SeekStart)
+	return err
+}
@@ -0,0 +1,45 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveAsChecksDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	file, err := ioutil.TempFile(dir, "archive-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := &Archive{File: file}
+	defer archive.Remove()
+	// a sparse archive larger than the free space of most filesystems
+	err = file.Truncate(1 << 43)
+	if err != nil {
+		t.Skipf("unable to create a sparse archive: %v", err)
+	}
+
+	// the context is already cancelled, so a filesystem with room fails the copy right away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	keptPath := filepath.Join(dir, "kept", "archive")
+	err = os.MkdirAll(filepath.Dir(keptPath), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = archive.SaveAs(ctx, keptPath)
+	if errors.Is(err, context.Canceled) {
+		t.Skip("the filesystem has room for the archive")
+	}
+	if err == nil || !strings.Contains(err.Error(), "insufficient disk space") {
+		t.Errorf("saving gave %v rather than insufficient disk space", err)
+	}
+	if _, err := os.Stat(keptPath); !os.IsNotExist(err) {
+		t.Errorf("the archive was saved: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Fetcher retrieves the content at URLs of the scheme(s) it is registered for
+type Fetcher interface {
+	Fetch(ctx context.Context, u *url.URL) (*Response, error)
+}
+
+// FetcherFunc adapts a function to a Fetcher
+type FetcherFunc func(ctx context.Context, u *url.URL) (*Response, error)
+
+func (f FetcherFunc) Fetch(ctx context.Context, u *url.URL) (*Response, error) {
+	return f(ctx, u)
+}
+
+// Response is the content retrieved by a Fetcher
+type Response struct {
+	Body io.ReadCloser
+	// ContentLength is the number of bytes in Body or -1 when unknown
+	ContentLength int64
+}
+
+// ErrTruncated indicates a response ended before the number of bytes that it declared
+var ErrTruncated = errors.New("download was truncated")
+
+// lengthCheckedBody fails with ErrTruncated when the body ends before its content length, rather
+// than passing a short archive along to be reported as corrupt
+type lengthCheckedBody struct {
+	io.ReadCloser
+	contentLength int64
+	read          int64
+}
+
+func (b *lengthCheckedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if (err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF)) && b.read < b.contentLength {
+		return n, fmt.Errorf("%w after %d of the %d bytes declared by its Content-Length",
+			ErrTruncated, b.read, b.contentLength)
+	}
+	return n, err
+}
+
+// checkLength wraps the body of the response to fail with ErrTruncated when it ends early, where
+// a body of unknown length is left as is
+func checkLength(resp *Response) io.ReadCloser {
+	if resp.ContentLength < 0 {
+		return resp.Body
+	}
+	return &lengthCheckedBody{ReadCloser: resp.Body, contentLength: resp.ContentLength}
+}
+
+var (
+	registryMu sync.RWMutex
+	fetchers   = make(map[string]Fetcher)
+)
+
+func init() {
+	httpFetcher := &HTTPFetcher{}
+	Register("http", httpFetcher)
+	Register("https", httpFetcher)
+	Register("file", &FileFetcher{})
+	Register("oci", &OCIFetcher{})
+}
+
+// Register makes the fetcher available for URLs with the given scheme. Registering an
+// existing scheme replaces its fetcher.
+func Register(scheme string, fetcher Fetcher) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	fetchers[strings.ToLower(scheme)] = fetcher
+}
+
+// ClientFetcher is a Fetcher that makes its requests with an HTTP client, where ForURL gives it
+// the client configured for the download, such as with a proxy or CA certificates
+type ClientFetcher interface {
+	Fetcher
+	WithClient(client *http.Client) Fetcher
+}
+
+// ForURL returns the fetcher registered for the scheme of the URL, or a plugin, as Lookup does,
+// where a ClientFetcher, such as that of http, https, and oci, is given the client when there is one
+func ForURL(u *url.URL, client *http.Client) (Fetcher, error) {
+	fetcher, err := Lookup(strings.ToLower(u.Scheme))
+	if err != nil {
+		return nil, err
+	}
+	if clientFetcher, ok := fetcher.(ClientFetcher); ok && client != nil {
+		return clientFetcher.WithClient(client), nil
+	}
+	return fetcher, nil
+}
+
+// Lookup returns the fetcher registered for the scheme or, otherwise, a PluginFetcher for
+// an executable named with PluginPrefix and the scheme that is on the PATH
+func Lookup(scheme string) (Fetcher, error) {
+	registryMu.RLock()
+	fetcher, exists := fetchers[strings.ToLower(scheme)]
+	registryMu.RUnlock()
+	if exists {
+		return fetcher, nil
+	}
+
+	plugin, err := lookupPlugin(scheme)
+	if err != nil {
+		return nil, fmt.Errorf("no fetcher is registered for the URL scheme '%s' and no %s%s plugin was found",
+			scheme, PluginPrefix, scheme)
+	}
+	return plugin, nil
+}
+
+// IsNotFound determines if the error is due to there being nothing at the URL, such as a 404
+// response or a missing file
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusNotFound
+	}
+	return errors.Is(err, os.ErrNotExist)
+}
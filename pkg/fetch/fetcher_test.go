@@ -0,0 +1,51 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestForURLGivesClient(t *testing.T) {
+	client := &http.Client{}
+	for _, scheme := range []string{"http", "HTTPS", "oci"} {
+		fetcher, err := ForURL(&url.URL{Scheme: scheme, Host: "example.com"}, client)
+		if err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		switch fetcher := fetcher.(type) {
+		case *HTTPFetcher:
+			if fetcher.Client != client {
+				t.Errorf("%s fetcher wasn't given the client", scheme)
+			}
+		case *OCIFetcher:
+			if fetcher.Client != client {
+				t.Errorf("%s fetcher wasn't given the client", scheme)
+			}
+		default:
+			t.Errorf("%s has fetcher %T", scheme, fetcher)
+		}
+	}
+}
+
+func TestRegisterReplacesBuiltin(t *testing.T) {
+	previous, err := Lookup("https")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Register("https", previous)
+
+	replacement := FetcherFunc(func(ctx context.Context, u *url.URL) (*Response, error) {
+		return nil, nil
+	})
+	Register("https", replacement)
+
+	fetcher, err := ForURL(&url.URL{Scheme: "https", Host: "example.com"}, &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fetcher.(FetcherFunc); !ok {
+		t.Errorf("https has fetcher %T rather than the registered one", fetcher)
+	}
+}
@@ -0,0 +1,49 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// FileFetcher retrieves file URLs, such as file:///tmp/tool.tar.gz
+type FileFetcher struct{}
+
+func (f *FileFetcher) Fetch(_ context.Context, u *url.URL) (*Response, error) {
+	file, err := os.Open(localPath(u))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	return &Response{
+		Body:          file,
+		ContentLength: stat.Size(),
+	}, nil
+}
+
+// Probe reports the size of the file
+func (f *FileFetcher) Probe(_ context.Context, u *url.URL) (*Info, error) {
+	stat, err := os.Stat(localPath(u))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	return &Info{ContentLength: stat.Size()}, nil
+}
+
+func localPath(u *url.URL) string {
+	if runtime.GOOS == "windows" {
+		// file:///C:/tools/tool.zip has a path of /C:/tools/tool.zip
+		return filepath.FromSlash(strings.TrimPrefix(u.Path, "/"))
+	}
+	return u.Path
+}
@@ -0,0 +1,144 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrHTML indicates the server responded with an HTML page, such as the error or login page of a
+// mirror that is served with status 200, where an archive was expected
+var ErrHTML = errors.New("server returned HTML, not an archive")
+
+// sniffLen is how much of the body is inspected for HTML, which is enough for the title of most pages
+const sniffLen = 1024
+
+var htmlTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// HTTPFetcher retrieves http and https URLs. When Client is nil, one from NewHTTPClient with default options is used.
+type HTTPFetcher struct {
+	Client *http.Client
+
+	initClient sync.Once
+	clientErr  error
+}
+
+// WithClient returns an HTTPFetcher that makes its requests with the client
+func (h *HTTPFetcher) WithClient(client *http.Client) Fetcher {
+	return &HTTPFetcher{Client: client}
+}
+
+func (h *HTTPFetcher) Fetch(ctx context.Context, u *url.URL) (*Response, error) {
+	resp, err := h.do(ctx, http.MethodGet, u)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := sniffHTML(resp)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return &Response{
+		Body:          body,
+		ContentLength: resp.ContentLength,
+	}, nil
+}
+
+// sniffHTML fails with ErrHTML when the start of the body is an HTML page, so that it isn't
+// reported later as a corrupt archive. Otherwise, it returns the body with the inspected start
+// put back in front.
+func sniffHTML(resp *http.Response) (io.ReadCloser, error) {
+	start := make([]byte, sniffLen)
+	n, err := io.ReadFull(resp.Body, start)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	start = start[:n]
+
+	if strings.HasPrefix(http.DetectContentType(start), "text/html") {
+		detail := resp.Request.URL.Host + " sent a page"
+		if m := htmlTitle.FindSubmatch(start); m != nil {
+			if title := strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " "); title != "" {
+				detail += fmt.Sprintf(" titled \"%s\"", title)
+			}
+		}
+		if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+			detail += " as " + contentType
+		}
+		return nil, fmt.Errorf("%w: %s, which is likely an error or login page", ErrHTML, detail)
+	}
+
+	return &sniffedBody{ReadCloser: resp.Body, start: bytes.NewReader(start)}, nil
+}
+
+// sniffedBody reads the start that was inspected by sniffHTML before the rest of the body
+type sniffedBody struct {
+	io.ReadCloser
+	start *bytes.Reader
+}
+
+func (b *sniffedBody) Read(p []byte) (int, error) {
+	if b.start.Len() > 0 {
+		return b.start.Read(p)
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// Probe issues a HEAD request for the URL
+func (h *HTTPFetcher) Probe(ctx context.Context, u *url.URL) (*Info, error) {
+	resp, err := h.do(ctx, http.MethodHead, u)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+
+	return &Info{
+		ContentLength: resp.ContentLength,
+		ContentType:   resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+func (h *HTTPFetcher) do(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	h.initClient.Do(func() {
+		if h.Client == nil {
+			h.Client, h.clientErr = NewHTTPClient(ClientOptions{})
+		}
+	})
+	if h.clientErr != nil {
+		return nil, h.clientErr
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		_ = resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp, nil
+}
+
+// StatusError is the unsuccessful response to an HTTP request
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "failed to retrieve archive: " + e.Status
+}
@@ -0,0 +1,443 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/itzg/easy-add/internal/dockerconfig"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	ociIndexType           = "application/vnd.oci.image.index.v1+json"
+	ociManifestType        = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestListType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerManifestType     = "application/vnd.docker.distribution.manifest.v2+json"
+	// ociTitleAnnotation names the file of a layer, such as those pushed by oras
+	ociTitleAnnotation = "org.opencontainers.image.title"
+)
+
+// OCIFetcher retrieves a layer of an artifact in an OCI registry, such as ghcr.io, addressed by an
+// oci://registry/repository:tag/title URL, or with @digest in place of :tag. The title is that of
+// the layer to retrieve, which can be left off when the artifact has only one. Multi-platform
+// artifacts resolve to the manifest of the current platform. Credentials are those of the Docker
+// CLI, such as from docker login, including its credential helpers.
+// When Client is nil, one from NewHTTPClient with default options is used.
+type OCIFetcher struct {
+	Client *http.Client
+
+	initClient sync.Once
+	clientErr  error
+}
+
+// WithClient returns an OCIFetcher that makes its requests with the client
+func (f *OCIFetcher) WithClient(client *http.Client) Fetcher {
+	return &OCIFetcher{Client: client}
+}
+
+// ociReference is the parsed form of an oci URL
+type ociReference struct {
+	registry   string
+	repository string
+	// reference is the tag or digest of the manifest
+	reference string
+	title     string
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform"`
+}
+
+type ociManifest struct {
+	MediaType string           `json:"mediaType"`
+	Manifests []*ociDescriptor `json:"manifests"`
+	Layers    []*ociDescriptor `json:"layers"`
+}
+
+func parseOCIReference(u *url.URL) (*ociReference, error) {
+	ref := &ociReference{registry: u.Host}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, segment := range segments {
+		if at := strings.Index(segment, "@"); at >= 0 {
+			ref.reference = segment[at+1:]
+			segment = segment[:at]
+		} else if colon := strings.Index(segment, ":"); colon >= 0 {
+			ref.reference = segment[colon+1:]
+			segment = segment[:colon]
+		} else {
+			continue
+		}
+		ref.repository = strings.Join(append(segments[:i:i], segment), "/")
+		ref.title = strings.Join(segments[i+1:], "/")
+		break
+	}
+	if ref.registry == "" || ref.repository == "" || ref.reference == "" {
+		return nil, fmt.Errorf("%s is not of the form oci://registry/repository:tag or oci://registry/repository@digest, "+
+			"optionally followed by /title of the layer", u.Redacted())
+	}
+	ref.normalizeDockerHub()
+	return ref, nil
+}
+
+// normalizeDockerHub addresses Docker Hub by the host of its registry API, where its official
+// images, such as alpine, are within library
+func (ref *ociReference) normalizeDockerHub() {
+	if ref.registry == "docker.io" {
+		ref.registry = "registry-1.docker.io"
+	}
+	if ref.registry == "registry-1.docker.io" && !strings.Contains(ref.repository, "/") {
+		ref.repository = "library/" + ref.repository
+	}
+}
+
+func (f *OCIFetcher) Fetch(ctx context.Context, u *url.URL) (*Response, error) {
+	session, layer, err := f.resolve(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := session.get(ctx, "blobs/"+layer.Digest, "")
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body
+	if strings.HasPrefix(layer.Digest, "sha256:") {
+		body = &digestCheckedBody{ReadCloser: body, hash: sha256.New(), expected: strings.TrimPrefix(layer.Digest, "sha256:")}
+	}
+	return &Response{
+		Body:          body,
+		ContentLength: resp.ContentLength,
+	}, nil
+}
+
+// Probe describes the layer from the manifest, without retrieving it
+func (f *OCIFetcher) Probe(ctx context.Context, u *url.URL) (*Info, error) {
+	_, layer, err := f.resolve(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return &Info{
+		ContentLength: layer.Size,
+		ContentType:   layer.MediaType,
+	}, nil
+}
+
+// resolve retrieves the manifest of the URL and finds its requested layer
+func (f *OCIFetcher) resolve(ctx context.Context, u *url.URL) (*ociSession, *ociDescriptor, error) {
+	client, err := f.client()
+	if err != nil {
+		return nil, nil, err
+	}
+	ref, err := parseOCIReference(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	session := &ociSession{client: client, ref: ref}
+
+	manifest, err := session.manifest(ctx, ref.reference)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(manifest.Manifests) > 0 {
+		var platformDigest string
+		for _, m := range manifest.Manifests {
+			if m.Platform != nil && m.Platform.OS == runtime.GOOS && m.Platform.Architecture == runtime.GOARCH {
+				platformDigest = m.Digest
+				break
+			}
+		}
+		if platformDigest == "" {
+			return nil, nil, fmt.Errorf("%s/%s:%s has no manifest for %s/%s",
+				ref.registry, ref.repository, ref.reference, runtime.GOOS, runtime.GOARCH)
+		}
+		manifest, err = session.manifest(ctx, platformDigest)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var titles []string
+	for _, layer := range manifest.Layers {
+		title := layer.Annotations[ociTitleAnnotation]
+		if ref.title == "" && len(manifest.Layers) == 1 || ref.title != "" && title == ref.title {
+			return session, layer, nil
+		}
+		titles = append(titles, title)
+	}
+	if ref.title == "" {
+		return nil, nil, fmt.Errorf("%s/%s:%s has %d layers, so one of their titles must follow it, such as /%s",
+			ref.registry, ref.repository, ref.reference, len(manifest.Layers), strings.Join(titles, " or /"))
+	}
+	return nil, nil, &StatusError{
+		StatusCode: http.StatusNotFound,
+		Status:     fmt.Sprintf("no layer of %s/%s:%s is titled %s", ref.registry, ref.repository, ref.reference, ref.title),
+	}
+}
+
+func (f *OCIFetcher) client() (*http.Client, error) {
+	f.initClient.Do(func() {
+		if f.Client == nil {
+			f.Client, f.clientErr = NewHTTPClient(ClientOptions{})
+		}
+	})
+	return f.Client, f.clientErr
+}
+
+// ociSession makes the requests of a repository with the token its registry issued
+type ociSession struct {
+	client *http.Client
+	ref    *ociReference
+	// authorization is the value of the Authorization header, once the registry asked for one
+	authorization string
+}
+
+func (s *ociSession) manifest(ctx context.Context, reference string) (*ociManifest, error) {
+	resp, err := s.get(ctx, "manifests/"+reference,
+		strings.Join([]string{ociIndexType, ociManifestType, dockerManifestListType, dockerManifestType}, ", "))
+	if err != nil {
+		return nil, err
+	}
+	//noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	var manifest ociManifest
+	err = json.NewDecoder(io.LimitReader(resp.Body, 4*1024*1024)).Decode(&manifest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse manifest of %s/%s:%s: %w", s.ref.registry, s.ref.repository, reference, err)
+	}
+	return &manifest, nil
+}
+
+// get requests the path within the repository, authenticating as the registry challenges
+func (s *ociSession) get(ctx context.Context, path string, accept string) (*http.Response, error) {
+	// like the Docker CLI, local registries are expected to not have TLS
+	scheme := "https"
+	host := s.ref.registry
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if isLoopbackHost(host) {
+		scheme = "http"
+	}
+	target := fmt.Sprintf("%s://%s/v2/%s/%s", scheme, s.ref.registry, s.ref.repository, path)
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if s.authorization != "" {
+			req.Header.Set("Authorization", s.authorization)
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			err = s.authenticate(ctx, resp.Header.Get("WWW-Authenticate"))
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+}
+
+// authenticate responds to the challenge of the registry with the credentials of the Docker CLI,
+// if any, which for a Bearer challenge are exchanged for a token
+func (s *ociSession) authenticate(ctx context.Context, challenge string) error {
+	creds, err := dockerconfig.Lookup(ctx, s.ref.registry)
+	if err != nil {
+		return err
+	}
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds == nil || creds.Password == "" {
+			return fmt.Errorf("%s requires a login, such as with docker login %s", s.ref.registry, s.ref.registry)
+		}
+		req := &http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(creds.Username, creds.Password)
+		s.authorization = req.Header.Get("Authorization")
+		return nil
+	case "bearer":
+		token, err := s.requestToken(ctx, params, creds)
+		if err != nil {
+			return err
+		}
+		s.authorization = "Bearer " + token
+		return nil
+	default:
+		return fmt.Errorf("%s responded with an unsupported authentication challenge '%s'", s.ref.registry, challenge)
+	}
+}
+
+// requestToken gets a token with the pull scope of the repository from the realm of the
+// challenge, anonymously when there are no credentials
+func (s *ociSession) requestToken(ctx context.Context, params map[string]string, creds *dockerconfig.Credentials) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("the authentication challenge of %s has no realm", s.ref.registry)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + s.ref.repository + ":pull"
+	}
+
+	var req *http.Request
+	var err error
+	if creds != nil && creds.IdentityToken != "" {
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {creds.IdentityToken},
+			"service":       {params["service"]},
+			"scope":         {scope},
+			"client_id":     {"easy-add"},
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, realm, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := url.Values{"scope": {scope}}
+		if service := params["service"]; service != "" {
+			query.Set("service", service)
+		}
+		separator := "?"
+		if strings.Contains(realm, "?") {
+			separator = "&"
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, realm+separator+query.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		if creds != nil {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to request a token of %s: %w", s.ref.registry, err)
+	}
+	//noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		if creds == nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return "", fmt.Errorf("%s refused an anonymous token for %s, so log in with docker login %s",
+				s.ref.registry, s.ref.repository, s.ref.registry)
+		}
+		return "", fmt.Errorf("unable to request a token of %s: %w", s.ref.registry,
+			&StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+
+	var result struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse token of %s: %w", s.ref.registry, err)
+	}
+	if result.Token != "" {
+		return result.Token, nil
+	}
+	if result.AccessToken != "" {
+		return result.AccessToken, nil
+	}
+	return "", fmt.Errorf("%s issued an empty token", s.ref.registry)
+}
+
+// parseChallenge splits a WWW-Authenticate header, such as
+// Bearer realm="https://ghcr.io/token",service="ghcr.io", into its scheme and parameters
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+	challenge = strings.TrimSpace(challenge)
+	space := strings.Index(challenge, " ")
+	if space < 0 {
+		return challenge, params
+	}
+	scheme, rest := challenge[:space], challenge[space+1:]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		name := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[name] = value
+		rest = strings.TrimLeft(rest, " ,")
+	}
+	return scheme, params
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// errDigestMismatch is a layer whose content doesn't match the digest its manifest declares
+var errDigestMismatch = errors.New("layer doesn't match the digest of its manifest")
+
+// digestCheckedBody fails at its end when the content doesn't match the expected digest
+type digestCheckedBody struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (b *digestCheckedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(b.hash.Sum(nil)) != b.expected {
+		log.Printf("D! Layer has sha256:%s rather than sha256:%s", hex.EncodeToString(b.hash.Sum(nil)), b.expected)
+		return n, errDigestMismatch
+	}
+	return n, err
+}
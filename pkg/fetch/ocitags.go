@@ -0,0 +1,72 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxTagPages limits how many pages of tags are requested of a registry that keeps linking to more
+const maxTagPages = 100
+
+// nextLink finds the URL of the next page in a Link header, such as
+// </v2/owner/tool/tags/list?last=1.2.3&n=1000>; rel="next"
+var nextLink = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// Tags lists the tags of a repository of an OCI registry, given as registry/repository such as
+// ghcr.io/owner/tool. Like the Docker CLI, a repository without a registry, such as alpine, is
+// one of Docker Hub.
+func (f *OCIFetcher) Tags(ctx context.Context, repository string) ([]string, error) {
+	client, err := f.client()
+	if err != nil {
+		return nil, err
+	}
+	ref := parseOCIRepository(repository)
+	session := &ociSession{client: client, ref: ref}
+
+	var tags []string
+	path := "tags/list?n=1000"
+	for page := 0; page < maxTagPages && path != ""; page++ {
+		resp, err := session.get(ctx, path, "application/json")
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the tags of %s/%s: %w", ref.registry, ref.repository, err)
+		}
+		var result struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, 4*1024*1024)).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the tags of %s/%s: %w", ref.registry, ref.repository, err)
+		}
+		tags = append(tags, result.Tags...)
+
+		path = ""
+		if m := nextLink.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			next, err := url.Parse(m[1])
+			if err == nil && next.RawQuery != "" {
+				path = "tags/list?" + next.RawQuery
+			}
+		}
+	}
+	return tags, nil
+}
+
+// parseOCIRepository splits the repository, such as ghcr.io/owner/tool, into its registry, which
+// is Docker Hub when the first element isn't a host, and the repository within it
+func parseOCIRepository(repository string) *ociReference {
+	repository = strings.Trim(strings.TrimPrefix(repository, "oci://"), "/")
+	ref := &ociReference{registry: "docker.io", repository: repository}
+	if slash := strings.Index(repository, "/"); slash >= 0 {
+		first := repository[:slash]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			ref.registry, ref.repository = first, repository[slash+1:]
+		}
+	}
+	ref.normalizeDockerHub()
+	return ref
+}
@@ -0,0 +1,165 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+)
+
+var partSuffix = regexp.MustCompile(`\.(0+1)$`)
+
+// SplitName determines if the name, such as tool.zip.001, is the first part of an archive that was
+// split into numbered parts and, if so, returns the name of the whole archive, such as tool.zip
+func SplitName(name string) (string, bool) {
+	loc := partSuffix.FindStringIndex(name)
+	if loc == nil || loc[0] == 0 {
+		return name, false
+	}
+	return name[:loc[0]], true
+}
+
+// PartsFetcher retrieves an archive that was split into parts as the concatenation of them
+type PartsFetcher struct {
+	Fetcher Fetcher
+	// Parts are the URLs of the parts that follow the one fetched. When none are given, the parts
+	// are numbered after it, such as tool.zip.002 after tool.zip.001, up until one isn't found.
+	Parts []*url.URL
+}
+
+func (p *PartsFetcher) Fetch(ctx context.Context, u *url.URL) (*Response, error) {
+	resp, err := p.Fetcher.Fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Body: &partsReader{
+			ctx:     ctx,
+			fetcher: p,
+			current: checkLength(resp),
+			url:     u,
+		},
+		// a total isn't known until every part has been retrieved
+		ContentLength: -1,
+	}, nil
+}
+
+// Probe reports the total size of the parts when the fetcher can describe each of them
+func (p *PartsFetcher) Probe(ctx context.Context, u *url.URL) (*Info, error) {
+	info, err := Probe(ctx, p.Fetcher, u)
+	if err != nil {
+		return nil, err
+	}
+	total := &Info{ContentLength: info.ContentLength, ContentType: info.ContentType}
+	for i := 1; ; i++ {
+		partURL, err := p.part(u, i)
+		if err != nil {
+			return nil, err
+		}
+		if partURL == nil {
+			return total, nil
+		}
+		info, err = Probe(ctx, p.Fetcher, partURL)
+		if err != nil {
+			if len(p.Parts) == 0 && IsNotFound(err) {
+				return total, nil
+			}
+			return nil, err
+		}
+		if total.ContentLength >= 0 && info.ContentLength >= 0 {
+			total.ContentLength += info.ContentLength
+		} else {
+			total.ContentLength = -1
+		}
+	}
+}
+
+// part returns the URL of the part at the given index after the first, or nil when there's none
+func (p *PartsFetcher) part(first *url.URL, index int) (*url.URL, error) {
+	if len(p.Parts) > 0 {
+		if index > len(p.Parts) {
+			return nil, nil
+		}
+		return p.Parts[index-1], nil
+	}
+
+	loc := partSuffix.FindStringSubmatchIndex(first.Path)
+	if loc == nil {
+		return nil, fmt.Errorf("%s is not numbered as the first part of a split archive", first)
+	}
+	digits := first.Path[loc[2]:loc[3]]
+	partURL := *first
+	partURL.Path = fmt.Sprintf("%s%0*d", first.Path[:loc[2]], len(digits), index+1)
+	partURL.RawPath = ""
+	return &partURL, nil
+}
+
+// partsReader reads each part in turn, retrieving the next once the current one is exhausted
+type partsReader struct {
+	ctx     context.Context
+	fetcher *PartsFetcher
+	current io.ReadCloser
+	url     *url.URL
+	index   int
+	done    bool
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for !r.done {
+		n, err := r.current.Read(p)
+		if err != io.EOF || n > 0 {
+			if err == io.EOF {
+				err = nil
+			}
+			return n, err
+		}
+
+		err = r.next()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, io.EOF
+}
+
+// next closes the current part and retrieves the one after it
+func (r *partsReader) next() error {
+	_ = r.current.Close()
+	r.index++
+	partURL, err := r.fetcher.part(r.url, r.index)
+	if err != nil {
+		return err
+	}
+	if partURL == nil {
+		r.done = true
+		r.current = nopCloser{}
+		return nil
+	}
+
+	resp, err := r.fetcher.Fetcher.Fetch(r.ctx, partURL)
+	if err != nil {
+		if len(r.fetcher.Parts) == 0 && IsNotFound(err) {
+			r.done = true
+			r.current = nopCloser{}
+			return nil
+		}
+		return fmt.Errorf("failed to retrieve part %d: %w", r.index+1, err)
+	}
+	r.current = checkLength(resp)
+	return nil
+}
+
+func (r *partsReader) Close() error {
+	return r.current.Close()
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,84 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// PluginPrefix is the prefix of executables, discovered on the PATH, that fetch URLs of a scheme
+// that is otherwise not registered. For example, easy-add-fetch-s3 would fetch s3:// URLs.
+const PluginPrefix = "easy-add-fetch-"
+
+// PluginFetcher runs an external executable with the URL as its only argument and reads the
+// archive content from its stdout. A non-zero exit of the executable fails the fetch.
+type PluginFetcher struct {
+	Path string
+}
+
+// lookupPlugin finds the plugin executable for the scheme on the PATH
+func lookupPlugin(scheme string) (*PluginFetcher, error) {
+	pluginPath, err := exec.LookPath(PluginPrefix + strings.ToLower(scheme))
+	if err != nil {
+		return nil, err
+	}
+	return &PluginFetcher{Path: pluginPath}, nil
+}
+
+func (p *PluginFetcher) Fetch(ctx context.Context, u *url.URL) (*Response, error) {
+	cmd := exec.CommandContext(ctx, p.Path, u.String())
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start fetch plugin %s: %w", p.Path, err)
+	}
+
+	return &Response{
+		Body:          &pluginOutput{cmd: cmd, stdout: stdout},
+		ContentLength: -1,
+	}, nil
+}
+
+// pluginOutput reads the stdout of a plugin and, at the end of that, reports a failed exit
+// as an error rather than a truncated archive
+type pluginOutput struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	waited bool
+}
+
+func (o *pluginOutput) Read(p []byte) (int, error) {
+	n, err := o.stdout.Read(p)
+	if err == io.EOF {
+		if waitErr := o.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (o *pluginOutput) Close() error {
+	_ = o.stdout.Close()
+	return o.wait()
+}
+
+func (o *pluginOutput) wait() error {
+	if o.waited {
+		return nil
+	}
+	o.waited = true
+	err := o.cmd.Wait()
+	if err != nil {
+		return fmt.Errorf("fetch plugin %s failed: %w", o.cmd.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Info describes the content at a URL without retrieving it
+type Info struct {
+	// ContentLength is the number of bytes of content or -1 when unknown
+	ContentLength int64
+	ContentType   string
+}
+
+// Prober is implemented by fetchers that can describe the content at a URL without retrieving it,
+// such as with an HTTP HEAD request
+type Prober interface {
+	Probe(ctx context.Context, u *url.URL) (*Info, error)
+}
+
+// Probe describes the content at the URL when the fetcher supports it
+func Probe(ctx context.Context, fetcher Fetcher, u *url.URL) (*Info, error) {
+	prober, ok := fetcher.(Prober)
+	if !ok {
+		return nil, fmt.Errorf("the fetcher for %s URLs can't check content without retrieving it", u.Scheme)
+	}
+	return prober.Probe(ctx, u)
+}
+
+// CheckSize fails when the content length is known to exceed maxSize, where a maxSize of zero or less is unlimited
+func CheckSize(contentLength int64, maxSize int64) error {
+	if maxSize > 0 && contentLength > maxSize {
+		return fmt.Errorf("archive is %d bytes, which exceeds the maximum download size of %d bytes", contentLength, maxSize)
+	}
+	return nil
+}
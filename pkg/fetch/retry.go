@@ -0,0 +1,188 @@
+package fetch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/telemetry"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRetryOn are the response statuses retried when ClientOptions.RetryOn is not given
+const DefaultRetryOn = "408,429,5xx"
+
+// DefaultMaxRetryWait caps the wait requested by a Retry-After header when
+// ClientOptions.MaxRetryWait is not given
+const DefaultMaxRetryWait = time.Minute
+
+const (
+	initialRetryDelay = time.Second
+	maxRetryDelay     = 30 * time.Second
+)
+
+// StatusCodes matches HTTP response status codes given individually, such as 429, or by
+// class, such as 5xx
+type StatusCodes struct {
+	codes   map[int]bool
+	classes map[int]bool
+}
+
+// ParseStatusCodes parses a comma separated list of status codes and classes, such as 404,429,5xx
+func ParseStatusCodes(list string) (*StatusCodes, error) {
+	s := &StatusCodes{codes: make(map[int]bool), classes: make(map[int]bool)}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if len(item) == 3 && strings.HasSuffix(item, "xx") && item[0] >= '1' && item[0] <= '5' {
+			s.classes[int(item[0]-'0')] = true
+			continue
+		}
+		code, err := strconv.Atoi(item)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status code '%s', such as 429 or 5xx", item)
+		}
+		s.codes[code] = true
+	}
+	return s, nil
+}
+
+// Matches determines if the status code is one of those given or within one of the classes
+func (s *StatusCodes) Matches(code int) bool {
+	return s.codes[code] || s.classes[code/100]
+}
+
+// retryTransport repeats requests that fail to connect or respond with a retryable status
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	retryOn *StatusCodes
+	// maxWait caps the delay requested by a Retry-After header
+	maxWait time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a consumed body can't be sent again, and an invalid URL fails the same way each time
+	if (req.Body != nil && req.GetBody == nil) || !isValidRequestURL(req.URL) {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.retries || ctx.Err() != nil || isPermanent(err) {
+			return resp, err
+		}
+
+		delay := retryDelay(attempt)
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else if t.retryOn.Matches(resp.StatusCode) {
+			reason = resp.Status
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+				if requested, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+					delay = requested
+					if delay > t.maxWait {
+						log.Printf("W! %s asked to retry after %s, but waiting at most %s", req.URL.Host, requested, t.maxWait)
+						delay = t.maxWait
+					}
+				}
+			}
+			// drain what's small enough to allow the connection to be reused
+			_, _ = io.CopyN(ioutil.Discard, resp.Body, 4096)
+			_ = resp.Body.Close()
+		} else {
+			return resp, nil
+		}
+
+		log.Printf("W! Retrying %s %s in %s after %s", req.Method, req.URL.Redacted(), delay, reason)
+		telemetry.Add(ctx, "easy_add.http.retries", "1", 1)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// isValidRequestURL determines if the URL can be requested, which is otherwise refused by the transport
+func isValidRequestURL(u *url.URL) bool {
+	scheme := strings.ToLower(u.Scheme)
+	return (scheme == "http" || scheme == "https") && u.Host != ""
+}
+
+// isPermanent determines if a request failed in a way that repeating it can't change, such as a
+// certificate that doesn't verify or a host that doesn't exist
+func isPermanent(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalidCertificate x509.CertificateInvalidError
+	var hostnameMismatch x509.HostnameError
+	var verification *tls.CertificateVerificationError
+	var addrErr *net.AddrError
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &unknownAuthority), errors.As(err, &invalidCertificate),
+		errors.As(err, &hostnameMismatch), errors.As(err, &verification), errors.As(err, &addrErr):
+		return true
+	case errors.As(err, &dnsErr):
+		return dnsErr.IsNotFound
+	}
+	return false
+}
+
+// retryAfter parses the value of a Retry-After header given as seconds or an HTTP date
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	when, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	delay := when.Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, true
+}
+
+// retryDelay doubles with each attempt up to maxRetryDelay
+func retryDelay(attempt int) time.Duration {
+	delay := initialRetryDelay
+	for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
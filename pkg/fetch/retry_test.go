@@ -0,0 +1,81 @@
+package fetch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// countingTransport fails each request with err, counting them
+type countingTransport struct {
+	err   error
+	count int
+}
+
+func (t *countingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	t.count++
+	return nil, t.err
+}
+
+func TestPermanentFailuresNotRetried(t *testing.T) {
+	for name, test := range map[string]struct {
+		url string
+		err error
+	}{
+		"unknown authority": {
+			url: "https://example.com/tool.tar.gz",
+			err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}},
+		},
+		"hostname mismatch": {
+			url: "https://example.com/tool.tar.gz",
+			err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"},
+		},
+		"missing host": {
+			url: "https://downloads.example.invalid/tool.tar.gz",
+			err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "downloads.example.invalid", IsNotFound: true}},
+		},
+		"no host": {
+			url: "https:///tool.tar.gz",
+			err: errors.New("http: no Host in request URL"),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			next := &countingTransport{err: test.err}
+			transport := &retryTransport{next: next, retries: 3, retryOn: &StatusCodes{}, maxWait: time.Second}
+			req, err := http.NewRequest(http.MethodGet, test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = transport.RoundTrip(req)
+			if err == nil {
+				t.Fatal("request succeeded")
+			}
+			if next.count != 1 {
+				t.Errorf("requested %d times rather than once", next.count)
+			}
+		})
+	}
+}
+
+func TestUntrustedCertificateNotRetried(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(ClientOptions{Retries: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = client.Get(server.URL)
+	if err == nil {
+		t.Fatal("request to a server of an untrusted certificate succeeded")
+	}
+	if elapsed := time.Since(start); elapsed >= initialRetryDelay {
+		t.Errorf("failed after %s, as if retried", elapsed)
+	}
+}
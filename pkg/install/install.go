@@ -0,0 +1,168 @@
+// Package install places an extracted file at its destination
+package install
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/itzg/easy-add/internal/ctxio"
+	"github.com/itzg/easy-add/internal/diskspace"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+)
+
+// Options declares how an extracted file is placed at its destination
+type Options struct {
+	// To is the directory where the file will be placed
+	To string
+	// Name, when set, is the file name to install as rather than the base of the archive entry name
+	Name string
+	// Mode, when set, is the permissions of the file rather than 0755
+	Mode os.FileMode
+	// Owner, when set, is given the installed file
+	Owner *Owner
+	// TargetOS and TargetArch, named like GOOS and GOARCH, are the platform the file is expected
+	// to be built for rather than the current one
+	TargetOS         string
+	TargetArch       string
+	RequireArchMatch bool
+	// VerifyArgs, when non-empty, are used to run the file prior to moving it into place
+	VerifyArgs   []string
+	Capabilities *Capabilities
+	SELinuxType  string
+}
+
+// File describes an installed file
+type File struct {
+	Path   string
+	SHA256 string
+}
+
+// DefaultDir is the default installation directory on the current operating system
+func DefaultDir() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("LOCALAPPDATA"), "Programs", "easy-add", "bin")
+	}
+	return "/usr/local/bin"
+}
+
+// Install writes the content into the options' directory using the base of the given archive
+// entry name, unless the options give a name
+func Install(ctx context.Context, content io.Reader, name string, size int64, opts *Options) (*File, error) {
+	outName := path.Base(name)
+	if opts.Name != "" {
+		outName = opts.Name
+	}
+	outPath := filepath.Join(opts.To, outName)
+
+	err := diskspace.Check(opts.To, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write alongside the destination and rename into place only once complete, so that a
+	// failed or interrupted extraction never leaves a partially written executable behind
+	file, err := ioutil.TempFile(opts.To, ".easy-add-*-"+outName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create destination file: %w", err)
+	}
+	tempPath := file.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			_ = file.Close()
+			_ = os.Remove(tempPath)
+		}
+	}()
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hasher), ctxio.NewReader(ctx, content))
+	if err != nil {
+		return nil, fmt.Errorf("unable to copy extracted file content: %w", err)
+	}
+
+	if runtime.GOOS != "windows" {
+		mode := os.FileMode(0755)
+		if opts.Mode != 0 {
+			mode = opts.Mode
+		}
+		err = file.Chmod(mode)
+		if err != nil {
+			return nil, fmt.Errorf("unable to set permissions of extracted file: %w", err)
+		}
+	}
+
+	err = file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to write extracted file: %w", err)
+	}
+
+	if opts.Owner != nil {
+		err = os.Chown(tempPath, opts.Owner.uid, opts.Owner.gid)
+		if err != nil {
+			return nil, fmt.Errorf("unable to set owner of extracted file: %w", err)
+		}
+	}
+
+	if opts.Capabilities != nil {
+		err = setFileCapabilities(tempPath, opts.Capabilities)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.SELinuxType != "" {
+		err = setSELinuxType(tempPath, opts.SELinuxType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = checkBinaryPlatform(tempPath, opts.TargetOS, opts.TargetArch, opts.RequireArchMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.VerifyArgs) > 0 {
+		err = runVerifyCmd(ctx, tempPath, outPath, opts.VerifyArgs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = os.Rename(tempPath, outPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to move extracted file into place: %w", err)
+	}
+	committed = true
+
+	return &File{
+		Path:   outPath,
+		SHA256: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+// WarnIfNotOnPath warns when neither the installed file nor any of its links can be found
+// via the PATH, which would otherwise surface later as "command not found"
+func WarnIfNotOnPath(installedPath string, links []string) {
+	onPath := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			onPath[abs] = true
+		}
+	}
+
+	for _, p := range append([]string{installedPath}, links...) {
+		if dir, err := filepath.Abs(filepath.Dir(p)); err == nil && onPath[dir] {
+			return
+		}
+	}
+
+	log.Printf("W! %s is not on the PATH, so it will need to be invoked by its full path", filepath.Dir(installedPath))
+}
@@ -0,0 +1,63 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// CreateLink creates or replaces a symbolic link at linkPath that points at target
+func CreateLink(target string, linkPath string) error {
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return fmt.Errorf("unable to resolve link target: %w", err)
+	}
+	return replaceLink(target, linkPath)
+}
+
+// CreateRelativeLink creates or replaces a symbolic link at linkPath that points at target
+// relative to the directory of the link, so that it remains valid when both are moved together
+func CreateRelativeLink(target string, linkPath string) error {
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return fmt.Errorf("unable to resolve link target: %w", err)
+	}
+	linkDir, err := filepath.Abs(filepath.Dir(linkPath))
+	if err != nil {
+		return fmt.Errorf("unable to resolve directory of link: %w", err)
+	}
+	relTarget, err := filepath.Rel(linkDir, target)
+	if err != nil {
+		return fmt.Errorf("unable to create link %s: %w", linkPath, err)
+	}
+	return replaceLink(relTarget, linkPath)
+}
+
+func replaceLink(target string, linkPath string) error {
+	if info, err := os.Lstat(linkPath); err == nil && info.IsDir() {
+		return fmt.Errorf("unable to create link %s since it is an existing directory", linkPath)
+	}
+
+	// Reserve a unique name next to the link, replace it with the symlink, and rename that into
+	// place so that an existing link is swapped atomically rather than removed and re-created
+	placeholder, err := ioutil.TempFile(filepath.Dir(linkPath), ".easy-add-link-*")
+	if err != nil {
+		return fmt.Errorf("unable to create link %s: %w", linkPath, err)
+	}
+	tempPath := placeholder.Name()
+	_ = placeholder.Close()
+	_ = os.Remove(tempPath)
+
+	err = os.Symlink(target, tempPath)
+	if err != nil {
+		return fmt.Errorf("unable to create link %s: %w", linkPath, err)
+	}
+
+	err = os.Rename(tempPath, linkPath)
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("unable to move link %s into place: %w", linkPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Owner is a numeric user and group ID where -1 leaves that one unchanged
+type Owner struct {
+	uid int
+	gid int
+}
+
+// NewOwner is of the numeric user and group ID
+func NewOwner(uid int, gid int) *Owner {
+	return &Owner{uid: uid, gid: gid}
+}
+
+// CanChown determines if this process is privileged to give files to other users, which is only
+// the case when running as root
+func CanChown() bool {
+	return os.Geteuid() == 0
+}
+
+// ParseOwner parses user[:group] where each can be a name or numeric ID. When only a user name
+// is given, that user's primary group is used.
+func ParseOwner(spec string) (*Owner, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	owner := &Owner{uid: -1, gid: -1}
+
+	if parts[0] != "" {
+		if uid, err := strconv.Atoi(parts[0]); err == nil {
+			owner.uid = uid
+		} else {
+			u, err := user.Lookup(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("unable to lookup owner: %w", err)
+			}
+			owner.uid, _ = strconv.Atoi(u.Uid)
+			if len(parts) == 1 {
+				owner.gid, _ = strconv.Atoi(u.Gid)
+			}
+		}
+	}
+
+	if len(parts) == 2 && parts[1] != "" {
+		if gid, err := strconv.Atoi(parts[1]); err == nil {
+			owner.gid = gid
+		} else {
+			g, err := user.LookupGroup(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("unable to lookup group: %w", err)
+			}
+			owner.gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+
+	return owner, nil
+}
+
+// ParseDirMode parses an octal permission mode such as 0750
+func ParseDirMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("directory mode '%s' must be given in octal, such as 0755", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// Mkdirs creates dir along with any missing parents. When given, the mode and owner are
+// applied to only the directories it created; otherwise, those get 0755 filtered by the umask.
+func Mkdirs(dir string, mode *os.FileMode, owner *Owner) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range missing {
+		if mode != nil {
+			err = os.Chmod(d, *mode)
+			if err != nil {
+				return fmt.Errorf("unable to set mode of %s: %w", d, err)
+			}
+		}
+		if owner != nil {
+			err = os.Chown(d, owner.uid, owner.gid)
+			if err != nil {
+				return fmt.Errorf("unable to set owner of %s: %w", d, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SetModTimes sets the modification and access times of the paths, without following links,
+// along with those of the directories containing them up to, but not including, stop or the
+// root of the filesystem. Since writing a file changes the time of its directory, this
+// is what makes a layer of an image, such as one that easy-add installed into, reproducible.
+func SetModTimes(paths []string, stop string, t time.Time) error {
+	if stop != "" {
+		var err error
+		stop, err = filepath.Abs(stop)
+		if err != nil {
+			return err
+		}
+	}
+
+	dirs := make(map[string]bool)
+	for _, p := range paths {
+		p, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		err = setLinkTimes(p, t)
+		if err != nil {
+			return fmt.Errorf("unable to set modification time of %s: %w", p, err)
+		}
+		for d := filepath.Dir(p); d != stop && filepath.Dir(d) != d; d = filepath.Dir(d) {
+			dirs[d] = true
+		}
+	}
+
+	for d := range dirs {
+		err := os.Chtimes(d, t, t)
+		if err != nil {
+			return fmt.Errorf("unable to set modification time of %s: %w", d, err)
+		}
+	}
+	return nil
+}
+
+// NormalizedMode is 0755 when any execute permission of the mode is set, or otherwise 0644
+func NormalizedMode(mode os.FileMode) os.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
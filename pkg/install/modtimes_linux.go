@@ -0,0 +1,12 @@
+package install
+
+import (
+	"golang.org/x/sys/unix"
+	"time"
+)
+
+// setLinkTimes sets the times of the path, which is itself changed when it's a symbolic link
+func setLinkTimes(path string, t time.Time) error {
+	tv := unix.NsecToTimeval(t.UnixNano())
+	return unix.Lutimes(path, []unix.Timeval{tv, tv})
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package install
+
+import (
+	"os"
+	"time"
+)
+
+// setLinkTimes sets the times of the path, where those of a symbolic link are left as is
+func setLinkTimes(path string, t time.Time) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	return os.Chtimes(path, t, t)
+}
@@ -0,0 +1,65 @@
+package install
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OwnerMapCurrent maps the owner of every archive entry to the user running easy-add
+const OwnerMapCurrent = "current"
+
+// OwnerMap maps the numeric user and group IDs recorded in an archive to those given to the
+// installed files, such as when extracting without root, which can't give files to other users
+type OwnerMap struct {
+	entries map[[2]int]*Owner
+}
+
+// ParseOwnerMap parses either current, which leaves every file owned by the user running
+// easy-add, or comma separated uid:gid=uid:gid pairs, such as 0:0=1000:1000, where the owners of
+// entries that aren't mapped are also left as the current user
+func ParseOwnerMap(spec string) (*OwnerMap, error) {
+	m := &OwnerMap{entries: make(map[[2]int]*Owner)}
+	if spec == OwnerMapCurrent {
+		return m, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("owner map '%s' must be current or uid:gid=uid:gid pairs separated by commas", spec)
+		}
+		from, err := parseNumericOwner(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid owner map '%s': %w", spec, err)
+		}
+		to, err := parseNumericOwner(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid owner map '%s': %w", spec, err)
+		}
+		m.entries[[2]int{from.uid, from.gid}] = to
+	}
+	return m, nil
+}
+
+func parseNumericOwner(spec string) (*Owner, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("%s must be a numeric uid:gid", spec)
+	}
+	uid, err := strconv.Atoi(parts[0])
+	if err != nil || uid < 0 {
+		return nil, fmt.Errorf("%s must be a numeric uid:gid", spec)
+	}
+	gid, err := strconv.Atoi(parts[1])
+	if err != nil || gid < 0 {
+		return nil, fmt.Errorf("%s must be a numeric uid:gid", spec)
+	}
+	return NewOwner(uid, gid), nil
+}
+
+// Owner is what the owner recorded in the archive maps to, where nil leaves the file owned by
+// the user running easy-add
+func (m *OwnerMap) Owner(uid int, gid int) *Owner {
+	return m.entries[[2]int{uid, gid}]
+}
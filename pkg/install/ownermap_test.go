@@ -0,0 +1,44 @@
+package install
+
+import "testing"
+
+func TestParseOwnerMap(t *testing.T) {
+	m, err := ParseOwnerMap("0:0=1000:1000, 33:33=1000:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		uid, gid int
+		want     *Owner
+	}{
+		{0, 0, NewOwner(1000, 1000)},
+		{33, 33, NewOwner(1000, 100)},
+		{33, 0, nil},
+		{1, 1, nil},
+	}
+	for _, test := range tests {
+		got := m.Owner(test.uid, test.gid)
+		if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+			t.Errorf("Owner(%d, %d) = %v, want %v", test.uid, test.gid, got, test.want)
+		}
+	}
+}
+
+func TestParseOwnerMapCurrent(t *testing.T) {
+	m, err := ParseOwnerMap(OwnerMapCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner := m.Owner(0, 0); owner != nil {
+		t.Errorf("current mapped 0:0 to %v rather than leaving it to the current user", owner)
+	}
+}
+
+func TestParseOwnerMapInvalid(t *testing.T) {
+	for _, spec := range []string{"", "root", "0:0", "0=1000:1000", "0:0=1000", "a:0=1:1", "0:0=-1:1"} {
+		if _, err := ParseOwnerMap(spec); err == nil {
+			t.Errorf("ParseOwnerMap(%q) succeeded", spec)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package install
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"strings"
+)
+
+// binaryPlatform is the operating system and architectures, named like GOOS and GOARCH,
+// that an executable binary was built for
+type binaryPlatform struct {
+	os    string
+	archs []string
+}
+
+func (p *binaryPlatform) String() string {
+	return p.os + "/" + strings.Join(p.archs, ",")
+}
+
+func (p *binaryPlatform) matches(goos, goarch string) bool {
+	if p.os != goos {
+		return false
+	}
+	for _, arch := range p.archs {
+		if arch == goarch {
+			return true
+		}
+	}
+	return false
+}
+
+// detectBinaryPlatform inspects the ELF, Mach-O, or PE header of the given file. It returns nil
+// when the file is not a recognized binary format, such as a script.
+func detectBinaryPlatform(filePath string) *binaryPlatform {
+	if f, err := elf.Open(filePath); err == nil {
+		//noinspection GoUnhandledErrorResult
+		defer f.Close()
+		return &binaryPlatform{os: elfOS(f), archs: []string{elfArch(f)}}
+	}
+
+	if f, err := macho.Open(filePath); err == nil {
+		//noinspection GoUnhandledErrorResult
+		defer f.Close()
+		return &binaryPlatform{os: "darwin", archs: []string{machoArch(f.Cpu)}}
+	}
+
+	if f, err := macho.OpenFat(filePath); err == nil {
+		//noinspection GoUnhandledErrorResult
+		defer f.Close()
+		p := &binaryPlatform{os: "darwin"}
+		for _, arch := range f.Arches {
+			p.archs = append(p.archs, machoArch(arch.Cpu))
+		}
+		return p
+	}
+
+	if f, err := pe.Open(filePath); err == nil {
+		//noinspection GoUnhandledErrorResult
+		defer f.Close()
+		return &binaryPlatform{os: "windows", archs: []string{peArch(f.Machine)}}
+	}
+
+	return nil
+}
+
+// StaticallyLinked reports whether the ELF binary of the content needs neither a dynamic loader
+// nor shared libraries, so that it runs in images without glibc, such as alpine or scratch. ok is
+// false when the content is not an ELF binary.
+func StaticallyLinked(content io.ReaderAt) (static bool, ok bool) {
+	f, err := elf.NewFile(content)
+	if err != nil {
+		return false, false
+	}
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_INTERP {
+			return false, true
+		}
+	}
+	// static-pie binaries have a dynamic section, but it needs no libraries
+	needed, err := f.DynString(elf.DT_NEEDED)
+	return err == nil && len(needed) == 0, true
+}
+
+func elfOS(f *elf.File) string {
+	switch f.OSABI {
+	case elf.ELFOSABI_FREEBSD:
+		return "freebsd"
+	case elf.ELFOSABI_NETBSD:
+		return "netbsd"
+	case elf.ELFOSABI_OPENBSD:
+		return "openbsd"
+	case elf.ELFOSABI_SOLARIS:
+		return "solaris"
+	default:
+		// most Linux binaries declare the generic System V ABI
+		return "linux"
+	}
+}
+
+func elfArch(f *elf.File) string {
+	switch f.Machine {
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_386:
+		return "386"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_PPC64:
+		if f.Data == elf.ELFDATA2LSB {
+			return "ppc64le"
+		}
+		return "ppc64"
+	case elf.EM_S390:
+		return "s390x"
+	case elf.EM_RISCV:
+		return "riscv64"
+	case elf.EM_LOONGARCH:
+		return "loong64"
+	default:
+		return f.Machine.String()
+	}
+}
+
+func machoArch(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuAmd64:
+		return "amd64"
+	case macho.Cpu386:
+		return "386"
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.CpuArm:
+		return "arm"
+	default:
+		return cpu.String()
+	}
+}
+
+func peArch(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "amd64"
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "386"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		return "arm"
+	default:
+		return fmt.Sprintf("machine-%#x", machine)
+	}
+}
+
+// checkBinaryPlatform warns, or fails when required, if the given file is a binary built for
+// a platform other than the target, which defaults to the one easy-add is running on
+func checkBinaryPlatform(filePath string, targetOS string, targetArch string, required bool) error {
+	if targetOS == "" {
+		targetOS = runtime.GOOS
+	}
+	if targetArch == "" {
+		targetArch = runtime.GOARCH
+	}
+	p := detectBinaryPlatform(filePath)
+	if p == nil || p.matches(targetOS, targetArch) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("extracted binary is built for %s, but the target platform is %s/%s",
+		p, targetOS, targetArch)
+	if required {
+		return errors.New(msg)
+	}
+	log.Printf("W! %s", msg)
+	return nil
+}
@@ -0,0 +1,35 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"strings"
+)
+
+const selinuxXattrName = "security.selinux"
+
+// setSELinuxType replaces the type of the SELinux security context of the given file, such
+// as changing system_u:object_r:user_tmp_t:s0 to system_u:object_r:bin_t:s0
+func setSELinuxType(filePath string, selinuxType string) error {
+	buf := make([]byte, 256)
+	n, err := unix.Getxattr(filePath, selinuxXattrName, buf)
+	if err != nil {
+		return fmt.Errorf("unable to read SELinux context of %s, which requires SELinux to be enabled: %w", filePath, err)
+	}
+
+	current := string(bytes.TrimRight(buf[:n], "\x00"))
+	// the level after the type may itself contain colons
+	parts := strings.SplitN(current, ":", 4)
+	if len(parts) < 3 {
+		return fmt.Errorf("unexpected SELinux context '%s' on %s", current, filePath)
+	}
+	parts[2] = selinuxType
+	context := strings.Join(parts, ":")
+
+	err = unix.Setxattr(filePath, selinuxXattrName, append([]byte(context), 0), 0)
+	if err != nil {
+		return fmt.Errorf("unable to set SELinux context of %s to %s: %w", filePath, context, err)
+	}
+	return nil
+}
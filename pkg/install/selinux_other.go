@@ -0,0 +1,9 @@
+//go:build !linux
+
+package install
+
+import "errors"
+
+func setSELinuxType(string, string) error {
+	return errors.New("SELinux labeling is only supported on Linux")
+}
@@ -0,0 +1,126 @@
+package install
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// capabilityNames are the Linux capabilities, without the cap_ prefix, indexed by number
+var capabilityNames = []string{
+	"chown", "dac_override", "dac_read_search", "fowner", "fsetid", "kill", "setgid", "setuid",
+	"setpcap", "linux_immutable", "net_bind_service", "net_broadcast", "net_admin", "net_raw",
+	"ipc_lock", "ipc_owner", "sys_module", "sys_rawio", "sys_chroot", "sys_ptrace", "sys_pacct",
+	"sys_admin", "sys_boot", "sys_nice", "sys_resource", "sys_time", "sys_tty_config", "mknod",
+	"lease", "audit_write", "audit_control", "setfcap", "mac_override", "mac_admin", "syslog",
+	"wake_alarm", "block_suspend", "audit_read", "perfmon", "bpf", "checkpoint_restore",
+}
+
+const (
+	// vfsCapRevision2 is the version of the security.capability extended attribute format
+	vfsCapRevision2       = 0x02000000
+	vfsCapFlagsEffective  = 0x000001
+	capabilityXattrName   = "security.capability"
+	capabilityXattrLength = 20
+)
+
+// Capabilities are the capability sets of a file where each bit is a capability number
+type Capabilities struct {
+	permitted   uint64
+	inheritable uint64
+	effective   uint64
+}
+
+// ParseCapabilities parses the textual form used by setcap, such as cap_net_bind_service=+ep
+// or "cap_net_raw,cap_net_admin+ep cap_sys_nice=i"
+func ParseCapabilities(text string) (*Capabilities, error) {
+	caps := &Capabilities{}
+
+	for _, clause := range strings.Fields(text) {
+		opIndex := strings.IndexAny(clause, "=+-")
+		if opIndex < 0 {
+			return nil, fmt.Errorf("capabilities clause '%s' is missing an =, +, or - operator", clause)
+		}
+
+		var selected uint64
+		names := strings.ToLower(clause[:opIndex])
+		if names == "" || names == "all" {
+			selected = 1<<uint(len(capabilityNames)) - 1
+		} else {
+			for _, name := range strings.Split(names, ",") {
+				bit, err := lookupCapability(name)
+				if err != nil {
+					return nil, err
+				}
+				selected |= bit
+			}
+		}
+
+		ops := clause[opIndex:]
+		for len(ops) > 0 {
+			op := ops[0]
+			end := strings.IndexAny(ops[1:], "=+-") + 1
+			if end == 0 {
+				end = len(ops)
+			}
+			flags := strings.ToLower(ops[1:end])
+			ops = ops[end:]
+
+			if op == '=' {
+				caps.permitted &^= selected
+				caps.inheritable &^= selected
+				caps.effective &^= selected
+			}
+			for _, flag := range flags {
+				var set *uint64
+				switch flag {
+				case 'p':
+					set = &caps.permitted
+				case 'i':
+					set = &caps.inheritable
+				case 'e':
+					set = &caps.effective
+				default:
+					return nil, fmt.Errorf("unknown capability flag '%c' in '%s'", flag, clause)
+				}
+				if op == '-' {
+					*set &^= selected
+				} else {
+					*set |= selected
+				}
+			}
+		}
+	}
+
+	if caps.effective != 0 && caps.effective != caps.permitted|caps.inheritable {
+		return nil, errors.New("file capabilities can only be all or none effective")
+	}
+	return caps, nil
+}
+
+func lookupCapability(name string) (uint64, error) {
+	name = strings.TrimPrefix(name, "cap_")
+	for i, n := range capabilityNames {
+		if n == name {
+			return 1 << uint(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown capability 'cap_%s'", name)
+}
+
+// xattr encodes the capabilities as the value of the security.capability extended attribute
+func (c *Capabilities) xattr() []byte {
+	magic := uint32(vfsCapRevision2)
+	if c.effective != 0 {
+		magic |= vfsCapFlagsEffective
+	}
+
+	data := make([]byte, capabilityXattrLength)
+	binary.LittleEndian.PutUint32(data[0:], magic)
+	binary.LittleEndian.PutUint32(data[4:], uint32(c.permitted))
+	binary.LittleEndian.PutUint32(data[8:], uint32(c.inheritable))
+	binary.LittleEndian.PutUint32(data[12:], uint32(c.permitted>>32))
+	binary.LittleEndian.PutUint32(data[16:], uint32(c.inheritable>>32))
+	return data
+}
@@ -0,0 +1,16 @@
+package install
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+)
+
+// setFileCapabilities writes the security.capability extended attribute, which is
+// equivalent to what setcap does
+func setFileCapabilities(filePath string, caps *Capabilities) error {
+	err := unix.Setxattr(filePath, capabilityXattrName, caps.xattr(), 0)
+	if err != nil {
+		return fmt.Errorf("unable to set capabilities of %s: %w", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package install
+
+import "errors"
+
+func setFileCapabilities(string, *Capabilities) error {
+	return errors.New("file capabilities are only supported on Linux")
+}
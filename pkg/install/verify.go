@@ -0,0 +1,26 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runVerifyCmd smoke-tests an extracted file, prior to moving it into place at installPath,
+// by executing it with the given arguments
+func runVerifyCmd(ctx context.Context, extractedPath string, installPath string, args []string) error {
+	log.Printf("I! Verifying extracted file by running it with %s", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, extractedPath, args...)
+	// present the final name to tools that look at how they were invoked
+	cmd.Args[0] = installPath
+	cmd.Stdout = log.Writer()
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("verify command failed: %w", err)
+	}
+	return nil
+}
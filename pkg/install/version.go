@@ -0,0 +1,73 @@
+package install
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultVersionRegex finds a dotted version, such as 1.2.3, in the output of a version command
+const DefaultVersionRegex = `(\d+(?:\.\d+)+)`
+
+// InstalledVersion runs the installed file with the given arguments and finds its version in
+// the output with the regex, where the first group, if any, is the version
+func InstalledVersion(ctx context.Context, filePath string, args []string, versionRegex *regexp.Regexp) (string, error) {
+	cmd := exec.CommandContext(ctx, filePath, args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("version command failed: %w", err)
+	}
+
+	match := versionRegex.FindStringSubmatch(output.String())
+	switch {
+	case match == nil:
+		return "", fmt.Errorf("no version found in the output of %s", filePath)
+	case len(match) > 1:
+		return match[1], nil
+	default:
+		return match[0], nil
+	}
+}
+
+// CompareVersions compares dotted versions, such as 1.10.2 and v1.9, returning a negative
+// number, zero, or a positive number when a is less than, equal to, or greater than b.
+// Pre-release and build suffixes after a - or + are ignored.
+func CompareVersions(a string, b string) int {
+	aParts := versionParts(a)
+	bParts := versionParts(b)
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aPart, bPart := "0", "0"
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+
+		aNum, aErr := strconv.Atoi(aPart)
+		bNum, bErr := strconv.Atoi(bPart)
+		if aErr == nil && bErr == nil {
+			if aNum != bNum {
+				return aNum - bNum
+			}
+		} else if c := strings.Compare(aPart, bPart); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) []string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	return strings.Split(version, ".")
+}
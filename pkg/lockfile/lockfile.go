@@ -0,0 +1,117 @@
+// Package lockfile records the archives that tools were resolved to and where they were installed
+package lockfile
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// DefaultPath is the lockfile used by commands that require one when none is given
+const DefaultPath = "easy-add.lock.yaml"
+
+// Lockfile records tools by name
+type Lockfile struct {
+	Tools map[string]*Entry `yaml:"tools"`
+}
+
+// Entry records how a tool was resolved and, once installed, where it was placed
+type Entry struct {
+	// From is the URL template of the archive, which is evaluated with Vars
+	From string `yaml:"from"`
+	// Parts are the URL templates of the further parts of a split archive
+	Parts []string `yaml:"parts,omitempty"`
+	File  string   `yaml:"file"`
+	// Match is how File is compared with the archive entries when not exact
+	Match string `yaml:"match,omitempty"`
+	// Pick is how one entry is chosen when File matches several
+	Pick string `yaml:"pick,omitempty"`
+	// PreferStatic narrows the entries File matches to those statically linked
+	PreferStatic bool `yaml:"preferStatic,omitempty"`
+	// All and Flatten install every entry that matches File
+	All     bool `yaml:"all,omitempty"`
+	Flatten bool `yaml:"flatten,omitempty"`
+	// StripTopDir makes File and Map relative to the top-level directory of the archive
+	StripTopDir bool `yaml:"stripTopDir,omitempty"`
+	// Map are further entries extracted each to its own path, given as entry=path
+	Map []string `yaml:"map,omitempty"`
+	// Format of the archive when not detected from From
+	Format string            `yaml:"format,omitempty"`
+	Vars   map[string]string `yaml:"vars,omitempty"`
+	// URL is the archive URL that From resolved to
+	URL string `yaml:"url"`
+	// Checksum is the digest of the archive as algorithm:hex
+	Checksum string `yaml:"checksum"`
+	To       string `yaml:"to,omitempty"`
+	// Path is where the tool was installed, if it has been
+	Path string `yaml:"path,omitempty"`
+	// SHA256 is the digest of the installed file
+	SHA256 string `yaml:"sha256,omitempty"`
+	// JavaLauncher is the name of the script created alongside Path to run it with java
+	JavaLauncher string `yaml:"javaLauncher,omitempty"`
+	// Layout is versioned when the tool was installed into a directory of its version within To
+	Layout string   `yaml:"layout,omitempty"`
+	Links  []string `yaml:"links,omitempty"`
+}
+
+// Load reads the lockfile at the given path, where a missing file is treated as empty
+func Load(path string) (*Lockfile, error) {
+	l := &Lockfile{Tools: make(map[string]*Entry)}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read lockfile: %w", err)
+	}
+
+	err = yaml.Unmarshal(content, l)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse lockfile %s: %w", path, err)
+	}
+	if l.Tools == nil {
+		l.Tools = make(map[string]*Entry)
+	}
+	return l, nil
+}
+
+// Save writes the lockfile to the given path
+func (l *Lockfile) Save(path string) error {
+	content, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write lockfile: %w", err)
+	}
+	return nil
+}
+
+// Names returns the names of the tools in sorted order
+func (l *Lockfile) Names() []string {
+	names := make([]string, 0, len(l.Tools))
+	for name := range l.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select validates the given names are in the lockfile and returns them or, when none are
+// given, returns all names
+func (l *Lockfile) Select(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return l.Names(), nil
+	}
+
+	for _, name := range names {
+		if _, exists := l.Tools[name]; !exists {
+			return nil, fmt.Errorf("%s is not in the lockfile", name)
+		}
+	}
+	return names, nil
+}
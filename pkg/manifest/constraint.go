@@ -0,0 +1,161 @@
+package manifest
+
+import (
+	"fmt"
+	"github.com/itzg/easy-add/pkg/install"
+	"strconv"
+	"strings"
+)
+
+// Constraint limits the versions a tool can be updated to. It is made of space separated terms,
+// all of which a version must satisfy, where each term is one of
+//
+//	~1.7      1.7 or newer, but older than 1.8
+//	^1.7.0    1.7.0 or newer, but older than 2, or older than 0.4 for ^0.3.1
+//	1.x       1 or newer, but older than 2, as is 1.*
+//	>=1.2     along with >, <=, <, and =, compared to the version
+//	1.7.5     exactly that version
+type Constraint struct {
+	text  string
+	terms []constraintTerm
+}
+
+type constraintTerm struct {
+	op      string
+	version string
+}
+
+// ParseConstraint parses the constraint, where an empty one allows any version
+func ParseConstraint(text string) (*Constraint, error) {
+	c := &Constraint{text: text}
+	for _, field := range strings.Fields(text) {
+		terms, err := parseConstraintTerm(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint '%s': %w", text, err)
+		}
+		c.terms = append(c.terms, terms...)
+	}
+	return c, nil
+}
+
+func parseConstraintTerm(field string) ([]constraintTerm, error) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(field, op) {
+			version := strings.TrimPrefix(field, op)
+			if _, err := numericParts(version); err != nil {
+				return nil, err
+			}
+			return []constraintTerm{{op: op, version: version}}, nil
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(field, "~"):
+		parts, err := numericParts(field[1:])
+		if err != nil {
+			return nil, err
+		}
+		// the minor version may not change, or the major version when given alone
+		position := 1
+		if len(parts) == 1 {
+			position = 0
+		}
+		return []constraintTerm{{op: ">=", version: field[1:]}, {op: "<", version: bump(parts, position)}}, nil
+
+	case strings.HasPrefix(field, "^"):
+		parts, err := numericParts(field[1:])
+		if err != nil {
+			return nil, err
+		}
+		// the leftmost non-zero part may not change
+		position := 0
+		for position < len(parts)-1 && parts[position] == 0 {
+			position++
+		}
+		return []constraintTerm{{op: ">=", version: field[1:]}, {op: "<", version: bump(parts, position)}}, nil
+
+	case field == "*" || field == "x":
+		return nil, nil
+
+	case strings.HasSuffix(field, ".x") || strings.HasSuffix(field, ".*"):
+		prefix := field[:len(field)-2]
+		parts, err := numericParts(prefix)
+		if err != nil {
+			return nil, err
+		}
+		return []constraintTerm{{op: ">=", version: prefix}, {op: "<", version: bump(parts, len(parts)-1)}}, nil
+
+	default:
+		if _, err := numericParts(field); err != nil {
+			return nil, err
+		}
+		return []constraintTerm{{op: "=", version: field}}, nil
+	}
+}
+
+// numericParts splits the version, such as 1.7.5 or v1.7, into its numbers
+func numericParts(version string) ([]int, error) {
+	trimmed := strings.TrimPrefix(version, "v")
+	if trimmed == "" {
+		return nil, fmt.Errorf("missing version")
+	}
+	var parts []int
+	for _, part := range strings.Split(trimmed, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s is not a version of numbers separated by dots", version)
+		}
+		parts = append(parts, n)
+	}
+	return parts, nil
+}
+
+// bump increments the part at the position and drops those after it, such that 1.7.5 bumped
+// at 1 is 1.8
+func bump(parts []int, position int) string {
+	bumped := make([]string, position+1)
+	for i := 0; i < position; i++ {
+		bumped[i] = strconv.Itoa(parts[i])
+	}
+	bumped[position] = strconv.Itoa(parts[position] + 1)
+	return strings.Join(bumped, ".")
+}
+
+// Allows reports if the version satisfies all terms of the constraint
+func (c *Constraint) Allows(version string) bool {
+	for _, term := range c.terms {
+		compared := install.CompareVersions(version, term.version)
+		var ok bool
+		switch term.op {
+		case ">=":
+			ok = compared >= 0
+		case ">":
+			ok = compared > 0
+		case "<=":
+			ok = compared <= 0
+		case "<":
+			ok = compared < 0
+		default:
+			ok = compared == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Newest returns the newest of the versions the constraint allows or an empty string when none are
+func (c *Constraint) Newest(versions []string) string {
+	var newest string
+	for _, version := range versions {
+		if c.Allows(version) && (newest == "" || install.CompareVersions(version, newest) > 0) {
+			newest = version
+		}
+	}
+	return newest
+}
+
+func (c *Constraint) String() string {
+	return c.text
+}
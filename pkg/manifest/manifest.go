@@ -0,0 +1,219 @@
+// Package manifest declares the tools to install, pinned to versions and digests, in a form that
+// dependency update bots can bump. Each tool places its repo and version on adjacent lines, such as
+//
+//	tools:
+//	  - name: restify
+//	    repo: itzg/restify
+//	    version: 1.7.5
+//	    digests:
+//	      linux/amd64: sha256:...
+//
+// and the digests are then refreshed by easy-add lock --update-checksums, or the versions bumped
+// along with them by easy-add lock --update.
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/itzg/easy-add/pkg/catalog"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// DefaultPath is the manifest used when none is given
+const DefaultPath = "tools.yaml"
+
+// Manifest declares tools to install
+type Manifest struct {
+	// Platforms are given as os/arch using Go's names and default to the current platform
+	Platforms []string `yaml:"platforms,omitempty"`
+	// To is the directory where the tools are installed, which defaults to install.DefaultDir
+	To    string  `yaml:"to,omitempty"`
+	Tools []*Tool `yaml:"tools"`
+
+	// node retains the document, including comments, for saving
+	node yaml.Node
+}
+
+// Tool is a pinned version of a tool, which is either declared in full or found in the catalog by name
+type Tool struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	// Constraint limits the versions that easy-add lock --update moves the tool to, such as ~1.7,
+	// as described by ParseConstraint
+	Constraint string `yaml:"constraint,omitempty"`
+	// Tool optionally declares how to download the tool, which otherwise comes from the catalog
+	catalog.Tool `yaml:",inline"`
+	// Digests are the checksums of the downloads, as algorithm:hex, by os/arch
+	Digests map[string]string `yaml:"digests,omitempty"`
+}
+
+// Load reads the manifest at the given path
+func Load(path string) (*Manifest, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest: %w", err)
+	}
+
+	m := &Manifest{}
+	err = yaml.Unmarshal(content, &m.node)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse manifest %s: %w", path, err)
+	}
+	err = m.node.Decode(m)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse manifest %s: %w", path, err)
+	}
+
+	for i, tool := range m.Tools {
+		if tool == nil || tool.Name == "" || tool.Version == "" {
+			return nil, fmt.Errorf("tool %d of manifest %s requires name and version", i+1, path)
+		}
+	}
+	return m, nil
+}
+
+// Save writes the manifest to the given path, where only the versions and digests of tools are
+// updated so that comments and formatting are retained
+func (m *Manifest) Save(path string) error {
+	toolsNode := mappingValue(documentRoot(&m.node), "tools")
+	if toolsNode == nil || len(toolsNode.Content) != len(m.Tools) {
+		return fmt.Errorf("the tools of manifest %s no longer match what was loaded", path)
+	}
+	for i, tool := range m.Tools {
+		setVersion(toolsNode.Content[i], tool.Version)
+		setDigests(toolsNode.Content[i], tool.Digests)
+	}
+
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	// match the indentation typically written by hand
+	encoder.SetIndent(2)
+	err := encoder.Encode(&m.node)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, content.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write manifest: %w", err)
+	}
+	return nil
+}
+
+// PlatformList returns the platforms of the manifest as os and arch pairs
+func (m *Manifest) PlatformList() ([][2]string, error) {
+	platforms := m.Platforms
+	if len(platforms) == 0 {
+		platforms = []string{runtime.GOOS + "/" + runtime.GOARCH}
+	}
+
+	var pairs [][2]string
+	for _, platform := range platforms {
+		parts := strings.Split(platform, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid platform '%s', must be os/arch", platform)
+		}
+		pairs = append(pairs, [2]string{parts[0], parts[1]})
+	}
+	return pairs, nil
+}
+
+// Select validates the given names are in the manifest and returns their tools or, when none
+// are given, returns all tools
+func (m *Manifest) Select(names []string) ([]*Tool, error) {
+	if len(names) == 0 {
+		return m.Tools, nil
+	}
+
+	var tools []*Tool
+	for _, name := range names {
+		tool := m.Lookup(name)
+		if tool == nil {
+			return nil, fmt.Errorf("%s is not in the manifest", name)
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// Lookup finds the named tool or returns nil
+func (m *Manifest) Lookup(name string) *Tool {
+	for _, tool := range m.Tools {
+		if tool.Name == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+// Definition returns how to download the tool, which is declared by the tool itself when it
+// has a from or otherwise found in the catalog by name
+func (t *Tool) Definition(c *catalog.Catalog) (*catalog.Tool, error) {
+	if t.From != "" {
+		if t.File == "" {
+			return nil, fmt.Errorf("tool %s requires file along with from", t.Name)
+		}
+		return &t.Tool, nil
+	}
+	return c.Lookup(t.Name)
+}
+
+func documentRoot(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	return node
+}
+
+// mappingValue returns the value node of the key within the mapping node or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setVersion replaces the value of the version, keeping its quoting
+func setVersion(toolNode *yaml.Node, version string) {
+	if existing := mappingValue(toolNode, "version"); existing != nil {
+		existing.Value = version
+	}
+}
+
+func setDigests(toolNode *yaml.Node, digests map[string]string) {
+	if len(digests) == 0 {
+		return
+	}
+
+	platforms := make([]string, 0, len(digests))
+	for platform := range digests {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	digestsNode := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, platform := range platforms {
+		digestsNode.Content = append(digestsNode.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: platform},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: digests[platform]},
+		)
+	}
+
+	if existing := mappingValue(toolNode, "digests"); existing != nil {
+		existing.Kind, existing.Tag, existing.Style, existing.Content =
+			digestsNode.Kind, digestsNode.Tag, digestsNode.Style, digestsNode.Content
+		return
+	}
+	toolNode.Content = append(toolNode.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "digests"},
+		digestsNode,
+	)
+}
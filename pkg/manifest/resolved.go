@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+)
+
+// Resolved is a manifest whose tools are resolved to the concrete download of each platform, so
+// that they can be installed elsewhere without the catalog or evaluating templates, and always
+// from the same archives
+type Resolved struct {
+	// To is the directory where the tools are installed, which defaults to install.DefaultDir
+	To    string          `yaml:"to,omitempty"`
+	Tools []*ResolvedTool `yaml:"tools"`
+}
+
+// ResolvedTool is a tool of a Resolved manifest
+type ResolvedTool struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	// Downloads are by os/arch using Go's names
+	Downloads map[string]*Download `yaml:"downloads"`
+}
+
+// Download is the archive of a tool for a platform
+type Download struct {
+	URL  string `yaml:"url"`
+	File string `yaml:"file"`
+	// Format of the archive, such as binary, which is otherwise detected from the suffix of URL
+	Format string `yaml:"format,omitempty"`
+	// Checksum is the digest of the archive as algorithm:hex
+	Checksum string `yaml:"checksum"`
+}
+
+// LoadResolved reads the resolved manifest at the given path
+func LoadResolved(path string) (*Resolved, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read resolved manifest: %w", err)
+	}
+
+	r := &Resolved{}
+	err = yaml.Unmarshal(content, r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse resolved manifest %s: %w", path, err)
+	}
+	for i, tool := range r.Tools {
+		if tool == nil || tool.Name == "" {
+			return nil, fmt.Errorf("tool %d of resolved manifest %s requires a name", i+1, path)
+		}
+		for platform, download := range tool.Downloads {
+			if download == nil || download.URL == "" || download.File == "" || download.Checksum == "" {
+				return nil, fmt.Errorf("the %s download of %s in resolved manifest %s requires url, file, and checksum",
+					platform, tool.Name, path)
+			}
+		}
+	}
+	return r, nil
+}
+
+// Encode formats the resolved manifest as YAML
+func (r *Resolved) Encode() ([]byte, error) {
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	// match the indentation typically written by hand
+	encoder.SetIndent(2)
+	err := encoder.Encode(r)
+	if err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
+
+// Select validates the given names are in the resolved manifest and returns their tools or, when
+// none are given, returns all tools
+func (r *Resolved) Select(names []string) ([]*ResolvedTool, error) {
+	if len(names) == 0 {
+		return r.Tools, nil
+	}
+
+	var tools []*ResolvedTool
+	for _, name := range names {
+		var found *ResolvedTool
+		for _, tool := range r.Tools {
+			if tool.Name == name {
+				found = tool
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("%s is not in the resolved manifest", name)
+		}
+		tools = append(tools, found)
+	}
+	return tools, nil
+}
@@ -0,0 +1,138 @@
+// Package minisign verifies signatures made by minisign and compatible tools, such as rsign and
+// signify-based release tooling
+package minisign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/blake2b"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	algorithmEd        = "Ed"
+	algorithmPrehashed = "ED"
+	keyIDSize          = 8
+	untrustedPrefix    = "untrusted comment:"
+	trustedPrefix      = "trusted comment: "
+)
+
+// PublicKey is a minisign public key
+type PublicKey struct {
+	KeyID [keyIDSize]byte
+	key   ed25519.PublicKey
+}
+
+// String provides the key ID as minisign displays it
+func (k *PublicKey) String() string {
+	id := make([]byte, keyIDSize)
+	// minisign displays the little-endian key ID as a number
+	for i := range id {
+		id[i] = k.KeyID[keyIDSize-1-i]
+	}
+	return strings.ToUpper(hex.EncodeToString(id))
+}
+
+// Signature is a parsed minisign signature file
+type Signature struct {
+	Algorithm      string
+	KeyID          [keyIDSize]byte
+	TrustedComment string
+	signature      []byte
+	globalSig      []byte
+}
+
+// ParsePublicKey parses the base64 public key, such as given to minisign -P, or the content of a
+// public key file, which has an untrusted comment line before the key
+func ParsePublicKey(content string) (*PublicKey, error) {
+	var encoded string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, untrustedPrefix) {
+			encoded = line
+			break
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minisign public key: %w", err)
+	}
+	if len(decoded) != 2+keyIDSize+ed25519.PublicKeySize || string(decoded[:2]) != algorithmEd {
+		return nil, errors.New("invalid minisign public key")
+	}
+	publicKey := &PublicKey{key: ed25519.PublicKey(decoded[2+keyIDSize:])}
+	copy(publicKey.KeyID[:], decoded[2:])
+	return publicKey, nil
+}
+
+// Parse parses the content of a .minisig file
+func Parse(content []byte) (*Signature, error) {
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], untrustedPrefix) ||
+		!strings.HasPrefix(lines[2], trustedPrefix) {
+		return nil, errors.New("not a minisign signature")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid minisign signature: %w", err)
+	}
+	if len(decoded) != 2+keyIDSize+ed25519.SignatureSize {
+		return nil, errors.New("invalid minisign signature")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return nil, errors.New("invalid minisign trusted comment signature")
+	}
+
+	signature := &Signature{
+		Algorithm:      string(decoded[:2]),
+		TrustedComment: strings.TrimSuffix(strings.TrimPrefix(lines[2], trustedPrefix), "\r"),
+		signature:      decoded[2+keyIDSize:],
+		globalSig:      globalSig,
+	}
+	copy(signature.KeyID[:], decoded[2:])
+	if signature.Algorithm != algorithmEd && signature.Algorithm != algorithmPrehashed {
+		return nil, fmt.Errorf("unsupported minisign signature algorithm %q", signature.Algorithm)
+	}
+	return signature, nil
+}
+
+// Verify checks that the signature of the message was made by the public key, along with the
+// signature of the trusted comment
+func (s *Signature) Verify(message io.Reader, publicKey *PublicKey) error {
+	if s.KeyID != publicKey.KeyID {
+		return fmt.Errorf("signed by key %s rather than %s", (&PublicKey{KeyID: s.KeyID}).String(), publicKey)
+	}
+
+	var signed []byte
+	if s.Algorithm == algorithmPrehashed {
+		h, _ := blake2b.New512(nil)
+		if _, err := io.Copy(h, message); err != nil {
+			return fmt.Errorf("failed to read signed content: %w", err)
+		}
+		signed = h.Sum(nil)
+	} else {
+		var err error
+		signed, err = ioutil.ReadAll(message)
+		if err != nil {
+			return fmt.Errorf("failed to read signed content: %w", err)
+		}
+	}
+	if !ed25519.Verify(publicKey.key, signed, s.signature) {
+		return errors.New("minisign signature is not valid")
+	}
+
+	global := bytes.NewBuffer(append([]byte{}, s.signature...))
+	global.WriteString(s.TrustedComment)
+	if !ed25519.Verify(publicKey.key, global.Bytes(), s.globalSig) {
+		return errors.New("minisign trusted comment signature is not valid")
+	}
+	return nil
+}
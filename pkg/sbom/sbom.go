@@ -0,0 +1,142 @@
+// Package sbom describes installed files as a CycloneDX document that image SBOM pipelines can merge
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Component describes an installed file
+type Component struct {
+	Name    string
+	Version string
+	// Repo is the GitHub owner/name of the tool, if known, which is used for its package URL
+	Repo string
+	// Tag is the release tag of the version, when it differs, such as v1.2.3 for 1.2.3
+	Tag string
+	// SourceURL is where the archive was downloaded from
+	SourceURL string
+	SHA256    string
+	// License is an SPDX license ID, if known
+	License string
+	Path    string
+}
+
+type document struct {
+	BOMFormat   string      `json:"bomFormat"`
+	SpecVersion string      `json:"specVersion"`
+	Version     int         `json:"version"`
+	Metadata    metadata    `json:"metadata"`
+	Components  []component `json:"components"`
+}
+
+type metadata struct {
+	Tools struct {
+		Components []toolComponent `json:"components"`
+	} `json:"tools"`
+}
+
+type toolComponent struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type component struct {
+	Type               string              `json:"type"`
+	Name               string              `json:"name"`
+	Version            string              `json:"version,omitempty"`
+	Purl               string              `json:"purl,omitempty"`
+	Hashes             []hash              `json:"hashes,omitempty"`
+	Licenses           []licenseChoice     `json:"licenses,omitempty"`
+	ExternalReferences []externalReference `json:"externalReferences,omitempty"`
+	Properties         []property          `json:"properties,omitempty"`
+}
+
+type hash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type licenseChoice struct {
+	License struct {
+		ID string `json:"id"`
+	} `json:"license"`
+}
+
+type externalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// WriteCycloneDX writes a CycloneDX 1.5 JSON document with the given components, where
+// toolVersion is the version of easy-add that installed them
+func WriteCycloneDX(w io.Writer, components []Component, toolVersion string) error {
+	doc := document{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Components:  make([]component, 0, len(components)),
+	}
+	doc.Metadata.Tools.Components = []toolComponent{
+		{Type: "application", Name: "easy-add", Version: toolVersion},
+	}
+
+	for _, c := range components {
+		converted := component{
+			Type:    "application",
+			Name:    c.Name,
+			Version: c.Version,
+		}
+		if c.Repo != "" && strings.Count(c.Repo, "/") == 1 {
+			converted.Purl = "pkg:github/" + strings.ToLower(c.Repo)
+			if c.Tag != "" {
+				converted.Purl += "@" + c.Tag
+			} else if c.Version != "" {
+				converted.Purl += "@" + c.Version
+			}
+		}
+		if c.SHA256 != "" {
+			converted.Hashes = []hash{{Alg: "SHA-256", Content: c.SHA256}}
+		}
+		if c.License != "" {
+			var license licenseChoice
+			license.License.ID = c.License
+			converted.Licenses = []licenseChoice{license}
+		}
+		if c.SourceURL != "" {
+			converted.ExternalReferences = []externalReference{{Type: "distribution", URL: c.SourceURL}}
+		}
+		if c.Path != "" {
+			converted.Properties = []property{{Name: "easy-add:path", Value: c.Path}}
+		}
+		doc.Components = append(doc.Components, converted)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// WriteCycloneDXFile writes the document to the file at the given path
+func WriteCycloneDXFile(path string, components []Component, toolVersion string) error {
+	var content strings.Builder
+	err := WriteCycloneDX(&content, components, toolVersion)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(path, []byte(content.String()), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write SBOM: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package sshsig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+)
+
+// AllowedSigner is an entry of an allowed signers file, as described by the ALLOWED SIGNERS
+// section of ssh-keygen(1)
+type AllowedSigner struct {
+	// Principals are patterns, such as *@example.com, of who the key may sign as
+	Principals []string
+	PublicKey  ssh.PublicKey
+	// Namespaces, when non-empty, restricts what the key may sign
+	Namespaces  []string
+	ValidAfter  time.Time
+	ValidBefore time.Time
+}
+
+// ParseAllowedSigners parses the lines of an allowed signers file. Entries marked as a
+// cert-authority are skipped since signatures made with certificates are not supported.
+func ParseAllowedSigners(r io.Reader) ([]*AllowedSigner, error) {
+	var signers []*AllowedSigner
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d of allowed signers is missing a key", lineNum)
+		}
+		publicKey, _, options, _, err := ssh.ParseAuthorizedKey([]byte(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d of allowed signers: %w", lineNum, err)
+		}
+
+		signer := &AllowedSigner{
+			Principals: strings.Split(fields[0], ","),
+			PublicKey:  publicKey,
+		}
+		certAuthority := false
+		for _, option := range options {
+			name, value := option, ""
+			if i := strings.Index(option, "="); i >= 0 {
+				name, value = option[:i], strings.Trim(option[i+1:], `"`)
+			}
+			switch strings.ToLower(name) {
+			case "cert-authority":
+				certAuthority = true
+			case "namespaces":
+				signer.Namespaces = strings.Split(value, ",")
+			case "valid-after":
+				signer.ValidAfter, err = parseTimestamp(value)
+			case "valid-before":
+				signer.ValidBefore, err = parseTimestamp(value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d of allowed signers: %w", lineNum, err)
+			}
+		}
+		if !certAuthority {
+			signers = append(signers, signer)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return signers, nil
+}
+
+// LoadAllowedSigners reads the allowed signers file at the path
+func LoadAllowedSigners(filePath string) ([]*AllowedSigner, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read allowed signers: %w", err)
+	}
+	return ParseAllowedSigners(bytes.NewReader(content))
+}
+
+// allows determines if the signer may sign in the namespace, as the principal when non-empty, at this time
+func (a *AllowedSigner) allows(namespace string, principal string) bool {
+	now := time.Now()
+	if !a.ValidAfter.IsZero() && now.Before(a.ValidAfter) {
+		return false
+	}
+	if !a.ValidBefore.IsZero() && now.After(a.ValidBefore) {
+		return false
+	}
+	if len(a.Namespaces) > 0 && !matchesAny(a.Namespaces, namespace) {
+		return false
+	}
+	return principal == "" || matchesAny(a.Principals, principal)
+}
+
+// matchesAny applies the patterns of ssh_config(5), where * and ? are wildcards and a leading ! negates
+func matchesAny(patterns []string, value string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		if ok, _ := path.Match(strings.TrimPrefix(pattern, "!"), value); ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// parseTimestamp parses the YYYYMMDD[HHMM[SS]] form of valid-after and valid-before, which are
+// in local time unless suffixed with Z
+func parseTimestamp(value string) (time.Time, error) {
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") {
+		loc = time.UTC
+		value = strings.TrimSuffix(value, "Z")
+	}
+	for _, layout := range []string{"20060102150405", "200601021504", "20060102"} {
+		if len(value) == len(layout) {
+			return time.ParseInLocation(layout, value, loc)
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %s", value)
+}
@@ -0,0 +1,133 @@
+// Package sshsig verifies signatures made by ssh-keygen -Y sign against the allowed signers
+// file used by ssh-keygen -Y verify
+package sshsig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"hash"
+	"io"
+)
+
+const (
+	magic         = "SSHSIG"
+	pemType       = "SSH SIGNATURE"
+	formatVersion = 1
+)
+
+// Signature is a parsed SSH signature
+type Signature struct {
+	PublicKey     ssh.PublicKey
+	Namespace     string
+	HashAlgorithm string
+	signature     *ssh.Signature
+}
+
+// sigBlob is the binary form of an SSH signature after the magic preamble
+type sigBlob struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// signedData is what the signature is computed over
+type signedData struct {
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// Parse parses the armored signature written by ssh-keygen -Y sign
+func Parse(armored []byte) (*Signature, error) {
+	block, _ := pem.Decode(armored)
+	if block == nil || block.Type != pemType {
+		return nil, errors.New("not an SSH signature")
+	}
+	if !bytes.HasPrefix(block.Bytes, []byte(magic)) {
+		return nil, errors.New("invalid SSH signature preamble")
+	}
+
+	var blob sigBlob
+	err := ssh.Unmarshal(block.Bytes[len(magic):], &blob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH signature: %w", err)
+	}
+	if blob.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported SSH signature version %d", blob.Version)
+	}
+
+	publicKey, err := ssh.ParsePublicKey(blob.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of SSH signature: %w", err)
+	}
+	if _, isCert := publicKey.(*ssh.Certificate); isCert {
+		return nil, errors.New("SSH signatures made with certificates are not supported")
+	}
+
+	signature := new(ssh.Signature)
+	err = ssh.Unmarshal(blob.Signature, signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH signature: %w", err)
+	}
+
+	return &Signature{
+		PublicKey:     publicKey,
+		Namespace:     blob.Namespace,
+		HashAlgorithm: blob.HashAlgorithm,
+		signature:     signature,
+	}, nil
+}
+
+// Verify confirms the signature is of the message in the given namespace, such as file, by
+// one of the allowed signers. When principal is non-empty, the signer must be allowed for it.
+// The principals of the matching allowed signer are returned.
+func (s *Signature) Verify(message io.Reader, namespace string, signers []*AllowedSigner, principal string) ([]string, error) {
+	if s.Namespace != namespace {
+		return nil, fmt.Errorf("SSH signature is for the namespace %s rather than %s", s.Namespace, namespace)
+	}
+
+	var hasher hash.Hash
+	switch s.HashAlgorithm {
+	case "sha256":
+		hasher = sha256.New()
+	case "sha512":
+		hasher = sha512.New()
+	default:
+		return nil, fmt.Errorf("unsupported SSH signature hash algorithm %s", s.HashAlgorithm)
+	}
+	_, err := io.Copy(hasher, message)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append([]byte(magic), ssh.Marshal(signedData{
+		Namespace:     s.Namespace,
+		HashAlgorithm: s.HashAlgorithm,
+		Hash:          hasher.Sum(nil),
+	})...)
+	err = s.PublicKey.Verify(data, s.signature)
+	if err != nil {
+		return nil, fmt.Errorf("SSH signature is invalid: %w", err)
+	}
+
+	keyBytes := s.PublicKey.Marshal()
+	for _, signer := range signers {
+		if !bytes.Equal(signer.PublicKey.Marshal(), keyBytes) || !signer.allows(namespace, principal) {
+			continue
+		}
+		return signer.Principals, nil
+	}
+	if principal != "" {
+		return nil, fmt.Errorf("SSH signing key %s is not allowed for %s", ssh.FingerprintSHA256(s.PublicKey), principal)
+	}
+	return nil, fmt.Errorf("SSH signing key %s is not an allowed signer", ssh.FingerprintSHA256(s.PublicKey))
+}
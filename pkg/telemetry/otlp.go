@@ -0,0 +1,276 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportOptions declares where and how the recorded telemetry is exported
+type ExportOptions struct {
+	// Endpoint is the base URL of an OTLP/HTTP receiver, such as http://localhost:4318, where
+	// traces and metrics are posted to /v1/traces and /v1/metrics
+	Endpoint string
+	// Headers are added to each request, such as for authorization
+	Headers map[string]string
+	// ServiceName identifies easy-add in the resource of the telemetry
+	ServiceName string
+	Version     string
+	Client      *http.Client
+}
+
+// ParseHeaders parses headers given as comma separated key=value pairs with URL encoded values,
+// as in the OTEL_EXPORTER_OTLP_HEADERS environment variable
+func ParseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid OTLP header '%s', which must be key=value", pair)
+		}
+		decoded, err := url.QueryUnescape(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP header '%s': %w", pair, err)
+		}
+		headers[strings.TrimSpace(parts[0])] = decoded
+	}
+	return headers, nil
+}
+
+// Export posts the ended spans and the counters using the JSON encoding of OTLP/HTTP
+func (r *Recorder) Export(ctx context.Context, opts ExportOptions) error {
+	r.mu.Lock()
+	traces, metrics := r.encode(opts)
+	r.mu.Unlock()
+
+	endpoint := strings.TrimSuffix(opts.Endpoint, "/")
+	if traces != nil {
+		err := post(ctx, opts, endpoint+"/v1/traces", traces)
+		if err != nil {
+			return err
+		}
+	}
+	if metrics != nil {
+		return post(ctx, opts, endpoint+"/v1/metrics", metrics)
+	}
+	return nil
+}
+
+func post(ctx context.Context, opts ExportOptions, endpoint string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid OTLP endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range opts.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export telemetry: %w", err)
+	}
+	//noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to export telemetry to %s: %s", endpoint, resp.Status)
+	}
+	return nil
+}
+
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpDataPoint struct {
+	AsInt             string `json:"asInt"`
+	StartTimeUnixNano string `json:"startTimeUnixNano"`
+	TimeUnixNano      string `json:"timeUnixNano"`
+}
+
+type otlpMetric struct {
+	Name string `json:"name"`
+	Unit string `json:"unit"`
+	Sum  struct {
+		DataPoints             []otlpDataPoint `json:"dataPoints"`
+		AggregationTemporality int             `json:"aggregationTemporality"`
+		IsMonotonic            bool            `json:"isMonotonic"`
+	} `json:"sum"`
+}
+
+type otlpMetrics struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+const (
+	spanKindInternal      = 1
+	statusCodeOk          = 1
+	statusCodeError       = 2
+	temporalityCumulative = 2
+)
+
+// encode converts what was recorded into OTLP requests, which are nil when there is nothing to export
+func (r *Recorder) encode(opts ExportOptions) (*otlpTraces, *otlpMetrics) {
+	serviceName := opts.ServiceName
+	if serviceName == "" {
+		serviceName = "easy-add"
+	}
+	resource := otlpResource{Attributes: []otlpKeyValue{keyValue("service.name", serviceName)}}
+	if opts.Version != "" {
+		resource.Attributes = append(resource.Attributes, keyValue("service.version", opts.Version))
+	}
+	scope := otlpScope{Name: "github.com/itzg/easy-add", Version: opts.Version}
+
+	var spans []otlpSpan
+	for _, span := range r.spans {
+		if span.end.IsZero() {
+			continue
+		}
+		encoded := otlpSpan{
+			TraceID:           hex.EncodeToString(r.traceID[:]),
+			SpanID:            hex.EncodeToString(span.id[:]),
+			Name:              span.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(span.start),
+			EndTimeUnixNano:   unixNano(span.end),
+		}
+		if span.parentID != [8]byte{} {
+			encoded.ParentSpanID = hex.EncodeToString(span.parentID[:])
+		}
+		keys := make([]string, 0, len(span.attributes))
+		for key := range span.attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			encoded.Attributes = append(encoded.Attributes, keyValue(key, span.attributes[key]))
+		}
+		if span.err != nil {
+			encoded.Status.Code = statusCodeError
+			encoded.Status.Message = span.err.Error()
+		} else {
+			encoded.Status.Code = statusCodeOk
+		}
+		spans = append(spans, encoded)
+	}
+
+	var traces *otlpTraces
+	if len(spans) > 0 {
+		traces = &otlpTraces{ResourceSpans: []otlpResourceSpans{{
+			Resource:   resource,
+			ScopeSpans: []otlpScopeSpans{{Scope: scope, Spans: spans}},
+		}}}
+	}
+
+	names := make([]string, 0, len(r.counters))
+	for name := range r.counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := unixNano(time.Now())
+	var metrics []otlpMetric
+	for _, name := range names {
+		metric := otlpMetric{Name: name, Unit: r.counters[name].unit}
+		metric.Sum.AggregationTemporality = temporalityCumulative
+		metric.Sum.IsMonotonic = true
+		metric.Sum.DataPoints = []otlpDataPoint{{
+			AsInt:             strconv.FormatInt(r.counters[name].value, 10),
+			StartTimeUnixNano: unixNano(r.start),
+			TimeUnixNano:      now,
+		}}
+		metrics = append(metrics, metric)
+	}
+
+	var encodedMetrics *otlpMetrics
+	if len(metrics) > 0 {
+		encodedMetrics = &otlpMetrics{ResourceMetrics: []otlpResourceMetrics{{
+			Resource:     resource,
+			ScopeMetrics: []otlpScopeMetrics{{Scope: scope, Metrics: metrics}},
+		}}}
+	}
+	return traces, encodedMetrics
+}
+
+func keyValue(key string, value string) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	kv.Value.StringValue = value
+	return kv
+}
+
+// unixNano formats the time as OTLP JSON does for 64-bit integers
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
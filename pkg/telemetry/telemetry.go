@@ -0,0 +1,155 @@
+// Package telemetry records the spans and counters of a run, which are exported with OTLP so that
+// runs of easy-add within a build system show up in its tracing dashboards. Nothing is recorded
+// unless a Recorder is attached to the context.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recorder collects the spans and counters of one trace
+type Recorder struct {
+	mu      sync.Mutex
+	traceID [16]byte
+	// parentID is the span of the caller, given by a traceparent, which the first spans are children of
+	parentID [8]byte
+	start    time.Time
+	spans    []*Span
+	counters map[string]*counter
+}
+
+type counter struct {
+	unit  string
+	value int64
+}
+
+// Span is an operation of the trace, which is ended once it completes
+type Span struct {
+	recorder   *Recorder
+	id         [8]byte
+	parentID   [8]byte
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        error
+}
+
+type contextKey int
+
+const (
+	recorderKey contextKey = iota
+	spanKey
+)
+
+// NewRecorder starts a trace, which continues that of the W3C traceparent when one is given,
+// such as from the TRACEPARENT environment variable set by a build system
+func NewRecorder(traceparent string) *Recorder {
+	r := &Recorder{
+		start:    time.Now(),
+		counters: make(map[string]*counter),
+	}
+	if !parseTraceparent(traceparent, r) {
+		_, _ = rand.Read(r.traceID[:])
+	}
+	return r
+}
+
+// parseTraceparent parses a traceparent such as 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func parseTraceparent(value string, r *Recorder) bool {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return false
+	}
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	parentID, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	copy(r.traceID[:], traceID)
+	copy(r.parentID[:], parentID)
+	return true
+}
+
+// WithRecorder attaches the recorder to the context, which enables Start and Add
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey, r)
+}
+
+// FromContext provides the recorder attached to the context, if any
+func FromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey).(*Recorder)
+	return r
+}
+
+// Start begins a span that is a child of the span of the context, if any. The returned context
+// carries the new span for its children. When no recorder is attached, the span is nil, which
+// is safe to use.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	r := FromContext(ctx)
+	if r == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		recorder:   r,
+		name:       name,
+		start:      time.Now(),
+		parentID:   r.parentID,
+		attributes: make(map[string]string),
+	}
+	_, _ = rand.Read(span.id[:])
+	if parent, ok := ctx.Value(spanKey).(*Span); ok {
+		span.parentID = parent.id
+	}
+
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+// SetAttribute records a detail of the span, such as the URL being downloaded
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// End completes the span, which failed when err is not nil
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.end = time.Now()
+	s.err = err
+}
+
+// Add increases the named counter, such as of downloaded bytes, where unit is as given by UCUM,
+// such as By for bytes or 1 for a count
+func Add(ctx context.Context, name string, unit string, delta int64) {
+	r := FromContext(ctx)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, exists := r.counters[name]
+	if !exists {
+		c = &counter{unit: unit}
+		r.counters[name] = c
+	}
+	c.value += delta
+}
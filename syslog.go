@@ -0,0 +1,28 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"log/syslog"
+)
+
+// openSyslog connects to the system log, which is captured by the journal on hosts with systemd,
+// and provides what sends each message with the priority of its level
+func openSyslog() (func(level string, message string) error, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "easy-add")
+	if err != nil {
+		return nil, err
+	}
+	return func(level string, message string) error {
+		switch level {
+		case "DEBUG":
+			return writer.Debug(message)
+		case "WARN":
+			return writer.Warning(message)
+		case "ERROR":
+			return writer.Err(message)
+		default:
+			return writer.Info(message)
+		}
+	}, nil
+}
@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+func openSyslog() (func(level string, message string) error, error) {
+	return nil, errors.New("logging to syslog is not supported on " + runtime.GOOS)
+}
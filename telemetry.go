@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"github.com/itzg/easy-add/pkg/telemetry"
+	"log"
+	"os"
+	"time"
+)
+
+// otlpExportTimeout bounds how long exporting telemetry can delay the exit of easy-add
+const otlpExportTimeout = 10 * time.Second
+
+// startTelemetry attaches a recorder to the context, with a span for the whole command, when an
+// OTLP endpoint is configured. The returned function ends that span and exports what was recorded.
+func startTelemetry(ctx context.Context, cmdName string) (context.Context, func(err error)) {
+	endpoint := networkArgs.OtlpEndpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		return ctx, func(error) {}
+	}
+
+	recorder := telemetry.NewRecorder(os.Getenv("TRACEPARENT"))
+	ctx, span := telemetry.Start(telemetry.WithRecorder(ctx, recorder), "easy-add "+cmdName)
+	return ctx, func(err error) {
+		span.End(err)
+
+		headers, err := telemetry.ParseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+		if err != nil {
+			log.Printf("W! Not exporting telemetry: %v", err)
+			return
+		}
+		// the command's context may have been cancelled by an interrupt
+		exportCtx, cancel := context.WithTimeout(context.Background(), otlpExportTimeout)
+		defer cancel()
+		err = recorder.Export(exportCtx, telemetry.ExportOptions{
+			Endpoint:    endpoint,
+			Headers:     headers,
+			ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+			Version:     version,
+		})
+		if err != nil {
+			log.Printf("W! %v", err)
+		}
+	}
+}
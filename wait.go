@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/itzg/easy-add/internal/githubapi"
+	"github.com/itzg/easy-add/pkg/fetch"
+	"log"
+	"net/http"
+	"time"
+)
+
+// noMatchingAssetError is a release without an asset matching asset-regex, which may be one that
+// is still being published
+type noMatchingAssetError struct {
+	msg string
+}
+
+func (e *noMatchingAssetError) Error() string {
+	return e.msg
+}
+
+// missingError is what check reports when the archive, or the release or asset it's found
+// through, doesn't exist
+type missingError struct {
+	err error
+}
+
+func (e *missingError) Error() string {
+	return "not found: " + e.err.Error()
+}
+
+func (e *missingError) Unwrap() error {
+	return e.err
+}
+
+// waitForAvailability calls attempt until it succeeds or fails with anything but what's
+// requested not being published yet, trying again every poll until waitFor has passed. A waitFor
+// of zero calls attempt only once.
+func waitForAvailability(ctx context.Context, waitFor time.Duration, poll time.Duration, attempt func() error) error {
+	deadline := time.Now().Add(waitFor)
+	for {
+		err := attempt()
+		if err == nil || !notPublishedYet(err) || time.Now().Add(poll).After(deadline) {
+			return err
+		}
+
+		log.Printf("I! Checking again in %s since it's not available yet: %v", poll, err)
+		timer := time.NewTimer(poll)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// notPublishedYet determines if the error is a download, release, or release asset that wasn't found
+func notPublishedYet(err error) bool {
+	var statusErr *fetch.StatusError
+	var apiErr *githubapi.StatusError
+	var assetErr *noMatchingAssetError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode == http.StatusNotFound
+	case errors.As(err, &apiErr):
+		return apiErr.StatusCode == http.StatusNotFound
+	default:
+		return errors.As(err, &assetErr)
+	}
+}